@@ -0,0 +1,37 @@
+// alert.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// alertBell is the question_alert value that rings the terminal bell
+// rather than running a command.
+const alertBell = "bell"
+
+// alertQuestion lets the user know the model is waiting on an answer
+// (question_alert). It rings the bell on out, or starts the configured
+// command with the question on its stdin and does not wait for it.
+func alertQuestion(setting, question string, out io.Writer) error {
+	switch setting {
+	case "":
+		return nil
+	case alertBell:
+		_, err := fmt.Fprint(out, "\a")
+		return err
+	}
+	parts, err := splitCommand(setting)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Stdin = strings.NewReader(question)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,44 @@
+// alert_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertQuestion_Bell(t *testing.T) {
+	var out bytes.Buffer
+	if err := alertQuestion(alertBell, "Who is it for?", &out); err != nil || out.String() != "\a" {
+		t.Errorf("bell: %q, %v", out.String(), err)
+	}
+	out.Reset()
+	if err := alertQuestion("", "Who is it for?", &out); err != nil || out.Len() != 0 {
+		t.Errorf("unset: %q, %v, want nothing", out.String(), err)
+	}
+}
+
+func TestAlertQuestion_Command(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "question.txt")
+	if err := alertQuestion("sh -c 'cat > "+path+"'", "Who is it for?", &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(path)
+		if string(data) == "Who is it for?" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("command got %q, want the question on stdin", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := alertQuestion("no-such-command-here", "x", &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing command: error = %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+// anthropic.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// anthropicVersion is the Messages API version the client speaks.
+const anthropicVersion = "2023-06-01"
+
+// anthropicMaxTokens is sent when no max_tokens is set; the Messages API
+// requires one.
+const anthropicMaxTokens = 4096
+
+type AnthropicRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Stream      bool      `json:"stream"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	TopP        *float64  `json:"top_p,omitempty"`
+}
+
+// AnthropicEvent is one data payload of a Messages API event stream.
+type AnthropicEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"` // message_start
+	Usage anthropicUsage `json:"usage"` // message_delta, running output count
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicClient talks to Anthropic's Messages API.
+type AnthropicClient struct {
+	Host   string
+	Model  string
+	APIKey string
+	Params GenerationParams
+	client *http.Client
+	usage  *Usage // reported for the last request
+}
+
+func init() {
+	promptbuilder.RegisterProvider(providerAnthropic, promptbuilder.Provider{
+		New: func(host, model, apiKey string, hc *http.Client) LLMClient {
+			client := NewAnthropicClient(host, model, apiKey)
+			client.client = hc
+			return client
+		},
+		Host:      "https://api.anthropic.com",
+		APIKeyEnv: "ANTHROPIC_API_KEY",
+		Prefill:   true,
+		Parse:     parseAnthropicStream,
+		NeedsKey:  true,
+	})
+}
+
+func NewAnthropicClient(host, model, apiKey string) *AnthropicClient {
+	return &AnthropicClient{
+		Host:   host,
+		Model:  model,
+		APIKey: apiKey,
+		client: &http.Client{},
+	}
+}
+
+// SetParams sets the sampling settings for subsequent requests. The
+// Messages API has no seed, so Seed is ignored.
+func (c *AnthropicClient) SetParams(params GenerationParams) {
+	c.Params = params
+}
+
+// LastUsage returns the token usage reported for the last request.
+func (c *AnthropicClient) LastUsage() (Usage, bool) {
+	if c.usage == nil {
+		return Usage{}, false
+	}
+	return *c.usage, true
+}
+
+// anthropicMessages splits messages into the top-level system prompt and
+// the turns. Consecutive turns from the same role are merged, since the
+// API wants user and assistant to alternate.
+func anthropicMessages(messages []Message) (string, []Message) {
+	var system []string
+	var turns []Message
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		if n := len(turns); n > 0 && turns[n-1].Role == m.Role {
+			turns[n-1].Content += "\n\n" + m.Content
+			continue
+		}
+		turns = append(turns, m)
+	}
+	return strings.Join(system, "\n\n"), turns
+}
+
+func (c *AnthropicClient) ChatStream(ctx context.Context, messages []Message, onToken StreamCallback) (string, error) {
+	c.usage = nil
+	system, turns := anthropicMessages(messages)
+	req := AnthropicRequest{
+		Model:       c.Model,
+		System:      system,
+		Messages:    turns,
+		MaxTokens:   anthropicMaxTokens,
+		Stream:      true,
+		Temperature: c.Params.Temperature,
+		TopP:        c.Params.TopP,
+	}
+	if c.Params.MaxTokens != nil {
+		req.MaxTokens = *c.Params.MaxTokens
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Host+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create LLM request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+	if c.APIKey != "" {
+		httpReq.Header.Set("X-Api-Key", c.APIKey)
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return "", connectError(c.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newHTTPError(resp, c.Model)
+	}
+
+	reply, usage, err := parseAnthropicStreamUsage(resp.Body, onToken)
+	c.usage = usage
+	return reply, err
+}
+
+// parseAnthropicStream reads a Messages API event stream, calling onToken
+// for each text delta, and returns the whole reply. Every data payload
+// repeats its event type, so the event: lines are not needed.
+func parseAnthropicStream(r io.Reader, onToken StreamCallback) (string, error) {
+	reply, _, err := parseAnthropicStreamUsage(r, onToken)
+	return reply, err
+}
+
+// parseAnthropicStreamUsage is parseAnthropicStream that also returns the
+// usage from the message_start and message_delta events, or nil.
+func parseAnthropicStreamUsage(r io.Reader, onToken StreamCallback) (string, *Usage, error) {
+	var accumulated strings.Builder
+	var usage *Usage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELine)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimPrefix(data, " ")
+
+		var event AnthropicEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return "", nil, fmt.Errorf("failed to parse streaming event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage = &Usage{PromptTokens: event.Message.Usage.InputTokens, CompletionTokens: event.Message.Usage.OutputTokens}
+		case "message_delta":
+			if usage != nil && event.Usage.OutputTokens > 0 {
+				usage.CompletionTokens = event.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				continue
+			}
+			if err := onToken(event.Delta.Text); err != nil {
+				return "", nil, err
+			}
+			accumulated.WriteString(event.Delta.Text)
+		case "error":
+			return "", nil, fmt.Errorf("LLM stream failed: %s - %s", event.Error.Type, event.Error.Message)
+		case "message_stop":
+			return accumulated.String(), usage, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", nil, fmt.Errorf("error reading stream: %w", err)
+	}
+
+	return accumulated.String(), usage, nil
+}
+
+func (c *AnthropicClient) ChatStreamWithSpinner(ctx context.Context, messages []Message, tty bool, onToken StreamCallback) (string, error) {
+	return streamWithSpinner(tty, onToken, func(cb StreamCallback) (string, error) {
+		return c.ChatStream(ctx, messages, cb)
+	})
+}
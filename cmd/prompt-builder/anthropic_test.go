@@ -0,0 +1,126 @@
+// anthropic_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicMessages(t *testing.T) {
+	system, turns := anthropicMessages([]Message{
+		{Role: "system", Content: "be terse"},
+		{Role: "user", Content: "idea"},
+		{Role: "assistant", Content: "question?"},
+		{Role: "user", Content: "answer"},
+		{Role: "user", Content: "nudge"},
+	})
+	if system != "be terse" {
+		t.Errorf("system = %q, want %q", system, "be terse")
+	}
+	want := []Message{
+		{Role: "user", Content: "idea"},
+		{Role: "assistant", Content: "question?"},
+		{Role: "user", Content: "answer\n\nnudge"},
+	}
+	if len(turns) != len(want) {
+		t.Fatalf("turns = %v, want %v", turns, want)
+	}
+	for i := range want {
+		if turns[i] != want[i] {
+			t.Errorf("turns[%d] = %v, want %v", i, turns[i], want[i])
+		}
+	}
+}
+
+func TestParseAnthropicStream(t *testing.T) {
+	stream := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","role":"assistant","content":[]}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type": "ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+	var tokens []string
+	got, err := parseAnthropicStream(strings.NewReader(stream), func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hello world" || len(tokens) != 2 {
+		t.Errorf("got %q from tokens %q, want %q from 2 tokens", got, tokens, "Hello world")
+	}
+}
+
+func TestParseAnthropicStream_Error(t *testing.T) {
+	stream := `event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`
+	_, err := parseAnthropicStream(strings.NewReader(stream), func(string) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "LLM") || !strings.Contains(err.Error(), "Overloaded") {
+		t.Errorf("error = %v, want an LLM error with the server's message", err)
+	}
+}
+
+func TestAnthropicClient_Request(t *testing.T) {
+	var got AnthropicRequest
+	var header http.Header
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, header = r.URL.Path, r.Header
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte("data: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	defer server.Close()
+
+	client, err := newLLMClient(&Config{Provider: providerAnthropic, APIKey: "sk-ant"}, server.URL, "claude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp := 0.2
+	client.SetParams(GenerationParams{Temperature: &temp})
+	client.ChatStream(context.Background(), []Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "idea"}}, func(string) error { return nil })
+
+	if path != "/v1/messages" {
+		t.Errorf("path = %q, want /v1/messages", path)
+	}
+	if header.Get("X-Api-Key") != "sk-ant" || header.Get("Anthropic-Version") != anthropicVersion {
+		t.Errorf("headers = %v, want the key and API version", header)
+	}
+	if header.Get("Authorization") != "" {
+		t.Errorf("Authorization = %q, want none", header.Get("Authorization"))
+	}
+	if got.Model != "claude" || got.System != "sys" || len(got.Messages) != 1 || !got.Stream {
+		t.Errorf("request = %+v, want the system prompt separate from one user turn", got)
+	}
+	if got.MaxTokens != anthropicMaxTokens {
+		t.Errorf("max_tokens = %d, want default %d", got.MaxTokens, anthropicMaxTokens)
+	}
+	if got.Temperature == nil || *got.Temperature != temp {
+		t.Errorf("temperature = %v, want %v", got.Temperature, temp)
+	}
+}
@@ -0,0 +1,106 @@
+// bench_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// Benchmarks for the client-side cost of a turn. The model is a mock or a
+// canned stream, so the numbers exclude inference and show only what this
+// tool adds. Compare runs with benchstat:
+//
+//	go test -run XXX -bench . -count 10 ./cmd/prompt-builder > new.txt
+//	benchstat old.txt new.txt
+
+const benchTokens = 500
+
+// benchReply is a final prompt of benchTokens words in a code block.
+var benchReply = "Here is your prompt:\n```\n" + strings.TrimSpace(strings.Repeat("word ", benchTokens)) + "\n```"
+
+// benchStream encodes reply as an SSE stream, one event per word.
+func benchStream(reply string) string {
+	var b strings.Builder
+	for _, token := range strings.SplitAfter(reply, " ") {
+		chunk, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"delta": map[string]string{"content": token}}}})
+		fmt.Fprintf(&b, "data: %s\n\n", chunk)
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+func reportPerToken(b *testing.B, tokens int) {
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*tokens), "ns/token")
+}
+
+func BenchmarkParseSSEStream(b *testing.B) {
+	stream := benchStream(benchReply)
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := parseSSEStream(strings.NewReader(stream), func(string) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportPerToken(b, benchTokens)
+}
+
+func BenchmarkChatClient_Turn(b *testing.B) {
+	stream := benchStream(benchReply)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, stream)
+	}))
+	defer server.Close()
+
+	client := NewChatClient(server.URL, "bench")
+	messages := []Message{{Role: "system", Content: "system"}, {Role: "user", Content: "idea"}}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := client.ChatStream(context.Background(), messages, func(string) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportPerToken(b, benchTokens)
+}
+
+// BenchmarkRunWithDeps_Turn measures a whole interactive turn: streaming
+// to the terminal, completion detection, /copy and the exit summary.
+func BenchmarkRunWithDeps_Turn(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		deps := newTestDeps(withResponses(benchReply), withStdin("/copy\n"))
+		if err := runWithDeps(context.Background(), &CLI{Idea: "idea"}, deps); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportPerToken(b, benchTokens)
+}
+
+// BenchmarkRunWithDeps_PipeTurn measures a turn in -q pipe mode.
+func BenchmarkRunWithDeps_PipeTurn(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		deps := newTestDeps(withResponses(benchReply), withTTY(false))
+		if err := runWithDeps(context.Background(), &CLI{Idea: "idea", Quiet: QuietPrompt}, deps); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportPerToken(b, benchTokens)
+}
+
+func BenchmarkExtractLastCodeBlock(b *testing.B) {
+	// A long conversation's worth of drafts
+	text := strings.Repeat(benchReply+"\n\nRevised:\n", 20)
+	b.SetBytes(int64(len(text)))
+	for b.Loop() {
+		promptbuilder.ExtractLastCodeBlock(text)
+	}
+}
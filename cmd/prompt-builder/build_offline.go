@@ -0,0 +1,7 @@
+//go:build offline
+
+// build_offline.go
+package main
+
+// offlineBuild turns offline mode on whatever the config says.
+const offlineBuild = true
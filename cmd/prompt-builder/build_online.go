@@ -0,0 +1,8 @@
+//go:build !offline
+
+// build_online.go
+package main
+
+// offlineBuild turns offline mode on whatever the config says; build with
+// -tags offline for machines that must never leave the local network.
+const offlineBuild = false
@@ -0,0 +1,154 @@
+// chain.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+	"gopkg.in/yaml.v3"
+)
+
+// chainInstruction is added to the system prompt by --chain.
+const chainInstruction = `
+
+The user is building a pipeline. Design it as a chain of prompts, one per step (for example extract, analyze, summarize), each doing one job. Deliver each step's prompt in its own fenced code block tagged with the step's name, in order, such as ` + "```step extract" + `. Refer to the pipeline's input as {{input}} and to an earlier step's output by its name, such as {{extract}}. Finish with a fenced code block tagged manifest (` + "```manifest" + `) in YAML listing the steps in order, each with its name, its inputs (input or earlier step names) and its output:
+
+steps:
+  - name: extract
+    inputs: [input]
+    output: the key facts as a JSON list`
+
+// chainInput names the pipeline's own input in a manifest.
+const chainInput = "input"
+
+// chainManifestFile is the manifest's name in the --chain directory.
+const chainManifestFile = "manifest.yaml"
+
+// ChainStep is one step of a prompt chain as the manifest describes it.
+type ChainStep struct {
+	Name   string   `yaml:"name"`
+	Prompt string   `yaml:"prompt"` // file holding the step's prompt
+	Inputs []string `yaml:"inputs"`
+	Output string   `yaml:"output,omitempty"`
+}
+
+// ChainManifest describes the data flow between the steps of a chain.
+type ChainManifest struct {
+	Steps []ChainStep `yaml:"steps"`
+}
+
+// Chain is a prompt chain: the manifest and each step's prompt, in order.
+type Chain struct {
+	Manifest ChainManifest
+	Prompts  []string
+}
+
+// parseChain reads the step blocks and manifest from response. Without a
+// manifest block, each step takes the previous step's output.
+func parseChain(response string) (*Chain, error) {
+	prompts := map[string]string{}
+	var order []string
+	var manifest *promptbuilder.CodeBlock
+	for _, b := range promptbuilder.CodeBlocks(response) {
+		if name, ok := strings.CutPrefix(b.Info, "step "); ok {
+			name = strings.TrimSpace(name)
+			if _, dup := prompts[name]; !dup {
+				order = append(order, name)
+			}
+			prompts[name] = strings.TrimSpace(b.Body)
+		}
+		if b.Info == "manifest" {
+			manifest = &b
+		}
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("the reply has no step blocks")
+	}
+
+	var m ChainManifest
+	if manifest != nil {
+		if err := yaml.Unmarshal([]byte(manifest.Body), &m); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %v", err)
+		}
+	} else {
+		for i, name := range order {
+			input := chainInput
+			if i > 0 {
+				input = order[i-1]
+			}
+			m.Steps = append(m.Steps, ChainStep{Name: name, Inputs: []string{input}})
+		}
+	}
+
+	c := &Chain{Manifest: m}
+	seen := map[string]bool{chainInput: true}
+	for i := range m.Steps {
+		step := &m.Steps[i]
+		prompt, ok := prompts[step.Name]
+		if !ok {
+			return nil, fmt.Errorf("manifest step %q has no step block", step.Name)
+		}
+		for _, in := range step.Inputs {
+			if !seen[in] {
+				return nil, fmt.Errorf("step %q takes %q, which is not the input or an earlier step", step.Name, in)
+			}
+		}
+		seen[step.Name] = true
+		step.Prompt = fmt.Sprintf("%02d-%s.md", i+1, slugify(step.Name))
+		c.Prompts = append(c.Prompts, prompt)
+	}
+	if len(m.Steps) == 0 {
+		return nil, fmt.Errorf("the manifest lists no steps")
+	}
+	return c, nil
+}
+
+// writeChain writes each step's prompt, with stamp applied, and the
+// manifest into dir, replacing earlier files of the same names.
+func writeChain(dir string, c *Chain, stamp func(string) (string, error)) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i, step := range c.Manifest.Steps {
+		prompt, err := stamp(c.Prompts[i])
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(prompt, "\n") {
+			prompt += "\n"
+		}
+		if err := os.WriteFile(filepath.Join(dir, step.Prompt), []byte(prompt), 0644); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Manifest); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, chainManifestFile), buf.Bytes(), 0644)
+}
+
+// writeTabChain writes the chain in tab's last response to the session's
+// --chain directory and returns a line saying what was written.
+func writeTabChain(cfg *Config, tab *Tab, now time.Time) (string, error) {
+	c, err := parseChain(tab.Response)
+	if err != nil {
+		return "", fmt.Errorf("cannot write chain: %v", err)
+	}
+	dir := ExpandPath(tab.Session.Chain)
+	err = writeChain(dir, c, func(p string) (string, error) {
+		return stampPrompt(cfg, tab.Session, now, p)
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot write chain: %v", err)
+	}
+	return fmt.Sprintf("✓ Wrote %d prompts and %s to %s", len(c.Prompts), chainManifestFile, dir), nil
+}
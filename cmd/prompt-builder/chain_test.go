@@ -0,0 +1,95 @@
+// chain_test.go
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chainReply = "Here is the pipeline.\n\n" +
+	"```step extract\nList the facts in {{input}}.\n```\n\n" +
+	"```step summarize\nSummarize {{extract}} for {{input}}.\n```\n\n" +
+	"```manifest\nsteps:\n  - name: extract\n    inputs: [input]\n    output: facts\n  - name: summarize\n    inputs: [extract, input]\n    output: a summary\n```\n"
+
+func TestParseChain(t *testing.T) {
+	c, err := parseChain(chainReply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := c.Manifest.Steps
+	if len(steps) != 2 || steps[0].Prompt != "01-extract.md" || steps[1].Prompt != "02-summarize.md" {
+		t.Fatalf("steps = %+v, want two numbered prompt files", steps)
+	}
+	if c.Prompts[1] != "Summarize {{extract}} for {{input}}." || steps[1].Output != "a summary" {
+		t.Errorf("chain = %+v, want the summarize prompt and output", c)
+	}
+}
+
+func TestParseChain_NoManifestChainsInOrder(t *testing.T) {
+	c, err := parseChain("```step a\nA\n```\n```step b\nB\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Manifest.Steps; len(got) != 2 || got[0].Inputs[0] != chainInput || got[1].Inputs[0] != "a" {
+		t.Errorf("steps = %+v, want a from the input and b from a", got)
+	}
+}
+
+func TestParseChain_Errors(t *testing.T) {
+	for name, reply := range map[string]string{
+		"no steps":      "```\njust one prompt\n```",
+		"missing block": "```step a\nA\n```\n```manifest\nsteps:\n  - name: b\n    inputs: [input]\n```",
+		"later input":   "```step a\nA\n```\n```step b\nB\n```\n```manifest\nsteps:\n  - name: a\n    inputs: [b]\n  - name: b\n    inputs: [input]\n```",
+		"bad yaml":      "```step a\nA\n```\n```manifest\nsteps: [\n```",
+	} {
+		if _, err := parseChain(reply); err == nil {
+			t.Errorf("%s: parseChain() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestWriteChain(t *testing.T) {
+	c, err := parseChain(chainReply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "pipeline")
+	stamp := func(p string) (string, error) { return "# v1\n" + p, nil }
+	if err := writeChain(dir, c, stamp); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "02-summarize.md"))
+	if err != nil || string(data) != "# v1\nSummarize {{extract}} for {{input}}.\n" {
+		t.Errorf("02-summarize.md = %q, %v, want the stamped prompt", data, err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, chainManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"prompt: 01-extract.md", "- extract", "output: a summary"} {
+		if !strings.Contains(string(manifest), want) {
+			t.Errorf("manifest missing %q:\n%s", want, manifest)
+		}
+	}
+}
+
+func TestRun_Chain_WritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	deps := newTestDeps(withResponses(chainReply), withTTY(false))
+	deps.Session = &Session{Idea: "summarize reports", Chain: dir, CreatedAt: time.Now()}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "summarize reports", Quiet: QuietPrompt, Chain: dir}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "01-extract.md")); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(stderr(deps), "Wrote 2 prompts") {
+		t.Errorf("stderr = %q, want a note about the written chain", stderr(deps))
+	}
+}
@@ -0,0 +1,60 @@
+// cleanup.go
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// Escape sequences for terminal state the tool may change.
+const (
+	hideCursor = "\x1b[?25l"
+	showCursor = "\x1b[?25h"
+)
+
+// cleanups holds functions that restore terminal and process state. They
+// run once on every exit path: normal return, error, signal or panic.
+var cleanups struct {
+	sync.Mutex
+	next int
+	fns  map[int]func()
+}
+
+// onExit registers fn to run at exit and returns a function that
+// unregisters it, for state that is restored before exiting anyway.
+func onExit(fn func()) (remove func()) {
+	cleanups.Lock()
+	defer cleanups.Unlock()
+	if cleanups.fns == nil {
+		cleanups.fns = make(map[int]func())
+	}
+	id := cleanups.next
+	cleanups.next++
+	cleanups.fns[id] = fn
+	return func() {
+		cleanups.Lock()
+		defer cleanups.Unlock()
+		delete(cleanups.fns, id)
+	}
+}
+
+// runCleanup runs and clears every registered cleanup, newest first.
+func runCleanup() {
+	cleanups.Lock()
+	fns := cleanups.fns
+	last := cleanups.next
+	cleanups.fns = nil
+	cleanups.Unlock()
+
+	for id := last - 1; id >= 0; id-- {
+		if fn, ok := fns[id]; ok {
+			fn()
+		}
+	}
+}
+
+// exit restores terminal state and exits with code.
+func exit(code int) {
+	runCleanup()
+	os.Exit(code)
+}
@@ -0,0 +1,50 @@
+// cleanup_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunCleanup_NewestFirstAndOnce(t *testing.T) {
+	var ran []string
+	onExit(func() { ran = append(ran, "first") })
+	remove := onExit(func() { ran = append(ran, "removed") })
+	onExit(func() { ran = append(ran, "last") })
+	remove()
+
+	runCleanup()
+	runCleanup() // second call must be a no-op
+
+	want := []string{"last", "first"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("cleanups ran %v, want %v", ran, want)
+	}
+}
+
+func TestSpinner_StopUnregistersCleanup(t *testing.T) {
+	s := NewSpinner("Loading")
+	s.Start()
+	s.Stop()
+
+	cleanups.Lock()
+	n := len(cleanups.fns)
+	cleanups.Unlock()
+	if n != 0 {
+		t.Errorf("expected spinner cleanup to be unregistered, %d remain", n)
+	}
+}
+
+func TestSpinner_CleanupStopsRunningSpinner(t *testing.T) {
+	s := NewSpinner("Loading")
+	s.Start()
+
+	runCleanup() // simulates an interrupt while spinning
+
+	select {
+	case <-s.doneCh:
+	default:
+		t.Error("cleanup should stop the spinner and wait for the line to clear")
+	}
+	s.Stop() // still safe afterwards
+}
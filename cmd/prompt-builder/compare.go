@@ -0,0 +1,82 @@
+// compare.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// compareModel is one model taking part in --compare.
+type compareModel struct {
+	Name   string
+	Client LLMClient
+}
+
+// compareResult is what one model produced.
+type compareResult struct {
+	Prompt string
+	Err    error
+}
+
+// parseModelList splits a comma-separated --compare value.
+func parseModelList(s string) []string {
+	var models []string
+	for _, m := range strings.Split(s, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// runCompare asks every model for a prompt for idea at the same time and
+// writes the results to out in the order given, each under a heading with
+// the prompt in a fenced block. It fails only if every model failed.
+func runCompare(ctx context.Context, models []compareModel, systemPrompt, idea string, out io.Writer) error {
+	results := make([]compareResult, len(models))
+	var wg sync.WaitGroup
+	for i, m := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prompt, err := promptbuilder.BuildPrompt(ctx, m.Client, systemPrompt, idea)
+			results[i] = compareResult{prompt, err}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, m := range models {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "## %s\n\n", m.Name)
+		if err := results[i].Err; err != nil {
+			failed++
+			fmt.Fprintf(out, "Error: %v\n", err)
+			continue
+		}
+		fence := fenceFor(results[i].Prompt)
+		fmt.Fprintf(out, "%s\n%s\n%s\n", fence, strings.TrimSuffix(results[i].Prompt, "\n"), fence)
+	}
+	if failed == len(models) {
+		return withKind(ErrLLM, fmt.Errorf("LLM request failed for every model"))
+	}
+	return nil
+}
+
+// fenceFor returns a backtick fence longer than any fence inside body.
+func fenceFor(body string) string {
+	n := 3
+	for _, line := range strings.Split(body, "\n") {
+		if run, _, ok := promptbuilder.ParseFence(line); ok && run >= n {
+			n = run + 1
+		}
+	}
+	return strings.Repeat("`", n)
+}
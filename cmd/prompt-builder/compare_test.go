@@ -0,0 +1,78 @@
+// compare_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// slowLLM replies after a delay, to show the models run concurrently.
+type slowLLM struct {
+	mockLLM
+	delay time.Duration
+}
+
+func (s *slowLLM) ChatStream(ctx context.Context, messages []Message, onToken StreamCallback) (string, error) {
+	time.Sleep(s.delay)
+	return s.mockLLM.ChatStream(ctx, messages, onToken)
+}
+
+func TestParseModelList(t *testing.T) {
+	if got := strings.Join(parseModelList(" a, b,,c "), "|"); got != "a|b|c" {
+		t.Errorf("parseModelList() = %q", got)
+	}
+	if got := parseModelList(""); got != nil {
+		t.Errorf("parseModelList(\"\") = %q, want none", got)
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	models := []compareModel{
+		{"llama", &slowLLM{mockLLM{responses: []string{"```\nprompt one\n```"}}, delay}},
+		{"mistral", &slowLLM{mockLLM{err: errors.New("model not found")}, delay}},
+		{"qwen", &slowLLM{mockLLM{responses: []string{"````\n# Example\n```\nx\n```\n````"}}, delay}},
+	}
+
+	var out bytes.Buffer
+	start := time.Now()
+	if err := runCompare(context.Background(), models, "sys", "idea", &out); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*delay {
+		t.Errorf("took %v, want the models asked concurrently", elapsed)
+	}
+
+	want := "## llama\n\n```\nprompt one\n```\n\n" +
+		"## mistral\n\nError: LLM request failed: model not found\n\n" +
+		"## qwen\n\n````\n# Example\n```\nx\n```\n````\n"
+	if out.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	// Each prompt can be taken back out with extract
+	if blocks := promptbuilder.CodeBlocks(out.String()); len(blocks) != 2 || blocks[1].Body != "# Example\n```\nx\n```\n" {
+		t.Errorf("codeBlocks(output) = %q", blocks)
+	}
+
+	for _, m := range models {
+		mock := &m.Client.(*slowLLM).mockLLM
+		if last := mock.last[len(mock.last)-1].Content; !strings.HasPrefix(last, promptbuilder.PipeModePrefix) {
+			t.Errorf("%s asked %q, want a pipe-mode request", m.Name, last)
+		}
+	}
+}
+
+func TestRunCompare_AllFail(t *testing.T) {
+	models := []compareModel{{"a", &mockLLM{err: errors.New("down")}}, {"b", &mockLLM{err: errors.New("down")}}}
+	err := runCompare(context.Background(), models, "sys", "idea", &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "LLM") {
+		t.Errorf("error = %v, want an LLM error", err)
+	}
+}
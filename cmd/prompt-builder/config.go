@@ -2,36 +2,516 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	_ "embed"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Model            string `yaml:"model"`
-	SystemPromptFile string `yaml:"system_prompt_file"`
-	Host             string `yaml:"host"`
-	ClipboardCmd     string `yaml:"clipboard_cmd"`
+	Model              string                   `yaml:"model"`
+	SystemPromptFile   string                   `yaml:"system_prompt_file"`
+	Provider           string                   `yaml:"provider"`
+	Hosts              []PoolHost               `yaml:"hosts"` // a pool of servers to spread requests over, instead of host
+	Host               string                   `yaml:"host"`
+	APIKey             string                   `yaml:"api_key"`
+	APIKeyEnv          string                   `yaml:"api_key_env"`
+	ClipboardCmd       string                   `yaml:"clipboard_cmd"`
+	ClipboardSensitive bool                     `yaml:"clipboard_sensitive"`
+	QuestionAlert      string                   `yaml:"question_alert"` // bell, or a command run when the model asks a question
+	SSHTunnel          string                   `yaml:"ssh_tunnel"`
+	CompletionNudge    string                   `yaml:"completion_nudge"`
+	MaxNudges          int                      `yaml:"max_nudges"`
+	IdleTimeout        Duration                 `yaml:"idle_timeout"`
+	RequestTimeout     Duration                 `yaml:"request_timeout"` // whole LLM request, streaming included
+	ConnectTimeout     Duration                 `yaml:"connect_timeout"`
+	StallTimeout       Duration                 `yaml:"stall_timeout"`     // longest silence mid-reply; 0s waits forever
+	MaxResponseSize    ByteSize                 `yaml:"max_response_size"` // a longer reply is stopped; 0 for no limit
+	DuplicateWindow    Duration                 `yaml:"duplicate_window"`  // a run repeated within it reuses the reply; 0s turns that off
+	ContextWindow      int                      `yaml:"context_window"`
+	ContextOverflow    string                   `yaml:"context_overflow"`
+	Aliases            map[string]string        `yaml:"aliases"`     // short names for models, such as fast
+	Models             map[string]ModelDefaults `yaml:"models"`      // settings that go with a model, by name or alias
+	FitSlots           map[string]int           `yaml:"fit_slots"`   // named --fit budgets in tokens
+	Tokenizers         map[string]string        `yaml:"tokenizers"`  // tokenizer specs by model name prefix
+	Profile            string                   `yaml:"profile"`     // default profile, overridden by --profile
+	Profiles           map[string]yaml.Node     `yaml:"profiles"`    // named sets of config keys, decoded over the rest
+	Persona            string                   `yaml:"persona"`     // default persona, overridden by --persona
+	PromptsDir         string                   `yaml:"prompts_dir"` // system prompts --prompt picks from by name
+	Personas           map[string]Persona       `yaml:"personas"`
+	PostProcess        []string                 `yaml:"post_process"` // filters the final prompt is piped through
+	PromptHeader       string                   `yaml:"prompt_header"`
+	PromptFooter       string                   `yaml:"prompt_footer"`
+	Prefill            string                   `yaml:"prefill"`        // start of non-interactive replies, such as ```
+	ShowUsage          bool                     `yaml:"show_usage"`     // print token usage per turn and for the session
+	TerminalTitle      bool                     `yaml:"terminal_title"` // show the run's state in the terminal title
+	Headers            map[string]string        `yaml:"headers"`        // sent with every request to the LLM server
+	Proxy              string                   `yaml:"proxy"`          // http, https or socks5 URL
+	Share              ShareConfig              `yaml:"share"`
+	Scrub              ScrubConfig              `yaml:"scrub"` // what /export public removes
+	Save               SaveConfig               `yaml:"save"`
+	Review             ReviewConfig             `yaml:"review"`
+	Webhooks           []Webhook                `yaml:"webhooks"` // notified when a session completes
+	Registry           RegistryConfig           `yaml:"registry"` // where push publishes prompts
+	Offline            OfflineConfig            `yaml:"offline"`
+	Experiment         ExperimentConfig         `yaml:"experiment"` // system prompts to compare across runs
+
+	// Sampling settings for every turn; /temp and friends change them
+	// for the rest of a session.
+	GenerationParams `yaml:",inline"`
+
+	// Deprecations lists legacy keys found while loading, for warnings.
+	Deprecations []string `yaml:"-"`
+	// UnknownKeys lists keys no setting reads, for warnings.
+	UnknownKeys []string `yaml:"-"`
+	// Hash identifies the config files' contents, for session metadata.
+	Hash string `yaml:"-"`
+
+	// StreamLog records LLM responses when --debug-stream is given.
+	StreamLog *StreamLog `yaml:"-"`
+
+	// pool balances requests over Hosts; Host then holds the first of
+	// them, standing for the pool.
+	pool *hostPool
 }
 
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+// Duration is a time.Duration written as a string such as "30m" in YAML.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
 	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q (use e.g. 90s, 30m, 1h)", value.Line, value.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+// builtinSystemPrompt is the R.G.C.O.A. prompt-architect system prompt,
+// used when system_prompt_file is unset.
+//
+//go:embed prompt-architect.md
+var builtinSystemPrompt string
+
+// builtinSystemPromptName names the built-in system prompt in headers and
+// reports.
+const builtinSystemPromptName = "prompt-architect.md (built-in)"
+
+// defaultConfig returns a Config with every default applied.
+func defaultConfig() Config {
+	return Config{
+		CompletionNudge: "Wrap the final prompt in a fenced code block (```) and do not ask any more questions.",
+		MaxNudges:       2,
+		ContextOverflow: overflowAsk,
+		StallTimeout:    Duration(defaultStallTimeout),
+		MaxResponseSize: defaultMaxResponseSize,
+		DuplicateWindow: Duration(defaultDuplicateWindow),
+	}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	return LoadProfile(path, "")
+}
+
+// LoadProfile loads the config at path with the named profile's settings
+// over the top-level ones. An empty name selects the profile key's
+// profile, if any.
+func LoadProfile(path, profile string) (*Config, error) {
+	return LoadFiles([]string{path}, profile)
+}
+
+// LoadFiles loads the config files at paths, each decoded over the ones
+// before it, with the named profile's settings over all of them. In
+// offline mode it also restricts connections to the allowed hosts.
+func LoadFiles(paths []string, profile string) (*Config, error) {
+	layers := make([]configLayer, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, configLayer{Path: path, Data: data, Project: filepath.Base(path) == projectConfigName})
+	}
+	cfg, err := parseLayers(layers, profile)
+	if err == nil && cfg.Offline.active() {
+		restrictConnections(cfg.Offline)
+	}
+	return cfg, err
+}
+
+// loadRunConfig loads the config file at path, or without one the files
+// configFiles finds. With none of them, the defaults apply.
+func loadRunConfig(path, profile string) (*Config, error) {
+	if path != "" {
+		return LoadProfile(ExpandPath(path), profile)
+	}
+	return LoadFiles(configFiles(), profile)
+}
+
+// configFiles returns the config files a run without --config reads, in
+// the order they are merged: the user's config, then the nearest project
+// config over it. Files that do not exist are left out.
+func configFiles() []string {
+	var files []string
+	if path := defaultConfigPath(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	if homeDir() != "" {
+		return files // everything lives under PROMPT_BUILDER_HOME
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if path := findProjectConfig(wd); path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// configLayer is the contents of one config file.
+type configLayer struct {
+	Path    string // "" when not read from a file
+	Data    []byte
+	Project bool // a project config, merged over the user's
+}
+
+// parseConfig decodes and checks a config file's contents, as LoadProfile
+// does. Empty data yields the defaults.
+func parseConfig(data []byte, profile string) (*Config, error) {
+	return parseLayers([]configLayer{{Data: data}}, profile)
+}
+
+// parseLayers decodes config layers in order, each over the ones before,
+// as profiles are: set keys replace earlier values and maps are merged.
+// It then applies profile and checks the result.
+func parseLayers(layers []configLayer, profile string) (*Config, error) {
+	cfg := defaultConfig()
+	for _, l := range layers {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(l.Data, &doc); err != nil {
+			return nil, l.wrap(err, len(layers))
+		}
+		prefix := ""
+		if l.Path != "" {
+			prefix = l.Path + ": "
+		}
+		for _, p := range configKeyProblems(&doc) {
+			cfg.UnknownKeys = append(cfg.UnknownKeys, prefix+p)
+		}
+		for _, d := range migrateLegacyKeys(&doc) {
+			cfg.Deprecations = append(cfg.Deprecations, prefix+d)
+		}
+		if l.Project {
+			if err := checkProjectKeys(&doc); err != nil {
+				return nil, fmt.Errorf("%s: %w", l.Path, err)
+			}
+			resolvePromptPaths(&doc, filepath.Dir(l.Path))
+		}
+		if doc.Kind != 0 {
+			if err := doc.Decode(&cfg); err != nil {
+				return nil, l.wrap(err, len(layers))
+			}
+		}
+	}
+	if err := cfg.applyProfile(cmp.Or(profile, cfg.Profile)); err != nil {
 		return nil, err
 	}
+	cfg.Hash = configHash(layers)
 
-	cfg := Config{
-		Host: "http://localhost:11434",
+	if len(cfg.Hosts) > 0 {
+		if err := validatePool(cfg.Hosts); err != nil {
+			return nil, err
+		}
+		if cfg.Host != "" || cfg.SSHTunnel != "" {
+			return nil, fmt.Errorf("hosts replaces host and ssh_tunnel; set only one of them")
+		}
+		cfg.Host = cfg.Hosts[0].URL
+		cfg.pool = newHostPool(cfg.Hosts)
+	}
+	if err := applyProviderDefaults(&cfg); err != nil {
+		return nil, err
+	}
+	if _, ok := commentStyles[cfg.PromptHeader]; !ok && cfg.PromptHeader != "" && cfg.PromptHeader != "none" {
+		return nil, fmt.Errorf("prompt_header must be none, html, hash or slash, got %q", cfg.PromptHeader)
+	}
+	if _, err := splitCommand(cfg.ClipboardCmd); err != nil {
+		return nil, fmt.Errorf("clipboard_cmd: %v", err)
+	}
+	if _, err := splitCommand(cfg.QuestionAlert); err != nil {
+		return nil, fmt.Errorf("question_alert: %v", err)
+	}
+	if _, err := parseFooter(cfg.PromptFooter); err != nil {
+		return nil, fmt.Errorf("prompt_footer: %v", err)
+	}
+	if err := cfg.Save.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Review.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Scrub.validate(); err != nil {
+		return nil, err
+	}
+	if err := validateWebhooks(cfg.Webhooks); err != nil {
+		return nil, err
+	}
+	if err := cfg.Registry.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Experiment.validate(); err != nil {
+		return nil, err
 	}
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := cfg.Offline.validate(&cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.GenerationParams.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Proxy != "" {
+		if err := validateProxy(cfg.Proxy); err != nil {
+			return nil, err
+		}
+	}
+	for alias, model := range cfg.Aliases {
+		if model == "" {
+			return nil, fmt.Errorf("aliases: %s has no model", alias)
+		}
+		if _, ok := cfg.Aliases[model]; ok {
+			return nil, fmt.Errorf("aliases: %s points to alias %s; name the model itself", alias, model)
+		}
+	}
+	for _, cmd := range cfg.PostProcess {
+		if err := validatePostProcess(cmd); err != nil {
+			return nil, fmt.Errorf("post_process: %v", err)
+		}
+	}
+	for name, p := range cfg.Personas {
+		if err := p.validate(name); err != nil {
+			return nil, err
+		}
+	}
+	for name, d := range cfg.Models {
+		if err := d.GenerationParams.Validate(); err != nil {
+			return nil, fmt.Errorf("models: %s: %v", name, err)
+		}
+	}
+	if _, ok := cfg.Personas[cfg.Persona]; cfg.Persona != "" && !ok {
+		return nil, fmt.Errorf("persona %q is not defined under personas", cfg.Persona)
+	}
+	for name, n := range cfg.FitSlots {
+		if n <= 0 {
+			return nil, fmt.Errorf("fit_slots: %s must be a positive token count, got %d", name, n)
+		}
+	}
+	for prefix, spec := range cfg.Tokenizers {
+		if kind, path, _ := strings.Cut(spec, ":"); kind == "sentencepiece" && path == "" {
+			return nil, fmt.Errorf("tokenizers: %s must name the model file, as in sentencepiece:~/models/llama/tokenizer.model", prefix)
+		}
+	}
 
 	return &cfg, nil
 }
 
+// wrap names the file err is about when n layers make up the config, so
+// it is clear which one to fix.
+func (l configLayer) wrap(err error, n int) error {
+	if n == 1 || l.Path == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", l.Path, err)
+}
+
+// userOnlyKeys lists config keys a project config may not set, since a
+// repository should not be able to loosen offline, run commands, pick
+// the server the conversation goes to, or send prompts and credentials
+// anywhere else.
+var userOnlyKeys = []string{
+	"offline",
+	"host", "hosts", "provider", "ssh_tunnel", "proxy",
+	"api_key", "api_key_env", "headers",
+	"clipboard_cmd", "post_process", "prompt_footer", "question_alert",
+	"share", "review", "webhooks", "registry",
+}
+
+// checkProjectKeys refuses a project config that sets a key only the
+// user's config may set.
+func checkProjectKeys(doc *yaml.Node) error {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	return checkUserOnlyKeys(doc.Content[0], "")
+}
+
+// checkUserOnlyKeys checks the keys of mapping m, including those merged
+// in with <<, and at the top level those of each profile and persona,
+// which can set them too.
+func checkUserOnlyKeys(m *yaml.Node, prefix string) error {
+	if m.Kind == yaml.AliasNode {
+		m = m.Alias
+	}
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, value := m.Content[i].Value, m.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		switch {
+		case slices.Contains(userOnlyKeys, key):
+			return fmt.Errorf("%s%s can only be set in your own config, not a project's", prefix, key)
+		case key == "<<":
+			merged := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				merged = value.Content
+			}
+			for _, node := range merged {
+				if err := checkUserOnlyKeys(node, prefix); err != nil {
+					return err
+				}
+			}
+		case (key == "profiles" || key == "personas") && prefix == "" && value.Kind == yaml.MappingNode:
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				if err := checkUserOnlyKeys(value.Content[j+1], key+": "+value.Content[j].Value+": "); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolvePromptPaths makes relative system_prompt_file and prompts_dir
+// values anywhere in node relative to dir, so a project config can name
+// prompts kept in the project wherever the tool runs from.
+func resolvePromptPaths(node *yaml.Node, dir string) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if (key.Value == "system_prompt_file" || key.Value == "prompts_dir") && value.Kind == yaml.ScalarNode && value.Value != "" &&
+				!filepath.IsAbs(value.Value) && !strings.HasPrefix(value.Value, "~") && !isPromptURL(value.Value) {
+				value.Value = filepath.Join(dir, value.Value)
+			}
+		}
+	}
+	for _, child := range node.Content {
+		resolvePromptPaths(child, dir)
+	}
+}
+
+// applyProfile decodes the profile called name over c. Every profile is
+// decoded on the way, so a mistake in one that is not used still shows.
+func (c *Config) applyProfile(name string) error {
+	names := make([]string, 0, len(c.Profiles))
+	for n, node := range c.Profiles {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("profiles: %s must be a mapping of config keys", n)
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			if key := node.Content[i].Value; key == "profile" || key == "profiles" {
+				return fmt.Errorf("profiles: %s: %s cannot be set in a profile", n, key)
+			}
+		}
+		var scratch Config
+		if err := node.Decode(&scratch); err != nil {
+			return fmt.Errorf("profiles: %s: %v", n, err)
+		}
+		names = append(names, n)
+	}
+	if name == "" {
+		return nil
+	}
+	node, ok := c.Profiles[name]
+	if !ok {
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %q; the config file has no profiles", name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("unknown profile %q; the config file defines: %s", name, strings.Join(names, ", "))
+	}
+	if err := node.Decode(c); err != nil {
+		return fmt.Errorf("profiles: %s: %v", name, err)
+	}
+	c.Profile = name
+	return nil
+}
+
+// resolveModel returns the model an alias stands for, or name itself when
+// it is not an alias.
+func (c *Config) resolveModel(name string) string {
+	if model, ok := c.Aliases[name]; ok {
+		return model
+	}
+	return name
+}
+
+// ModelDefaults are settings that suit one model (models in the config),
+// such as a more explicit system prompt for a small local model. They
+// replace the config's when that model is used; a persona's settings and
+// flags still override them.
+type ModelDefaults struct {
+	SystemPromptFile string `yaml:"system_prompt_file"`
+
+	GenerationParams `yaml:",inline"`
+}
+
+// applyModelDefaults overlays the defaults for model name, given as a
+// model or an alias, on c. Settings of the persona in use stay in place.
+func (c *Config) applyModelDefaults(name string) {
+	d, ok := c.Models[name]
+	if !ok {
+		d, ok = c.Models[c.resolveModel(name)]
+	}
+	if !ok {
+		return
+	}
+	p := c.Personas[c.Persona] // zero without a persona
+	if d.SystemPromptFile != "" && p.SystemPromptFile == "" {
+		c.SystemPromptFile = d.SystemPromptFile
+	}
+	c.GenerationParams = c.GenerationParams.WithOverrides(d.GenerationParams).WithOverrides(p.GenerationParams)
+}
+
+// systemPrompt returns the contents of system_prompt_file, a file or an
+// https URL, or the built-in system prompt when it is unset.
+func (c *Config) systemPrompt() (string, error) {
+	switch {
+	case c.SystemPromptFile == "":
+		return builtinSystemPrompt, nil
+	case strings.HasPrefix(c.SystemPromptFile, "http://"):
+		// Anyone on the network could replace it
+		return "", fmt.Errorf("system prompt %s: use an https URL", c.SystemPromptFile)
+	case isPromptURL(c.SystemPromptFile):
+		return remotePrompt(context.Background(), promptHTTPClient, remotePromptsDir(), c.SystemPromptFile)
+	}
+	path := ExpandPath(c.SystemPromptFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("system prompt not found: %s", path)
+	}
+	return string(data), nil
+}
+
+// systemPromptName names the system prompt in use.
+func (c *Config) systemPromptName() string {
+	if c.SystemPromptFile == "" {
+		return builtinSystemPromptName
+	}
+	return ExpandPath(c.SystemPromptFile)
+}
+
 func ExpandPath(path string) string {
 	if strings.HasPrefix(path, "~/") {
 		home, err := os.UserHomeDir()
@@ -4,7 +4,12 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadConfig_ValidFile(t *testing.T) {
@@ -66,6 +71,58 @@ func TestLoadConfig_FileNotFound(t *testing.T) {
 	}
 }
 
+func TestParseConfig_Empty(t *testing.T) {
+	cfg, err := parseConfig(nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Host != "http://localhost:11434" || cfg.MaxNudges != 2 || cfg.SystemPromptFile != "" {
+		t.Errorf("config = %+v, want the defaults", cfg)
+	}
+}
+
+func TestLoadProfile_UnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("model: llama3.2\nsytem_prompt_file: terse.md\nprofiles:\n  work:\n    hots: http://gpu:11434\n"), 0644)
+	cfg, err := LoadProfile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		path + ": line 2: unknown key sytem_prompt_file (did you mean system_prompt_file?)",
+		path + ": line 5: unknown key profiles.work.hots (did you mean host?)",
+	}
+	if !slices.Equal(cfg.UnknownKeys, want) {
+		t.Errorf("UnknownKeys = %q, want %q", cfg.UnknownKeys, want)
+	}
+	if cfg.SystemPromptFile != "" {
+		t.Errorf("SystemPromptFile = %q, want the misspelled key ignored", cfg.SystemPromptFile)
+	}
+}
+
+func TestConfig_SystemPrompt(t *testing.T) {
+	cfg := &Config{}
+	prompt, err := cfg.systemPrompt()
+	if err != nil || prompt != builtinSystemPrompt || !strings.Contains(prompt, "R.G.C.O.A.") {
+		t.Errorf("unset: systemPrompt() = %.40q, %v, want the built-in prompt", prompt, err)
+	}
+	if cfg.systemPromptName() != builtinSystemPromptName {
+		t.Errorf("systemPromptName() = %q", cfg.systemPromptName())
+	}
+
+	path := filepath.Join(t.TempDir(), "mine.md")
+	os.WriteFile(path, []byte("You are terse."), 0644)
+	cfg.SystemPromptFile = path
+	if prompt, err := cfg.systemPrompt(); err != nil || prompt != "You are terse." {
+		t.Errorf("file: systemPrompt() = %q, %v, want the file's contents", prompt, err)
+	}
+
+	cfg.SystemPromptFile = filepath.Join(t.TempDir(), "missing.md")
+	if _, err := cfg.systemPrompt(); err == nil || !strings.HasPrefix(err.Error(), "system prompt not found") {
+		t.Errorf("missing file: error = %v", err)
+	}
+}
+
 func TestExpandPath_Tilde(t *testing.T) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -88,3 +145,361 @@ func TestExpandPath_Tilde(t *testing.T) {
 		}
 	}
 }
+
+func TestUserConfigPath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("APPDATA", "")
+	if got, want := userConfigPath("linux"), filepath.Join(home, ".config", "prompt-builder", "config.yaml"); got != want {
+		t.Errorf("default: %q, want %q", got, want)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	t.Setenv("APPDATA", `C:\Users\me\AppData\Roaming`)
+	if got, want := userConfigPath("linux"), filepath.Join("/xdg", "prompt-builder", "config.yaml"); got != want {
+		t.Errorf("XDG_CONFIG_HOME: %q, want %q", got, want)
+	}
+	if got, want := userConfigPath("windows"), filepath.Join(`C:\Users\me\AppData\Roaming`, "prompt-builder", "config.yaml"); got != want {
+		t.Errorf("windows: %q, want %q", got, want)
+	}
+}
+
+func TestConfigFiles_Project(t *testing.T) {
+	t.Setenv(homeEnv, "")
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	root := t.TempDir()
+	sub := filepath.Join(root, "docs", "prompts")
+	os.MkdirAll(sub, 0755)
+	t.Chdir(sub)
+
+	if got := configFiles(); len(got) != 0 {
+		t.Errorf("no config files: %q, want none", got)
+	}
+
+	userPath := filepath.Join(xdg, "prompt-builder", "config.yaml")
+	os.MkdirAll(filepath.Dir(userPath), 0755)
+	os.WriteFile(userPath, []byte("model: llama3.2\n"), 0644)
+	projectPath := filepath.Join(root, projectConfigName)
+	os.WriteFile(projectPath, []byte("system_prompt_file: prompts/docs.md\n"), 0644)
+	if got, want := configFiles(), []string{userPath, projectPath}; !slices.Equal(got, want) {
+		t.Errorf("configFiles() = %q, want %q", got, want)
+	}
+
+	t.Setenv(homeEnv, t.TempDir())
+	if got := configFiles(); len(got) != 0 {
+		t.Errorf("%s set: %q, want the project config ignored", homeEnv, got)
+	}
+}
+
+func TestLoadFiles_ProjectOverUser(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "config.yaml")
+	os.WriteFile(userPath, []byte(`model: llama3.2
+ollama_host: http://localhost:11434
+aliases:
+  smart: llama3.3:70b
+`), 0644)
+	projectDir := filepath.Join(dir, "repo")
+	os.MkdirAll(projectDir, 0755)
+	projectPath := filepath.Join(projectDir, projectConfigName)
+	os.WriteFile(projectPath, []byte(`system_prompt_file: prompts/docs.md
+aliases:
+  fast: qwen2.5:3b
+`), 0644)
+
+	cfg, err := LoadFiles([]string{userPath, projectPath}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Model != "llama3.2" {
+		t.Errorf("Model = %q, want the user's", cfg.Model)
+	}
+	if want := filepath.Join(projectDir, "prompts", "docs.md"); cfg.SystemPromptFile != want {
+		t.Errorf("SystemPromptFile = %q, want %q", cfg.SystemPromptFile, want)
+	}
+	if cfg.Aliases["smart"] != "llama3.3:70b" || cfg.Aliases["fast"] != "qwen2.5:3b" {
+		t.Errorf("Aliases = %v, want both files' aliases", cfg.Aliases)
+	}
+	if len(cfg.Deprecations) != 1 || !strings.HasPrefix(cfg.Deprecations[0], userPath+": ollama_host") {
+		t.Errorf("Deprecations = %q, want the user file named", cfg.Deprecations)
+	}
+
+	for config, want := range map[string]string{
+		"offline:\n  enabled: false\n":                                   "offline can only be set in your own config",
+		"host: http://gpu.example:11434\n":                               "host can only be set",
+		"hosts:\n  - url: http://gpu.example:11434\n":                    "hosts can only be set",
+		"provider: openai\n":                                             "provider can only be set",
+		"ssh_tunnel: gpu.example\n":                                      "ssh_tunnel can only be set",
+		"proxy: http://proxy.example:3128\n":                             "proxy can only be set",
+		"api_key: sk-test\n":                                             "api_key can only be set",
+		"api_key_env: HOME\n":                                            "api_key_env can only be set",
+		"headers:\n  X-Repo: site\n":                                     "headers can only be set",
+		"clipboard_cmd: sh -c id\n":                                      "clipboard_cmd can only be set",
+		"post_process: [sh -c id]\n":                                     "post_process can only be set",
+		"question_alert: sh -c id\n":                                     "question_alert can only be set",
+		"prompt_footer: '{{ .Model }}'\n":                                "prompt_footer can only be set",
+		"share:\n  url: https://paste.example\n":                         "share can only be set",
+		"review:\n  url: https://review.example\n":                       "review can only be set",
+		"webhooks:\n  - url: https://hook.example\n":                     "webhooks can only be set",
+		"registry:\n  url: https://registry.example\n":                   "registry can only be set",
+		"profiles:\n  work:\n    host: http://gpu.example:11434\n":       "profiles: work: host can only be set",
+		"personas:\n  docs:\n    post_process: [sh -c id]\n":             "personas: docs: post_process can only be set",
+		"x-base: &base\n  proxy: http://proxy.example:3128\n<<: *base\n": "proxy can only be set",
+	} {
+		os.WriteFile(projectPath, []byte(config), 0644)
+		if _, err := LoadFiles([]string{userPath, projectPath}, ""); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("project %q: error = %v, want %q", config, err, want)
+		}
+	}
+}
+
+func TestLoadConfig_NudgeDefaults(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	content := `model: llama3.2
+max_nudges: 0
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.MaxNudges != 0 {
+		t.Errorf("MaxNudges = %d, want explicit 0", cfg.MaxNudges)
+	}
+	if cfg.CompletionNudge == "" {
+		t.Error("CompletionNudge should default to a reminder")
+	}
+}
+
+func TestLoadConfig_IdleTimeout(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	os.WriteFile(configPath, []byte("idle_timeout: 30m\n"), 0644)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Duration(cfg.IdleTimeout) != 30*time.Minute {
+		t.Errorf("IdleTimeout = %v, want 30m", time.Duration(cfg.IdleTimeout))
+	}
+
+	os.WriteFile(configPath, []byte("idle_timeout: soon\n"), 0644)
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+}
+
+func TestLoadConfig_ClipboardCmdQuoting(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	for cmd, wantErr := range map[string]bool{`xclip -selection clipboard`: false, `sh -c 'cat > "/tmp/a b"'`: false, `sh -c 'cat`: true} {
+		data, _ := yaml.Marshal(map[string]string{"clipboard_cmd": cmd})
+		if err := os.WriteFile(configPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadConfig(configPath)
+		if (err != nil) != wantErr {
+			t.Errorf("clipboard_cmd %q: error = %v, wantErr %v", cmd, err, wantErr)
+		}
+	}
+}
+
+func TestLoadConfig_PromptHeader(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	for style, wantErr := range map[string]bool{"html": false, "none": false, "xml": true} {
+		if err := os.WriteFile(configPath, []byte("prompt_header: "+style+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadConfig(configPath)
+		if (err != nil) != wantErr {
+			t.Errorf("prompt_header %q: error = %v, wantErr %v", style, err, wantErr)
+		}
+	}
+}
+
+func TestLoadConfig_Aliases(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	os.WriteFile(configPath, []byte("model: fast\naliases:\n  fast: llama3.2:3b-instruct-q4\n  best: openai/gpt-4o\n"), 0644)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.resolveModel(cfg.Model); got != "llama3.2:3b-instruct-q4" {
+		t.Errorf("resolveModel(fast) = %q", got)
+	}
+	if got := cfg.resolveModel("mistral"); got != "mistral" {
+		t.Errorf("resolveModel(mistral) = %q, want the name unchanged", got)
+	}
+
+	for config, want := range map[string]string{
+		"aliases:\n  fast: \"\"\n":                 "has no model",
+		"aliases:\n  fast: best\n  best: gpt-4o\n": "points to alias best",
+	} {
+		os.WriteFile(configPath, []byte(config), 0644)
+		if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error = %v, want %q", config, err, want)
+		}
+	}
+}
+
+func TestApplyModelDefaults(t *testing.T) {
+	cfg, err := parseConfig([]byte(`model: fast
+system_prompt_file: ~/architect.md
+temperature: 0.7
+max_tokens: 2048
+aliases:
+  fast: llama3.2:3b
+models:
+  llama3.2:3b:
+    system_prompt_file: ~/explicit.md
+    temperature: 0.2
+  best:
+    max_tokens: 8192
+personas:
+  coding:
+    temperature: 0.5
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	small := *cfg
+	small.applyModelDefaults("fast") // found through the alias
+	if small.SystemPromptFile != "~/explicit.md" || *small.Temperature != 0.2 || *small.MaxTokens != 2048 {
+		t.Errorf("fast: system prompt %q, temperature %v, max_tokens %v", small.SystemPromptFile, *small.Temperature, *small.MaxTokens)
+	}
+
+	other := *cfg
+	other.applyModelDefaults("mistral")
+	if other.SystemPromptFile != "~/architect.md" || *other.Temperature != 0.7 {
+		t.Errorf("mistral: system prompt %q, temperature %v, want the config's", other.SystemPromptFile, *other.Temperature)
+	}
+
+	persona := *cfg
+	if err := persona.applyPersona("coding"); err != nil {
+		t.Fatal(err)
+	}
+	persona.applyModelDefaults("fast")
+	if persona.SystemPromptFile != "~/explicit.md" || *persona.Temperature != 0.5 {
+		t.Errorf("with a persona: system prompt %q, temperature %v, want the persona's temperature", persona.SystemPromptFile, *persona.Temperature)
+	}
+
+	if _, err := parseConfig([]byte("models:\n  fast:\n    temperature: 3\n"), ""); err == nil || !strings.Contains(err.Error(), "models: fast: temperature") {
+		t.Errorf("bad temperature: error = %v", err)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	os.WriteFile(configPath, []byte(`model: llama3.2
+system_prompt_file: ~/architect.md
+temperature: 0.7
+headers:
+  X-Team: prompts
+profile: local
+profiles:
+  local:
+    host: http://localhost:11434
+  work:
+    provider: openai
+    model: gpt-4o-mini
+    api_key_env: WORK_OPENAI_KEY
+    temperature: 0.2
+    headers:
+      OpenAI-Organization: org-1234
+`), 0644)
+
+	cfg, err := LoadProfile(configPath, "work")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Profile != "work" || cfg.Provider != providerOpenAI || cfg.Model != "gpt-4o-mini" || *cfg.Temperature != 0.2 {
+		t.Errorf("cfg = %+v, want the work profile's settings", cfg)
+	}
+	if cfg.Host != "https://api.openai.com" || cfg.SystemPromptFile != "~/architect.md" {
+		t.Errorf("host = %q, system prompt = %q, want the provider's host and the top-level prompt", cfg.Host, cfg.SystemPromptFile)
+	}
+	if cfg.Headers["X-Team"] != "prompts" || cfg.Headers["OpenAI-Organization"] != "org-1234" {
+		t.Errorf("headers = %v, want both sets", cfg.Headers)
+	}
+
+	// Without a name, the profile key picks one
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Profile != "local" || cfg.Host != "http://localhost:11434" || cfg.Model != "llama3.2" {
+		t.Errorf("cfg = %+v, want the local profile", cfg)
+	}
+
+	if _, err := LoadProfile(configPath, "home"); err == nil || !strings.Contains(err.Error(), "defines: local, work") {
+		t.Errorf("unknown profile: error = %v, want the defined profiles", err)
+	}
+}
+
+func TestLoadProfile_Invalid(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	for config, want := range map[string]string{
+		"profiles:\n  work: gpt-4o\n":                             "must be a mapping",
+		"profiles:\n  work:\n    profile: home\n":                 "cannot be set in a profile",
+		"profiles:\n  work:\n    max_nudges: many\n":              "profiles: work",
+		"profiles:\n  work:\n    temperature: 5\nprofile: work\n": "temperature",
+	} {
+		os.WriteFile(configPath, []byte(config), 0644)
+		if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error = %v, want %q", config, err, want)
+		}
+	}
+}
+
+func TestLoadConfig_GenerationParams(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	os.WriteFile(configPath, []byte("temperature: 0.2\ntop_p: 0.9\nmax_tokens: 2048\nseed: 42\n"), 0644)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := cfg.GenerationParams
+	if deref(p.Temperature) != 0.2 || deref(p.TopP) != 0.9 || deref(p.MaxTokens) != 2048 || deref(p.Seed) != 42 {
+		t.Errorf("GenerationParams = %+v, want the configured values", p)
+	}
+
+	for _, bad := range []string{"temperature: 3\n", "top_p: 0\n", "max_tokens: -1\n"} {
+		os.WriteFile(configPath, []byte(bad), 0644)
+		if _, err := LoadConfig(configPath); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestLoadConfig_Webhooks(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("webhooks:\n  - url: https://registry.example.com/hook\n    secret_env: HOOK_SECRET\n"), 0644)
+	cfg, err := LoadConfig(configPath)
+	if err != nil || len(cfg.Webhooks) != 1 || cfg.Webhooks[0].SecretEnv != "HOOK_SECRET" {
+		t.Fatalf("LoadConfig() = %+v, %v", cfg, err)
+	}
+
+	os.WriteFile(configPath, []byte("webhooks:\n  - url: registry.example.com/hook\n"), 0644)
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "webhooks: entry 1") {
+		t.Errorf("error = %v, want a bad url error", err)
+	}
+}
@@ -0,0 +1,251 @@
+// configedit.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// settingType returns the type of the setting at key, a dotted path of
+// config keys such as model, save.dir or profiles.work.host.
+func settingType(key string) (reflect.Type, error) {
+	config := reflect.TypeFor[Config]()
+	t, path := config, ""
+	for part := range strings.SplitSeq(key, ".") {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t == reflect.TypeFor[yaml.Node]() {
+			t = config // a profile holds config keys
+		}
+		switch {
+		case part == "":
+			return nil, fmt.Errorf("invalid key %q", key)
+		case t.Kind() == reflect.Struct:
+			fields := yamlFields(t)
+			ft, ok := fields[part]
+			if !ok {
+				msg := fmt.Sprintf("unknown key %s%s", path, part)
+				if s := closestKey(part, fields); s != "" {
+					msg += fmt.Sprintf(" (did you mean %s?)", s)
+				}
+				return nil, errors.New(msg)
+			}
+			t = ft
+		case t.Kind() == reflect.Map:
+			t = t.Elem()
+		case t.Kind() == reflect.Slice:
+			return nil, fmt.Errorf("%s is a list; edit the config file to change its entries", strings.TrimSuffix(path, "."))
+		default:
+			return nil, fmt.Errorf("%s has no key %s", strings.TrimSuffix(path, "."), part)
+		}
+		path += part + "."
+	}
+	return t, nil
+}
+
+// lookupNode returns the value at the dotted key in mapping node m, or
+// nil if it is not set.
+func lookupNode(m *yaml.Node, key string) *yaml.Node {
+	for part := range strings.SplitSeq(key, ".") {
+		if m == nil || m.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			if m.Content[i].Value == part {
+				next = m.Content[i+1]
+			}
+		}
+		m = next
+	}
+	return m
+}
+
+// setNode sets the dotted key in mapping node m to value, adding the
+// mappings on the way that are missing. Comments on a replaced value are
+// kept.
+func setNode(m *yaml.Node, key string, value *yaml.Node) {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		at := -1
+		for j := 0; j+1 < len(m.Content); j += 2 {
+			if m.Content[j].Value == part {
+				at = j + 1
+			}
+		}
+		next := value
+		if i < len(parts)-1 {
+			if at != -1 && m.Content[at].Kind == yaml.MappingNode {
+				m = m.Content[at]
+				continue
+			}
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		if at == -1 {
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, next)
+		} else {
+			old := m.Content[at]
+			next.HeadComment, next.LineComment, next.FootComment = old.HeadComment, old.LineComment, old.FootComment
+			m.Content[at] = next
+		}
+		m = next
+	}
+}
+
+// removeLegacyKey drops the legacy name of a top-level key from mapping
+// node m, so setting host does not leave an ollama_host behind.
+func removeLegacyKey(m *yaml.Node, key string) {
+	for _, r := range renamedKeys {
+		if r.New != key {
+			continue
+		}
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			if m.Content[i].Value == r.Old {
+				m.Content = append(m.Content[:i], m.Content[i+2:]...)
+				break
+			}
+		}
+	}
+}
+
+// runConfigGet implements config get: it prints the value a run would
+// use, from the config files or the defaults.
+func runConfigGet(args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("config get", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	configPath := fs.String("config", "", "Path to config file (default: your config and the project's)")
+	profile := fs.String("profile", "", "Profile from the config file to apply")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return ExitConfigError
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(errOut, "Usage: prompt-builder config get <key> [--profile name] [--config path]")
+		return ExitConfigError
+	}
+	key := positional[0]
+	if _, err := settingType(key); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+
+	cfg, err := loadRunConfig(*configPath, *profile)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: invalid config: %v\n", err)
+		return ExitConfigError
+	}
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	value := lookupNode(&doc, key)
+	switch {
+	case value == nil || value.Tag == "!!null":
+		// Unset, with no default
+	case value.Kind == yaml.ScalarNode:
+		fmt.Fprintln(out, value.Value)
+	default:
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(value); err != nil {
+			fmt.Fprintf(errOut, "Error: %v\n", err)
+			return ExitConfigError
+		}
+	}
+	return ExitSuccess
+}
+
+// runConfigSet implements config set: it changes one key in a config
+// file, keeping the rest of the file and its comments as they are.
+func runConfigSet(args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("config set", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	configPath := fs.String("config", defaultConfigPath(), "Path to config file")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return ExitConfigError
+	}
+	if len(positional) != 2 {
+		fmt.Fprintln(errOut, "Usage: prompt-builder config set <key> <value> [--config path]")
+		return ExitConfigError
+	}
+	key, raw := positional[0], positional[1]
+	path := ExpandPath(*configPath)
+
+	t, err := settingType(key)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	var parsed yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &parsed); err != nil {
+		fmt.Fprintf(errOut, "Error: %s: %v\n", key, err)
+		return ExitConfigError
+	}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: raw}
+	if len(parsed.Content) > 0 {
+		value = parsed.Content[0]
+	}
+	if t.Kind() == reflect.String && value.Kind == yaml.ScalarNode {
+		value.Tag, value.Style = "!!str", 0 // model 123 is the name "123"
+	}
+	if err := value.Decode(reflect.New(t).Interface()); err != nil {
+		fmt.Fprintf(errOut, "Error: %s: %v\n", key, err)
+		return ExitConfigError
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		fmt.Fprintf(errOut, "Error: %s: %v\n", path, err)
+		return ExitConfigError
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		fmt.Fprintf(errOut, "Error: %s: the config is not a mapping of keys\n", path)
+		return ExitConfigError
+	}
+	setNode(doc.Content[0], key, value)
+	removeLegacyKey(doc.Content[0], key)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	// Check the whole file as a run would load it before writing it
+	layer := configLayer{Path: path, Data: buf.Bytes(), Project: filepath.Base(path) == projectConfigName}
+	if _, err := parseLayers([]configLayer{layer}, ""); err != nil {
+		fmt.Fprintf(errOut, "Error: %s not changed: %v\n", path, err)
+		return ExitConfigError
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	fmt.Fprintf(out, "✓ Set %s in %s\n", key, path)
+	return ExitSuccess
+}
@@ -0,0 +1,123 @@
+// configedit_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSettingType(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"model", ""},
+		{"save.dir", ""},
+		{"headers.X-Team", ""},
+		{"profiles.work.host", ""},
+		{"personas.critic.model", ""},
+		{"modle", "unknown key modle (did you mean model?)"},
+		{"save.formt", "unknown key save.formt"},
+		{"hosts.1.url", "hosts is a list"},
+		{"model.name", "model has no key name"},
+		{"save.", "invalid key"},
+	}
+	for _, tt := range tests {
+		_, err := settingType(tt.key)
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("settingType(%q) error = %v, want %q", tt.key, err, tt.want)
+		}
+	}
+}
+
+func TestRunConfigSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`# my setup
+model: llama3.2 # the fast one
+ollama_host: http://localhost:11434
+save:
+  dir: ~/prompts
+`), 0644)
+
+	set := func(args ...string) (int, string) {
+		var out, errOut bytes.Buffer
+		code := runConfig(context.Background(), append([]string{"set"}, append(args, "--config", path)...), nil, &out, &errOut)
+		return code, errOut.String()
+	}
+	for _, args := range [][]string{
+		{"model", "qwen2.5"},
+		{"host", "http://gpu:11434"},
+		{"temperature", "0.3"},
+		{"save.title", "model"},
+		{"headers.X-Team", "docs"},
+		{"profiles.work.model", "123"},
+	} {
+		if code, errOut := set(args...); code != ExitSuccess {
+			t.Fatalf("set %v = %d: %s", args, code, errOut)
+		}
+	}
+	want := `# my setup
+model: qwen2.5 # the fast one
+save:
+  dir: ~/prompts
+  title: model
+host: http://gpu:11434
+temperature: 0.3
+headers:
+  X-Team: docs
+profiles:
+  work:
+    model: "123"
+`
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("config =\n%s\nwant\n%s", data, want)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"modle", "x"}, "did you mean model?"},
+		{[]string{"temperature", "hot"}, "cannot unmarshal"},
+		{[]string{"temperature", "5"}, "not changed: temperature"},
+		{[]string{"model"}, "Usage:"},
+	} {
+		if code, errOut := set(tt.args...); code != ExitConfigError || !strings.Contains(errOut, tt.want) {
+			t.Errorf("set %v = %d %q, want an error with %q", tt.args, code, errOut, tt.want)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("config changed by a failed set:\n%s", data)
+	}
+}
+
+func TestRunConfigGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("model: llama3.2\nheaders:\n  X-Team: docs\nprofiles:\n  work:\n    model: qwen2.5\n"), 0644)
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"model"}, "llama3.2\n"},
+		{[]string{"model", "--profile", "work"}, "qwen2.5\n"},
+		{[]string{"max_nudges"}, "2\n"}, // the default
+		{[]string{"stall_timeout"}, "2m0s\n"},
+		{[]string{"headers"}, "X-Team: docs\n"},
+		{[]string{"temperature"}, ""}, // unset
+	} {
+		var out, errOut bytes.Buffer
+		args := append([]string{"get"}, append(tt.args, "--config", path)...)
+		if code := runConfig(context.Background(), args, nil, &out, &errOut); code != ExitSuccess || out.String() != tt.want {
+			t.Errorf("get %v = %d %q, want %q (%s)", tt.args, code, out.String(), tt.want, errOut.String())
+		}
+	}
+
+	var out, errOut bytes.Buffer
+	if code := runConfig(context.Background(), []string{"get", "sytem_prompt_file", "--config", path}, nil, &out, &errOut); code != ExitConfigError || !strings.Contains(errOut.String(), "did you mean system_prompt_file?") {
+		t.Errorf("get of an unknown key = %d %q", code, errOut.String())
+	}
+}
@@ -0,0 +1,107 @@
+// context_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadContextFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, data, 0644)
+		return path
+	}
+	spec := write("spec.md", []byte("# Spec\n\nThe API returns JSON.\n"))
+	code := write("main.go", []byte("package main\n"))
+
+	files, err := readContextFiles([]string{spec, code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Name != spec || files[1].Content != "package main\n" {
+		t.Errorf("readContextFiles() = %+v", files)
+	}
+
+	big := strings.Repeat("x", maxContextFile/2)
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"missing", []string{filepath.Join(dir, "gone.md")}, "cannot attach context"},
+		{"empty", []string{write("empty.md", []byte("\n \n"))}, "the file is empty"},
+		{"binary", []string{write("logo.png", []byte("\x89PNG\r\n\x1a\n\x00\x00"))}, "looks like a binary file"},
+		{"invalid UTF-8", []string{write("latin1.txt", []byte("caf\xe9"))}, "looks like a binary file"},
+		{"too large", []string{write("huge.log", []byte(strings.Repeat("x", maxContextFile+1)))}, "over the 100.0 kB limit"},
+		{"too much together", []string{
+			write("a.txt", []byte(big)), write("b.txt", []byte(big)), write("c.txt", []byte(big)),
+			write("d.txt", []byte(big)), write("e.txt", []byte(big)), write("f.txt", []byte(big)),
+			write("g.txt", []byte(big)),
+		}, "over 300.0 kB together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readContextFiles(tt.paths); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	if got := withContext("a code reviewer", nil); got != "a code reviewer" {
+		t.Errorf("without files: %q", got)
+	}
+
+	files := []contextFile{
+		{Name: "docs/spec.md", Content: "Use ```go fences```.\n```\nexample\n```\n"},
+		{Name: "main.go", Content: "package main\n"},
+	}
+	got := withContext("a code reviewer", files)
+	want := "a code reviewer\n\n" + contextIntro +
+		"\n\nFile docs/spec.md:\n````\nUse ```go fences```.\n```\nexample\n```\n````" +
+		"\n\nFile main.go:\n```\npackage main\n```"
+	if got != want {
+		t.Errorf("withContext() =\n%s\nwant\n%s", got, want)
+	}
+	if got := withContext("", files[1:]); !strings.HasPrefix(got, contextIntro) {
+		t.Errorf("without an idea: %q, want only the files", got)
+	}
+}
+
+func TestCondenseContextFiles(t *testing.T) {
+	client := &mockLLM{responses: []string{"log gist", "more gist", "the rest", "the end"}}
+	var status bytes.Buffer
+	files := []contextFile{
+		{Name: "build.log", Content: strings.Repeat("error: retrying ", 200)},
+		{Name: "spec.md", Content: "# Spec\n\nThe API returns JSON.\n"},
+	}
+
+	got, err := condenseContextFiles(context.Background(), client, files, 300, &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[1] != files[1] {
+		t.Errorf("spec.md = %q, want the short file sent whole", got[1].Content)
+	}
+	if !strings.HasPrefix(got[0].Content, "log gist") || EstimateTokens(got[0].Content) > 300 {
+		t.Errorf("build.log = %q, want its summary", got[0].Content)
+	}
+	if !strings.HasPrefix(files[0].Content, "error: retrying") {
+		t.Error("condenseContextFiles changed the files it was given")
+	}
+	if !strings.Contains(status.String(), "Summarizing build.log") {
+		t.Errorf("status = %q, want a note about the summary", status.String())
+	}
+
+	client.calls = 0
+	if got, err := condenseContextFiles(context.Background(), client, files[1:], 300, &status); err != nil || got[0] != files[1] || client.calls != 0 {
+		t.Errorf("fitting files: %+v, %v after %d calls; want them unchanged", got, err, client.calls)
+	}
+}
@@ -0,0 +1,56 @@
+// deadline.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// deadlineError reports a run that --deadline cut short. Draft is true
+// when the last complete draft was delivered in place of the final prompt.
+type deadlineError struct {
+	Deadline time.Duration
+	Draft    bool
+}
+
+func (e *deadlineError) Error() string {
+	if e.Draft {
+		return fmt.Sprintf("the %s deadline passed; delivered the last complete draft, which the model may still have revised", e.Deadline)
+	}
+	return fmt.Sprintf("the %s deadline passed before the model wrote a prompt", e.Deadline)
+}
+
+// lastDraft returns the latest assistant message in conv that holds a
+// complete prompt, or "" if there is none.
+func lastDraft(conv *Conversation) string {
+	for i := len(conv.Messages) - 1; i >= 0; i-- {
+		if m := conv.Messages[i]; m.Role == "assistant" && promptbuilder.IsComplete(m.Content) {
+			return m.Content
+		}
+	}
+	return ""
+}
+
+// deliverLastDraft ends a run whose --deadline passed: it delivers the
+// tab's last complete draft, if any, as a finished run would deliver its
+// prompt, and returns the deadlineError to exit with.
+func deliverLastDraft(ctx context.Context, cli *CLI, deps *Deps, tab *Tab) error {
+	draft := lastDraft(tab.Conv)
+	if draft == "" {
+		return &deadlineError{Deadline: cli.Deadline}
+	}
+	tab.Response = draft
+	if cli.Quiet == QuietNone {
+		// The draft scrolled by with the conversation; show it again
+		fmt.Fprintln(deps.Stdout, promptbuilder.ExtractLastCodeBlock(draft))
+	}
+	// Steps that need the model, such as a title for --save, fall back
+	// to what works without it
+	if err := emitPrompt(ctx, cli, deps, tab); err != nil {
+		return err
+	}
+	return &deadlineError{Deadline: cli.Deadline, Draft: true}
+}
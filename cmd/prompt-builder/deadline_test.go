@@ -0,0 +1,76 @@
+// deadline_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hangingLLM answers its first n requests like mockLLM, then waits for
+// the context to end, like a model that is still thinking.
+type hangingLLM struct {
+	*mockLLM
+	n int
+}
+
+func (h *hangingLLM) ChatStreamWithSpinner(ctx context.Context, messages []Message, tty bool, onToken StreamCallback) (string, error) {
+	if h.calls < h.n {
+		return h.mockLLM.ChatStreamWithSpinner(ctx, messages, tty, onToken)
+	}
+	onToken("```\nhalf a ")
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestRun_Deadline_DeliversLastDraft(t *testing.T) {
+	requireBuild(t, tiktokenBuilt, "notiktoken")
+	long := "```\n" + strings.Repeat("Always be polite. ", 20) + "\n```"
+	deps := newTestDeps(withResponses(long), withTTY(false))
+	deps.Client = &hangingLLM{mockLLM: deps.Client.(*mockLLM), n: 1}
+	// Over the --fit budget, so the model is asked to shorten it
+	deps.Session = &Session{Idea: "support bot", Fit: &Fit{Model: "gpt-4o-mini", Slot: "10", Budget: 10, Encoding: "o200k_base"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := runWithDeps(ctx, &CLI{Idea: "support bot", Quiet: QuietPrompt, Deadline: time.Minute}, deps)
+	var deadline *deadlineError
+	if !errors.As(err, &deadline) || !deadline.Draft {
+		t.Fatalf("error = %v, want a deadline error after delivering the draft", err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(stdout(deps)), "Always be polite.") {
+		t.Errorf("stdout = %q, want the last complete draft", stdout(deps))
+	}
+	if !strings.Contains(err.Error(), "the 1m0s deadline passed; delivered the last complete draft") {
+		t.Errorf("error = %q", err.Error())
+	}
+}
+
+func TestRun_Deadline_NoDraft(t *testing.T) {
+	deps := newTestDeps(withResponses(), withTTY(false))
+	deps.Client = &hangingLLM{mockLLM: deps.Client.(*mockLLM)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := runWithDeps(ctx, &CLI{Idea: "support bot", Quiet: QuietPrompt, Deadline: 30 * time.Second}, deps)
+	var deadline *deadlineError
+	if !errors.As(err, &deadline) || deadline.Draft {
+		t.Fatalf("error = %v, want a deadline error without a draft", err)
+	}
+	if stdout(deps) != "" {
+		t.Errorf("stdout = %q, want nothing", stdout(deps))
+	}
+}
+
+func TestLastDraft(t *testing.T) {
+	conv := NewConversation("system")
+	conv.AddUserMessage("idea")
+	conv.AddAssistantMessage("```\nfirst\n```")
+	conv.AddUserMessage("shorter")
+	conv.AddAssistantMessage("Which audience?")
+	if got := lastDraft(conv); got != "```\nfirst\n```" {
+		t.Errorf("lastDraft() = %q, want the last reply with a prompt", got)
+	}
+}
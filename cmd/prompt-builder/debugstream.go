@@ -0,0 +1,271 @@
+// debugstream.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// streamRecord is one line of a --debug-stream dump. Each response opens
+// with a record carrying the provider, URL and status, followed by one
+// record per read from the response body, exactly as the server sent it.
+type streamRecord struct {
+	Stream   int    `json:"stream"`
+	Provider string `json:"provider,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Data     string `json:"data,omitempty"`
+	Base64   string `json:"base64,omitempty"` // a chunk that is not valid UTF-8, such as half a character
+	Ms       int64  `json:"ms"`               // since the request was sent
+	Error    string `json:"error,omitempty"`  // the request or read failed
+}
+
+// StreamLog writes the responses of LLM requests to an NDJSON dump for
+// replay-stream. It is safe for concurrent streams, as with --compare.
+type StreamLog struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	provider string
+	streams  int
+}
+
+// NewStreamLog returns a log writing to w for responses from provider.
+func NewStreamLog(w io.Writer, provider string) *StreamLog {
+	return &StreamLog{enc: json.NewEncoder(w), provider: provider}
+}
+
+func (l *StreamLog) write(r streamRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(r) // a failing dump must not fail the request
+}
+
+// transport wraps next so chat requests (POST) are recorded. Other
+// requests, such as version checks, pass through.
+func (l *StreamLog) transport(next http.RoundTripper) http.RoundTripper {
+	return recordingTransport{l, next}
+}
+
+type recordingTransport struct {
+	log  *StreamLog
+	next http.RoundTripper
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost {
+		return t.next.RoundTrip(req)
+	}
+	l := t.log
+	l.mu.Lock()
+	l.streams++
+	id := l.streams
+	l.mu.Unlock()
+
+	// Without the query, which some gateways use for keys
+	head := streamRecord{Stream: id, Provider: l.provider, URL: req.URL.Scheme + "://" + req.URL.Host + req.URL.Path}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		head.Error = err.Error()
+		l.write(head)
+		return nil, err
+	}
+	head.Status = resp.StatusCode
+	head.Ms = time.Since(start).Milliseconds()
+	l.write(head)
+	resp.Body = &recordingBody{resp.Body, l, id, start}
+	return resp, nil
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	log   *StreamLog
+	id    int
+	start time.Time
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	r := streamRecord{Stream: b.id, Ms: time.Since(b.start).Milliseconds()}
+	if n > 0 {
+		if utf8.Valid(p[:n]) {
+			r.Data = string(p[:n])
+		} else {
+			r.Base64 = base64.StdEncoding.EncodeToString(p[:n])
+		}
+	}
+	if err != nil && err != io.EOF {
+		r.Error = err.Error()
+	}
+	if n > 0 || r.Error != "" {
+		b.log.write(r)
+	}
+	return n, err
+}
+
+// recordedStream is one response read back from a dump.
+type recordedStream struct {
+	Provider string
+	URL      string
+	Status   int
+	Chunks   [][]byte
+	Error    string
+}
+
+// readStreamLog reads a --debug-stream dump, returning its responses in
+// the order they started.
+func readStreamLog(r io.Reader) ([]*recordedStream, error) {
+	var streams []*recordedStream
+	byID := map[int]*recordedStream{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec streamRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		s := byID[rec.Stream]
+		if s == nil {
+			s = &recordedStream{Provider: rec.Provider, URL: rec.URL, Status: rec.Status}
+			byID[rec.Stream] = s
+			streams = append(streams, s)
+		}
+		chunk := []byte(rec.Data)
+		if rec.Base64 != "" {
+			var err error
+			if chunk, err = base64.StdEncoding.DecodeString(rec.Base64); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		if len(chunk) > 0 {
+			s.Chunks = append(s.Chunks, chunk)
+		}
+		if rec.Error != "" {
+			s.Error = rec.Error
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+// chunkReader returns one recorded chunk per Read, so a parser sees the
+// same boundaries it saw from the server.
+type chunkReader struct {
+	chunks [][]byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	if n == len(r.chunks[0]) {
+		r.chunks = r.chunks[1:]
+	} else {
+		r.chunks[0] = r.chunks[0][n:]
+	}
+	return n, nil
+}
+
+// runReplayStream implements the replay-stream subcommand: it runs each
+// response in a --debug-stream dump through its provider's parser again,
+// printing the reply as it would have streamed and a summary per
+// response. It exits 1 when a response fails to parse.
+func runReplayStream(args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("replay-stream", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	provider := fs.String("provider", "", "Parse as this provider instead of the one recorded")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(errOut, "Usage: prompt-builder replay-stream [--provider NAME] <dump.ndjson>")
+		return ExitConfigError
+	}
+	if _, ok := promptbuilder.LookupProvider(*provider); *provider != "" && !ok {
+		fmt.Fprintf(errOut, "Error: unknown provider %q\n", *provider)
+		return ExitConfigError
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	defer f.Close()
+	streams, err := readStreamLog(f)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: invalid dump %s: %v\n", fs.Arg(0), err)
+		return ExitConfigError
+	}
+	if len(streams) == 0 {
+		fmt.Fprintf(errOut, "Error: %s has no recorded responses\n", fs.Arg(0))
+		return ExitConfigError
+	}
+
+	code := ExitSuccess
+	for i, s := range streams {
+		name := s.Provider
+		if *provider != "" {
+			name = *provider
+		}
+		label := fmt.Sprintf("stream %d (%s, %s)", i+1, name, s.URL)
+		if s.Status == 0 {
+			fmt.Fprintf(errOut, "- %s: request failed: %s\n", label, s.Error)
+			continue
+		}
+		if s.Status != http.StatusOK {
+			// The client reports these without parsing them
+			fmt.Fprintf(errOut, "- %s: status %d, not a stream: %s\n", label, s.Status, bytes.Join(s.Chunks, nil))
+			continue
+		}
+
+		p, _ := promptbuilder.LookupProvider(name)
+		parse := p.Parse
+		if parse == nil {
+			parse = parseSSEStream
+		}
+		tokens := 0
+		reply, err := parse(&chunkReader{s.Chunks}, func(token string) error {
+			tokens++
+			fmt.Fprint(out, token)
+			return nil
+		})
+		if tokens > 0 {
+			fmt.Fprintln(out)
+		}
+		if err != nil {
+			fmt.Fprintf(errOut, "✗ %s: %v\n", label, err)
+			code = ExitConfigError
+			continue
+		}
+		summary := fmt.Sprintf("%d chunks, %d tokens", len(s.Chunks), tokens)
+		if s.Error != "" {
+			summary += ", cut off: " + s.Error
+		}
+		if promptbuilder.IsComplete(reply) {
+			summary += ", prompt found"
+		} else {
+			summary += ", no prompt"
+		}
+		fmt.Fprintf(errOut, "✓ %s: %s\n", label, summary)
+	}
+	return code
+}
@@ -0,0 +1,118 @@
+// debugstream_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStreamLog_RecordsAndReplays(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\n", "Café ", "prompt\n```"})
+	defer server.Close()
+
+	var dump bytes.Buffer
+	cfg := &Config{Provider: providerCompatible, StreamLog: NewStreamLog(&dump, providerCompatible)}
+	client, err := newLLMClient(cfg, server.URL, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := client.ChatStream(context.Background(), nil, func(string) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams, err := readStreamLog(&dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 || streams[0].Provider != providerCompatible || streams[0].Status != http.StatusOK ||
+		!strings.HasSuffix(streams[0].URL, "/v1/chat/completions") {
+		t.Fatalf("streams = %+v, want one recorded chat response", streams)
+	}
+	got, err := parseSSEStream(&chunkReader{streams[0].Chunks}, func(string) error { return nil })
+	if err != nil || got != want {
+		t.Errorf("replayed reply = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestReadStreamLog_SplitCharacter(t *testing.T) {
+	// "é" split between two reads cannot be stored as a JSON string
+	event := []byte("data: {\"choices\":[{\"delta\":{\"content\":\"é\"}}]}\n\n")
+	cut := bytes.IndexByte(event, 0xc3) + 1
+	var dump bytes.Buffer
+	log := NewStreamLog(&dump, providerCompatible)
+	log.write(streamRecord{Stream: 1, Provider: providerCompatible, Status: 200})
+	for _, part := range [][]byte{event[:cut], event[cut:]} {
+		body := &recordingBody{ReadCloser: io.NopCloser(bytes.NewReader(part)), log: log, id: 1}
+		body.Read(make([]byte, len(part)))
+	}
+	if !strings.Contains(dump.String(), `"base64"`) {
+		t.Fatalf("dump = %s, want the split chunks as base64", dump.String())
+	}
+
+	streams, err := readStreamLog(&dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseSSEStream(&chunkReader{streams[0].Chunks}, func(string) error { return nil })
+	if err != nil || got != "é" {
+		t.Errorf("replayed reply = %q, %v, want é", got, err)
+	}
+}
+
+func writeDump(t *testing.T, records ...streamRecord) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		enc.Encode(r)
+	}
+	path := filepath.Join(t.TempDir(), "dump.ndjson")
+	os.WriteFile(path, buf.Bytes(), 0644)
+	return path
+}
+
+func TestRunReplayStream(t *testing.T) {
+	path := writeDump(t,
+		streamRecord{Stream: 1, Provider: providerAnthropic, URL: "https://api.anthropic.com/v1/messages", Status: 200},
+		streamRecord{Stream: 1, Data: "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"```\\nHi\\n```\"}}\n\n"},
+		streamRecord{Stream: 2, Provider: providerAnthropic, Status: 529, Data: `{"error": "overloaded"}`},
+	)
+	var out, errOut bytes.Buffer
+	if code := runReplayStream([]string{path}, &out, &errOut); code != ExitSuccess {
+		t.Fatalf("exit code = %d, stderr = %s", code, errOut.String())
+	}
+	if out.String() != "```\nHi\n```\n" {
+		t.Errorf("stdout = %q, want the replayed reply", out.String())
+	}
+	for _, want := range []string{"✓ stream 1 (anthropic", "1 chunks, 1 tokens, prompt found", "stream 2", "status 529", "overloaded"} {
+		if !strings.Contains(errOut.String(), want) {
+			t.Errorf("stderr missing %q:\n%s", want, errOut.String())
+		}
+	}
+}
+
+func TestRunReplayStream_ParseError(t *testing.T) {
+	path := writeDump(t,
+		streamRecord{Stream: 1, Provider: providerCompatible, Status: 200},
+		streamRecord{Stream: 1, Data: "data: {\"choices\": [\n\n"},
+	)
+	var out, errOut bytes.Buffer
+	if code := runReplayStream([]string{path}, &out, &errOut); code != ExitConfigError {
+		t.Errorf("exit code = %d, want %d", code, ExitConfigError)
+	}
+	if !strings.Contains(errOut.String(), "✗ stream 1") || !strings.Contains(errOut.String(), "failed to parse streaming chunk") {
+		t.Errorf("stderr = %q, want the parse error", errOut.String())
+	}
+
+	if code := runReplayStream([]string{"--provider", "bard", path}, &out, &errOut); code != ExitConfigError {
+		t.Errorf("unknown provider: exit code = %d, want %d", code, ExitConfigError)
+	}
+}
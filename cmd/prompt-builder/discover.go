@@ -0,0 +1,166 @@
+// discover.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostAuto is the config value for host that triggers discovery.
+const hostAuto = "auto"
+
+// wellKnownServer is a default port used by a popular local LLM server.
+type wellKnownServer struct {
+	Name string
+	Port int
+}
+
+var wellKnownServers = []wellKnownServer{
+	{"Ollama", 11434},
+	{"LM Studio", 1234},
+	{"llama.cpp", 8080},
+	{"vLLM", 8000},
+}
+
+// DiscoveredServer is an OpenAI-compatible server that answered a probe.
+type DiscoveredServer struct {
+	Name   string
+	Host   string
+	Models []string
+}
+
+type modelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// candidateHosts expands machine names into URLs for every well-known port.
+// Entries that already carry a scheme are used as-is.
+func candidateHosts(machines []string) []string {
+	var hosts []string
+	for _, m := range machines {
+		if strings.Contains(m, "://") {
+			hosts = append(hosts, strings.TrimRight(m, "/"))
+			continue
+		}
+		for _, s := range wellKnownServers {
+			hosts = append(hosts, fmt.Sprintf("http://%s:%d", m, s.Port))
+		}
+	}
+	return hosts
+}
+
+// serverName guesses the server software from the host's port.
+func serverName(host string) string {
+	for _, s := range wellKnownServers {
+		if strings.HasSuffix(host, fmt.Sprintf(":%d", s.Port)) {
+			return s.Name
+		}
+	}
+	return "OpenAI-compatible"
+}
+
+// probeServer checks whether host answers the OpenAI models endpoint.
+func probeServer(ctx context.Context, client *http.Client, host string) (*DiscoveredServer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, host+"/v1/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var models modelsResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&models); err != nil {
+		return nil, fmt.Errorf("not an OpenAI-compatible server: %w", err)
+	}
+
+	server := &DiscoveredServer{Name: serverName(host), Host: host}
+	for _, m := range models.Data {
+		server.Models = append(server.Models, m.ID)
+	}
+	return server, nil
+}
+
+// DiscoverServers probes hosts concurrently and returns the ones that
+// respond, in the order they were given.
+func DiscoverServers(ctx context.Context, hosts []string, timeout time.Duration) []DiscoveredServer {
+	client := &http.Client{Timeout: timeout}
+	results := make([]*DiscoveredServer, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if server, err := probeServer(ctx, client, host); err == nil {
+				results[i] = server
+			}
+		}()
+	}
+	wg.Wait()
+
+	var found []DiscoveredServer
+	for _, r := range results {
+		if r != nil {
+			found = append(found, *r)
+		}
+	}
+	return found
+}
+
+// resolveAutoHost returns the first server found on localhost, or else
+// the first one announced on the LAN over mDNS.
+func resolveAutoHost(ctx context.Context) (string, error) {
+	found := DiscoverServers(ctx, candidateHosts([]string{"localhost"}), 2*time.Second)
+	if len(found) == 0 {
+		found = DiscoverServers(ctx, browseMDNS(ctx, mdnsGroup, time.Second), 2*time.Second)
+	}
+	if len(found) == 0 {
+		return "", fmt.Errorf("host is 'auto' but no LLM server was found on localhost or announced on the LAN\n\nRun 'prompt-builder discover' to probe other machines")
+	}
+	return found[0].Host, nil
+}
+
+// runDiscover implements the discover subcommand. Without machines it
+// probes localhost and the servers announced on the LAN over mDNS.
+func runDiscover(ctx context.Context, args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+
+	hosts := candidateHosts(fs.Args())
+	if fs.NArg() == 0 {
+		hosts = append(candidateHosts([]string{"localhost"}), browseMDNS(ctx, mdnsGroup, time.Second)...)
+	}
+
+	found := DiscoverServers(ctx, hosts, 2*time.Second)
+	if len(found) == 0 {
+		fmt.Fprintln(out, "No LLM servers found.")
+		return ExitLLMError
+	}
+
+	for _, s := range found {
+		fmt.Fprintf(out, "%-12s %s\n", s.Name, s.Host)
+		for _, m := range s.Models {
+			fmt.Fprintf(out, "  %s\n", m)
+		}
+	}
+	return ExitSuccess
+}
@@ -0,0 +1,77 @@
+// discover_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeModelsServer(models ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		var ids []string
+		for _, m := range models {
+			ids = append(ids, fmt.Sprintf(`{"id":%q}`, m))
+		}
+		fmt.Fprintf(w, `{"data":[%s]}`, strings.Join(ids, ","))
+	}))
+}
+
+func TestCandidateHosts(t *testing.T) {
+	hosts := candidateHosts([]string{"gpu-box", "http://example.com:9000/"})
+
+	if len(hosts) != len(wellKnownServers)+1 {
+		t.Fatalf("got %d hosts, want %d", len(hosts), len(wellKnownServers)+1)
+	}
+	if hosts[0] != "http://gpu-box:11434" {
+		t.Errorf("hosts[0] = %q, want %q", hosts[0], "http://gpu-box:11434")
+	}
+	if hosts[len(hosts)-1] != "http://example.com:9000" {
+		t.Errorf("explicit URL = %q, want %q", hosts[len(hosts)-1], "http://example.com:9000")
+	}
+}
+
+func TestDiscoverServers(t *testing.T) {
+	server := fakeModelsServer("llama3.2", "mistral")
+	defer server.Close()
+
+	notLLM := httptest.NewServer(http.NotFoundHandler())
+	defer notLLM.Close()
+
+	found := DiscoverServers(context.Background(), []string{notLLM.URL, server.URL}, time.Second)
+
+	if len(found) != 1 {
+		t.Fatalf("found %d servers, want 1", len(found))
+	}
+	if found[0].Host != server.URL {
+		t.Errorf("Host = %q, want %q", found[0].Host, server.URL)
+	}
+	if len(found[0].Models) != 2 || found[0].Models[0] != "llama3.2" {
+		t.Errorf("Models = %v, want [llama3.2 mistral]", found[0].Models)
+	}
+}
+
+func TestRunDiscover_NoneFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	var out bytes.Buffer
+	code := runDiscover(context.Background(), []string{server.URL}, &out, io.Discard)
+
+	if code != ExitLLMError {
+		t.Errorf("exit code = %d, want %d", code, ExitLLMError)
+	}
+	if !strings.Contains(out.String(), "No LLM servers found") {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+}
@@ -0,0 +1,136 @@
+// doctor.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// runDoctor implements the doctor subcommand: it checks the config, the
+// server and its version, and the clipboard, and reports what it finds.
+func runDoctor(ctx context.Context, args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.SetOutput(out)
+	configPath := fs.String("config", "", "Path to config file (default: your config and the project's)")
+	profile := fs.String("profile", "", "Profile from the config file to check")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	files := configFiles()
+	if *configPath != "" {
+		files = []string{ExpandPath(*configPath)}
+	}
+
+	cfg, err := LoadFiles(files, *profile)
+	if err != nil {
+		fmt.Fprintf(out, "✗ config %s: %v\n", strings.Join(files, " + "), err)
+		return ExitConfigError
+	}
+	switch {
+	case len(files) == 0:
+		fmt.Fprintf(out, "- no config file; using the defaults (%s)\n", defaultConfigPath())
+	case cfg.Profile != "":
+		fmt.Fprintf(out, "✓ config %s (profile %s)\n", strings.Join(files, " + "), cfg.Profile)
+	default:
+		fmt.Fprintf(out, "✓ config %s\n", strings.Join(files, " + "))
+	}
+	for _, d := range cfg.Deprecations {
+		fmt.Fprintf(out, "  ! %s\n", d)
+	}
+	for _, k := range cfg.UnknownKeys {
+		fmt.Fprintf(out, "  ! %s; it is ignored\n", k)
+	}
+
+	code := ExitSuccess
+	if _, err := cfg.systemPrompt(); err != nil {
+		fmt.Fprintf(out, "✗ %v\n", err)
+		code = ExitConfigError
+	} else {
+		fmt.Fprintf(out, "✓ system prompt %s\n", cfg.systemPromptName())
+	}
+
+	if serverCode := doctorServer(ctx, cfg, out); code == ExitSuccess {
+		code = serverCode
+	}
+
+	if cmd := DetectClipboardCmd(cfg.ClipboardCmd); cmd != "" {
+		fmt.Fprintf(out, "✓ clipboard %s\n", cmd)
+	} else {
+		fmt.Fprintf(out, "! no clipboard command; /copy saves to %s\n", lastPromptPath())
+	}
+	return code
+}
+
+// doctorServer reports on the configured server and returns an exit code.
+func doctorServer(ctx context.Context, cfg *Config, out io.Writer) int {
+	if _, err := resolveAPIKey(cfg); err != nil {
+		fmt.Fprintf(out, "✗ api key: %v\n", err)
+		return ExitConfigError
+	}
+	if cfg.Provider != providerCompatible {
+		fmt.Fprintf(out, "✓ provider %s at %s\n", cfg.Provider, cfg.Host)
+		return ExitSuccess
+	}
+
+	host := cfg.Host
+	switch {
+	case cfg.SSHTunnel != "":
+		fmt.Fprintf(out, "- server not checked: ssh_tunnel %s is opened only for a session\n", cfg.SSHTunnel)
+		return ExitSuccess
+	case host == hostAuto:
+		var err error
+		if host, err = resolveAutoHost(ctx); err != nil {
+			fmt.Fprintf(out, "✗ host auto: %v\n", err)
+			return ExitLLMError
+		}
+	}
+
+	if cfg.pool == nil {
+		return doctorHost(ctx, newHTTPClient(cfg), host, out)
+	}
+	// Check each host of the pool itself, not whichever the pool picks
+	direct := *cfg
+	direct.pool = nil
+	code := ExitSuccess
+	for _, m := range cfg.pool.members {
+		if c := doctorHost(ctx, newHTTPClient(&direct), m.URL, out); c != ExitSuccess {
+			code = c
+		}
+	}
+	return code
+}
+
+// doctorHost reports on the server at host and returns an exit code.
+func doctorHost(ctx context.Context, hc *http.Client, host string, out io.Writer) int {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	info, err := DetectServer(ctx, hc, host)
+	if err != nil {
+		fmt.Fprintf(out, "✗ server %s: %v\n", host, err)
+		return ExitLLMError
+	}
+	if info.Version == "" {
+		fmt.Fprintf(out, "✓ server %s (not Ollama, or version unknown)\n", host)
+		return ExitSuccess
+	}
+
+	fmt.Fprintf(out, "✓ server %s (Ollama %s)\n", host, info.Version)
+	code := ExitSuccess
+	for _, f := range ollamaFeatures {
+		switch {
+		case info.Supports(f.Name):
+			fmt.Fprintf(out, "  ✓ %s\n", f.Name)
+		case f.Required:
+			fmt.Fprintf(out, "  ✗ %s (needs %s): %s\n", f.Name, f.Since, f.Missing)
+			code = ExitLLMError
+		default:
+			fmt.Fprintf(out, "  ! %s (needs %s): %s\n", f.Name, f.Since, f.Missing)
+		}
+	}
+	return code
+}
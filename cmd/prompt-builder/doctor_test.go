@@ -0,0 +1,116 @@
+// doctor_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDoctorConfig(t *testing.T, host string) string {
+	t.Helper()
+	dir := t.TempDir()
+	promptFile := filepath.Join(dir, "prompt.md")
+	configFile := filepath.Join(dir, "config.yaml")
+	os.WriteFile(promptFile, []byte("prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s\nclipboard_cmd: cat\n", host, promptFile)
+	if err := os.WriteFile(configFile, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return configFile
+}
+
+func TestRunDoctor(t *testing.T) {
+	server := fakeOllama("0.1.30")
+	defer server.Close()
+
+	var out bytes.Buffer
+	code := runDoctor(context.Background(), []string{"--config", writeDoctorConfig(t, server.URL)}, &out)
+	if code != ExitSuccess {
+		t.Errorf("runDoctor() = %d, want %d\n%s", code, ExitSuccess, out.String())
+	}
+	for _, want := range []string{"✓ config", "✓ system prompt", "Ollama 0.1.30", "✓ " + featureChatAPI, "! " + featureRunningModels, "✓ clipboard cat"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunDoctor_OutdatedServer(t *testing.T) {
+	server := fakeOllama("0.1.20")
+	defer server.Close()
+
+	var out bytes.Buffer
+	code := runDoctor(context.Background(), []string{"--config", writeDoctorConfig(t, server.URL)}, &out)
+	if code != ExitLLMError || !strings.Contains(out.String(), "✗ "+featureChatAPI) {
+		t.Errorf("runDoctor() = %d, want %d with chat API missing\n%s", code, ExitLLMError, out.String())
+	}
+}
+
+func TestRunDoctor_BuiltinSystemPrompt(t *testing.T) {
+	server := fakeOllama("0.1.30")
+	defer server.Close()
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configFile, []byte(fmt.Sprintf("model: m\nhost: %s\nclipboard_cmd: cat\n", server.URL)), 0644)
+
+	var out bytes.Buffer
+	code := runDoctor(context.Background(), []string{"--config", configFile}, &out)
+	if code != ExitSuccess || !strings.Contains(out.String(), "✓ system prompt "+builtinSystemPromptName) {
+		t.Errorf("runDoctor() = %d, want success with the built-in prompt\n%s", code, out.String())
+	}
+}
+
+func TestRunDoctor_Profile(t *testing.T) {
+	server := fakeOllama("0.1.30")
+	defer server.Close()
+
+	// The top-level host is unreachable; the profile's is not
+	configFile := writeDoctorConfig(t, "http://127.0.0.1:1")
+	f, _ := os.OpenFile(configFile, os.O_APPEND|os.O_WRONLY, 0)
+	fmt.Fprintf(f, "profiles:\n  local:\n    host: %s\n", server.URL)
+	f.Close()
+
+	var out bytes.Buffer
+	code := runDoctor(context.Background(), []string{"--config", configFile, "--profile", "local"}, &out)
+	if code != ExitSuccess || !strings.Contains(out.String(), "(profile local)") {
+		t.Errorf("runDoctor() = %d, want %d checking the profile\n%s", code, ExitSuccess, out.String())
+	}
+}
+
+func TestRunDoctor_HostPool(t *testing.T) {
+	server := fakeOllama("0.1.30")
+	defer server.Close()
+
+	dir := t.TempDir()
+	promptFile := filepath.Join(dir, "prompt.md")
+	configFile := filepath.Join(dir, "config.yaml")
+	os.WriteFile(promptFile, []byte("prompt"), 0644)
+	config := fmt.Sprintf("model: m\nsystem_prompt_file: %s\nhosts:\n  - url: %s\n  - url: http://127.0.0.1:1\n", promptFile, server.URL)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	var out bytes.Buffer
+	code := runDoctor(context.Background(), []string{"--config", configFile}, &out)
+	if code != ExitLLMError || !strings.Contains(out.String(), "✓ server "+server.URL) || !strings.Contains(out.String(), "✗ server http://127.0.0.1:1") {
+		t.Errorf("runDoctor() = %d, want each pool host checked\n%s", code, out.String())
+	}
+}
+
+func TestRunDoctor_Unreachable(t *testing.T) {
+	var out bytes.Buffer
+	code := runDoctor(context.Background(), []string{"--config", writeDoctorConfig(t, "http://127.0.0.1:1")}, &out)
+	if code != ExitLLMError || !strings.Contains(out.String(), "✗ server") {
+		t.Errorf("runDoctor() = %d, want %d\n%s", code, ExitLLMError, out.String())
+	}
+}
+
+func TestRunDoctor_BadConfig(t *testing.T) {
+	var out bytes.Buffer
+	code := runDoctor(context.Background(), []string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}, &out)
+	if code != ExitConfigError || !strings.Contains(out.String(), "✗ config") {
+		t.Errorf("runDoctor() = %d, want %d\n%s", code, ExitConfigError, out.String())
+	}
+}
@@ -4,14 +4,19 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var testBinary string
@@ -25,6 +30,8 @@ func TestMain(m *testing.M) {
 	}
 
 	testBinary = filepath.Join(tmp, "prompt-builder")
+	// Keep the runs' caches, such as recent replies, out of the user's
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(tmp, "cache"))
 
 	cmd := exec.Command("go", "build", "-o", testBinary, ".")
 	cmd.Dir = "."
@@ -264,3 +271,820 @@ func TestE2E_CustomConfig(t *testing.T) {
 		t.Error("expected some output with custom config")
 	}
 }
+
+func TestE2E_Delimiter(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\nprompt\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: test\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--delimiter", `\x1e`, "test idea")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, output)
+	}
+
+	if !strings.HasSuffix(string(output), "```\n\x1e\n") {
+		t.Errorf("expected response followed by record separator, got: %q", output)
+	}
+}
+
+func TestE2E_Silent(t *testing.T) {
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	// Nothing listens here, so the run fails and must still print nothing
+	config := fmt.Sprintf("model: test\nhost: http://127.0.0.1:1\nsystem_prompt_file: %s", promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--silent", "test idea")
+	output, err := cmd.CombinedOutput()
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != ExitLLMError {
+		t.Errorf("expected exit code %d, got: %v", ExitLLMError, err)
+	}
+	if len(output) != 0 {
+		t.Errorf("expected no output with --silent, got: %q", output)
+	}
+}
+
+func TestE2E_Strict(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\nprompt\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: test\nollama_host: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	// Without --strict the legacy key only warns
+	cmd := exec.Command(testBinary, "--config", configFile, "-q", "test idea")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if output, err := cmd.Output(); err != nil || !strings.Contains(string(output), "prompt") {
+		t.Fatalf("command failed: %v\n%s", err, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "ollama_host is deprecated") {
+		t.Errorf("expected deprecation warning, got: %q", stderr.String())
+	}
+
+	cmd = exec.Command(testBinary, "--config", configFile, "-q", "--strict", "test idea")
+	output, err := cmd.CombinedOutput()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != ExitDeprecatedConfig {
+		t.Errorf("expected exit code %d, got: %v\n%s", ExitDeprecatedConfig, err, output)
+	}
+}
+
+func TestE2E_QR(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\nprompt\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: test\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "-q", "--qr", "test idea")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, stderr.String())
+	}
+
+	if string(output) != "prompt\n\n" {
+		t.Errorf("expected only the prompt on stdout, got: %q", output)
+	}
+	if !strings.Contains(stderr.String(), "▀") {
+		t.Errorf("expected QR code on stderr, got: %q", stderr.String())
+	}
+}
+
+func TestE2E_Save(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\nReview the Go code.\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	saveDir := filepath.Join(tmpDir, "prompts")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: test\nhost: %s\nsystem_prompt_file: %s\nsave:\n  dir: %s\n  categories:\n    coding:\n      file: \"coding/{{.Slug}}.md\"\n",
+		server.URL, promptFile, saveDir)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "-q", "--save", "a code reviewer")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, output)
+	}
+	data, err := os.ReadFile(filepath.Join(saveDir, "coding", "a-code-reviewer.md"))
+	if err != nil || string(data) != "Review the Go code.\n" {
+		t.Errorf("saved prompt = %q, %v", data, err)
+	}
+}
+
+func TestE2E_Extract(t *testing.T) {
+	cmd := exec.Command(testBinary, "extract", "--block", "lang=markdown")
+	cmd.Stdin = strings.NewReader("Draft:\n```markdown\n# Role\n```\nAnything else?\n```\nnot this\n```\n")
+	output, err := cmd.Output()
+	if err != nil || string(output) != "# Role\n" {
+		t.Errorf("extract = %q, %v, want the markdown block", output, err)
+	}
+
+	cmd = exec.Command(testBinary, "extract")
+	cmd.Stdin = strings.NewReader("No code here.")
+	if err := cmd.Run(); err == nil {
+		t.Error("expected a non-zero exit without a code block")
+	}
+}
+
+func TestE2E_Compare(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\nprompt\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("host: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--compare", "llama3.2,mistral", "test idea")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "## llama3.2\n\n```\nprompt\n```") || !strings.Contains(string(output), "## mistral\n") {
+		t.Errorf("expected a section per model, got:\n%s", output)
+	}
+
+	cmd = exec.Command(testBinary, "--config", configFile, "--compare", "a,b", "--resume", "x", "test idea")
+	if err := cmd.Run(); err == nil {
+		t.Error("expected --compare with --resume to fail")
+	}
+}
+
+func TestE2E_DetectComplete(t *testing.T) {
+	for input, want := range map[string]int{
+		"```\nprompt\n```\n":  0,
+		"What is the goal?\n": 1,
+	} {
+		cmd := exec.Command(testBinary, "detect-complete")
+		cmd.Stdin = strings.NewReader(input)
+		err := cmd.Run()
+		code := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Errorf("detect-complete %q exited %d, want %d", input, code, want)
+		}
+	}
+}
+
+func TestE2E_Refine(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\nstricter prompt\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	existing := filepath.Join(tmpDir, "existing.md")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	os.WriteFile(existing, []byte("# Role\nYou cite sources.\n"), 0644)
+	config := fmt.Sprintf("model: test\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "-q", "refine", existing, "make it stricter about citations")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "stricter prompt") {
+		t.Errorf("expected the revised prompt, got: %s", output)
+	}
+
+	cmd = exec.Command(testBinary, "--config", configFile, "-q", "refine", filepath.Join(tmpDir, "missing.md"), "x")
+	if err := cmd.Run(); err == nil {
+		t.Error("expected an error for a missing prompt file")
+	}
+}
+
+func TestE2E_Reverse(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", "```\nderived prompt\n```")
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	example := filepath.Join(tmpDir, "release-notes.md")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	os.WriteFile(example, []byte("## v1.2\n- Faster startup\n"), 0644)
+	config := fmt.Sprintf("model: test\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "-q", "reverse", "--examples", example)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "derived prompt") {
+		t.Errorf("expected the derived prompt, got: %s", output)
+	}
+	if !strings.Contains(received, "Faster startup") || !strings.Contains(received, "release-notes.md") {
+		t.Errorf("request did not include the example: %s", received)
+	}
+}
+
+func TestE2E_Models(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama3.2","size":2019393189}]}`)
+		case "/api/ps":
+			fmt.Fprint(w, `{"models":[{"name":"llama3.2"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	os.WriteFile(configFile, []byte(fmt.Sprintf("model: llama3.2\nhost: %s\n", server.URL)), 0644)
+
+	output, err := exec.Command(testBinary, "models", "--config", configFile).Output()
+	if err != nil {
+		t.Fatalf("models failed: %v", err)
+	}
+	for _, want := range []string{"* llama3.2", "2.0 GB", "yes"} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestE2E_Split(t *testing.T) {
+	server := fakeStreamingServer([]string{"```system\nYou triage tickets.\n```\n\n```user\nTicket: {{ticket}}\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--split", "-q", "test idea")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	want := "{\n  \"system\": \"You triage tickets.\",\n  \"user\": \"Ticket: {{ticket}}\"\n}\n"
+	if string(output) != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+}
+
+func TestE2E_Messages(t *testing.T) {
+	var received struct {
+		Messages []Message `json:"messages"`
+	}
+	reply := fakeStreamingServer([]string{"```\nReview Go code.\n```"})
+	defer reply.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		reply.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--messages", "-", "keep it short")
+	cmd.Stdin = strings.NewReader(`[{"role": "user", "content": "a code reviewer"}, {"role": "assistant", "content": "Which language?"}, {"role": "user", "content": "Go"}]`)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "Review Go code." {
+		t.Errorf("output = %q, want only the next draft", output)
+	}
+	if n := len(received.Messages); n != 5 || received.Messages[0].Content != "Test prompt" || received.Messages[4].Content != "keep it short" {
+		t.Errorf("sent messages = %+v, want the system prompt, the conversation and the idea", received.Messages)
+	}
+}
+
+func TestE2E_IdeaFile(t *testing.T) {
+	var received struct {
+		Messages []Message `json:"messages"`
+	}
+	reply := fakeStreamingServer([]string{"```\nReview Go code.\n```"})
+	defer reply.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		reply.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	ideaFile := filepath.Join(tmpDir, "spec.md")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+	idea := "A reviewer for Go code.\n\nIt checks \"errors\" and naming."
+	os.WriteFile(ideaFile, []byte(idea+"\n"), 0644)
+
+	for name, cmd := range map[string]*exec.Cmd{
+		"file":  exec.Command(testBinary, "--config", configFile, "-q", "--idea-file", ideaFile),
+		"stdin": exec.Command(testBinary, "--config", configFile, "-"),
+	} {
+		cmd.Stdin = strings.NewReader(idea)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s: command failed: %v", name, err)
+		}
+		if strings.TrimSpace(string(output)) != "Review Go code." {
+			t.Errorf("%s: output = %q, want only the prompt", name, output)
+		}
+		if n := len(received.Messages); n != 2 || !strings.HasSuffix(received.Messages[1].Content, idea) {
+			t.Errorf("%s: sent messages = %+v, want the idea", name, received.Messages)
+		}
+	}
+
+	output, err := exec.Command(testBinary, "--config", configFile, "--idea-file", ideaFile, "another idea").CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "--idea-file cannot be combined with an idea argument") {
+		t.Errorf("idea file and argument: err = %v, output = %s", err, output)
+	}
+}
+
+func TestE2E_Chain(t *testing.T) {
+	server := fakeStreamingServer([]string{"```step extract\nFacts in {{input}}\n```\n\n```step summarize\nSummarize {{extract}}\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	chainDir := filepath.Join(tmpDir, "chain")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--chain", chainDir, "-q", "test idea")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, output)
+	}
+	for _, name := range []string{"01-extract.md", "02-summarize.md", "manifest.yaml"} {
+		if _, err := os.Stat(filepath.Join(chainDir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+}
+
+func TestE2E_Tools(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\nCall `search_docs`, never `drop_table`.\n```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	toolsFile := filepath.Join(tmpDir, "tools.json")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+	os.WriteFile(toolsFile, []byte(`[{"name": "search_docs", "input_schema": {"properties": {"query": {}}}}]`), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--tools", toolsFile, "-q", "test idea")
+	var errOut strings.Builder
+	cmd.Stderr = &errOut
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, errOut.String())
+	}
+	if !strings.Contains(string(output), "search_docs") || !strings.Contains(errOut.String(), `"drop_table"`) {
+		t.Errorf("stdout = %q, stderr = %q, want the prompt and a warning about drop_table", output, errOut.String())
+	}
+
+	os.WriteFile(toolsFile, []byte(`[]`), 0644)
+	if err := exec.Command(testBinary, "--config", configFile, "--tools", toolsFile, "-q", "test idea").Run(); err == nil {
+		t.Error("expected an empty tools file to fail")
+	}
+}
+
+func TestE2E_Fit(t *testing.T) {
+	replies := []string{"```\n" + strings.Repeat("Always answer politely and briefly. ", 10) + "\n```", "```\nAnswer politely.\n```"}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		reply := replies[min(requests, len(replies)-1)]
+		requests++
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", reply)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s\nfit_slots:\n  system: 20", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--fit", "gpt-4o-mini:system", "-q", "test idea")
+	var errOut strings.Builder
+	cmd.Stderr = &errOut
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, errOut.String())
+	}
+	if strings.TrimSpace(string(output)) != "Answer politely." || requests != 2 {
+		t.Errorf("stdout = %q after %d requests, want the shortened prompt after 2", output, requests)
+	}
+	if !strings.Contains(errOut.String(), "The prompt fits") {
+		t.Errorf("stderr = %q, want confirmation that the prompt fits", errOut.String())
+	}
+
+	if err := exec.Command(testBinary, "--config", configFile, "--fit", "llama3:system", "-q", "test idea").Run(); err == nil {
+		t.Error("expected a model without a tokenizer to fail")
+	}
+}
+
+func TestE2E_Prefill(t *testing.T) {
+	var received ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", "\nprefilled prompt\n```")
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s\nprefill: \"```\\n\"", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "-q", "test idea")
+	var errOut strings.Builder
+	cmd.Stderr = &errOut
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, errOut.String())
+	}
+	if strings.TrimSpace(string(output)) != "prefilled prompt" {
+		t.Errorf("stdout = %q, want the prompt inside the prefilled fence", output)
+	}
+	if last := received.Messages[len(received.Messages)-1]; last.Role != "assistant" || last.Content != "```" {
+		t.Errorf("last message sent = %+v, want the prefill", last)
+	}
+}
+
+func TestE2E_Headers(t *testing.T) {
+	var tenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		tenant = r.Header.Get("X-Tenant")
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", "```\nprompt\n```")
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s\nheaders:\n  X-Tenant: research", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	if out, err := exec.Command(testBinary, "--config", configFile, "-q", "test idea").CombinedOutput(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	if tenant != "research" {
+		t.Errorf("X-Tenant = %q, want the configured header", tenant)
+	}
+}
+
+func TestE2E_ModelAlias(t *testing.T) {
+	var model string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		model = req.Model
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", "```\nprompt\n```")
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s\naliases:\n  fast: llama3.2:3b-instruct-q4", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	if out, err := exec.Command(testBinary, "--config", configFile, "--model", "fast", "-q", "test idea").CombinedOutput(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	if model != "llama3.2:3b-instruct-q4" {
+		t.Errorf("model = %q, want the model the alias names", model)
+	}
+}
+
+func TestE2E_Persona(t *testing.T) {
+	var req struct {
+		Model       string   `json:"model"`
+		Temperature *float64 `json:"temperature"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", "```\nreview the diff\n```")
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s\npersonas:\n  coding:\n    model: coder\n    temperature: 0.2\n    post_process: [\"tr a-z A-Z\"]", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	out, err := exec.Command(testBinary, "--config", configFile, "--persona", "coding", "-q", "test idea").Output()
+	if err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	if req.Model != "coder" || req.Temperature == nil || *req.Temperature != 0.2 {
+		t.Errorf("request model = %q, temperature = %v, want the persona's", req.Model, req.Temperature)
+	}
+	if strings.TrimSpace(string(out)) != "REVIEW THE DIFF" {
+		t.Errorf("stdout = %q, want the post-processed prompt", out)
+	}
+
+	if out, err := exec.Command(testBinary, "--config", configFile, "--persona", "legal", "-q", "test idea").CombinedOutput(); err == nil || !strings.Contains(string(out), "unknown persona") {
+		t.Errorf("unknown persona: err = %v, output = %s", err, out)
+	}
+}
+
+func TestE2E_Deadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Let me think\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	start := time.Now()
+	output, err := exec.Command(testBinary, "--config", configFile, "--deadline", "300ms", "-q", "test idea").CombinedOutput()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 7 {
+		t.Fatalf("err = %v, want exit code 7\nOutput: %s", err, output)
+	}
+	if !strings.Contains(string(output), "the 300ms deadline passed before the model wrote a prompt") {
+		t.Errorf("output = %s, want the deadline notice", output)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run took %s, want it cut off near the deadline", elapsed)
+	}
+}
+
+func TestE2E_DebugStream(t *testing.T) {
+	server := fakeStreamingServer([]string{"```\n", "recorded prompt\n", "```"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	dumpFile := filepath.Join(tmpDir, "dump.ndjson")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: m\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	if out, err := exec.Command(testBinary, "--config", configFile, "--debug-stream", dumpFile, "-q", "test idea").CombinedOutput(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+
+	cmd := exec.Command(testBinary, "replay-stream", dumpFile)
+	var errOut strings.Builder
+	cmd.Stderr = &errOut
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("replay-stream failed: %v\n%s", err, errOut.String())
+	}
+	if string(output) != "```\nrecorded prompt\n```\n" || !strings.Contains(errOut.String(), "prompt found") {
+		t.Errorf("stdout = %q, stderr = %q, want the recorded reply", output, errOut.String())
+	}
+}
+
+func TestE2E_ModelNotFoundHint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"message":"model \"llama3.2\" not found, try pulling it first","type":"api_error"}}`)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: llama3.2\nhost: %s\nsystem_prompt_file: %s", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "-q", "test idea")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != ExitLLMError {
+		t.Fatalf("err = %v, want exit code %d\n%s", err, ExitLLMError, out)
+	}
+	if !strings.Contains(string(out), "Run `ollama pull llama3.2`") {
+		t.Errorf("output = %q, want the pull hint", out)
+	}
+}
+
+func TestE2E_GenerationParams(t *testing.T) {
+	var received ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", "```\nprompt\n```")
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: test\nhost: %s\nsystem_prompt_file: %s\ntemperature: 0.2\nseed: 42\n", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "--temperature", "0.5", "-q", "test idea")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("command failed: %v\n%s", err, output)
+	}
+	if received.Temperature == nil || *received.Temperature != 0.5 || received.Seed == nil || *received.Seed != 42 {
+		t.Errorf("request params = %+v, want temperature 0.5 from the flag and seed 42 from config", received.GenerationParams)
+	}
+
+	cmd = exec.Command(testBinary, "--config", configFile, "--temperature", "5", "-q", "test idea")
+	if err := cmd.Run(); err == nil {
+		t.Error("expected --temperature 5 to fail")
+	}
+}
+
+func TestE2E_PoolFailover(t *testing.T) {
+	// Whichever host the pool picks first answers busy; the other answers
+	var mu sync.Mutex
+	busy := true
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		wasBusy := busy
+		busy = false
+		mu.Unlock()
+		if wasBusy {
+			http.Error(w, "loading model", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", "```\npooled prompt\n```")
+	})
+	gpu1, gpu2 := httptest.NewServer(handler), httptest.NewServer(handler)
+	defer gpu1.Close()
+	defer gpu2.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: test\nhosts:\n  - url: %s\n  - url: %s\nsystem_prompt_file: %s", gpu1.URL, gpu2.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	cmd := exec.Command(testBinary, "--config", configFile, "-q", "test idea")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if output, err := cmd.Output(); err != nil || !strings.Contains(string(output), "pooled prompt") {
+		t.Fatalf("command failed: %v\n%s", err, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "503") || !strings.Contains(stderr.String(), "sending the request to another host") {
+		t.Errorf("stderr = %q, want the failover warning", stderr.String())
+	}
+
+	mu.Lock()
+	busy = true
+	mu.Unlock()
+	output, err := exec.Command(testBinary, "--config", configFile, "-q", "--strict", "another idea").CombinedOutput()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != ExitFailover {
+		t.Errorf("err = %v, want exit code %d\n%s", err, ExitFailover, output)
+	}
+}
+
+func TestE2E_Clarification(t *testing.T) {
+	// The model only ever asks, so the reminders run out
+	server := fakeStreamingServer([]string{"Who is the prompt for?"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompt.txt")
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	os.WriteFile(promptFile, []byte("Test prompt"), 0644)
+	config := fmt.Sprintf("model: test\nhost: %s\nsystem_prompt_file: %s\nmax_nudges: 1", server.URL, promptFile)
+	os.WriteFile(configFile, []byte(config), 0644)
+
+	output, err := exec.Command(testBinary, "--config", configFile, "-q", "test idea").CombinedOutput()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != ExitClarification {
+		t.Fatalf("err = %v, want exit code %d\n%s", err, ExitClarification, output)
+	}
+	if !strings.Contains(string(output), "requested clarification") {
+		t.Errorf("output = %s, want the clarification error", output)
+	}
+}
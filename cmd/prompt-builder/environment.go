@@ -0,0 +1,104 @@
+// environment.go
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"runtime"
+	"strings"
+)
+
+// Environment records what a session ran with, so its prompt can be
+// reproduced and a bug report says what to set up: the tool, the
+// platform, the server, the model and the config.
+type Environment struct {
+	Version       string `json:"version"`
+	OS            string `json:"os"` // GOOS/GOARCH
+	Provider      string `json:"provider"`
+	ServerVersion string `json:"server_version,omitempty"` // Ollama's, when the server is Ollama
+	ModelDigest   string `json:"model_digest,omitempty"`   // as Ollama lists it
+	ConfigHash    string `json:"config_hash,omitempty"`    // of the config files' contents
+	Profile       string `json:"profile,omitempty"`
+}
+
+// String summarizes e on one line, for exports.
+func (e *Environment) String() string {
+	parts := []string{"prompt-builder " + e.Version, e.OS}
+	if e.ServerVersion != "" {
+		parts = append(parts, "Ollama "+e.ServerVersion)
+	} else {
+		parts = append(parts, e.Provider)
+	}
+	if e.ModelDigest != "" {
+		parts = append(parts, "model "+shortHash(e.ModelDigest))
+	}
+	if e.ConfigHash != "" {
+		parts = append(parts, "config "+e.ConfigHash)
+	}
+	if e.Profile != "" {
+		parts = append(parts, "profile "+e.Profile)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// shortHash returns the first 12 hex digits of a digest such as
+// "sha256:ab12...", which is enough to tell versions apart.
+func shortHash(digest string) string {
+	_, hash, ok := strings.Cut(digest, ":")
+	if !ok {
+		hash = digest
+	}
+	return hash[:min(len(hash), 12)]
+}
+
+// configHash identifies the contents of config layers, or is empty when
+// there are none.
+func configHash(layers []configLayer) string {
+	h := sha256.New()
+	n := 0
+	for _, l := range layers {
+		n += len(l.Data)
+		h.Write(l.Data)
+	}
+	if n == 0 {
+		return ""
+	}
+	return shortHash(hex.EncodeToString(h.Sum(nil)))
+}
+
+// captureEnvironment describes a run of model on host. server is what
+// DetectServer found; for Ollama, the model's digest is asked for too.
+func captureEnvironment(ctx context.Context, cfg *Config, host, model string, server ServerInfo) *Environment {
+	env := &Environment{
+		Version:       version,
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		Provider:      cfg.Provider,
+		ServerVersion: server.Version,
+		ConfigHash:    cfg.Hash,
+		Profile:       cfg.Profile,
+	}
+	if server.Version != "" {
+		env.ModelDigest = ollamaDigest(ctx, cfg, host, model)
+	}
+	return env
+}
+
+// ollamaDigest returns the digest an Ollama server at host lists for
+// model, or "" when it does not say.
+func ollamaDigest(ctx context.Context, cfg *Config, host, model string) string {
+	apiKey, err := resolveAPIKey(cfg)
+	if err != nil {
+		return ""
+	}
+	var tags ollamaModels
+	if ok, err := modelsGet(ctx, cfg, host, "/api/tags", apiKey, &tags); err != nil || !ok {
+		return ""
+	}
+	for _, m := range tags.Models {
+		if m.Name == model || m.Name == model+":latest" {
+			return m.Digest
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+// environment_test.go
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureEnvironment(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"models":[{"name":"mistral:latest","digest":"0123456789abcdef0123"},{"name":"llama3.2:latest","digest":"fedcba9876543210fedc"}]}`))
+	}))
+	defer srv.Close()
+
+	cfg, err := parseConfig([]byte("model: llama3.2\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := captureEnvironment(context.Background(), cfg, srv.URL, "llama3.2", ServerInfo{Version: "0.5.1"})
+	want := Environment{
+		Version:       version,
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		Provider:      providerCompatible,
+		ServerVersion: "0.5.1",
+		ModelDigest:   "fedcba9876543210fedc",
+		ConfigHash:    cfg.Hash,
+	}
+	if *env != want {
+		t.Errorf("captureEnvironment() = %+v, want %+v", *env, want)
+	}
+	if len(cfg.Hash) != 12 {
+		t.Errorf("config hash = %q, want 12 hex digits", cfg.Hash)
+	}
+	if got := env.String(); !strings.Contains(got, "Ollama 0.5.1, model fedcba987654, config "+cfg.Hash) {
+		t.Errorf("String() = %q", got)
+	}
+
+	// Not Ollama: no digest is asked for
+	env = captureEnvironment(context.Background(), cfg, srv.URL, "llama3.2", ServerInfo{})
+	if env.ModelDigest != "" || !strings.Contains(env.String(), providerCompatible) {
+		t.Errorf("without a server version: %+v", *env)
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	if got := configHash(nil); got != "" {
+		t.Errorf("no config: %q, want none", got)
+	}
+	a := configHash([]configLayer{{Data: []byte("model: a\n")}})
+	b := configHash([]configLayer{{Data: []byte("model: b\n")}})
+	if a == "" || a == b {
+		t.Errorf("hashes %q and %q, want them to differ", a, b)
+	}
+}
+
+func TestTranscript_Environment(t *testing.T) {
+	s := &Session{ID: "2026-10-16-1", Environment: &Environment{Version: "1.2.0", OS: "linux/amd64", Provider: providerAnthropic, ConfigHash: "abc123"}}
+	var b strings.Builder
+	if err := RenderHTML(&b, NewTranscript(s, nil, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "<dt>Environment</dt><dd>prompt-builder 1.2.0, linux/amd64, anthropic, config abc123</dd>") {
+		t.Errorf("export lacks the environment:\n%s", b.String())
+	}
+}
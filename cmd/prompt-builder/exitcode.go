@@ -0,0 +1,63 @@
+// exitcode.go
+package main
+
+import (
+	"errors"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// Kinds of failure that decide the exit code of a run. Errors are marked
+// with one by withKind, which leaves their message as it is. ErrLLM and
+// ErrClarificationNeeded are the library's, so its errors map the same way.
+var (
+	ErrConfig              = errors.New("configuration error")
+	ErrLLM                 = promptbuilder.ErrLLM
+	ErrNoModel             = errors.New("no model specified")
+	ErrNoClipboard         = errors.New("no clipboard available")
+	ErrClarificationNeeded = promptbuilder.ErrClarificationNeeded
+)
+
+// kindError is an error marked with its kind of failure.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// withKind marks err as a failure of kind, one of the Err values above,
+// so errors.Is(err, kind) holds. A nil err stays nil.
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind, err}
+}
+
+// exitCode returns the exit status for the error a run failed with.
+// Errors of no known kind exit with ExitFailure, so ExitConfigError
+// means the config really is at fault.
+func exitCode(err error) int {
+	var strict *strictError
+	var deadline *deadlineError
+	var llmErr *LLMError
+	switch {
+	case errors.As(err, &strict):
+		return strict.Code
+	case errors.As(err, &deadline):
+		return ExitDeadline
+	case errors.Is(err, ErrClarificationNeeded):
+		return ExitClarification
+	case errors.As(err, &llmErr), errors.Is(err, ErrLLM):
+		return ExitLLMError
+	case errors.Is(err, ErrNoModel):
+		return ExitNoModel
+	case errors.Is(err, ErrNoClipboard):
+		return ExitNoClipboard
+	case errors.Is(err, ErrConfig):
+		return ExitConfigError
+	}
+	return ExitFailure
+}
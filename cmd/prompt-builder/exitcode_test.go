@@ -0,0 +1,65 @@
+// exitcode_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		// The message mentions a config file, which the old string matching
+		// took for a configuration error
+		{"llm", withKind(ErrLLM, fmt.Errorf("reading config from the server: connection refused")), ExitLLMError},
+		{"llm error type", fmt.Errorf("request: %w", &LLMError{Kind: errUnreachable}), ExitLLMError},
+		{"wrapped kind", fmt.Errorf("model a: %w", withKind(ErrLLM, errors.New("timeout"))), ExitLLMError},
+		{"no model", withKind(ErrNoModel, errors.New("no model specified")), ExitNoModel},
+		{"clarification", withKind(ErrClarificationNeeded, errors.New("LLM requested clarification")), ExitClarification},
+		{"config", withKind(ErrConfig, errors.New("LLM section missing")), ExitConfigError},
+		{"strict", &strictError{ExitNoClipboard, "no clipboard"}, ExitNoClipboard},
+		{"deadline", &deadlineError{}, ExitDeadline},
+		{"no clipboard", withKind(ErrNoClipboard, errors.New("clipboard not available")), ExitNoClipboard},
+		{"unknown", errors.New("something else"), ExitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWithKind(t *testing.T) {
+	err := withKind(ErrLLM, errors.New("connection refused"))
+	if err.Error() != "connection refused" {
+		t.Errorf("Error() = %q, want the message unchanged", err.Error())
+	}
+	if !errors.Is(err, ErrLLM) || errors.Is(err, ErrConfig) {
+		t.Errorf("errors.Is does not match the kind")
+	}
+	if withKind(ErrLLM, nil) != nil {
+		t.Errorf("withKind(nil) should stay nil")
+	}
+}
+
+func TestRun_LLMFailureExitCode(t *testing.T) {
+	deps := newTestDeps(withLLMError(errors.New("connection refused")))
+	err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps)
+	if code := exitCode(err); code != ExitLLMError {
+		t.Errorf("exitCode(%v) = %d, want %d", err, code, ExitLLMError)
+	}
+}
+
+func TestRun_ClipboardFailureExitCode(t *testing.T) {
+	deps := newTestDeps(withResponses("```\nfinal\n```"))
+	deps.Clipboard = &mockClipboard{err: errors.New("wl-copy failed: no display")}
+	err := runWithDeps(context.Background(), &CLI{Idea: "test idea", Quiet: QuietClipboard}, deps)
+	if code := exitCode(err); code != ExitNoClipboard {
+		t.Errorf("exitCode(%v) = %d, want %d", err, code, ExitNoClipboard)
+	}
+}
@@ -0,0 +1,293 @@
+// experiment.go
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// ExperimentConfig compares system prompts (experiment in the config):
+// runs take the variants in turn, and each completed one is recorded so
+// experiments report can compare them.
+type ExperimentConfig struct {
+	Name     string    `yaml:"name"`
+	Variants []Variant `yaml:"variants"`
+}
+
+// Variant is one system prompt under test.
+type Variant struct {
+	Name             string `yaml:"name"`
+	SystemPromptFile string `yaml:"system_prompt_file"` // empty for the built-in one
+}
+
+// ExperimentTag records which variant of which experiment a session ran.
+type ExperimentTag struct {
+	Name    string `json:"name"`
+	Variant string `json:"variant"`
+}
+
+func (t *ExperimentTag) String() string {
+	return t.Name + "/" + t.Variant
+}
+
+// validate checks the experiment config.
+func (c ExperimentConfig) validate() error {
+	if c.Name == "" && len(c.Variants) == 0 {
+		return nil
+	}
+	if !validPromptName.MatchString(c.Name) {
+		return fmt.Errorf("experiment.name must be letters, digits, '.', '_' or '-', got %q", c.Name)
+	}
+	if len(c.Variants) < 2 {
+		return fmt.Errorf("experiment.variants needs at least two system prompts to compare")
+	}
+	seen := make(map[string]bool)
+	for i, v := range c.Variants {
+		if v.Name == "" || strings.ContainsAny(v.Name, " \t/") {
+			return fmt.Errorf("experiment.variants: entry %d needs a name without spaces or slashes", i+1)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("experiment.variants: %s is listed twice", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
+
+// variant returns the variant tag names, if tag belongs to c.
+func (c ExperimentConfig) variant(tag *ExperimentTag) (Variant, bool) {
+	if tag == nil || tag.Name != c.Name {
+		return Variant{}, false
+	}
+	i := slices.IndexFunc(c.Variants, func(v Variant) bool { return v.Name == tag.Variant })
+	if i == -1 {
+		return Variant{}, false
+	}
+	return c.Variants[i], true
+}
+
+// experimentsDir holds each experiment's turn counter and results.
+func experimentsDir() string {
+	return filepath.Join(dataDir(), "experiments")
+}
+
+// nextVariant returns the variant of c whose turn it is, and moves the
+// turn on to the next one. The turn is kept in dir, so variants alternate
+// across runs.
+func nextVariant(dir string, c ExperimentConfig) (Variant, error) {
+	path := filepath.Join(dir, c.Name+".next")
+	n := 0
+	if data, err := os.ReadFile(path); err == nil {
+		n, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Variant{}, err
+	}
+	if n < 0 {
+		n = 0
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Variant{}, err
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(n+1)+"\n"), 0o600); err != nil {
+		return Variant{}, err
+	}
+	return c.Variants[n%len(c.Variants)], nil
+}
+
+// lintSections are the R.G.C.O.A. parts the built-in system prompt asks
+// for, as patterns for their headings.
+var lintSections = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"role", regexp.MustCompile(`(?im)^#+\s*role\b`)},
+	{"goal", regexp.MustCompile(`(?im)^#+\s*goal\b`)},
+	{"context", regexp.MustCompile(`(?im)^#+\s*context\b`)},
+	{"output", regexp.MustCompile(`(?im)^#+\s*output\b`)},
+	{"audience", regexp.MustCompile(`(?im)^#+\s*audience\b`)},
+}
+
+// lintPlaceholder matches text a model leaves for the user to fill in.
+var lintPlaceholder = regexp.MustCompile(`(?i)\bTODO\b|\bTBD\b|[\[<]\s*insert\b|lorem ipsum`)
+
+// lintPrompt checks prompt for the R.G.C.O.A. sections and for leftover
+// placeholders. It returns the problems found and the share of checks
+// passed as a percentage.
+func lintPrompt(prompt string) (problems []string, score int) {
+	checks := len(lintSections) + 1
+	for _, s := range lintSections {
+		if !s.pattern.MatchString(prompt) {
+			problems = append(problems, "no "+s.name+" section")
+		}
+	}
+	if m := lintPlaceholder.FindString(prompt); m != "" {
+		problems = append(problems, fmt.Sprintf("placeholder left in: %q", m))
+	}
+	return problems, 100 * (checks - len(problems)) / checks
+}
+
+// experimentResult is one completed run, a line of an experiment's
+// results file.
+type experimentResult struct {
+	Variant     string    `json:"variant"`
+	SessionID   string    `json:"session_id,omitempty"`
+	Turns       int       `json:"turns"`
+	Lint        int       `json:"lint"` // percentage of lint checks passed
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// recordExperiment appends the result of tab's session to its
+// experiment's results in dir, if it ran one and produced a prompt.
+func recordExperiment(dir string, tab *Tab, now time.Time) error {
+	prompt := promptbuilder.ExtractLastCodeBlock(tab.Response)
+	if tab.Session == nil || tab.Session.Experiment == nil || prompt == "" {
+		return nil
+	}
+	_, score := lintPrompt(prompt)
+	line, err := json.Marshal(experimentResult{
+		Variant:     tab.Session.Experiment.Variant,
+		SessionID:   tab.Session.ID,
+		Turns:       draftCount(tab.Conv.Messages),
+		Lint:        score,
+		CompletedAt: now.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, tab.Session.Experiment.Name+".jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// variantStats summarizes the results of one variant.
+type variantStats struct {
+	Name  string
+	Runs  int
+	Turns int // total, for the average
+	Lint  int // total, for the average
+}
+
+// readExperiment returns the per-variant results of experiment name in
+// dir, in the order the config lists variants, then any others by name.
+func readExperiment(dir, name string, variants []Variant) ([]variantStats, error) {
+	f, err := os.Open(filepath.Join(dir, name+".jsonl"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no results for experiment %s yet", name)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var stats []variantStats
+	for _, v := range variants {
+		stats = append(stats, variantStats{Name: v.Name})
+	}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		var r experimentResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", f.Name(), n, err)
+		}
+		i := slices.IndexFunc(stats, func(s variantStats) bool { return s.Name == r.Variant })
+		if i == -1 {
+			stats = append(stats, variantStats{Name: r.Variant})
+			i = len(stats) - 1
+		}
+		stats[i].Runs++
+		stats[i].Turns += r.Turns
+		stats[i].Lint += r.Lint
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(stats[len(variants):], func(a, b variantStats) int { return cmp.Compare(a.Name, b.Name) })
+	return stats, nil
+}
+
+// printExperiment writes stats as a table.
+func printExperiment(out io.Writer, name string, stats []variantStats) {
+	runs, width := 0, len("VARIANT")
+	for _, s := range stats {
+		runs += s.Runs
+		width = max(width, len(s.Name))
+	}
+	fmt.Fprintf(out, "Experiment %s: %d runs\n", name, runs)
+	fmt.Fprintf(out, "  %-*s  %4s  %9s  %8s\n", width, "VARIANT", "RUNS", "AVG TURNS", "AVG LINT")
+	for _, s := range stats {
+		if s.Runs == 0 {
+			fmt.Fprintf(out, "  %-*s  %4d  %9s  %8s\n", width, s.Name, 0, "-", "-")
+			continue
+		}
+		fmt.Fprintf(out, "  %-*s  %4d  %9.1f  %7.0f%%\n", width, s.Name, s.Runs,
+			float64(s.Turns)/float64(s.Runs), float64(s.Lint)/float64(s.Runs))
+	}
+}
+
+// runExperiments implements the experiments subcommand.
+func runExperiments(args []string, out, errOut io.Writer) int {
+	if len(args) == 0 || args[0] != "report" {
+		fmt.Fprintln(errOut, "Usage: prompt-builder experiments report [name] [--config path]")
+		return ExitConfigError
+	}
+	fs := flag.NewFlagSet("experiments report", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	configPath := fs.String("config", "", "Path to config file")
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil || len(positional) > 1 {
+		return ExitConfigError
+	}
+
+	cfg, err := loadRunConfig(*configPath, "")
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: invalid config: %v\n", err)
+		return ExitConfigError
+	}
+	name := cfg.Experiment.Name
+	if len(positional) == 1 {
+		name = positional[0]
+	}
+	if name == "" {
+		fmt.Fprintln(errOut, "Error: no experiment named; give one or set experiment.name in config")
+		return ExitConfigError
+	}
+	if !validPromptName.MatchString(name) {
+		fmt.Fprintf(errOut, "Error: invalid experiment name %q\n", name)
+		return ExitConfigError
+	}
+	var variants []Variant
+	if name == cfg.Experiment.Name {
+		variants = cfg.Experiment.Variants
+	}
+
+	stats, err := readExperiment(experimentsDir(), name, variants)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	printExperiment(out, name, stats)
+	return ExitSuccess
+}
@@ -0,0 +1,119 @@
+// experiment_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExperimentConfig_Validate(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"experiment:\n  name: terse\n  variants:\n    - name: a\n    - name: b\n      system_prompt_file: terse.md\n", ""},
+		{"experiment:\n  name: terse\n  variants:\n    - name: a\n", "at least two"},
+		{"experiment:\n  name: ../x\n  variants:\n    - name: a\n    - name: b\n", "experiment.name"},
+		{"experiment:\n  name: terse\n  variants:\n    - name: a\n    - name: a\n", "a is listed twice"},
+		{"experiment:\n  name: terse\n  variants:\n    - name: a\n    - system_prompt_file: b.md\n", "entry 2 needs a name"},
+	}
+	for _, tt := range tests {
+		_, err := parseConfig([]byte(tt.config), "")
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("parseConfig(%q) error = %v, want %q", tt.config, err, tt.want)
+		}
+	}
+}
+
+func TestNextVariant_Alternates(t *testing.T) {
+	dir := t.TempDir()
+	exp := ExperimentConfig{Name: "terse", Variants: []Variant{{Name: "a"}, {Name: "b", SystemPromptFile: "terse.md"}}}
+	var got []string
+	for range 5 {
+		v, err := nextVariant(dir, exp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.Name)
+	}
+	if strings.Join(got, "") != "ababa" {
+		t.Errorf("variants = %v, want them in turn", got)
+	}
+}
+
+func TestLintPrompt(t *testing.T) {
+	full := "## Role\nYou are a tutor.\n## Goal\nTeach.\n## Context\nKids.\n## Output format\nShort.\n## Audience\nAge 10."
+	if problems, score := lintPrompt(full); len(problems) != 0 || score != 100 {
+		t.Errorf("lintPrompt(full) = %v, %d", problems, score)
+	}
+	problems, score := lintPrompt("# Role\nYou are a tutor. TODO: add context")
+	if score != 16 || len(problems) != 5 || problems[4] != `placeholder left in: "TODO"` {
+		t.Errorf("lintPrompt(partial) = %q, %d", problems, score)
+	}
+}
+
+func TestExperimentReport(t *testing.T) {
+	t.Setenv(homeEnv, "")
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := experimentsDir()
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for _, r := range []struct {
+		variant, response string
+		drafts            int
+	}{
+		{"a", "```\n## Role\nx\n## Goal\ny\n```", 3},
+		{"b", "```\n## Role\n## Goal\n## Context\n## Output\n## Audience\n```", 1},
+		{"a", "```\n## Role\n## Goal\n## Context\n## Output\n## Audience\n```", 1},
+		{"a", "Who is it for?", 1}, // no prompt: not a result
+	} {
+		tab := &Tab{Conv: NewConversation("system"), Response: r.response, Session: &Session{Experiment: &ExperimentTag{Name: "terse", Variant: r.variant}}}
+		for range r.drafts {
+			tab.Conv.AddAssistantMessage("draft")
+		}
+		if err := recordExperiment(dir, tab, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recordExperiment(dir, &Tab{Conv: NewConversation("system"), Response: "```\np\n```", Session: &Session{}}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("experiment:\n  name: terse\n  variants:\n    - name: a\n    - name: b\n    - name: c\n"), 0644)
+	var out, errOut bytes.Buffer
+	if code := runExperiments([]string{"report", "--config", configPath}, &out, &errOut); code != ExitSuccess {
+		t.Fatalf("runExperiments() = %d: %s", code, errOut.String())
+	}
+	want := `Experiment terse: 3 runs
+  VARIANT  RUNS  AVG TURNS  AVG LINT
+  a           2        2.0       75%
+  b           1        1.0      100%
+  c           0          -         -
+`
+	if out.String() != want {
+		t.Errorf("report =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	errOut.Reset()
+	if code := runExperiments([]string{"report", "other", "--config", configPath}, &out, &errOut); code != ExitConfigError || !strings.Contains(errOut.String(), "no results for experiment other") {
+		t.Errorf("unknown experiment: %d %q", code, errOut.String())
+	}
+}
+
+func TestExperimentTag_TabsAndHeader(t *testing.T) {
+	tabs := NewTabs("system", &Tab{Conv: NewConversation("system"), Session: &Session{Model: "m", Experiment: &ExperimentTag{Name: "terse", Variant: "b"}}})
+	tab := tabs.Open("another idea")
+	if tab.Session.Experiment == nil || tab.Session.Experiment.Variant != "b" {
+		t.Errorf("new tab experiment = %v, want the first tab's", tab.Session.Experiment)
+	}
+
+	cfg := &Config{SystemPromptFile: "terse.md", PromptHeader: "hash"}
+	stamped, err := stampPrompt(cfg, tab.Session, time.Now(), "Be brief.")
+	if err != nil || !strings.Contains(stamped, "# framework: terse.md\n# experiment: terse/b\n") {
+		t.Errorf("stampPrompt() = %q, %v", stamped, err)
+	}
+}
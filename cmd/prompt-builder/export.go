@@ -0,0 +1,180 @@
+// export.go
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// Transcript is the exportable view of a conversation.
+type Transcript struct {
+	ID          string
+	Idea        string
+	Model       string
+	Version     string
+	Environment *Environment // what the session ran with, if recorded
+	CreatedAt   time.Time
+	ExportedAt  time.Time
+	Messages    []TranscriptMessage // user and assistant turns only
+	FinalPrompt string
+}
+
+// TranscriptMessage is a conversation turn with the notes attached to it.
+type TranscriptMessage struct {
+	Role    string
+	Content string
+	Notes   []Note
+}
+
+// NewTranscript builds a transcript from session metadata and the current
+// conversation messages.
+func NewTranscript(s *Session, messages []Message, now time.Time) Transcript {
+	t := Transcript{
+		Version:    version,
+		ExportedAt: now,
+	}
+	if s != nil {
+		t.ID, t.Idea, t.Model, t.CreatedAt, t.Environment = s.ID, s.Idea, s.Model, s.CreatedAt, s.Environment
+	}
+	draft := 0
+	for _, m := range messages {
+		if m.Role == "system" {
+			continue
+		}
+		tm := TranscriptMessage{Role: m.Role, Content: m.Content}
+		if m.Role == "assistant" {
+			draft++
+			if s != nil {
+				for _, n := range s.Notes {
+					if n.Draft == draft {
+						tm.Notes = append(tm.Notes, n)
+					}
+				}
+			}
+			if block := promptbuilder.ExtractLastCodeBlock(m.Content); block != "" {
+				t.FinalPrompt = block
+			}
+		}
+		t.Messages = append(t.Messages, tm)
+	}
+	return t
+}
+
+var htmlTranscript = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{if .Idea}}{{.Idea}} · {{end}}prompt-builder</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; max-width: 50rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; background: #f6f8fa; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5rem; }
+header dl { display: grid; grid-template-columns: max-content 1fr; gap: .25rem 1rem; font-size: .9rem; }
+header dt { color: #59636e; }
+.msg { margin: .75rem 0; padding: .75rem 1rem; border-radius: 1rem; white-space: pre-wrap; line-height: 1.45; max-width: 85%; }
+.user { background: #0969da; color: #fff; margin-left: auto; border-bottom-right-radius: .25rem; }
+.assistant { background: #fff; border: 1px solid #d0d7de; border-bottom-left-radius: .25rem; }
+.role { display: block; font-size: .75rem; opacity: .7; margin-bottom: .25rem; }
+.note { margin: -.25rem 0 .75rem 1.5rem; padding: .5rem .75rem; border-left: 3px solid #bf8700; background: #fff8c5; font-size: .9rem; white-space: pre-wrap; max-width: 75%; }
+.final { margin-top: 2rem; }
+.final pre { background: #fff8c5; border: 2px solid #d4a72c; border-radius: .5rem; padding: 1rem; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{if .Idea}}{{.Idea}}{{else}}Prompt session{{end}}</h1>
+<dl>
+{{- if .ID}}<dt>Session</dt><dd>{{.ID}}</dd>{{end}}
+{{- if .Model}}<dt>Model</dt><dd>{{.Model}}</dd>{{end}}
+{{- if not .CreatedAt.IsZero}}<dt>Started</dt><dd>{{.CreatedAt.Format "2006-01-02 15:04"}}</dd>{{end}}
+{{- with .Environment}}<dt>Environment</dt><dd>{{.}}</dd>{{end}}
+<dt>Exported</dt><dd>{{.ExportedAt.Format "2006-01-02 15:04"}} by prompt-builder {{.Version}}</dd>
+</dl>
+</header>
+<main>
+{{- range .Messages}}
+<div class="msg {{.Role}}"><span class="role">{{.Role}}</span>{{.Content}}</div>
+{{- range .Notes}}
+<aside class="note">📝 {{.Text}}</aside>
+{{- end}}
+{{- end}}
+</main>
+{{- if .FinalPrompt}}
+<section class="final">
+<h2>Final prompt</h2>
+<pre>{{.FinalPrompt}}</pre>
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// RenderHTML writes t as a standalone HTML page.
+func RenderHTML(w io.Writer, t Transcript) error {
+	return htmlTranscript.Execute(w, t)
+}
+
+// defaultExportName names an export after its idea, its session, or the
+// current time.
+func defaultExportName(s *Session, ext string, now time.Time) string {
+	switch {
+	case s != nil && s.Idea != "":
+		return slugify(s.Idea) + "." + ext
+	case s != nil && s.ID != "":
+		return s.ID + "." + ext
+	}
+	return "prompt-" + now.Format("20060102-150405") + "." + ext
+}
+
+// handleExport implements /export <format> [file]. The public format is
+// the HTML page with internal names, URLs and the scrub terms removed.
+func handleExport(args string, env *CommandEnv) error {
+	format, path, _ := strings.Cut(args, " ")
+	format = strings.ToLower(format)
+	if format != "html" && format != "public" {
+		return fmt.Errorf("Usage: /export html|public [file]")
+	}
+	if env.Conv == nil {
+		return fmt.Errorf("Nothing to export")
+	}
+
+	now := time.Now()
+	transcript := NewTranscript(env.Session, env.Conv.Messages, now)
+	if format == "public" {
+		var cfg ScrubConfig
+		if env.Scrub != nil {
+			cfg = *env.Scrub
+		}
+		transcript = newScrubber(cfg).ScrubTranscript(transcript)
+	}
+
+	path = strings.TrimSpace(path)
+	var f *os.File
+	var err error
+	if path == "" {
+		name := defaultExportName(env.Session, "html", now)
+		if format == "public" {
+			// Named after the scrubbed idea, so the name gives nothing away
+			name = strings.TrimSuffix(defaultExportName(&Session{Idea: transcript.Idea}, "html", now), ".html") + "-public.html"
+		}
+		// A generated name must not replace an earlier export
+		f, path, err = createUnique(name)
+	} else {
+		f, err = os.Create(ExpandPath(path))
+	}
+	if err != nil {
+		return fmt.Errorf("Export failed: %v", err)
+	}
+	defer f.Close()
+
+	if err := RenderHTML(f, transcript); err != nil {
+		return fmt.Errorf("Export failed: %v", err)
+	}
+	fmt.Fprintf(env.Out, "✓ Exported to %s\n", path)
+	return nil
+}
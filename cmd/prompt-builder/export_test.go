@@ -0,0 +1,126 @@
+// export_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTranscript(t *testing.T) {
+	s := &Session{ID: "2026-06-01-1", Idea: "idea", Model: "llama3.2"}
+	msgs := []Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "idea"},
+		{Role: "assistant", Content: "Draft:\n```\nfinal\n```"},
+		{Role: "assistant", Content: "Anything else?"},
+	}
+
+	tr := NewTranscript(s, msgs, time.Now())
+
+	if len(tr.Messages) != 3 {
+		t.Errorf("got %d messages, want 3 (system excluded)", len(tr.Messages))
+	}
+	if tr.FinalPrompt != "final\n" {
+		t.Errorf("FinalPrompt = %q, want %q", tr.FinalPrompt, "final\n")
+	}
+	if tr.Model != "llama3.2" || tr.ID != "2026-06-01-1" {
+		t.Errorf("metadata not copied: %+v", tr)
+	}
+}
+
+func TestRenderHTML_EscapesContent(t *testing.T) {
+	tr := Transcript{
+		Idea:        "xss test",
+		Messages:    []TranscriptMessage{{Role: "user", Content: "<script>alert(1)</script>"}},
+		FinalPrompt: "# Role <b>",
+		ExportedAt:  time.Now(),
+	}
+
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, tr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "<script>alert") {
+		t.Error("message content was not escaped")
+	}
+	if !strings.Contains(out, "Final prompt") || !strings.Contains(out, "# Role &lt;b&gt;") {
+		t.Errorf("final prompt section missing or unescaped:\n%s", out)
+	}
+}
+
+func TestHandleCommand_ExportHTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.html")
+	conv := NewConversation("system")
+	conv.AddUserMessage("idea")
+	conv.AddAssistantMessage("```\nprompt\n```")
+
+	var out bytes.Buffer
+	env := &CommandEnv{Conv: conv, Session: &Session{Idea: "idea"}, Out: &out}
+	shouldExit, err := HandleCommand("/export html "+path, "", env)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shouldExit {
+		t.Error("/export should not exit")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("export not written: %v", err)
+	}
+	if !strings.Contains(string(data), "<!DOCTYPE html>") {
+		t.Error("export is not an HTML document")
+	}
+	if !strings.Contains(out.String(), "Exported to "+path) {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+}
+
+func TestHandleCommand_ExportDefaultName(t *testing.T) {
+	t.Chdir(t.TempDir())
+	conv := NewConversation("system")
+	conv.AddUserMessage("idea")
+	env := &CommandEnv{Conv: conv, Session: &Session{ID: "s1", Idea: "A Go code reviewer"}, Out: &bytes.Buffer{}}
+
+	for _, want := range []string{"a-go-code-reviewer.html", "a-go-code-reviewer-2.html"} {
+		if _, err := HandleCommand("/export html", "", env); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("expected %s: %v", want, err)
+		}
+	}
+}
+
+func TestHandleCommand_ExportUnknownFormat(t *testing.T) {
+	var out bytes.Buffer
+	_, err := HandleCommand("/export pdf", "", &CommandEnv{Conv: NewConversation(""), Out: &out})
+	if err == nil || !strings.Contains(err.Error(), "Usage: /export") {
+		t.Errorf("expected usage error, got: %v", err)
+	}
+}
+
+func TestNewTranscript_AttachesNotes(t *testing.T) {
+	s := &Session{Notes: []Note{{Draft: 2, Text: "needs examples"}}}
+	msgs := []Message{
+		{Role: "user", Content: "idea"},
+		{Role: "assistant", Content: "draft one"},
+		{Role: "user", Content: "more"},
+		{Role: "assistant", Content: "draft two"},
+	}
+
+	tr := NewTranscript(s, msgs, time.Now())
+
+	if len(tr.Messages[1].Notes) != 0 {
+		t.Errorf("draft one should have no notes, got %+v", tr.Messages[1].Notes)
+	}
+	if len(tr.Messages[3].Notes) != 1 || tr.Messages[3].Notes[0].Text != "needs examples" {
+		t.Errorf("draft two notes = %+v", tr.Messages[3].Notes)
+	}
+}
@@ -0,0 +1,88 @@
+// extract.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// selectBlock picks one of blocks by selector: "last", a 1-based index,
+// or "lang=<name>" for the last block whose info string starts with that
+// language.
+func selectBlock(blocks []promptbuilder.CodeBlock, selector string) (promptbuilder.CodeBlock, error) {
+	if lang, ok := strings.CutPrefix(selector, "lang="); ok {
+		for i := len(blocks) - 1; i >= 0; i-- {
+			if fields := strings.Fields(blocks[i].Info); len(fields) > 0 && strings.EqualFold(fields[0], lang) {
+				return blocks[i], nil
+			}
+		}
+		return promptbuilder.CodeBlock{}, fmt.Errorf("no %s code block", lang)
+	}
+
+	n := len(blocks)
+	if selector != "last" {
+		var err error
+		if n, err = strconv.Atoi(selector); err != nil || n < 1 {
+			return promptbuilder.CodeBlock{}, fmt.Errorf("--block must be last, a number from 1, or lang=<name>, got %q", selector)
+		}
+	}
+	if n < 1 || n > len(blocks) {
+		return promptbuilder.CodeBlock{}, fmt.Errorf("no code block %d (found %d)", n, len(blocks))
+	}
+	return blocks[n-1], nil
+}
+
+// runExtract implements the extract subcommand: it prints one fenced code
+// block from the text on in. It exits 1 when there is no such block.
+func runExtract(args []string, in io.Reader, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	selector := fs.String("block", "last", "Block to print: last, a number from 1, or lang=<name>")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(errOut, "Usage: prompt-builder extract [--block last|N|lang=<name>] < response.md")
+		return ExitConfigError
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	block, err := selectBlock(promptbuilder.CodeBlocks(string(data)), *selector)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	fmt.Fprint(out, block.Body)
+	if !strings.HasSuffix(block.Body, "\n") {
+		fmt.Fprintln(out)
+	}
+	return ExitSuccess
+}
+
+// runDetectComplete implements the detect-complete subcommand: it exits 0
+// when the text on in is a finished reply by IsComplete, and 1 when the
+// model is still asking questions or gave no code block.
+func runDetectComplete(args []string, in io.Reader, errOut io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintln(errOut, "Usage: prompt-builder detect-complete < response.md")
+		return ExitConfigError
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	if !promptbuilder.IsComplete(string(data)) {
+		return 1
+	}
+	return ExitSuccess
+}
@@ -0,0 +1,91 @@
+// extract_test.go
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+const extractInput = "Example:\n```go\nfmt.Println()\n```\n\nPrompt:\n```markdown\n# Role\n```go\nnested\n```\n```\n\nNotes:\n```\nlast\n```\n"
+
+func TestCodeBlocks(t *testing.T) {
+	blocks := promptbuilder.CodeBlocks(extractInput)
+	want := []promptbuilder.CodeBlock{
+		{Info: "go", Body: "fmt.Println()\n"},
+		{Info: "markdown", Body: "# Role\n```go\nnested\n```\n"},
+		{Info: "", Body: "last\n"},
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("codeBlocks() = %q, want %q", blocks, want)
+	}
+	for i := range want {
+		if blocks[i] != want[i] {
+			t.Errorf("block %d = %q, want %q", i+1, blocks[i], want[i])
+		}
+	}
+}
+
+func TestRunExtract(t *testing.T) {
+	tests := []struct {
+		args     []string
+		want     string
+		wantCode int
+	}{
+		{nil, "last\n", ExitSuccess},
+		{[]string{"--block", "last"}, "last\n", ExitSuccess},
+		{[]string{"--block", "1"}, "fmt.Println()\n", ExitSuccess},
+		{[]string{"--block", "lang=Markdown"}, "# Role\n```go\nnested\n```\n", ExitSuccess},
+		{[]string{"--block", "lang=go"}, "fmt.Println()\n", ExitSuccess},
+		{[]string{"--block", "4"}, "", ExitConfigError},
+		{[]string{"--block", "0"}, "", ExitConfigError},
+		{[]string{"--block", "lang=python"}, "", ExitConfigError},
+		{[]string{"--block", "first"}, "", ExitConfigError},
+		{[]string{"file.md"}, "", ExitConfigError},
+	}
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		code := runExtract(tt.args, strings.NewReader(extractInput), &out, &errOut)
+		if code != tt.wantCode || out.String() != tt.want {
+			t.Errorf("extract %v = %d %q, want %d %q (stderr %q)", tt.args, code, out.String(), tt.wantCode, tt.want, errOut.String())
+		}
+		if code != ExitSuccess && errOut.Len() == 0 {
+			t.Errorf("extract %v: no error message", tt.args)
+		}
+	}
+}
+
+func TestRunExtract_NoBlock(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := runExtract(nil, strings.NewReader("What is your audience?"), &out, &errOut); code == ExitSuccess {
+		t.Errorf("exit code = %d, want failure without a code block", code)
+	}
+}
+
+func TestRunDetectComplete(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"Here you go:\n```\nprompt\n```\n", ExitSuccess},
+		{"```\nprompt\n```\nWant any changes?", 1},
+		{"Who is the audience?", 1},
+		{"", 1},
+	}
+	for _, tt := range tests {
+		var errOut bytes.Buffer
+		if got := runDetectComplete(nil, strings.NewReader(tt.input), &errOut); got != tt.want {
+			t.Errorf("detect-complete %q = %d, want %d", tt.input, got, tt.want)
+		}
+		if errOut.Len() > 0 {
+			t.Errorf("detect-complete %q wrote %q, want silence", tt.input, errOut.String())
+		}
+	}
+
+	var errOut bytes.Buffer
+	if got := runDetectComplete([]string{"file.md"}, strings.NewReader(""), &errOut); got == ExitSuccess || errOut.Len() == 0 {
+		t.Errorf("detect-complete with an argument = %d %q, want a usage error", got, errOut.String())
+	}
+}
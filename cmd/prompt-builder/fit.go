@@ -0,0 +1,158 @@
+// fit.go
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// maxShortens bounds how often the model is asked in a row to shorten a
+// prompt that is over the --fit budget.
+const maxShortens = 3
+
+// defaultFitSlots are the named --fit slots; fit_slots in the config adds
+// more or changes these.
+var defaultFitSlots = map[string]int{
+	"system": 2000,
+	"user":   1000,
+}
+
+// fitEncodings maps model name prefixes to the tiktoken encoding of their
+// family, longest prefixes first where they overlap. The tokenizers config
+// adds other models.
+var fitEncodings = []struct{ prefix, encoding string }{
+	{"gpt-5", "o200k_base"},
+	{"gpt-4.5", "o200k_base"},
+	{"gpt-4.1", "o200k_base"},
+	{"gpt-4o", "o200k_base"},
+	{"o1", "o200k_base"},
+	{"o3", "o200k_base"},
+	{"o4", "o200k_base"},
+	{"gpt-4", "cl100k_base"},
+	{"gpt-3.5-turbo", "cl100k_base"},
+}
+
+// Fit is a token budget the final prompt must meet (--fit MODEL:SLOT).
+type Fit struct {
+	Model    string `json:"model"`
+	Slot     string `json:"slot"` // a slot name or a token count
+	Budget   int    `json:"budget"`
+	Encoding string `json:"encoding"` // the tokenizer spec, see newTokenizer
+}
+
+// parseFit reads a --fit spec such as gpt-4o-mini:system or gpt-4o:1500.
+// slots holds the fit_slots config, which takes precedence over the
+// built-in slots, and configured the tokenizers config.
+func parseFit(spec string, slots map[string]int, configured map[string]string) (*Fit, error) {
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 || i == len(spec)-1 {
+		return nil, fmt.Errorf("--fit takes MODEL:SLOT, such as gpt-4o-mini:system or gpt-4o:1500")
+	}
+	f := &Fit{Model: spec[:i], Slot: spec[i+1:]}
+
+	// A budget is only worth checking with the model's own tokenizer
+	if f.Encoding = tokenizerSpec(f.Model, configured); f.Encoding == heuristicSpec {
+		return nil, fmt.Errorf("no tokenizer for model %q; --fit counts tokens for the gpt-5, gpt-4.1, gpt-4o, o-series, gpt-4 and gpt-3.5-turbo families, and for models named under tokenizers in the config", f.Model)
+	}
+	if _, err := newTokenizer(f.Encoding); err != nil {
+		return nil, err
+	}
+
+	if n, err := strconv.Atoi(f.Slot); err == nil {
+		if n <= 0 {
+			return nil, fmt.Errorf("--fit budget must be positive, got %d", n)
+		}
+		f.Budget = n
+		return f, nil
+	}
+	f.Budget = slots[f.Slot]
+	if f.Budget == 0 {
+		f.Budget = defaultFitSlots[f.Slot]
+	}
+	if f.Budget == 0 {
+		var names []string
+		for name := range defaultFitSlots {
+			names = append(names, name)
+		}
+		for name := range slots {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("unknown --fit slot %q; use a token count or one of: %s", f.Slot, strings.Join(names, ", "))
+	}
+	return f, nil
+}
+
+// String describes the budget, as in "2000-token system budget for gpt-4o-mini".
+func (f *Fit) String() string {
+	if _, err := strconv.Atoi(f.Slot); err == nil {
+		return fmt.Sprintf("%d-token budget for %s", f.Budget, f.Model)
+	}
+	return fmt.Sprintf("%d-token %s budget for %s", f.Budget, f.Slot, f.Model)
+}
+
+// Count returns the number of tokens text takes with the model's tokenizer.
+func (f *Fit) Count(text string) (int, error) {
+	t, err := newTokenizer(f.Encoding)
+	if err != nil {
+		return 0, err
+	}
+	return t.Count(text), nil
+}
+
+// fitText returns the part of response the budget applies to: with
+// --split, the message the slot names, otherwise the final prompt.
+func fitText(s *Session, response string) string {
+	if s.Split {
+		if p, ok := splitPrompt(response); ok {
+			switch s.Fit.Slot {
+			case "system":
+				return p.System
+			case "user":
+				return p.User
+			}
+		}
+	}
+	return promptbuilder.ExtractLastCodeBlock(response)
+}
+
+// shortenMessage asks the model to shorten its prompt, to at most budget
+// tokens if budget is positive.
+func shortenMessage(budget int) string {
+	target := "noticeably shorter"
+	if budget > 0 {
+		// About three quarters of a word per token in English prose
+		target = fmt.Sprintf("at most %d tokens (about %d words)", budget, budget*3/4)
+	}
+	return fmt.Sprintf("Shorten the prompt to %s. Cut repetition, merge overlapping rules and trim examples, but keep every constraint and the output format. Reply with the complete shortened prompt in one fenced code block.", target)
+}
+
+// handleShorten implements /shorten: it asks the model for a shorter
+// version of its prompt, to the given token count or the --fit budget.
+func handleShorten(args, lastResponse string, env *CommandEnv) error {
+	if env.Tabs == nil || env.Conv == nil {
+		return fmt.Errorf("/shorten is not available here")
+	}
+	if promptbuilder.ExtractLastCodeBlock(lastResponse) == "" {
+		return fmt.Errorf("No prompt to shorten yet")
+	}
+	budget := 0
+	if args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("Usage: /shorten [tokens]")
+		}
+		budget = n
+	} else if env.Session != nil && env.Session.Fit != nil {
+		budget = env.Session.Fit.Budget
+	}
+	env.Conv.AddUserMessage(shortenMessage(budget))
+	env.Tabs.Current().AwaitingReply = true
+	return nil
+}
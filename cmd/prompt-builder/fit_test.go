@@ -0,0 +1,140 @@
+// fit_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseFit(t *testing.T) {
+	requireBuild(t, tiktokenBuilt, "notiktoken")
+	tests := []struct {
+		spec     string
+		slots    map[string]int
+		budget   int
+		encoding string
+	}{
+		{"gpt-4o-mini:system", nil, 2000, "o200k_base"},
+		{"gpt-4o-mini:system", map[string]int{"system": 800}, 800, "o200k_base"},
+		{"gpt-4-turbo:1500", nil, 1500, "cl100k_base"},
+		{"o3-mini:tool", map[string]int{"tool": 300}, 300, "o200k_base"},
+	}
+	for _, tt := range tests {
+		f, err := parseFit(tt.spec, tt.slots, nil)
+		if err != nil {
+			t.Fatalf("parseFit(%q): %v", tt.spec, err)
+		}
+		if f.Budget != tt.budget || f.Encoding != tt.encoding {
+			t.Errorf("parseFit(%q) = %+v, want budget %d in %s", tt.spec, f, tt.budget, tt.encoding)
+		}
+	}
+}
+
+func TestParseFit_Errors(t *testing.T) {
+	requireBuild(t, tiktokenBuilt, "notiktoken")
+	for spec, want := range map[string]string{
+		"gpt-4o":              "MODEL:SLOT",
+		"llama3:system":       "no tokenizer",
+		"gpt-4o:0":            "must be positive",
+		"gpt-4o:instructions": "one of: system, user",
+	} {
+		_, err := parseFit(spec, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseFit(%q) error = %v, want %q", spec, err, want)
+		}
+	}
+}
+
+func TestParseFit_Tokenizers(t *testing.T) {
+	path := writeSentencePiece(t, false, false, []spPiece{{"▁hello", -1, 0}, {"▁world", -1, 0}})
+	f, err := parseFit("llama2:system", nil, map[string]string{"llama": "sentencepiece:" + path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.Count("hello world"); err != nil || n != 2 {
+		t.Errorf("Count() = %d, %v, want 2", n, err)
+	}
+
+	if _, err := parseFit("llama2:system", nil, map[string]string{"llama": "heuristic"}); err == nil || !strings.Contains(err.Error(), "no tokenizer") {
+		t.Errorf("heuristic tokenizer: error = %v, want no tokenizer", err)
+	}
+	if _, err := parseFit("llama2:system", nil, map[string]string{"llama": "sentencepiece:/nowhere/tokenizer.model"}); err == nil {
+		t.Error("a missing model file must fail --fit up front")
+	}
+}
+
+func TestFit_Count(t *testing.T) {
+	requireBuild(t, tiktokenBuilt, "notiktoken")
+	for _, encoding := range []string{"o200k_base", "cl100k_base"} {
+		f := &Fit{Encoding: encoding}
+		n, err := f.Count("hello world")
+		if err != nil || n != 2 {
+			t.Errorf("%s: Count() = %d, %v, want 2", encoding, n, err)
+		}
+	}
+}
+
+func TestFitText_Split(t *testing.T) {
+	s := &Session{Split: true, Fit: &Fit{Slot: "system"}}
+	if got := fitText(s, splitReply); got != "You are a support agent." {
+		t.Errorf("fitText() = %q, want the system message", got)
+	}
+}
+
+func TestRun_Fit_ShortensUntilItFits(t *testing.T) {
+	requireBuild(t, tiktokenBuilt, "notiktoken")
+	long := "```\n" + strings.Repeat("Always be polite. ", 20) + "\n```"
+	deps := newTestDeps(withResponses(long, long, "```\nBe polite.\n```"), withTTY(false))
+	deps.Session = &Session{Idea: "support bot", Fit: &Fit{Model: "gpt-4o-mini", Slot: "10", Budget: 10, Encoding: "o200k_base"}}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support bot", Quiet: QuietPrompt}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.TrimSpace(stdout(deps)); got != "Be polite." {
+		t.Errorf("stdout = %q, want the shortened prompt", got)
+	}
+	mock := deps.Client.(*mockLLM)
+	if mock.calls != 3 || !strings.Contains(mock.last[len(mock.last)-1].Content, "at most 10 tokens") {
+		t.Errorf("calls = %d, want two requests to shorten to 10 tokens", mock.calls)
+	}
+	if !strings.Contains(stderr(deps), "over the 10-token budget for gpt-4o-mini; shortening") {
+		t.Errorf("stderr = %q, want a note about shortening", stderr(deps))
+	}
+}
+
+func TestRun_Fit_GivesUp(t *testing.T) {
+	requireBuild(t, tiktokenBuilt, "notiktoken")
+	long := "```\n" + strings.Repeat("Always be polite. ", 20) + "\n```"
+	deps := newTestDeps(withResponses(long, long, long, long), withTTY(false))
+	deps.Session = &Session{Idea: "support bot", Fit: &Fit{Model: "gpt-4o-mini", Slot: "system", Budget: 10, Encoding: "o200k_base"}}
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "support bot", Quiet: QuietPrompt}, deps)
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts to shorten it, over the 10-token system budget") {
+		t.Fatalf("error = %v, want a give-up error", err)
+	}
+	if stdout(deps) != "" {
+		t.Errorf("stdout = %q, want no prompt", stdout(deps))
+	}
+}
+
+func TestHandleCommand_Shorten(t *testing.T) {
+	conv := NewConversation("system")
+	tab := &Tab{Conv: conv, Session: &Session{Fit: &Fit{Budget: 500}}}
+	env := &CommandEnv{Conv: conv, Session: tab.Session, Tabs: NewTabs("system", tab), Out: &bytes.Buffer{}}
+
+	if _, err := HandleCommand("/shorten", "```\nprompt\n```", env); err != nil {
+		t.Fatal(err)
+	}
+	if last := conv.Messages[len(conv.Messages)-1].Content; !tab.AwaitingReply || !strings.Contains(last, "at most 500 tokens") {
+		t.Errorf("last message = %q, want a request for the --fit budget", last)
+	}
+
+	if _, err := HandleCommand("/shorten", "No prompt yet", env); err == nil {
+		t.Error("/shorten without a prompt succeeded, want an error")
+	}
+	if _, err := HandleCommand("/shorten lots", "```\nprompt\n```", env); err == nil || !strings.Contains(err.Error(), "Usage") {
+		t.Errorf("/shorten lots error = %v, want usage", err)
+	}
+}
@@ -0,0 +1,151 @@
+// header.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// commentStyle is how a header is written in a given comment syntax.
+type commentStyle struct {
+	open, prefix, close string
+}
+
+// commentStyles are the values accepted by prompt_header, besides none.
+var commentStyles = map[string]commentStyle{
+	"html":  {open: "<!--", close: "-->"},
+	"hash":  {prefix: "# "},
+	"slash": {prefix: "// "},
+}
+
+// PromptHeader records where an emitted prompt came from, so a prompt
+// found in a repository later can be traced to how it was made.
+type PromptHeader struct {
+	Version    string
+	Date       time.Time
+	Model      string
+	Framework  string // system prompt file the prompt was built with
+	Experiment string // experiment/variant, if the run was part of one
+	Idea       string
+}
+
+// Render formats h as a comment block followed by a blank line. It returns
+// "" for style none or an unknown style.
+func (h PromptHeader) Render(style string) string {
+	cs, ok := commentStyles[style]
+	if !ok {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(h.Idea))
+	fields := [][2]string{
+		{"generator", "prompt-builder " + h.Version},
+		{"date", h.Date.Format("2006-01-02")},
+		{"model", h.Model},
+		{"framework", h.Framework},
+		{"experiment", h.Experiment},
+		{"idea-sha256", hex.EncodeToString(sum[:])[:12]},
+	}
+
+	var b strings.Builder
+	if cs.open != "" {
+		b.WriteString(cs.open + "\n")
+	}
+	for _, f := range fields {
+		if f[1] != "" {
+			fmt.Fprintf(&b, "%s%s: %s\n", cs.prefix, f[0], f[1])
+		}
+	}
+	if cs.close != "" {
+		b.WriteString(cs.close + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// footerFuncs are the functions available to prompt_footer besides the
+// PromptHeader fields.
+var footerFuncs = template.FuncMap{"env": footerEnv}
+
+// footerEnvPrefix marks the environment variables prompt_footer may read.
+const footerEnvPrefix = "PROMPT_BUILDER_"
+
+// footerEnvNames are the other variables it may read: who is running it.
+var footerEnvNames = []string{"USER", "LOGNAME"}
+
+// footerEnv implements env for prompt_footer. The footer ends up in
+// prompts that are copied, shared and sent to webhooks, so it may not read
+// just any variable, such as an API key.
+func footerEnv(name string) (string, error) {
+	if !strings.HasPrefix(name, footerEnvPrefix) && !slices.Contains(footerEnvNames, name) {
+		return "", fmt.Errorf("%s is not available to prompt_footer; only %s variables and %s are", name, footerEnvPrefix+"*", strings.Join(footerEnvNames, ", "))
+	}
+	return os.Getenv(name), nil
+}
+
+// parseFooter parses a prompt_footer template and checks it against the
+// fields it can use, so mistakes show up when the config is loaded.
+func parseFooter(text string) (*template.Template, error) {
+	tmpl, err := template.New("prompt_footer").Funcs(footerFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, PromptHeader{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// RenderFooter executes the footer template text with h.
+func (h PromptHeader) RenderFooter(text string) (string, error) {
+	tmpl, err := parseFooter(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, h); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// stampPrompt runs the configured post-processors on a prompt from
+// session s and adds the header and footer before it leaves the tool.
+func stampPrompt(cfg *Config, s *Session, now time.Time, prompt string) (string, error) {
+	prompt, err := postProcess(cfg.PostProcess, prompt)
+	if err != nil {
+		return "", err
+	}
+	h := PromptHeader{Version: version, Date: now, Framework: builtinSystemPromptName}
+	if cfg.SystemPromptFile != "" {
+		h.Framework = filepath.Base(cfg.SystemPromptFile)
+	}
+	if s != nil {
+		h.Model, h.Idea = s.Model, s.Idea
+		if s.Experiment != nil {
+			h.Experiment = s.Experiment.String()
+		}
+	}
+
+	if cfg.PromptHeader != "" && cfg.PromptHeader != "none" {
+		prompt = h.Render(cfg.PromptHeader) + prompt
+	}
+	if cfg.PromptFooter != "" {
+		footer, err := h.RenderFooter(cfg.PromptFooter)
+		if err != nil {
+			return "", fmt.Errorf("prompt_footer: %v", err)
+		}
+		if !strings.HasSuffix(prompt, "\n") {
+			prompt += "\n"
+		}
+		prompt += "\n" + footer + "\n"
+	}
+	return prompt, nil
+}
@@ -0,0 +1,87 @@
+// header_test.go
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromptHeader_Render(t *testing.T) {
+	h := PromptHeader{
+		Version:   "1.2.0",
+		Date:      time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Model:     "llama3.2",
+		Framework: "prompt-architect.md",
+		Idea:      "a code reviewer",
+	}
+
+	tests := []struct {
+		style      string
+		first      string
+		linePrefix string
+	}{
+		{"html", "<!--", ""},
+		{"hash", "# generator: prompt-builder 1.2.0", "# "},
+		{"slash", "// generator: prompt-builder 1.2.0", "// "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			got := h.Render(tt.style)
+			if !strings.HasPrefix(got, tt.first+"\n") || !strings.HasSuffix(got, "\n\n") {
+				t.Errorf("Render(%q) = %q, want comment block followed by blank line", tt.style, got)
+			}
+			for _, want := range []string{"date: 2024-06-01", "model: llama3.2", "framework: prompt-architect.md", "idea-sha256: "} {
+				if !strings.Contains(got, tt.linePrefix+want) {
+					t.Errorf("Render(%q) missing %q:\n%s", tt.style, tt.linePrefix+want, got)
+				}
+			}
+		})
+	}
+	if got := h.Render("html"); !strings.Contains(got, "\n-->\n") {
+		t.Errorf("html header not closed: %q", got)
+	}
+}
+
+func TestPromptHeader_IdeaHash(t *testing.T) {
+	a := PromptHeader{Idea: "one"}.Render("hash")
+	b := PromptHeader{Idea: "one"}.Render("hash")
+	c := PromptHeader{Idea: "two"}.Render("hash")
+	if a != b || a == c {
+		t.Error("idea hash should be stable and differ between ideas")
+	}
+	if strings.Contains(a, "one") {
+		t.Error("header should not contain the idea itself")
+	}
+}
+
+func TestPromptHeader_Disabled(t *testing.T) {
+	for _, style := range []string{"", "none"} {
+		if got, _ := stampPrompt(&Config{PromptHeader: style}, nil, time.Now(), "p\n"); got != "p\n" {
+			t.Errorf("stampPrompt() with header %q = %q, want prompt unchanged", style, got)
+		}
+	}
+}
+
+func TestStampPrompt_Footer(t *testing.T) {
+	t.Setenv("PROMPT_BUILDER_TEAM", "platform")
+	cfg := &Config{PromptFooter: "Owner: {{env \"PROMPT_BUILDER_TEAM\"}} · generated {{.Date.Format \"2006-01-02\"}} with {{.Model}}\nInternal use only.\n"}
+	s := &Session{Model: "llama3.2"}
+
+	got, err := stampPrompt(cfg, s, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), "the prompt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "the prompt\n\nOwner: platform · generated 2024-06-01 with llama3.2\nInternal use only.\n"
+	if got != want {
+		t.Errorf("stampPrompt() = %q, want %q", got, want)
+	}
+}
+
+func TestParseFooter_Invalid(t *testing.T) {
+	for _, text := range []string{"{{.Owner}}", "{{.Model", `{{env "OPENAI_API_KEY"}}`} {
+		if _, err := parseFooter(text); err == nil {
+			t.Errorf("parseFooter(%q) should fail", text)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// import.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// chatgptConversation is one entry of a ChatGPT conversations.json export.
+type chatgptConversation struct {
+	Title       string  `json:"title"`
+	CreateTime  float64 `json:"create_time"`
+	CurrentNode string  `json:"current_node"`
+	Mapping     map[string]struct {
+		Parent  string `json:"parent"`
+		Message *struct {
+			Author struct {
+				Role string `json:"role"`
+			} `json:"author"`
+			Content struct {
+				Parts []json.RawMessage `json:"parts"`
+			} `json:"content"`
+		} `json:"message"`
+	} `json:"mapping"`
+}
+
+// lmstudioConversation is an LM Studio conversation file.
+type lmstudioConversation struct {
+	Name      string  `json:"name"`
+	CreatedAt float64 `json:"createdAt"` // milliseconds
+	Messages  []struct {
+		CurrentlySelected int `json:"currentlySelected"`
+		Versions          []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"versions"`
+	} `json:"messages"`
+}
+
+// ImportSessions converts an exported conversation file into sessions.
+// Supported formats are ChatGPT's conversations.json (one or many
+// conversations), LM Studio conversation files, and plain
+// {"messages": [{"role": ..., "content": ...}]} documents.
+func ImportSessions(data []byte, now time.Time) ([]*Session, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var convs []chatgptConversation
+		if err := json.Unmarshal(data, &convs); err != nil {
+			return nil, fmt.Errorf("unrecognized export format: %w", err)
+		}
+		var sessions []*Session
+		for _, c := range convs {
+			s, err := c.session()
+			if err != nil {
+				return nil, err
+			}
+			if s != nil {
+				sessions = append(sessions, s)
+			}
+		}
+		return sessions, nil
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("unrecognized export format: %w", err)
+	}
+
+	var s *Session
+	switch {
+	case probe["mapping"] != nil:
+		var c chatgptConversation
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("invalid ChatGPT export: %w", err)
+		}
+		var err error
+		if s, err = c.session(); err != nil {
+			return nil, err
+		}
+	case strings.Contains(string(probe["messages"]), `"versions"`):
+		var c lmstudioConversation
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("invalid LM Studio export: %w", err)
+		}
+		s = c.session()
+	case probe["messages"] != nil:
+		var c struct {
+			Messages []Message `json:"messages"`
+		}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("invalid messages export: %w", err)
+		}
+		s = newImportedSession("messages", now, c.Messages)
+	default:
+		return nil, fmt.Errorf("unrecognized export format: expected ChatGPT, LM Studio, or a messages array")
+	}
+
+	if s == nil {
+		return nil, nil
+	}
+	return []*Session{s}, nil
+}
+
+func (c chatgptConversation) session() (*Session, error) {
+	// Walk from the selected leaf back to the root to get the active branch.
+	var msgs []Message
+	visited := map[string]bool{}
+	for id := c.CurrentNode; id != ""; id = c.Mapping[id].Parent {
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		if visited[id] {
+			return nil, fmt.Errorf("invalid ChatGPT export: conversation %q has a parent loop at node %q", c.Title, id)
+		}
+		visited[id] = true
+		if node.Message == nil {
+			continue
+		}
+		var text []string
+		for _, p := range node.Message.Content.Parts {
+			var s string
+			if json.Unmarshal(p, &s) == nil && s != "" {
+				text = append(text, s)
+			}
+		}
+		if len(text) > 0 {
+			msgs = append([]Message{{Role: node.Message.Author.Role, Content: strings.Join(text, "\n")}}, msgs...)
+		}
+	}
+	return newImportedSession("chatgpt", time.Unix(int64(c.CreateTime), 0), msgs), nil
+}
+
+func (c lmstudioConversation) session() *Session {
+	var msgs []Message
+	for _, m := range c.Messages {
+		if m.CurrentlySelected < 0 || m.CurrentlySelected >= len(m.Versions) {
+			continue
+		}
+		v := m.Versions[m.CurrentlySelected]
+		var text []string
+		for _, part := range v.Content {
+			if part.Type == "text" {
+				text = append(text, part.Text)
+			}
+		}
+		msgs = append(msgs, Message{Role: v.Role, Content: strings.Join(text, "\n")})
+	}
+	return newImportedSession("lmstudio", time.UnixMilli(int64(c.CreatedAt)), msgs)
+}
+
+// newImportedSession keeps user and assistant turns and uses the first user
+// message as the idea. It returns nil when there is nothing to continue.
+func newImportedSession(source string, created time.Time, msgs []Message) *Session {
+	s := &Session{Source: source, CreatedAt: created}
+	for _, m := range msgs {
+		if m.Role != "user" && m.Role != "assistant" {
+			continue
+		}
+		if s.Idea == "" && m.Role == "user" {
+			s.Idea = m.Content
+		}
+		s.Messages = append(s.Messages, m)
+	}
+	if s.Idea == "" {
+		return nil
+	}
+	return s
+}
+
+// readMessages reads the conversation --messages hands over: a JSON
+// array of {"role": ..., "content": ...} messages, or an object with one
+// under "messages", such as a chat completions request. A path of "-"
+// reads stdin. Only user and assistant turns are kept.
+func readMessages(path string, stdin io.Reader) (*Session, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(ExpandPath(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read messages: %v", err)
+	}
+
+	var msgs []Message
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &msgs)
+	} else {
+		var doc struct {
+			Messages []Message `json:"messages"`
+		}
+		err = json.Unmarshal(data, &doc)
+		msgs = doc.Messages
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid messages %s: %v", path, err)
+	}
+	s := newImportedSession("messages", time.Now(), msgs)
+	if s == nil {
+		return nil, fmt.Errorf("invalid messages %s: no user message to continue from", path)
+	}
+	s.Source = "" // a conversation to continue, not an import
+	return s, nil
+}
+
+// runImport implements the import subcommand.
+func runImport(args []string, out, errOut io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(errOut, "Usage: prompt-builder import <export.json>")
+		return ExitConfigError
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+
+	sessions, err := ImportSessions(data, time.Now())
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(errOut, "No conversations with user messages found.")
+		return ExitConfigError
+	}
+
+	dir := sessionsDir()
+	for _, s := range sessions {
+		if _, err := SaveSession(dir, s); err != nil {
+			fmt.Fprintf(errOut, "Error: %v\n", err)
+			return ExitConfigError
+		}
+		fmt.Fprintf(out, "%s  %s\n", s.ID, firstLine(s.Idea, 60))
+	}
+	fmt.Fprintf(out, "\nContinue with: prompt-builder --resume <id>\n")
+	return ExitSuccess
+}
+
+// firstLine returns the first line of s, cut to max runes.
+func firstLine(s string, max int) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		s = s[:i]
+	}
+	if r := []rune(s); len(r) > max {
+		return string(r[:max-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,184 @@
+// import_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chatgptExport = `[{
+  "title": "Keto prompt",
+  "create_time": 1717243200.5,
+  "current_node": "c",
+  "mapping": {
+    "root": {"parent": "", "message": null},
+    "s": {"parent": "root", "message": {"author": {"role": "system"}, "content": {"parts": [""]}}},
+    "a": {"parent": "s", "message": {"author": {"role": "user"}, "content": {"parts": ["I want a keto plan"]}}},
+    "b": {"parent": "a", "message": {"author": {"role": "assistant"}, "content": {"parts": ["How strict?"]}}},
+    "x": {"parent": "a", "message": {"author": {"role": "assistant"}, "content": {"parts": ["abandoned branch"]}}},
+    "c": {"parent": "b", "message": {"author": {"role": "user"}, "content": {"parts": ["Very strict"]}}}
+  }
+}]`
+
+const lmstudioExport = `{
+  "name": "Docs prompt",
+  "createdAt": 1717243200000,
+  "messages": [
+    {"currentlySelected": 0, "versions": [{"role": "user", "content": [{"type": "text", "text": "technical docs"}]}]},
+    {"currentlySelected": 1, "versions": [
+      {"role": "assistant", "content": [{"type": "text", "text": "first try"}]},
+      {"role": "assistant", "content": [{"type": "text", "text": "Which audience?"}]}
+    ]}
+  ]
+}`
+
+func TestImportSessions(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		input      string
+		wantSource string
+		wantIdea   string
+		wantMsgs   []string
+	}{
+		{
+			name:       "chatgpt follows current branch",
+			input:      chatgptExport,
+			wantSource: "chatgpt",
+			wantIdea:   "I want a keto plan",
+			wantMsgs:   []string{"I want a keto plan", "How strict?", "Very strict"},
+		},
+		{
+			name:       "lmstudio uses selected version",
+			input:      lmstudioExport,
+			wantSource: "lmstudio",
+			wantIdea:   "technical docs",
+			wantMsgs:   []string{"technical docs", "Which audience?"},
+		},
+		{
+			name:       "plain messages",
+			input:      `{"messages":[{"role":"system","content":"sys"},{"role":"user","content":"idea"}]}`,
+			wantSource: "messages",
+			wantIdea:   "idea",
+			wantMsgs:   []string{"idea"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, err := ImportSessions([]byte(tt.input), now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(sessions) != 1 {
+				t.Fatalf("got %d sessions, want 1", len(sessions))
+			}
+			s := sessions[0]
+			if s.Source != tt.wantSource {
+				t.Errorf("Source = %q, want %q", s.Source, tt.wantSource)
+			}
+			if s.Idea != tt.wantIdea {
+				t.Errorf("Idea = %q, want %q", s.Idea, tt.wantIdea)
+			}
+			var got []string
+			for _, m := range s.Messages {
+				got = append(got, m.Content)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.wantMsgs, "|") {
+				t.Errorf("Messages = %q, want %q", got, tt.wantMsgs)
+			}
+		})
+	}
+}
+
+func TestImportSessions_Unrecognized(t *testing.T) {
+	_, err := ImportSessions([]byte(`{"foo": 1}`), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "unrecognized") {
+		t.Errorf("expected unrecognized format error, got: %v", err)
+	}
+}
+
+func TestImportSessions_ParentLoop(t *testing.T) {
+	export := `{
+  "title": "Loop",
+  "current_node": "b",
+  "mapping": {
+    "a": {"parent": "b", "message": {"author": {"role": "user"}, "content": {"parts": ["hi"]}}},
+    "b": {"parent": "a", "message": {"author": {"role": "assistant"}, "content": {"parts": ["hello"]}}}
+  }
+}`
+	done := make(chan error)
+	go func() {
+		_, err := ImportSessions([]byte(export), time.Now())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "parent loop") {
+			t.Errorf("expected a parent loop error, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ImportSessions did not return for a parent loop")
+	}
+}
+
+func TestReadMessages(t *testing.T) {
+	conversation := `[
+  {"role": "system", "content": "You are someone else's assistant."},
+  {"role": "user", "content": "a code reviewer"},
+  {"role": "assistant", "content": "Which language?"},
+  {"role": "user", "content": "Go"}
+]`
+	path := filepath.Join(t.TempDir(), "messages.json")
+	os.WriteFile(path, []byte(`{"model": "gpt-4o", "messages": `+conversation+`}`), 0644)
+
+	for name, read := range map[string]func() (*Session, error){
+		"file":  func() (*Session, error) { return readMessages(path, nil) },
+		"stdin": func() (*Session, error) { return readMessages("-", strings.NewReader(conversation)) },
+	} {
+		s, err := read()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if s.Idea != "a code reviewer" || len(s.Messages) != 3 || s.Messages[2].Content != "Go" || s.Source != "" {
+			t.Errorf("%s: session = %+v, want the user and assistant turns", name, s)
+		}
+	}
+
+	for input, want := range map[string]string{
+		`[{"role": "assistant", "content": "Hi"}]`: "no user message",
+		`{"messages": "a code reviewer"}`:          "invalid messages -",
+		`a code reviewer`:                          "invalid messages -",
+	} {
+		if _, err := readMessages("-", strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("readMessages(%q) error = %v, want %q", input, err, want)
+		}
+	}
+	if _, err := readMessages(filepath.Join(t.TempDir(), "gone.json"), nil); err == nil || !strings.Contains(err.Error(), "cannot read messages") {
+		t.Errorf("missing file: error = %v", err)
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	exportPath := filepath.Join(t.TempDir(), "conversations.json")
+	os.WriteFile(exportPath, []byte(chatgptExport), 0644)
+
+	var out, errOut bytes.Buffer
+	code := runImport([]string{exportPath}, &out, &errOut)
+
+	if code != ExitSuccess {
+		t.Fatalf("exit code = %d, stderr: %s", code, errOut.String())
+	}
+	if !strings.Contains(out.String(), "2024-06-01-1") || !strings.Contains(out.String(), "--resume") {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+	if _, err := LoadSession(sessionsDir(), "2024-06-01-1"); err != nil {
+		t.Errorf("imported session not saved: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+// insist.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// pushMessages are what /push sends, from a plain reminder to an
+// ultimatum; each push of the same requirement takes the next one. %s is
+// the requirement.
+var pushMessages = []string{
+	"The prompt must include this requirement: %s. Revise the prompt so it does. Reply with the complete prompt in one fenced code block.",
+	"You ignored this requirement: %s. It is mandatory, not a suggestion. Add it to the prompt explicitly and keep everything else. Reply with the complete prompt in one fenced code block.",
+	"The prompt still leaves out this requirement: %s. A prompt without it is wrong. Before anything else, add a line to the prompt that states the requirement in these words, then reply with the complete prompt in one fenced code block.",
+}
+
+// pushState is a requirement /push insists on until a draft includes it.
+type pushState struct {
+	Requirement string
+	Level       int // pushMessages sent so far
+}
+
+// handlePush implements /push: it asks the model to add a requirement the
+// draft left out, and /push alone insists again, more firmly, on the last
+// one that is still missing.
+func handlePush(args string, env *CommandEnv) error {
+	if env.Tabs == nil || env.Conv == nil {
+		return fmt.Errorf("/push is not available here")
+	}
+	tab := env.Tabs.Current()
+	requirement := strings.TrimSpace(strings.Trim(args, `"'`))
+	switch {
+	case requirement != "" && (tab.Push == nil || tab.Push.Requirement != requirement):
+		tab.Push = &pushState{Requirement: requirement}
+	case requirement == "" && tab.Push == nil:
+		return fmt.Errorf(`Usage: /push "<requirement>"`)
+	}
+	env.Conv.AddUserMessage(tab.Push.next())
+	tab.AwaitingReply = true
+	return nil
+}
+
+// next returns the message for the next push, the firmest once all have
+// been sent.
+func (p *pushState) next() string {
+	msg := fmt.Sprintf(pushMessages[min(p.Level, len(pushMessages)-1)], p.Requirement)
+	p.Level++
+	return msg
+}
+
+// escalate reports whether an unmet push has firmer messages left to send
+// on its own.
+func (p *pushState) escalate() bool {
+	return p.Level < len(pushMessages)
+}
+
+// includesRequirement reports whether prompt includes requirement: word
+// for word, or with every content word of it somewhere in the prompt,
+// since the model may rephrase it. Case and runs of whitespace are
+// ignored.
+func includesRequirement(prompt, requirement string) bool {
+	if len(missingPins(prompt, []string{requirement})) == 0 {
+		return true
+	}
+	text := strings.ToLower(prompt)
+	words := strings.FieldsFunc(strings.ToLower(requirement), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	found := 0
+	for _, w := range words {
+		// Short words are mostly articles and prepositions
+		if len([]rune(w)) < 4 {
+			continue
+		}
+		if !strings.Contains(text, w) {
+			return false
+		}
+		found++
+	}
+	return found > 0
+}
@@ -0,0 +1,72 @@
+// insist_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIncludesRequirement(t *testing.T) {
+	prompt := "You are a support agent.\nAlways answer in  Spanish, and cite the help center article you used."
+	tests := map[string]bool{
+		"always answer in spanish":        true,  // word for word, ignoring case and spacing
+		"cite the article you used":       true,  // rephrased, every content word present
+		"answer in Spanish or Portuguese": false, // a content word is missing
+		"be in it":                        false, // no content words to check
+	}
+	for requirement, want := range tests {
+		if got := includesRequirement(prompt, requirement); got != want {
+			t.Errorf("includesRequirement(%q) = %v, want %v", requirement, got, want)
+		}
+	}
+}
+
+func TestHandlePush(t *testing.T) {
+	conv := NewConversation("system")
+	tabs := NewTabs("system", &Tab{Conv: conv})
+	env := &CommandEnv{Conv: conv, Tabs: tabs, Out: &bytes.Buffer{}}
+
+	if _, err := HandleCommand("/push", "", env); err == nil || !strings.Contains(err.Error(), "Usage: /push") {
+		t.Errorf("/push without a requirement: %v", err)
+	}
+	for i := range 4 {
+		if _, err := HandleCommand(`/push "answer in Spanish"`, "", env); err != nil {
+			t.Fatal(err)
+		}
+		want := strings.SplitN(pushMessages[min(i, len(pushMessages)-1)], "%s", 2)[0]
+		if msg := conv.Messages[len(conv.Messages)-1].Content; !strings.HasPrefix(msg, want) {
+			t.Errorf("push %d = %q, want it to start %q", i+1, msg, want)
+		}
+	}
+	if !tabs.Current().AwaitingReply {
+		t.Error("/push must ask the model")
+	}
+
+	// A new requirement starts over at the plain reminder
+	HandleCommand(`/push "cite sources"`, "", env)
+	if msg := conv.Messages[len(conv.Messages)-1].Content; msg != strings.Replace(pushMessages[0], "%s", "cite sources", 1) {
+		t.Errorf("new requirement: %q", msg)
+	}
+}
+
+func TestRun_Push(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("```\nYou are a support agent.\n```", "```\nYou are a support agent. Be kind.\n```", "```\nYou are a support agent. Always answer in Spanish.\n```"),
+		withStdin("/push \"answer in Spanish\"\n/bye\n"),
+	)
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support bot"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mock := deps.Client.(*mockLLM)
+	if mock.calls != 3 {
+		t.Errorf("calls = %d, want the push and one firmer one", mock.calls)
+	}
+	if msg := mock.last[len(mock.last)-1].Content; !strings.HasPrefix(msg, "You ignored this requirement: answer in Spanish.") {
+		t.Errorf("escalated message = %q", msg)
+	}
+	if !strings.Contains(stderr(deps), "still leaves out \"answer in Spanish\"; insisting") || !strings.Contains(stderr(deps), "✓ The draft now includes \"answer in Spanish\"") {
+		t.Errorf("stderr = %q", stderr(deps))
+	}
+}
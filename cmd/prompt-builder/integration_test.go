@@ -4,10 +4,16 @@ package main
 import (
 	"context"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
 )
 
 func TestIntegration_ConfigLoading(t *testing.T) {
@@ -171,7 +177,7 @@ func TestRun_PipeMode_Quiet(t *testing.T) {
 	cli := &CLI{
 		ConfigPath: configFile,
 		Idea:       "test idea",
-		Quiet:      true,
+		Quiet:      QuietPrompt,
 	}
 
 	err := runWithDeps(context.Background(), cli, deps)
@@ -378,3 +384,565 @@ func TestCommand_Unknown(t *testing.T) {
 		t.Errorf("expected 'Unknown command' error, got: %s", errOut)
 	}
 }
+
+func TestRun_ResumeAwaitingUser(t *testing.T) {
+	session := &Session{
+		Idea: "test idea",
+		Messages: []Message{
+			{Role: "user", Content: "test idea"},
+			{Role: "assistant", Content: "Who is the audience?"},
+		},
+	}
+
+	deps := newTestDeps(
+		withResponses("```\nresumed prompt\n```"),
+		withStdin("beginners\n/bye\n"),
+		withTTY(true),
+	)
+	deps.Session = session
+
+	err := runWithDeps(context.Background(), &CLI{}, deps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := stdout(deps)
+	if !strings.Contains(out, "Who is the audience?") {
+		t.Errorf("expected last answer to be shown on resume, got: %s", out)
+	}
+
+	mock := deps.Client.(*mockLLM)
+	if len(mock.last) != 4 || mock.last[3].Content != "beginners" {
+		t.Errorf("expected history plus new answer sent to LLM, got: %+v", mock.last)
+	}
+	if len(session.Messages) != 5 {
+		t.Errorf("expected session updated with 5 messages, got %d", len(session.Messages))
+	}
+}
+
+func TestRun_ExitSummary(t *testing.T) {
+	session := &Session{
+		Idea: "test idea",
+		Messages: []Message{
+			{Role: "user", Content: "test idea"},
+			{Role: "assistant", Content: "Who is the audience?"},
+		},
+	}
+	deps := newTestDeps(
+		withResponses("```\nresumed prompt\n```"),
+		withStdin("beginners\n/copy\n"),
+		withTTY(true),
+	)
+	deps.Session = session
+	saves := 0
+	deps.SaveSession = func(s *Session) (string, error) {
+		saves++
+		return "/data/sessions/2024-06-01-1.json", nil
+	}
+
+	if err := runWithDeps(context.Background(), &CLI{Resume: "2024-06-01-1"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout(deps)), "\n")
+	summary := lines[len(lines)-1]
+	for _, want := range []string{"2 turns", "final prompt ~4 tokens", "copied to clipboard", "saved as /data/sessions/2024-06-01-1.json"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q missing %q", summary, want)
+		}
+	}
+	if saves != 1 {
+		t.Errorf("expected session saved once, got %d", saves)
+	}
+}
+
+func TestRun_ExitSummary_CtrlD(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("```\nfresh prompt\n```"),
+		withStdin(""), // Ctrl-D at the first prompt
+		withTTY(true),
+	)
+	deps.SaveSession = func(s *Session) (string, error) {
+		return "/data/sessions/2024-06-01-2.json", nil
+	}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout(deps)), "\n")
+	if summary := lines[len(lines)-1]; !strings.Contains(summary, "1 turn") || !strings.Contains(summary, "saved as /data/sessions/2024-06-01-2.json") {
+		t.Errorf("summary = %q, want the turn count and where the new session was saved", summary)
+	}
+}
+
+func TestRun_SavesNewSession(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("```\nfirst draft\n```"),
+		withStdin("/note tone is too formal\n/bye\n"),
+		withTTY(true),
+	)
+	var saved *Session
+	deps.SaveSession = func(s *Session) (string, error) {
+		saved = s
+		return "/data/sessions/2024-06-01-1.json", nil
+	}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved == nil || len(saved.Notes) != 1 || saved.Notes[0].Text != "tone is too formal" {
+		t.Fatalf("saved session = %+v, want it saved with its note", saved)
+	}
+
+	// Tabs opened during the run are saved too
+	var ideas []string
+	deps = newTestDeps(
+		withResponses("First question?", "Second question?"),
+		withStdin("/new \"other idea\"\n/bye\n"),
+		withTTY(true),
+	)
+	deps.SaveSession = func(s *Session) (string, error) {
+		ideas = append(ideas, s.Idea)
+		return "", nil
+	}
+	if err := runWithDeps(context.Background(), &CLI{Idea: "first idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(ideas, []string{"first idea", "other idea"}) {
+		t.Errorf("saved sessions for %q, want both tabs", ideas)
+	}
+
+	// A run that ends before the model replies leaves nothing to resume
+	saved = nil
+	deps = newTestDeps(withStdin("/bye\n"), withTTY(true))
+	deps.SaveSession = func(s *Session) (string, error) { saved = s; return "", nil }
+	deps.Client = &mockLLM{err: errors.New("connection refused")}
+	runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps)
+	if saved != nil {
+		t.Errorf("saved %+v, want nothing saved without a reply", saved)
+	}
+}
+
+func TestRun_TruncatesRequestNotSession(t *testing.T) {
+	long := strings.Repeat("a", 400)
+	deps := newTestDeps(
+		withResponses("Q1 "+long+"?", "Q2 "+long+"?", "Q3 "+long+"?", "```\nfinal\n```"),
+		withStdin(long+"\n"+long+"\n"+long+"\n/bye\n"),
+		withTTY(true),
+	)
+	deps.Config.ContextWindow = 600
+	deps.Config.ContextOverflow = overflowTruncate
+	var saved *Session
+	deps.SaveSession = func(s *Session) (string, error) { saved = s; return "", nil }
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent := deps.Client.(*mockLLM).last
+	if len(sent) >= 8 || !strings.Contains(sent[1].Content, "omitted") {
+		t.Errorf("last request had %d messages, want earlier turns left out with a note", len(sent))
+	}
+	if saved == nil || len(saved.Messages) != 9 {
+		t.Fatalf("saved session = %+v, want all 9 messages", saved)
+	}
+	for _, m := range saved.Messages {
+		if strings.Contains(m.Content, "omitted") {
+			t.Errorf("saved message %q carries the truncation note", m.Content)
+		}
+	}
+}
+
+func TestRun_PipeMode_NudgesForCodeBlock(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("Here is the prompt: be concise.", "```\nbe concise\n```"),
+		withTTY(false),
+	)
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "test idea", Quiet: QuietPrompt}, deps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mock := deps.Client.(*mockLLM)
+	if mock.calls != 2 {
+		t.Errorf("expected 2 calls, got %d", mock.calls)
+	}
+	if last := mock.last[len(mock.last)-1]; last.Content != deps.Config.CompletionNudge {
+		t.Errorf("expected nudge as last message, got: %q", last.Content)
+	}
+	if out := stdout(deps); out != "be concise\n\n" {
+		t.Errorf("stdout = %q, want extracted prompt", out)
+	}
+}
+
+func TestRun_PipeMode_Prefill(t *testing.T) {
+	deps := newTestDeps(withResponses("\nbe concise\n```"), withTTY(false))
+	deps.Config.Prefill = "```"
+	deps.Session = &Session{Idea: "test idea"}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mock := deps.Client.(*mockLLM)
+	if last := mock.last[len(mock.last)-1]; last.Role != "assistant" || last.Content != "```" {
+		t.Errorf("last message = %+v, want the prefill as an assistant message", last)
+	}
+	if out := stdout(deps); out != "```\nbe concise\n```\n" {
+		t.Errorf("stdout = %q, want the reply with its prefill", out)
+	}
+	if got := deps.Session.Messages[len(deps.Session.Messages)-1].Content; got != "```\nbe concise\n```" {
+		t.Errorf("saved reply = %q, want it to start with the prefill", got)
+	}
+}
+
+func TestRun_Interactive_NoPrefill(t *testing.T) {
+	deps := newTestDeps(withResponses("What tone?"), withStdin("/bye\n"), withTTY(true))
+	deps.Config.Prefill = "```"
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mock := deps.Client.(*mockLLM)
+	if last := mock.last[len(mock.last)-1]; last.Role != "user" {
+		t.Errorf("last message = %+v, want no prefill when the model may ask questions", last)
+	}
+}
+
+func TestRun_PipeMode_PromptHeader(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("```\nbe concise\n```"),
+		withTTY(false),
+	)
+	deps.Config.PromptHeader = "html"
+	deps.Session = &Session{Idea: "test idea", Model: "llama3.2"}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea", Quiet: QuietPrompt}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := stdout(deps)
+	if !strings.HasPrefix(out, "<!--\n") || !strings.Contains(out, "model: llama3.2") || !strings.HasSuffix(out, "-->\n\nbe concise\n\n") {
+		t.Errorf("stdout = %q, want header before the prompt", out)
+	}
+}
+
+func TestRun_QuestionAlert(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("Who is it for?", "```\nbe concise\n```"),
+		withStdin("kids\n/bye\n"),
+	)
+	deps.Config.QuestionAlert = alertBell
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := strings.Count(stdout(deps), "\a"); n != 1 {
+		t.Errorf("bell rang %d times, want once for the question:\n%q", n, stdout(deps))
+	}
+}
+
+func TestRun_Reload(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("Who is it for?", "```\nbe concise\n```"),
+		withStdin("/reload\nkids\n/bye\n"),
+	)
+	deps.ReloadSystemPrompt = func() (string, error) { return "edited system prompt", nil }
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last := deps.Client.(*mockLLM).last; last[0].Content != "edited system prompt" {
+		t.Errorf("system message after /reload = %q", last[0].Content)
+	}
+	if !strings.Contains(stdout(deps), "✓ Reloaded "+builtinSystemPromptName) {
+		t.Errorf("stdout = %q, want the reload confirmed", stdout(deps))
+	}
+
+	// SIGHUP reloads before the next request
+	hangup := make(chan os.Signal, 1)
+	hangup <- syscall.SIGHUP
+	deps = newTestDeps(withResponses("```\nbe concise\n```"), withStdin("/bye\n"))
+	deps.ReloadSystemPrompt = func() (string, error) { return "edited system prompt", nil }
+	deps.Hangup = hangup
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last := deps.Client.(*mockLLM).last; last[0].Content != "edited system prompt" || !strings.Contains(stderr(deps), "✓ Reloaded") {
+		t.Errorf("after SIGHUP: system message %q, stderr %q", last[0].Content, stderr(deps))
+	}
+}
+
+func TestRun_AskIdea(t *testing.T) {
+	// Files attached with --context follow the idea the user types
+	attached := withContext("", []contextFile{{Name: "spec.md", Content: "# Spec\n"}})
+	deps := newTestDeps(withResponses("Who is it for?"), withStdin("an email triage bot\n/bye\n"))
+	deps.Session = &Session{CreatedAt: time.Now()}
+	if err := runWithDeps(context.Background(), &CLI{Idea: attached}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first := deps.Client.(*mockLLM).last[1].Content; first != "an email triage bot\n\n"+attached {
+		t.Errorf("first message = %q", first)
+	}
+	if deps.Session.Idea != "an email triage bot" {
+		t.Errorf("session idea = %q", deps.Session.Idea)
+	}
+
+	// Leaving at the question sends nothing
+	for _, input := range []string{"/quit\n", ""} {
+		deps = newTestDeps(withStdin(input))
+		if err := runWithDeps(context.Background(), &CLI{}, deps); err != nil {
+			t.Fatalf("%q: unexpected error: %v", input, err)
+		}
+		if calls := deps.Client.(*mockLLM).calls; calls != 0 {
+			t.Errorf("%q: %d requests, want none", input, calls)
+		}
+	}
+}
+
+func TestRun_QuietLevels(t *testing.T) {
+	tests := []struct {
+		name          string
+		quiet         QuietLevel
+		wantStdout    string
+		wantClipboard string
+	}{
+		{"quiet", QuietPrompt, "final\n\n", ""},
+		{"clipboard only", QuietClipboard, "", "final\n"},
+		{"silent", QuietSilent, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, tty := range []bool{true, false} {
+				deps := newTestDeps(
+					withResponses("```\nfinal\n```"),
+					withTTY(tty),
+				)
+
+				if err := runWithDeps(context.Background(), &CLI{Idea: "idea", Quiet: tt.quiet}, deps); err != nil {
+					t.Fatalf("tty=%v: unexpected error: %v", tty, err)
+				}
+				if out := stdout(deps); out != tt.wantStdout {
+					t.Errorf("tty=%v: stdout = %q, want %q", tty, out, tt.wantStdout)
+				}
+				if got := clipboardWritten(deps); got != tt.wantClipboard {
+					t.Errorf("tty=%v: clipboard = %q, want %q", tty, got, tt.wantClipboard)
+				}
+				if user := deps.Client.(*mockLLM).last[1].Content; !strings.HasPrefix(user, promptbuilder.PipeModePrefix) {
+					t.Errorf("tty=%v: quiet runs should ask for the prompt without questions, got %q", tty, user)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_PipeMode_QR(t *testing.T) {
+	requireBuild(t, qrBuilt, "noqr")
+	deps := newTestDeps(
+		withResponses("```\nbe concise\n```"),
+		withTTY(false),
+	)
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea", Quiet: QuietPrompt, QR: true}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out := stdout(deps); out != "be concise\n\n" {
+		t.Errorf("stdout = %q, want only the prompt", out)
+	}
+	if qr := stderr(deps); !strings.HasPrefix(qr, "█") {
+		t.Errorf("expected QR code on stderr, got %q", qr)
+	}
+}
+
+func TestRun_PipeMode_GivesUpAfterNudges(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("Who is it for?", "Who is it for?", "Who is it for?", "unused"),
+		withTTY(false),
+	)
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps)
+	if err == nil || !strings.Contains(err.Error(), "clarification") {
+		t.Fatalf("expected clarification error, got: %v", err)
+	}
+	if code := exitCode(err); code != ExitClarification {
+		t.Errorf("exitCode = %d, want %d", code, ExitClarification)
+	}
+	if calls := deps.Client.(*mockLLM).calls; calls != 1+deps.Config.MaxNudges {
+		t.Errorf("expected %d calls, got %d", 1+deps.Config.MaxNudges, calls)
+	}
+}
+
+func TestCommand_TempAppliesToNextTurn(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("What audience?", "```\ndone\n```"),
+		withStdin("/temp 0.3\nexperts\n/bye\n"),
+		withTTY(true),
+	)
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mock := deps.Client.(*mockLLM)
+	if mock.params.Temperature == nil || *mock.params.Temperature != 0.3 {
+		t.Errorf("expected temperature 0.3 on second turn, got %v", mock.params.Temperature)
+	}
+}
+
+func TestRun_ConfigParamsWithCLIOverride(t *testing.T) {
+	deps := newTestDeps(withResponses("```\ndone\n```"), withTTY(false))
+	temp, seed, override := 0.2, 42, 0.7
+	deps.Config.Temperature, deps.Config.Seed = &temp, &seed
+
+	cli := &CLI{Idea: "test idea", Quiet: QuietPrompt, Params: GenerationParams{Temperature: &override}}
+	if err := runWithDeps(context.Background(), cli, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p := deps.Client.(*mockLLM).params
+	if deref(p.Temperature) != 0.7 || deref(p.Seed) != 42 {
+		t.Errorf("params = temperature %v, seed %v; want the flag's 0.7 and the config's 42", deref(p.Temperature), deref(p.Seed))
+	}
+}
+
+func TestRun_PipeMode_Delimiter(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("no fence yet", "```\nfinal\n```"),
+		withTTY(false),
+	)
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "test idea", Delimiter: "\x1e"}, deps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records := strings.Split(strings.TrimSuffix(stdout(deps), "\x1e\n"), "\x1e\n")
+	if len(records) != 2 {
+		t.Fatalf("expected 2 delimited records, got %d: %q", len(records), stdout(deps))
+	}
+	if !strings.Contains(records[1], "final") {
+		t.Errorf("second record = %q, want final response", records[1])
+	}
+}
+
+func TestRun_TTY_NoDelimiter(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("What audience?"),
+		withStdin("/bye\n"),
+		withTTY(true),
+	)
+
+	runWithDeps(context.Background(), &CLI{Idea: "test idea", Delimiter: "@@"}, deps)
+
+	if strings.Contains(stdout(deps), "@@") {
+		t.Errorf("delimiter should only be written in pipe mode, got: %q", stdout(deps))
+	}
+}
+
+func TestRun_IdleTimeoutAutosaves(t *testing.T) {
+	stdinReader, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	deps := newTestDeps(
+		withResponses("What audience?"),
+		withTTY(true),
+	)
+	deps.Stdin = stdinReader
+	deps.Config.IdleTimeout = Duration(20 * time.Millisecond)
+
+	var saved *Session
+	deps.SaveSession = func(s *Session) (string, error) {
+		s.ID = "2026-06-01-1"
+		saved = s
+		return "/sessions/2026-06-01-1.json", nil
+	}
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if saved == nil || len(saved.Messages) != 3 {
+		t.Fatalf("expected session with conversation to be saved, got %+v", saved)
+	}
+	if out := stdout(deps); !strings.Contains(out, "--resume 2026-06-01-1") {
+		t.Errorf("expected resume instructions, got: %s", out)
+	}
+}
+
+func TestCommand_NewTabAndSwitchBack(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("First question?", "Second question?", "```\nfirst done\n```"),
+		withStdin("/new \"other idea\"\n/switch 1\nanswer for first\n/bye\n"),
+		withTTY(true),
+	)
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "first idea"}, deps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mock := deps.Client.(*mockLLM)
+	if mock.calls != 3 {
+		t.Fatalf("expected 3 LLM calls, got %d", mock.calls)
+	}
+	// The last call continues the first conversation, not the new tab
+	if mock.last[1].Content != "first idea" || mock.last[len(mock.last)-1].Content != "answer for first" {
+		t.Errorf("last call went to the wrong conversation: %+v", mock.last)
+	}
+}
+
+func TestRun_SpinnerFollowsStderr(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusTTY   bool
+		quiet       QuietLevel
+		wantSpinner bool
+	}{
+		{"stdout redirected, stderr terminal", true, QuietNone, true},
+		{"stderr redirected", false, QuietNone, false},
+		{"quiet", true, QuietPrompt, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(
+				withResponses("```\nprompt\n```"),
+				withTTY(false),
+			)
+			deps.StatusTTY = func() bool { return tt.statusTTY }
+
+			if err := runWithDeps(context.Background(), &CLI{Idea: "idea", Quiet: tt.quiet}, deps); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := deps.Client.(*mockLLM).spinner; got != tt.wantSpinner {
+				t.Errorf("spinner = %v, want %v", got, tt.wantSpinner)
+			}
+		})
+	}
+}
+
+func TestRun_InterruptWhileWaitingForInput(t *testing.T) {
+	r, _ := io.Pipe()
+	deps := newTestDeps(withResponses("What audience?"), withTTY(true))
+	deps.Stdin = r
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	err := runWithDeps(ctx, &CLI{Idea: "test idea"}, deps)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("runWithDeps() = %v, want context.Canceled", err)
+	}
+}
+
+func TestRun_InterruptedRequest(t *testing.T) {
+	deps := newTestDeps(withResponses("```\nprompt\n```"), withTTY(false))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runWithDeps(ctx, &CLI{Idea: "test idea", Quiet: QuietPrompt}, deps)
+	if !errors.Is(err, context.Canceled) || stdout(deps) != "" {
+		t.Errorf("runWithDeps() = %v with stdout %q, want context.Canceled and no output", err, stdout(deps))
+	}
+}
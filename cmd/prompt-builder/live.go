@@ -0,0 +1,140 @@
+// live.go
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// liveEvent is one update of a live session view.
+type liveEvent struct {
+	Type    string `json:"type"` // message, token or end
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// liveBuffer is how many events a watcher may fall behind before it is
+// dropped; its page reconnects and catches up from the history.
+const liveBuffer = 1024
+
+// LiveFeed publishes a session to read-only watchers over server-sent
+// events (--live). Watchers that join late get the conversation so far.
+type LiveFeed struct {
+	Idea  string
+	token string // the secret part of the link
+
+	mu        sync.Mutex
+	history   []liveEvent
+	partial   string // tokens of the reply being streamed
+	published map[*Conversation]int
+	watchers  map[chan liveEvent]bool
+	ended     bool
+}
+
+// NewLiveFeed returns a feed for a session about idea, with a random
+// link token.
+func NewLiveFeed(idea string) *LiveFeed {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return &LiveFeed{
+		Idea:      idea,
+		token:     hex.EncodeToString(b),
+		published: map[*Conversation]int{},
+		watchers:  map[chan liveEvent]bool{},
+	}
+}
+
+// Path is the page of the live view.
+func (f *LiveFeed) Path() string {
+	return "/live/" + f.token
+}
+
+// Sync publishes the messages of conv that watchers have not seen,
+// leaving out the system prompt.
+func (f *LiveFeed) Sync(conv *Conversation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.published[conv]
+	if n > len(conv.Messages) {
+		// Trimmed to fit the context window; what was shown stands
+		n = len(conv.Messages)
+	}
+	for _, m := range conv.Messages[n:] {
+		if m.Role == "system" {
+			continue
+		}
+		if m.Role == "assistant" {
+			f.partial = ""
+		}
+		f.send(liveEvent{Type: "message", Role: m.Role, Content: m.Content})
+	}
+	f.published[conv] = len(conv.Messages)
+}
+
+// Token publishes a piece of the reply being streamed.
+func (f *LiveFeed) Token(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.partial += token
+	f.broadcast(liveEvent{Type: "token", Content: token})
+}
+
+// Close tells watchers the session is over.
+func (f *LiveFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ended {
+		return
+	}
+	f.send(liveEvent{Type: "end"})
+	f.ended = true
+	for ch := range f.watchers {
+		close(ch)
+		delete(f.watchers, ch)
+	}
+}
+
+// send records ev and passes it on. Callers hold f.mu.
+func (f *LiveFeed) send(ev liveEvent) {
+	f.history = append(f.history, ev)
+	f.broadcast(ev)
+}
+
+func (f *LiveFeed) broadcast(ev liveEvent) {
+	for ch := range f.watchers {
+		select {
+		case ch <- ev:
+		default:
+			close(ch)
+			delete(f.watchers, ch)
+		}
+	}
+}
+
+// watch returns the events so far and a channel for the ones to come,
+// which is closed when the session ends.
+func (f *LiveFeed) watch() ([]liveEvent, chan liveEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	past := append([]liveEvent(nil), f.history...)
+	if f.partial != "" {
+		past = append(past, liveEvent{Type: "token", Content: f.partial})
+	}
+	ch := make(chan liveEvent, liveBuffer)
+	if f.ended {
+		close(ch)
+	} else {
+		f.watchers[ch] = true
+	}
+	return past, ch
+}
+
+func (f *LiveFeed) unwatch(ch chan liveEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.watchers[ch] {
+		close(ch)
+		delete(f.watchers, ch)
+	}
+}
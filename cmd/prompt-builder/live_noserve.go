@@ -0,0 +1,15 @@
+//go:build noserve
+
+// live_noserve.go
+package main
+
+import "fmt"
+
+// serveBuilt reports whether this binary has the --live view and its HTTP
+// server.
+const serveBuilt = false
+
+// startLive fails: this binary was built without the live view.
+func startLive(addr string, feed *LiveFeed) (string, func(), error) {
+	return "", nil, fmt.Errorf("the live view is not available: this binary was built with -tags noserve")
+}
@@ -0,0 +1,169 @@
+//go:build !noserve
+
+// live_serve.go
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// serveBuilt reports whether this binary has the --live view and its HTTP
+// server; build with -tags noserve to leave them out.
+const serveBuilt = true
+
+// Handler serves the live view page and its event stream. Requests
+// without the link token get 404.
+func (f *LiveFeed) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /live/{token}", f.authorized(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		livePage.Execute(w, f)
+	}))
+	mux.HandleFunc("GET /live/{token}/events", f.authorized(f.serveEvents))
+	return mux
+}
+
+func (f *LiveFeed) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.PathValue("token")), []byte(f.token)) != 1 {
+			http.NotFound(w, r)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (f *LiveFeed) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	past, ch := f.watch()
+	defer f.unwatch(ch)
+	write := func(ev liveEvent) {
+		data, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	for _, ev := range past {
+		write(ev)
+	}
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			write(ev)
+			flusher.Flush()
+		}
+	}
+}
+
+// startLive serves feed on addr and returns the link to its page and a
+// function that ends the session for watchers and stops serving.
+func startLive(addr string, feed *LiveFeed) (string, func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot start the live view: %v", err)
+	}
+	srv := &http.Server{Handler: feed.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+
+	tcp := ln.Addr().(*net.TCPAddr)
+	host := tcp.IP.String()
+	if tcp.IP.IsUnspecified() {
+		// Listening on every interface; teammates need a reachable name
+		if name, err := os.Hostname(); err == nil {
+			host = name
+		}
+	}
+	link := fmt.Sprintf("http://%s%s", net.JoinHostPort(host, fmt.Sprint(tcp.Port)), feed.Path())
+	stop := func() {
+		feed.Close()
+		// Give watchers a moment to receive the end of the session
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}
+	return link, stop, nil
+}
+
+var livePage = template.Must(template.New("live").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{if .Idea}}{{.Idea}} · {{end}}live · prompt-builder</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; max-width: 50rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; background: #f6f8fa; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5rem; }
+#status { color: #59636e; font-size: .9rem; }
+.msg { margin: .75rem 0; padding: .75rem 1rem; border-radius: 1rem; white-space: pre-wrap; line-height: 1.45; max-width: 85%; }
+.user { background: #0969da; color: #fff; margin-left: auto; border-bottom-right-radius: .25rem; }
+.assistant { background: #fff; border: 1px solid #d0d7de; border-bottom-left-radius: .25rem; }
+.role { display: block; font-size: .75rem; opacity: .7; margin-bottom: .25rem; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{if .Idea}}{{.Idea}}{{else}}Prompt session{{end}}</h1>
+<p id="status">Live, read-only</p>
+</header>
+<main id="log"></main>
+<script>
+const log = document.getElementById("log");
+const status = document.getElementById("status");
+let partial = null;
+
+function bubble(role, text) {
+  const div = document.createElement("div");
+  div.className = "msg " + role;
+  const label = document.createElement("span");
+  label.className = "role";
+  label.textContent = role;
+  const body = document.createElement("span");
+  body.textContent = text;
+  div.append(label, body);
+  log.append(div);
+  return body;
+}
+
+const events = new EventSource(location.pathname + "/events");
+events.onopen = () => { log.replaceChildren(); partial = null; };
+events.onmessage = (e) => {
+  const ev = JSON.parse(e.data);
+  if (ev.type === "token") {
+    if (!partial) partial = bubble("assistant", "");
+    partial.textContent += ev.content;
+  } else if (ev.type === "message") {
+    if (ev.role === "assistant" && partial) {
+      partial.textContent = ev.content;
+      partial = null;
+    } else {
+      bubble(ev.role, ev.content);
+    }
+  } else if (ev.type === "end") {
+    status.textContent = "The session has ended";
+    events.close();
+  }
+  window.scrollTo(0, document.body.scrollHeight);
+};
+events.onerror = () => { status.textContent = "Reconnecting…"; };
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,160 @@
+//go:build !noserve
+
+// live_test.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readLiveEvents returns the events of a live view stream until the
+// session ends.
+func readLiveEvents(t *testing.T, body io.Reader) []liveEvent {
+	t.Helper()
+	var events []liveEvent
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var ev liveEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			t.Fatalf("bad event %q: %v", data, err)
+		}
+		events = append(events, ev)
+		if ev.Type == "end" {
+			break
+		}
+	}
+	return events
+}
+
+func TestLiveFeed_Sync(t *testing.T) {
+	feed := NewLiveFeed("idea")
+	conv := NewConversation("system")
+	conv.AddUserMessage("idea")
+	feed.Sync(conv)
+	feed.Token("Which ")
+	feed.Token("audience?")
+	conv.AddAssistantMessage("Which audience?")
+	feed.Sync(conv)
+	feed.Sync(conv)
+
+	past, _ := feed.watch()
+	want := []liveEvent{
+		{Type: "message", Role: "user", Content: "idea"},
+		{Type: "message", Role: "assistant", Content: "Which audience?"},
+	}
+	if len(past) != len(want) || past[0] != want[0] || past[1] != want[1] {
+		t.Errorf("history = %+v, want %+v (no system prompt, no repeats)", past, want)
+	}
+}
+
+func TestLiveFeed_LateWatcherGetsPartialReply(t *testing.T) {
+	feed := NewLiveFeed("idea")
+	conv := NewConversation("system")
+	conv.AddUserMessage("idea")
+	feed.Sync(conv)
+	feed.Token("Which ")
+	feed.Token("aud")
+
+	past, _ := feed.watch()
+	if last := past[len(past)-1]; last != (liveEvent{Type: "token", Content: "Which aud"}) {
+		t.Errorf("last event = %+v, want the reply so far", last)
+	}
+}
+
+func TestLiveFeed_Handler(t *testing.T) {
+	feed := NewLiveFeed("support <bot>")
+	srv := httptest.NewServer(feed.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + feed.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "support &lt;bot&gt;") {
+		t.Errorf("page: status %d, want 200 and the escaped idea:\n%s", resp.StatusCode, page)
+	}
+
+	for _, path := range []string{"/live/wrong", "/live/wrong/events", "/"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", path, resp.StatusCode)
+		}
+	}
+	resp, err = http.Post(srv.URL+feed.Path(), "text/plain", strings.NewReader("answer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("POST succeeded on a read-only view")
+	}
+}
+
+func TestLiveFeed_StreamsSession(t *testing.T) {
+	deps := newTestDeps(withResponses("Who is it for?", "```\nfinal\n```"), withStdin("new customers\n/bye\n"), withTTY(true))
+	deps.Live = NewLiveFeed("support bot")
+	srv := httptest.NewServer(deps.Live.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + deps.Live.Path() + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support bot", NoCopy: true}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deps.Live.Close()
+
+	var messages []string
+	tokens := 0
+	for _, ev := range readLiveEvents(t, resp.Body) {
+		switch ev.Type {
+		case "message":
+			messages = append(messages, ev.Role+": "+ev.Content)
+		case "token":
+			tokens++
+		}
+	}
+	want := []string{"user: support bot", "assistant: Who is it for?", "user: new customers", "assistant: ```\nfinal\n```"}
+	if strings.Join(messages, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+	if tokens == 0 {
+		t.Error("no tokens were streamed")
+	}
+}
+
+func TestLiveFeed_ClosedFeedEndsWatchers(t *testing.T) {
+	feed := NewLiveFeed("idea")
+	feed.Close()
+	feed.Close()
+	past, ch := feed.watch()
+	if len(past) != 1 || past[0].Type != "end" {
+		t.Errorf("history = %+v, want one end event", past)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("a watcher of an ended session got a live channel")
+	}
+}
@@ -4,30 +4,34 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
-)
 
-// LLMClient abstracts the LLM backend for testing.
-type LLMClient interface {
-	ChatStream(messages []Message, onToken StreamCallback) (string, error)
-	ChatStreamWithSpinner(messages []Message, tty bool, onToken StreamCallback) (string, error)
-}
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
 
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
+// The client interface and message types are the library's, so every
+// client here can be passed to promptbuilder.BuildPrompt.
+type (
+	LLMClient        = promptbuilder.LLMClient
+	Message          = promptbuilder.Message
+	GenerationParams = promptbuilder.GenerationParams
+	StreamCallback   = promptbuilder.StreamCallback
+)
 
 type ChatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	GenerationParams
 }
 
 type ChatStreamChunk struct {
@@ -37,14 +41,30 @@ type ChatStreamChunk struct {
 		} `json:"delta"`
 		FinishReason *string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage"` // in the last chunk when usage was asked for
 }
 
-type StreamCallback func(token string) error
-
 type ChatClient struct {
-	Host   string
-	Model  string
-	client *http.Client
+	Host    string
+	Model   string
+	APIKey  string            // sent as a bearer token when set
+	Headers map[string]string // extra request headers some providers want
+	Params  GenerationParams
+	client  *http.Client
+
+	IncludeUsage bool   // ask the server to report token usage
+	usage        *Usage // reported for the last request
+}
+
+func init() {
+	newClient := func(host, model, apiKey string, hc *http.Client) LLMClient {
+		client := NewChatClient(host, model)
+		client.APIKey = apiKey
+		client.client = hc
+		return client
+	}
+	promptbuilder.RegisterProvider(providerCompatible, promptbuilder.Provider{New: newClient, Host: "http://localhost:11434", Prefill: true})
+	promptbuilder.RegisterProvider(providerOpenAI, promptbuilder.Provider{New: newClient, Host: "https://api.openai.com", APIKeyEnv: "OPENAI_API_KEY", NeedsKey: true})
 }
 
 func NewChatClient(host, model string) *ChatClient {
@@ -55,11 +75,30 @@ func NewChatClient(host, model string) *ChatClient {
 	}
 }
 
-func (c *ChatClient) ChatStream(messages []Message, onToken StreamCallback) (string, error) {
+// SetParams sets the sampling settings for subsequent requests.
+func (c *ChatClient) SetParams(params GenerationParams) {
+	c.Params = params
+}
+
+// LastUsage returns the token usage the server reported for the last
+// request, which it does only when IncludeUsage is set.
+func (c *ChatClient) LastUsage() (Usage, bool) {
+	if c.usage == nil {
+		return Usage{}, false
+	}
+	return *c.usage, true
+}
+
+func (c *ChatClient) ChatStream(ctx context.Context, messages []Message, onToken StreamCallback) (string, error) {
+	c.usage = nil
 	req := ChatRequest{
-		Model:    c.Model,
-		Messages: messages,
-		Stream:   true,
+		Model:            c.Model,
+		Messages:         messages,
+		Stream:           true,
+		GenerationParams: c.Params,
+	}
+	if c.IncludeUsage {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
 	}
 
 	body, err := json.Marshal(req)
@@ -67,19 +106,51 @@ func (c *ChatClient) ChatStream(messages []Message, onToken StreamCallback) (str
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.client.Post(c.Host+"/v1/chat/completions", "application/json", bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Host+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create LLM request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	for k, v := range c.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to LLM server: %w", err)
+		return "", connectError(c.Host, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("LLM request failed: %s - %s", resp.Status, string(body))
+		return "", newHTTPError(resp, c.Model)
 	}
 
+	reply, usage, err := parseSSEStreamUsage(resp.Body, onToken)
+	c.usage = usage
+	return reply, err
+}
+
+// maxSSELine bounds one server-sent event line. A single chunk can carry
+// a whole response when a server does not really stream.
+const maxSSELine = 16 << 20
+
+// parseSSEStream reads an OpenAI-style chat completion event stream,
+// calling onToken for each piece of content, and returns the whole reply.
+func parseSSEStream(r io.Reader, onToken StreamCallback) (string, error) {
+	reply, _, err := parseSSEStreamUsage(r, onToken)
+	return reply, err
+}
+
+// parseSSEStreamUsage is parseSSEStream that also returns the usage the
+// stream reported, or nil.
+func parseSSEStreamUsage(r io.Reader, onToken StreamCallback) (string, *Usage, error) {
 	var accumulated strings.Builder
-	scanner := bufio.NewScanner(resp.Body)
+	var usage *Usage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELine)
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -89,11 +160,12 @@ func (c *ChatClient) ChatStream(messages []Message, onToken StreamCallback) (str
 			continue
 		}
 
-		// Strip "data: " prefix
-		if !strings.HasPrefix(line, "data: ") {
+		// Strip "data:" prefix; the space after it is optional
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
 			continue
 		}
-		data := strings.TrimPrefix(line, "data: ")
+		data = strings.TrimPrefix(data, " ")
 
 		// Check for stream end sentinel
 		if data == "[DONE]" {
@@ -102,7 +174,10 @@ func (c *ChatClient) ChatStream(messages []Message, onToken StreamCallback) (str
 
 		var chunk ChatStreamChunk
 		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-			return "", fmt.Errorf("failed to parse streaming chunk: %w", err)
+			return "", nil, fmt.Errorf("failed to parse streaming chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
 		}
 
 		if len(chunk.Choices) == 0 {
@@ -112,38 +187,51 @@ func (c *ChatClient) ChatStream(messages []Message, onToken StreamCallback) (str
 		content := chunk.Choices[0].Delta.Content
 		if content != "" {
 			if err := onToken(content); err != nil {
-				return "", err
+				return "", nil, err
 			}
 			accumulated.WriteString(content)
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("error reading stream: %w", err)
+		return "", nil, fmt.Errorf("error reading stream: %w", err)
 	}
 
-	return accumulated.String(), nil
+	return accumulated.String(), usage, nil
 }
 
-func (c *ChatClient) ChatStreamWithSpinner(messages []Message, tty bool, onToken StreamCallback) (string, error) {
+func (c *ChatClient) ChatStreamWithSpinner(ctx context.Context, messages []Message, tty bool, onToken StreamCallback) (string, error) {
+	return streamWithSpinner(tty, onToken, func(cb StreamCallback) (string, error) {
+		return c.ChatStream(ctx, messages, cb)
+	})
+}
+
+// streamWithSpinner runs stream with a spinner shown until the first token,
+// or until stream returns when it fails before one, so a retry does not
+// start a second spinner beside it.
+func streamWithSpinner(tty bool, onToken StreamCallback, stream func(StreamCallback) (string, error)) (string, error) {
 	var spinner *Spinner
 	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			if spinner != nil {
+				spinner.Stop()
+			}
+		})
+	}
 
 	if tty {
 		spinner = NewSpinnerWithTTY("Thinking...", tty)
 		spinner.Start()
 	}
+	defer stop()
 
 	wrappedCallback := func(token string) error {
-		once.Do(func() {
-			if spinner != nil {
-				spinner.Stop()
-			}
-		})
+		stop()
 		return onToken(token)
 	}
 
-	return c.ChatStream(messages, wrappedCallback)
+	return stream(wrappedCallback)
 }
 
 type Conversation struct {
@@ -173,8 +261,12 @@ type Spinner struct {
 	interval time.Duration
 	message  string
 	tty      bool
+	out      io.Writer
+	started  bool
+	stopOnce sync.Once
 	stopCh   chan struct{}
 	doneCh   chan struct{}
+	remove   func() // unregisters the exit cleanup
 }
 
 func NewSpinner(message string) *Spinner {
@@ -187,25 +279,32 @@ func NewSpinnerWithTTY(message string, tty bool) *Spinner {
 		interval: 120 * time.Millisecond,
 		message:  message,
 		tty:      tty,
+		out:      os.Stderr, // keeps progress out of redirected stdout
 		stopCh:   make(chan struct{}),
 		doneCh:   make(chan struct{}),
 	}
 }
 
+// Stop halts the animation and waits until the line is cleared, so
+// output written afterwards is not overdrawn.
 func (s *Spinner) Stop() {
-	select {
-	case <-s.stopCh:
-		// Already stopped
-		return
-	default:
+	s.stopOnce.Do(func() {
 		close(s.stopCh)
-	}
+		if s.started {
+			<-s.doneCh
+			s.remove()
+		}
+	})
 }
 
 func (s *Spinner) Start() {
 	if !s.tty {
 		return
 	}
+	s.started = true
+	// An interrupt mid-spin must not leave the frame or a hidden cursor behind
+	s.remove = onExit(s.Stop)
+	fmt.Fprint(s.out, hideCursor)
 	go func() {
 		ticker := time.NewTicker(s.interval)
 		defer ticker.Stop()
@@ -218,7 +317,7 @@ func (s *Spinner) Start() {
 				s.clearLine()
 				return
 			case <-ticker.C:
-				fmt.Printf("\r%c %s", s.frames[frame], s.message)
+				fmt.Fprintf(s.out, "\r%c %s", s.frames[frame], s.message)
 				frame = (frame + 1) % len(s.frames)
 			}
 		}
@@ -228,5 +327,5 @@ func (s *Spinner) Start() {
 func (s *Spinner) clearLine() {
 	// Clear the line: carriage return, spaces, carriage return
 	clearLen := len(s.message) + 3 // frame + space + message
-	fmt.Printf("\r%s\r", strings.Repeat(" ", clearLen))
+	fmt.Fprintf(s.out, "\r%s\r%s", strings.Repeat(" ", clearLen), showCursor)
 }
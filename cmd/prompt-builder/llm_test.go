@@ -2,13 +2,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestChatRequest_Serialization(t *testing.T) {
@@ -126,7 +129,7 @@ func TestChatClient_ChatStream_HappyPath(t *testing.T) {
 	}
 
 	var tokens []string
-	response, err := client.ChatStream(messages, func(token string) error {
+	response, err := client.ChatStream(context.Background(), messages, func(token string) error {
 		tokens = append(tokens, token)
 		return nil
 	})
@@ -161,7 +164,7 @@ func TestChatClient_ChatStream_CallbackError(t *testing.T) {
 
 	callbackErr := fmt.Errorf("callback failed")
 	callCount := 0
-	_, err := client.ChatStream(messages, func(token string) error {
+	_, err := client.ChatStream(context.Background(), messages, func(token string) error {
 		callCount++
 		if callCount == 2 {
 			return callbackErr
@@ -187,7 +190,7 @@ func TestChatClient_ChatStream_MalformedJSON(t *testing.T) {
 	client := NewChatClient(server.URL, "llama3.2")
 	messages := []Message{{Role: "user", Content: "Hi"}}
 
-	_, err := client.ChatStream(messages, func(token string) error {
+	_, err := client.ChatStream(context.Background(), messages, func(token string) error {
 		return nil
 	})
 
@@ -209,7 +212,7 @@ func TestChatClient_ChatStream_HTTPError(t *testing.T) {
 	client := NewChatClient(server.URL, "llama3.2")
 	messages := []Message{{Role: "user", Content: "Hi"}}
 
-	_, err := client.ChatStream(messages, func(token string) error {
+	_, err := client.ChatStream(context.Background(), messages, func(token string) error {
 		return nil
 	})
 
@@ -229,7 +232,7 @@ func TestChatClient_ChatStreamWithSpinner_StopsOnFirstToken(t *testing.T) {
 	messages := []Message{{Role: "user", Content: "Hi"}}
 
 	var tokens []string
-	response, err := client.ChatStreamWithSpinner(messages, false, func(token string) error {
+	response, err := client.ChatStreamWithSpinner(context.Background(), messages, false, func(token string) error {
 		tokens = append(tokens, token)
 		return nil
 	})
@@ -245,6 +248,26 @@ func TestChatClient_ChatStreamWithSpinner_StopsOnFirstToken(t *testing.T) {
 	}
 }
 
+func TestStreamWithSpinner_StopsOnErrorBeforeFirstToken(t *testing.T) {
+	registered := func() int {
+		cleanups.Lock()
+		defer cleanups.Unlock()
+		return len(cleanups.fns)
+	}
+	before := registered()
+
+	_, err := streamWithSpinner(true, func(string) error { return nil }, func(StreamCallback) (string, error) {
+		return "", &LLMError{Kind: errStalled}
+	})
+
+	if err == nil {
+		t.Fatal("expected the stream's error")
+	}
+	if registered() != before {
+		t.Error("the spinner is still running after the stream failed")
+	}
+}
+
 func TestConversation_AddMessage(t *testing.T) {
 	conv := NewConversation("You are helpful.")
 
@@ -325,3 +348,149 @@ func TestSpinner_StartNonTTY(t *testing.T) {
 	s.Start() // Should be no-op, not start goroutine
 	s.Stop()  // Should be safe
 }
+
+func TestChatRequest_SerializesParams(t *testing.T) {
+	temp, seed := 0.2, 7
+	req := ChatRequest{Model: "m", GenerationParams: GenerationParams{Temperature: &temp, Seed: &seed}}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	s := string(data)
+	if !strings.Contains(s, `"temperature":0.2`) || !strings.Contains(s, `"seed":7`) {
+		t.Errorf("params not serialized: %s", s)
+	}
+	if strings.Contains(s, "max_tokens") || strings.Contains(s, "top_p") {
+		t.Errorf("unset params should be omitted: %s", s)
+	}
+}
+
+func TestSpinner_WritesToItsOwnWriter(t *testing.T) {
+	var buf syncBuffer
+	s := NewSpinnerWithTTY("Thinking...", true)
+	s.out = &buf
+	s.interval = 5 * time.Millisecond
+
+	s.Start()
+	time.Sleep(30 * time.Millisecond)
+	s.Stop()
+
+	out := buf.String()
+	if !strings.Contains(out, "Thinking...") {
+		t.Errorf("expected spinner frames, got: %q", out)
+	}
+	if !strings.HasPrefix(out, hideCursor) || !strings.HasSuffix(out, showCursor) {
+		t.Errorf("expected cursor hidden then restored, got: %q", out)
+	}
+}
+
+// syncBuffer is a writer safe to share with the spinner goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestParseSSEStream(t *testing.T) {
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\r\n\r\n" +
+		": keep-alive comment\n\n" +
+		"data:{\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+		"data: {\"choices\":[]}\n\n" +
+		"data: [DONE]\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"ignored\"}}]}\n\n"
+
+	var tokens []string
+	got, err := parseSSEStream(strings.NewReader(stream), func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("parseSSEStream() error = %v", err)
+	}
+	if got != "Hello" || len(tokens) != 2 {
+		t.Errorf("parseSSEStream() = %q from %q, want %q from 2 tokens", got, tokens, "Hello")
+	}
+}
+
+func TestParseSSEStream_LongLine(t *testing.T) {
+	content := strings.Repeat("x", 1<<20)
+	data, _ := json.Marshal(content)
+	stream := fmt.Sprintf("data: {\"choices\":[{\"delta\":{\"content\":%s}}]}\n\ndata: [DONE]\n\n", data)
+
+	got, err := parseSSEStream(strings.NewReader(stream), func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("parseSSEStream() error = %v", err)
+	}
+	if got != content {
+		t.Errorf("parseSSEStream() returned %d bytes, want %d", len(got), len(content))
+	}
+}
+
+func FuzzParseSSEStream(f *testing.F) {
+	f.Add([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+	f.Add([]byte("data: {\"choices\":[{\"delta\":{}}]}\n"))
+	f.Add([]byte("data: {\"choices\":null}\n"))
+	f.Add([]byte("data:\n\ndata: \n"))
+	f.Add([]byte("event: ping\ndata: {}\n"))
+
+	f.Fuzz(func(t *testing.T, stream []byte) {
+		var tokens strings.Builder
+		got, err := parseSSEStream(strings.NewReader(string(stream)), func(token string) error {
+			if token == "" {
+				t.Error("onToken called with empty token")
+			}
+			tokens.WriteString(token)
+			return nil
+		})
+		if err == nil && got != tokens.String() {
+			t.Errorf("reply %q differs from streamed tokens %q", got, tokens.String())
+		}
+	})
+}
+
+func FuzzParseSSEStream_RoundTrip(f *testing.F) {
+	f.Add("Hello, world", 3)
+	f.Add("line\nbreaks\r\nand \"quotes\" and  ", 5)
+	f.Add("data: [DONE]", 1)
+
+	f.Fuzz(func(t *testing.T, content string, size int) {
+		if !utf8.ValidString(content) {
+			t.Skip("JSON cannot carry invalid UTF-8")
+		}
+		if size <= 0 || size > 64 {
+			size = 7
+		}
+		var stream strings.Builder
+		for rest := content; rest != ""; {
+			n := min(size, len(rest))
+			for n < len(rest) && !utf8.RuneStart(rest[n]) {
+				n++ // keep runes whole
+			}
+			chunk, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"delta": map[string]string{"content": rest[:n]}}}})
+			fmt.Fprintf(&stream, "data: %s\n\n", chunk)
+			rest = rest[n:]
+		}
+		stream.WriteString("data: [DONE]\n\n")
+
+		got, err := parseSSEStream(strings.NewReader(stream.String()), func(string) error { return nil })
+		if err != nil {
+			t.Fatalf("parseSSEStream() error = %v", err)
+		}
+		if got != content {
+			t.Errorf("round trip = %q, want %q", got, content)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+// llmclient_conformance_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// llmClientHarness adapts one LLMClient implementation to the conformance
+// suite. A nil field skips the checks that need it.
+type llmClientHarness struct {
+	// Streaming returns a client whose next reply is tokens, in order.
+	Streaming func(t *testing.T, tokens []string) LLMClient
+	// Status returns a client whose server fails every request with code.
+	Status func(t *testing.T, code int) LLMClient
+	// Unreachable returns a client with no server behind it.
+	Unreachable func(t *testing.T) LLMClient
+}
+
+// testLLMClientConformance checks the behaviour runWithDeps relies on:
+// tokens arrive in order and add up to the reply, a callback error or a
+// cancelled context stops the stream, and failures are worded so main
+// maps them to ExitLLMError.
+func testLLMClientConformance(t *testing.T, h llmClientHarness) {
+	messages := []Message{{Role: "user", Content: "idea"}}
+
+	t.Run("tokens add up to the reply in order", func(t *testing.T) {
+		property := func(tokens []string) bool {
+			client := h.Streaming(t, tokens)
+			for _, stream := range []func(StreamCallback) (string, error){
+				func(cb StreamCallback) (string, error) { return client.ChatStream(context.Background(), messages, cb) },
+				func(cb StreamCallback) (string, error) {
+					return client.ChatStreamWithSpinner(context.Background(), messages, false, cb)
+				},
+			} {
+				var streamed strings.Builder
+				reply, err := stream(func(token string) error {
+					if token == "" {
+						t.Error("empty token streamed")
+					}
+					streamed.WriteString(token)
+					return nil
+				})
+				if err != nil {
+					t.Logf("stream error: %v", err)
+					return false
+				}
+				if want := strings.Join(tokens, ""); reply != want || streamed.String() != want {
+					t.Logf("reply %q, streamed %q, want %q", reply, streamed.String(), want)
+					return false
+				}
+				client = h.Streaming(t, tokens)
+			}
+			return true
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("callback error stops the stream", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		client := h.Streaming(t, []string{"one ", "two ", "three"})
+		_, err := client.ChatStream(context.Background(), messages, func(string) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Errorf("ChatStream() error = %v, want the callback's error", err)
+		}
+		if calls != 1 {
+			t.Errorf("callback called %d times after failing, want 1", calls)
+		}
+	})
+
+	t.Run("cancelled context fails the request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client := h.Streaming(t, []string{"one ", "two"})
+		if _, err := client.ChatStream(ctx, messages, func(string) error { return nil }); !errors.Is(err, context.Canceled) {
+			t.Errorf("ChatStream() error = %v, want context.Canceled", err)
+		}
+	})
+
+	if h.Status != nil {
+		t.Run("server error maps to an LLM error", func(t *testing.T) {
+			for _, code := range []int{http.StatusNotFound, http.StatusInternalServerError} {
+				_, err := h.Status(t, code).ChatStream(context.Background(), messages, func(string) error { return nil })
+				if err == nil || !strings.Contains(err.Error(), "LLM") || !strings.Contains(err.Error(), fmt.Sprint(code)) {
+					t.Errorf("status %d: error = %v, want an LLM error naming the status", code, err)
+				}
+			}
+		})
+	}
+
+	if h.Unreachable != nil {
+		t.Run("unreachable server maps to a connection error", func(t *testing.T) {
+			_, err := h.Unreachable(t).ChatStream(context.Background(), messages, func(string) error { return nil })
+			if err == nil || !strings.Contains(err.Error(), "connect") {
+				t.Errorf("error = %v, want a connection error", err)
+			}
+		})
+	}
+}
+
+func TestChatClient_Conformance(t *testing.T) {
+	testLLMClientConformance(t, llmClientHarness{
+		Streaming: func(t *testing.T, tokens []string) LLMClient {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, token := range tokens {
+					chunk, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"delta": map[string]string{"content": token}}}})
+					fmt.Fprintf(w, "data: %s\n\n", chunk)
+				}
+				fmt.Fprint(w, "data: [DONE]\n\n")
+			}))
+			t.Cleanup(server.Close)
+			return NewChatClient(server.URL, "test")
+		},
+		Status: func(t *testing.T, code int) LLMClient {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "boom", code)
+			}))
+			t.Cleanup(server.Close)
+			return NewChatClient(server.URL, "test")
+		},
+		Unreachable: func(t *testing.T) LLMClient {
+			return NewChatClient("http://127.0.0.1:1", "test")
+		},
+	})
+}
+
+func TestAnthropicClient_Conformance(t *testing.T) {
+	testLLMClientConformance(t, llmClientHarness{
+		Streaming: func(t *testing.T, tokens []string) LLMClient {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\"}\n\n")
+				for _, token := range tokens {
+					event, _ := json.Marshal(map[string]any{"type": "content_block_delta", "delta": map[string]string{"type": "text_delta", "text": token}})
+					fmt.Fprintf(w, "event: content_block_delta\ndata: %s\n\n", event)
+				}
+				fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+			}))
+			t.Cleanup(server.Close)
+			return NewAnthropicClient(server.URL, "test", "key")
+		},
+		Status: func(t *testing.T, code int) LLMClient {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "boom", code)
+			}))
+			t.Cleanup(server.Close)
+			return NewAnthropicClient(server.URL, "test", "key")
+		},
+		Unreachable: func(t *testing.T) LLMClient {
+			return NewAnthropicClient("http://127.0.0.1:1", "test", "key")
+		},
+	})
+}
+
+func TestTourClient_Conformance(t *testing.T) {
+	testLLMClientConformance(t, llmClientHarness{
+		Streaming: func(t *testing.T, tokens []string) LLMClient {
+			return &tourClient{replies: []string{strings.Join(tokens, "")}}
+		},
+	})
+}
+
+// The mock stands in for real clients in integration tests, so it must
+// behave like them.
+func TestMockLLM_Conformance(t *testing.T) {
+	testLLMClientConformance(t, llmClientHarness{
+		Streaming: func(t *testing.T, tokens []string) LLMClient {
+			return &mockLLM{responses: []string{strings.Join(tokens, "")}}
+		},
+	})
+}
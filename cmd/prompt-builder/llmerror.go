@@ -0,0 +1,187 @@
+// llmerror.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// Kinds of LLMError the user gets a specific message and next step for.
+const (
+	errModelNotFound   = "model not found"
+	errContextExceeded = "context exceeded"
+	errAuth            = "auth failed"
+	errRateLimited     = "rate limited"
+	errOverloaded      = "overloaded"
+	errOutOfMemory     = "out of memory"
+	errUnreachable     = "unreachable"
+	errStalled         = "stalled"   // the reply stopped arriving mid-stream
+	errTooLarge        = "too large" // the reply passed max_response_size
+)
+
+// LLMError is a failed request to the LLM server, classified so the user
+// sees what went wrong and what to do about it rather than the raw reply.
+type LLMError struct {
+	Kind    string // one of the err constants above, or "" if unrecognized
+	Status  string // such as "404 Not Found"; empty when the server was not reached
+	Message string // the server's own explanation, or the connection error
+	Model   string
+	Host    string
+	Limit   string // rate limit details from the response headers
+}
+
+func (e *LLMError) Error() string {
+	var summary string
+	switch e.Kind {
+	case errModelNotFound:
+		summary = fmt.Sprintf("model %q not found", e.Model)
+	case errContextExceeded:
+		summary = "the conversation is longer than the model's context window"
+	case errAuth:
+		summary = "the server rejected the API key"
+	case errRateLimited:
+		summary = "rate limited"
+	case errOverloaded:
+		summary = "the server is overloaded"
+	case errOutOfMemory:
+		summary = fmt.Sprintf("the server ran out of memory for model %q", e.Model)
+	case errUnreachable:
+		return fmt.Sprintf("failed to connect to LLM server at %s: %s\n\n%s", e.Host, e.Message, e.Hint())
+	case errStalled:
+		summary = "the stream stalled"
+	case errTooLarge:
+		summary = "the reply passed max_response_size"
+	}
+
+	details := e.Status
+	if e.Limit != "" {
+		details += ", " + e.Limit
+	}
+	msg := "LLM request failed: " + details
+	switch {
+	case summary != "" && details == "":
+		msg = "LLM request failed: " + summary
+	case summary != "":
+		msg = fmt.Sprintf("LLM request failed: %s (%s)", summary, details)
+	}
+	if e.Message != "" {
+		msg += " - " + e.Message
+	}
+	if hint := e.Hint(); hint != "" {
+		msg += "\n\n" + hint
+	}
+	return msg
+}
+
+// Hint returns the next step for the error, or "" if there is none.
+func (e *LLMError) Hint() string {
+	switch e.Kind {
+	case errModelNotFound:
+		if strings.Contains(e.Message, "pull") {
+			// Ollama's wording; the model can simply be downloaded
+			return fmt.Sprintf("Run `ollama pull %s`, or pick an installed model from `prompt-builder models`.", e.Model)
+		}
+		return "Pick a model from `prompt-builder models` and pass it with --model or set model in the config file."
+	case errContextExceeded:
+		return "Start a new session, or set context_window to the model's context size so the history is trimmed first (see context_overflow)."
+	case errAuth:
+		return "Check the key in api_key_env, or in the provider's usual variable such as OPENAI_API_KEY or ANTHROPIC_API_KEY, and that it may use this model."
+	case errRateLimited:
+		return "Wait a minute and try again, or ask the provider for a higher limit."
+	case errOverloaded:
+		return "Try again in a minute."
+	case errOutOfMemory:
+		return "Use a smaller or more heavily quantized model (such as llama3.2:1b), lower the context size, or unload other models (`ollama ps`, then `ollama stop <model>`)."
+	case errUnreachable:
+		return "Start the server (for Ollama, `ollama serve`) or fix host in the config file. `prompt-builder doctor` checks the connection."
+	case errStalled:
+		return "The server stopped sending the reply. For Ollama, `ollama ps` shows whether the model is still running and `ollama stop <model>` unloads it. If the model is just slow, raise stall_timeout in the config file."
+	case errTooLarge:
+		return "The model may be stuck repeating itself; try again, or with a lower temperature. If the replies really are that long, raise max_response_size in the config file."
+	}
+	return ""
+}
+
+// newHTTPError reads a failed response from the LLM server into an
+// LLMError for a request that used model.
+func newHTTPError(resp *http.Response, model string) *LLMError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	e := &LLMError{
+		Status:  resp.Status,
+		Message: errorMessage(body),
+		Model:   model,
+		Limit:   rateLimitInfo(resp.Header),
+	}
+	e.Kind = classifyLLMError(resp.StatusCode, e.Message)
+	return e
+}
+
+// connectError describes a request that did not reach host.
+func connectError(host string, err error) error {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return &LLMError{Kind: errUnreachable, Host: host, Message: err.Error()}
+	}
+	return fmt.Errorf("failed to connect to LLM server: %w", err)
+}
+
+// errorMessage extracts the explanation from an error body: OpenAI and
+// Anthropic send {"error": {"message": ...}}, Ollama {"error": "..."}.
+// Anything else is returned as is.
+func errorMessage(body []byte) string {
+	var v struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if json.Unmarshal(body, &v) == nil {
+		var s string
+		var obj struct {
+			Message string `json:"message"`
+		}
+		switch {
+		case json.Unmarshal(v.Error, &s) == nil && s != "":
+			return s
+		case json.Unmarshal(v.Error, &obj) == nil && obj.Message != "":
+			return obj.Message
+		case v.Message != "":
+			return v.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// classifyLLMError returns the kind of error a status and message mean,
+// or "" if they match none. Servers word these differently, so messages
+// are matched loosely.
+func classifyLLMError(status int, message string) string {
+	m := strings.ToLower(message)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden ||
+		containsAny(m, "invalid api key", "incorrect api key", "invalid x-api-key"):
+		return errAuth
+	case status == http.StatusTooManyRequests || strings.Contains(m, "rate limit"):
+		return errRateLimited
+	case status == 529 || strings.Contains(m, "overloaded"):
+		return errOverloaded
+	case containsAny(m, "out of memory", "failed to allocate", "requires more system memory", "insufficient memory"):
+		return errOutOfMemory
+	case containsAny(m, "context length", "context_length_exceeded", "context window", "maximum context", "prompt is too long", "too many tokens"):
+		return errContextExceeded
+	case strings.Contains(m, "model") && (status == http.StatusNotFound || containsAny(m, "not found", "does not exist")):
+		return errModelNotFound
+	}
+	return ""
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
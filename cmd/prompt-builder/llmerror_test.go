@@ -0,0 +1,84 @@
+// llmerror_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClassifyLLMError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		kind   string
+		hint   string
+	}{
+		{"ollama missing model", 404, `{"error":{"message":"model \"llama3.2\" not found, try pulling it first","type":"api_error"}}`, errModelNotFound, "ollama pull llama3.2"},
+		{"openai missing model", 404, `{"error":{"message":"The model ` + "`llama3.2`" + ` does not exist or you do not have access to it.","code":"model_not_found"}}`, errModelNotFound, "prompt-builder models"},
+		{"anthropic missing model", 404, `{"type":"error","error":{"type":"not_found_error","message":"model: llama3.2"}}`, errModelNotFound, "prompt-builder models"},
+		{"openai context", 400, `{"error":{"message":"This model's maximum context length is 8192 tokens.","code":"context_length_exceeded"}}`, errContextExceeded, "context_window"},
+		{"anthropic context", 400, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`, errContextExceeded, "context_window"},
+		{"bad key", 401, `{"error":{"message":"Incorrect API key provided"}}`, errAuth, "api_key_env"},
+		{"rate limit", 429, `{"error":{"message":"Rate limit reached"}}`, errRateLimited, "try again"},
+		{"anthropic overloaded", 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, errOverloaded, "Try again"},
+		{"ollama oom", 500, `{"error":"model requires more system memory (12.0 GiB) than is available (8.0 GiB)"}`, errOutOfMemory, "smaller"},
+		{"cuda oom", 500, `{"error":"llama runner process has terminated: CUDA error: out of memory"}`, errOutOfMemory, "ollama ps"},
+		{"unknown", 500, "upstream exploded", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			resp.WriteHeader(tt.status)
+			resp.WriteString(tt.body)
+			e := newHTTPError(resp.Result(), "llama3.2")
+			if e.Kind != tt.kind {
+				t.Errorf("Kind = %q, want %q (message %q)", e.Kind, tt.kind, e.Message)
+			}
+			if !strings.Contains(e.Hint(), tt.hint) || (tt.hint == "") != (e.Hint() == "") {
+				t.Errorf("Hint() = %q, want it to mention %q", e.Hint(), tt.hint)
+			}
+		})
+	}
+}
+
+func TestLLMError_Error(t *testing.T) {
+	e := &LLMError{Kind: errModelNotFound, Status: "404 Not Found", Model: "llama3.2", Message: `model "llama3.2" not found, try pulling it first`}
+	want := "LLM request failed: model \"llama3.2\" not found (404 Not Found) - model \"llama3.2\" not found, try pulling it first\n\n" +
+		"Run `ollama pull llama3.2`, or pick an installed model from `prompt-builder models`."
+	if got := e.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	e = &LLMError{Status: "500 Internal Server Error", Message: "boom"}
+	if got := e.Error(); got != "LLM request failed: 500 Internal Server Error - boom" {
+		t.Errorf("Error() = %q, want the status and message alone", got)
+	}
+}
+
+func TestChatClient_ConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := "http://" + ln.Addr().String()
+	ln.Close()
+
+	_, err = NewChatClient(host, "m").ChatStream(context.Background(), nil, func(string) error { return nil })
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) || llmErr.Kind != errUnreachable || !strings.Contains(err.Error(), "ollama serve") {
+		t.Errorf("ChatStream() error = %v, want an unreachable server with a hint", err)
+	}
+}
+
+func TestRun_LLMErrorNotWrappedTwice(t *testing.T) {
+	deps := newTestDeps(withLLMError(&LLMError{Kind: errAuth, Status: "401 Unauthorized"}), withTTY(false))
+	err := runWithDeps(context.Background(), &CLI{Idea: "x", Quiet: QuietPrompt}, deps)
+	if err == nil || strings.Count(err.Error(), "LLM request failed") != 1 {
+		t.Errorf("error = %v, want it to say once that the request failed", err)
+	}
+}
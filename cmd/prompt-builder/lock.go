@@ -0,0 +1,118 @@
+// lock.go
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// Lock is a section of an approved draft that revisions must keep verbatim.
+type Lock struct {
+	Name string `json:"name"` // heading or text given to /lock
+	Text string `json:"text"` // the locked text, heading included
+}
+
+// maxRestores bounds how often the model is asked in a row to restore
+// locked sections, in case it keeps changing them.
+const maxRestores = 2
+
+// handleLock implements /lock: with a heading it locks that section of the
+// current draft, without one it lists the locks.
+func handleLock(args, lastResponse string, env *CommandEnv) error {
+	if env.Session == nil {
+		return fmt.Errorf("Locks are not available here")
+	}
+
+	name := strings.TrimSpace(strings.Trim(args, `"'`))
+	if name == "" {
+		if len(env.Session.Locks) == 0 {
+			fmt.Fprintln(env.Out, `No locked sections. Lock one with /lock "<heading>"`)
+			return nil
+		}
+		for i, l := range env.Session.Locks {
+			fmt.Fprintf(env.Out, "  %d. %s\n", i+1, l.Name)
+		}
+		return nil
+	}
+
+	prompt := promptbuilder.ExtractLastCodeBlock(lastResponse)
+	if prompt == "" {
+		return fmt.Errorf("No draft to lock yet")
+	}
+	text, ok := lockedSection(prompt, name)
+	if !ok {
+		return fmt.Errorf("No section %q in the current draft", name)
+	}
+	env.Session.Locks = append(env.Session.Locks, Lock{Name: name, Text: text})
+	fmt.Fprintf(env.Out, "Locked %d: %s (%d lines)\n", len(env.Session.Locks), name, strings.Count(text, "\n")+1)
+	return nil
+}
+
+// handleUnlock implements /unlock <n>.
+func handleUnlock(args string, env *CommandEnv) error {
+	if env.Session == nil {
+		return fmt.Errorf("Locks are not available here")
+	}
+	n, err := strconv.Atoi(args)
+	if err != nil {
+		return fmt.Errorf("Usage: /unlock <n>")
+	}
+	if n < 1 || n > len(env.Session.Locks) {
+		return fmt.Errorf("No lock %d. Type /lock to list them.", n)
+	}
+	removed := env.Session.Locks[n-1]
+	env.Session.Locks = append(env.Session.Locks[:n-1], env.Session.Locks[n:]...)
+	fmt.Fprintf(env.Out, "Unlocked: %s\n", removed.Name)
+	return nil
+}
+
+// lockedSection finds name in prompt. A heading, given with or without its
+// #s, selects everything up to the next heading of the same or a higher
+// level; any other name must appear in prompt and selects just that text.
+func lockedSection(prompt, name string) (string, bool) {
+	lines := strings.Split(prompt, "\n")
+	title := strings.TrimSpace(strings.TrimLeft(name, "#"))
+	for i, line := range lines {
+		level := headingLevel(line)
+		if level == 0 || !strings.EqualFold(strings.TrimSpace(line[level:]), title) {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) {
+			if l := headingLevel(lines[end]); l > 0 && l <= level {
+				break
+			}
+			end++
+		}
+		return strings.TrimRight(strings.Join(lines[i:end], "\n"), "\n "), true
+	}
+
+	if strings.Contains(prompt, name) {
+		return name, true
+	}
+	return "", false
+}
+
+// changedLocks returns the locks whose text is no longer in prompt.
+func changedLocks(prompt string, locks []Lock) []Lock {
+	var changed []Lock
+	for _, l := range locks {
+		if !strings.Contains(prompt, l.Text) {
+			changed = append(changed, l)
+		}
+	}
+	return changed
+}
+
+// restoreMessage asks the model to put changed locked sections back.
+func restoreMessage(changed []Lock) string {
+	var b strings.Builder
+	b.WriteString("These sections were approved and must not change. Rewrite the prompt with each of them restored exactly as below, keeping your other edits:\n")
+	for _, l := range changed {
+		fmt.Fprintf(&b, "\n%s\n", l.Text)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,103 @@
+// lock_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+const lockDraft = "Here it is:\n```\n# Role\nYou review code.\n\n## Output format\nA list of findings.\nMost severe first.\n\n## Ask\nReview the diff.\n```"
+
+func TestLockedSection(t *testing.T) {
+	prompt := promptbuilder.ExtractLastCodeBlock(lockDraft)
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"## Output format", "## Output format\nA list of findings.\nMost severe first.", true},
+		{"output format", "## Output format\nA list of findings.\nMost severe first.", true},
+		// A level-1 heading runs on through its subsections
+		{"# Role", strings.TrimSuffix(prompt, "\n"), true},
+		{"Review the diff.", "Review the diff.", true},
+		{"## Missing", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := lockedSection(prompt, tt.name)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("lockedSection(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestHandleCommand_Lock(t *testing.T) {
+	var out bytes.Buffer
+	env := &CommandEnv{Session: &Session{}, Out: &out}
+
+	if _, err := HandleCommand(`/lock "## Ask"`, "", env); err == nil {
+		t.Error("expected error locking without a draft")
+	}
+	if _, err := HandleCommand(`/lock "## Missing"`, lockDraft, env); err == nil {
+		t.Error("expected error locking a section that is not there")
+	}
+	if _, err := HandleCommand(`/lock "## Output format"`, lockDraft, env); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.Session.Locks) != 1 || !strings.HasSuffix(env.Session.Locks[0].Text, "Most severe first.") {
+		t.Errorf("Locks = %+v", env.Session.Locks)
+	}
+
+	out.Reset()
+	HandleCommand("/lock", lockDraft, env)
+	if !strings.Contains(out.String(), "1. ## Output format") {
+		t.Errorf("list = %q", out.String())
+	}
+
+	if _, err := HandleCommand("/unlock 2", "", env); err == nil {
+		t.Error("expected error for a lock that does not exist")
+	}
+	if _, err := HandleCommand("/unlock 1", "", env); err != nil || len(env.Session.Locks) != 0 {
+		t.Errorf("/unlock 1: err %v, Locks %+v", err, env.Session.Locks)
+	}
+}
+
+func TestRunWithDeps_LockRestoresChangedSection(t *testing.T) {
+	changed := strings.Replace(lockDraft, "Most severe first.", "In any order.", 1)
+	deps := newTestDeps(
+		withResponses(lockDraft, changed, lockDraft),
+		withStdin("/lock \"## Output format\"\nmake it shorter\n/quit\n"),
+	)
+	if err := runWithDeps(context.Background(), &CLI{Idea: "idea"}, deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := stderr(deps); !strings.Contains(got, `changed locked section "## Output format"`) {
+		t.Errorf("stderr = %q, want a warning about the changed section", got)
+	}
+	mock := deps.Client.(*mockLLM)
+	if mock.calls != 3 {
+		t.Fatalf("calls = %d, want a third turn restoring the section", mock.calls)
+	}
+	restore := mock.last[len(mock.last)-1].Content
+	if !strings.Contains(restore, "## Output format\nA list of findings.\nMost severe first.") {
+		t.Errorf("restore request = %q, want the locked text verbatim", restore)
+	}
+}
+
+func TestRunWithDeps_LockGivesUpAfterMaxRestores(t *testing.T) {
+	changed := strings.Replace(lockDraft, "Most severe first.", "In any order.", 1)
+	deps := newTestDeps(
+		withResponses(lockDraft, changed, changed, changed, changed),
+		withStdin("/lock \"## Output format\"\nmake it shorter\n/quit\n"),
+	)
+	if err := runWithDeps(context.Background(), &CLI{Idea: "idea"}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if mock := deps.Client.(*mockLLM); mock.calls != 2+maxRestores {
+		t.Errorf("calls = %d, want %d", mock.calls, 2+maxRestores)
+	}
+}
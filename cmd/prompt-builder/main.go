@@ -2,47 +2,180 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
 	"golang.org/x/term"
 )
 
 const (
 	ExitSuccess     = 0
 	ExitConfigError = 1
-	ExitLLMError = 2
+	ExitLLMError    = 2
 	ExitNoModel     = 3
+
+	// Warnings that --strict turns into failures
+	ExitDeprecatedConfig = 4
+	ExitNoClipboard      = 5
+	ExitTruncated        = 6
+
+	ExitDeadline      = 7 // --deadline passed; the output, if any, is a draft
+	ExitClarification = 8 // a run without a terminal got a question instead of a prompt
+	ExitFailover      = 9 // --strict: a host in hosts failed and another would have answered
+
+	ExitFailure = 10 // any other failure
 )
 
+// strictError is a warning promoted to an error by --strict. Code is the
+// process exit status.
+type strictError struct {
+	Code int
+	Msg  string
+}
+
+func (e *strictError) Error() string {
+	return e.Msg + " (--strict)"
+}
+
 var (
 	version = "dev"
 )
 
+// QuietLevel controls how much a run prints. Any level above QuietNone
+// makes the run non-interactive, since there is no conversation to answer.
+type QuietLevel int
+
+const (
+	QuietNone      QuietLevel = iota // full conversation
+	QuietPrompt                      // -q: only the final prompt on stdout
+	QuietClipboard                   // -qq: final prompt to the clipboard, nothing on stdout
+	QuietSilent                      // --silent: nothing at all, exit code only
+)
+
+// quietFlag is a boolean flag that raises the quiet level to value.
+type quietFlag struct {
+	level *QuietLevel
+	value QuietLevel
+}
+
+func (f *quietFlag) String() string   { return "false" }
+func (f *quietFlag) IsBoolFlag() bool { return true }
+
+func (f *quietFlag) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if on && *f.level < f.value {
+		*f.level = f.value
+	}
+	return nil
+}
+
+// stringsFlag collects the values of a flag that may be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// paramFlag is an optional number flag that sets *target when given.
+type paramFlag[T int | float64] struct {
+	target **T
+}
+
+func (f paramFlag[T]) String() string {
+	if f.target == nil || *f.target == nil {
+		return ""
+	}
+	return fmt.Sprint(**f.target)
+}
+
+func (f paramFlag[T]) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("not a number")
+	}
+	n := T(v)
+	if float64(n) != v {
+		return fmt.Errorf("not an integer")
+	}
+	*f.target = &n
+	return nil
+}
+
 type CLI struct {
-	Model      string
-	ConfigPath string
-	NoCopy     bool
-	Quiet      bool
-	Idea       string
+	Model       string
+	ConfigPath  string
+	NoCopy      bool
+	Quiet       QuietLevel
+	Verbose     bool // print the conversation even when stdout is a file
+	Resume      string
+	Messages    string // JSON file of the conversation to continue; - for stdin
+	IdeaFile    string // file the idea is read from; - for stdin
+	Delimiter   string
+	QR          bool
+	Strict      bool
+	Save        bool
+	Output      string           // file to write the final prompt to
+	Force       bool             // let Output replace an existing file
+	Split       bool             // deliver the prompt as system and user messages
+	Chain       string           // directory to write a prompt chain to
+	Tools       string           // file with the target agent's tool schemas
+	Fit         string           // MODEL:SLOT token budget for the final prompt
+	Profile     string           // profile from the config file to use
+	Persona     string           // persona from the config whose defaults apply
+	Prompt      string           // system prompt from the prompts directory, by name
+	ShowUsage   bool             // print token usage per turn and for the session
+	Deadline    time.Duration    // bounds the whole run; 0 means no limit
+	Live        string           // address to serve a read-only live view on
+	DebugStream string           // file to record raw LLM responses to
+	Params      GenerationParams // overrides the config's sampling settings
+	Compare     []string         // models to compare instead of a conversation
+	Refine      string           // file with an existing prompt to start from
+	Draft       string           // contents of Refine
+	Examples    []string         // files with example outputs to derive a prompt from
+	Context     []string         // files attached to the first message (--context)
+	Idea        string           // with Refine, the revision instructions
+	RedactEnv   bool             // keep the environment out of the session and exports
 }
 
 // Deps holds injectable dependencies for the app.
 type Deps struct {
-	Client       LLMClient
-	Stdin        io.Reader
-	Stdout       io.Writer
-	Stderr       io.Writer
-	Clipboard    ClipboardWriter
-	IsTTY        func() bool
-	SystemPrompt string
+	Client             LLMClient
+	Stdin              io.Reader
+	Stdout             io.Writer
+	Stderr             io.Writer
+	Clipboard          ClipboardWriter
+	IsTTY              func() bool // stdout is a terminal: interactive conversation
+	StatusTTY          func() bool // stderr is a terminal: spinner and status
+	SystemPrompt       string
+	Session            *Session // session metadata; history is resumed if present
+	SaveSession        func(*Session) (string, error)
+	OpenBrowser        func(path string) error
+	Config             *Config
+	NewClient          func(model string) (LLMClient, error) // for /model; nil disables it
+	Live               *LiveFeed                             // watchers of the session; nil when --live is off
+	ResponseCache      string                                // recent replies, to catch repeated runs; "" turns that off
+	ReloadSystemPrompt func() (string, error)                // re-reads the system prompt for /reload; nil disables it
+	Hangup             <-chan os.Signal                      // SIGHUP: reload the system prompt before the next turn
 }
 
 func parseArgs() (*CLI, error) {
@@ -53,15 +186,67 @@ func parseArgs() (*CLI, error) {
 	flag.StringVar(&cli.ConfigPath, "config", "", "Use alternate config file")
 	flag.StringVar(&cli.ConfigPath, "c", "", "Use alternate config file (shorthand)")
 	flag.BoolVar(&cli.NoCopy, "no-copy", false, "Don't copy to clipboard")
-	flag.BoolVar(&cli.Quiet, "quiet", false, "Suppress conversation output")
-	flag.BoolVar(&cli.Quiet, "q", false, "Suppress conversation output (shorthand)")
+	flag.Var(&quietFlag{&cli.Quiet, QuietPrompt}, "quiet", "Print only the final prompt")
+	flag.Var(&quietFlag{&cli.Quiet, QuietPrompt}, "q", "Print only the final prompt (shorthand)")
+	flag.Var(&quietFlag{&cli.Quiet, QuietClipboard}, "qq", "Copy the final prompt to the clipboard and print nothing")
+	flag.Var(&quietFlag{&cli.Quiet, QuietSilent}, "silent", "Print nothing; report the result only through the exit code")
+	flag.BoolVar(&cli.Verbose, "verbose", false, "Print the whole conversation even when stdout is redirected to a file")
+	flag.StringVar(&cli.Resume, "resume", "", "Continue a saved session by ID or path")
+	flag.Var((*stringsFlag)(&cli.Context), "context", "Attach a file, such as a spec or code, to the first message; repeat for more files")
+	flag.StringVar(&cli.IdeaFile, "idea-file", "", "Read the idea from this file (- or an idea of - reads stdin)")
+	flag.StringVar(&cli.Messages, "messages", "", "Continue the conversation in this JSON file of messages (- reads stdin) and print the next draft")
+	flag.StringVar(&cli.Delimiter, "delimiter", "", "Line written after each response in pipe mode (escapes like \\x1e allowed)")
+	flag.BoolVar(&cli.QR, "qr", false, "Show the final prompt as a QR code on stderr")
+	flag.BoolVar(&cli.Save, "save", false, "Save the final prompt to a file chosen by the save config")
+	flag.StringVar(&cli.Output, "output", "", "Write the final prompt to this file")
+	flag.StringVar(&cli.Output, "o", "", "Write the final prompt to this file (shorthand)")
+	flag.BoolVar(&cli.Force, "force", false, "Let --output replace an existing file")
+	flag.BoolVar(&cli.Split, "split", false, "Deliver the prompt as a system and a user message, printed and copied as JSON")
+	flag.StringVar(&cli.Chain, "chain", "", "Design a multi-step prompt chain and write its prompts and manifest to this directory")
+	flag.StringVar(&cli.Tools, "tools", "", "JSON file with the target agent's tool schemas, for a prompt with tool-usage guidance")
+	flag.StringVar(&cli.Profile, "profile", "", "Use a profile from the config file (its model, host, system prompt, settings)")
+	flag.StringVar(&cli.Persona, "persona", "", "Use the defaults of a persona from the config (system prompt, model, settings)")
+	flag.StringVar(&cli.Prompt, "prompt", "", "Use the system prompt NAME.md from the prompts directory (see prompts list)")
+	flag.StringVar(&cli.Fit, "fit", "", "Shorten the prompt until it fits a token budget, e.g. gpt-4o-mini:system or gpt-4o:1500")
+	flag.Var(paramFlag[float64]{&cli.Params.Temperature}, "temperature", "Sampling temperature, 0-2 (overrides config)")
+	flag.Var(paramFlag[float64]{&cli.Params.TopP}, "top-p", "Nucleus sampling cutoff, 0-1 (overrides config)")
+	flag.Var(paramFlag[int]{&cli.Params.MaxTokens}, "max-tokens", "Response token limit (overrides config)")
+	flag.Var(paramFlag[int]{&cli.Params.Seed}, "seed", "Sampling seed for reproducible runs (overrides config)")
+	flag.DurationVar(&cli.Deadline, "deadline", 0, "Stop after this long (e.g. 60s) and deliver the last complete draft, exiting with code 7")
+	flag.StringVar(&cli.Live, "live", "", "Serve a read-only live view of the session on this address (e.g. :7070) and print its link")
+	flag.BoolVar(&cli.ShowUsage, "show-usage", false, "Print the tokens each request used and the session total (or set show_usage)")
+	flag.StringVar(&cli.DebugStream, "debug-stream", "", "Record the raw LLM responses to this NDJSON file, for replay-stream")
+	flag.BoolVar(&cli.RedactEnv, "redact-env", false, "Keep the tool version, OS, server, model digest and config hash out of the session and exports")
+	flag.BoolVar(&cli.Strict, "strict", false, "Fail on warnings (deprecated or unknown config keys, no clipboard, truncated history) with exit codes 4-6")
+
+	compare := flag.String("compare", "", "Generate a prompt with each of these comma-separated models and show them together")
 
 	showVersion := flag.Bool("version", false, "Show version")
 	showVersionShort := flag.Bool("v", false, "Show version (shorthand)")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: prompt-builder [flags] <idea>\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: prompt-builder [flags] [idea]\n")
+		fmt.Fprintf(os.Stderr, "       prompt-builder <command> [args]\n\n")
 		fmt.Fprintf(os.Stderr, "Transform ideas into structured prompts using R.G.C.O.A. framework.\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		fmt.Fprintf(os.Stderr, "  refine <file> [changes] Start from an existing prompt instead of an idea\n")
+		fmt.Fprintf(os.Stderr, "  reverse --examples FILE Derive a prompt from example outputs (one or more files)\n")
+		fmt.Fprintf(os.Stderr, "  discover [machine...]   Find LLM servers on well-known ports and over mDNS\n")
+		fmt.Fprintf(os.Stderr, "  import <export.json>    Import ChatGPT or LM Studio conversations as sessions\n")
+		fmt.Fprintf(os.Stderr, "  config migrate          Rewrite the config file to rename legacy keys\n")
+		fmt.Fprintf(os.Stderr, "  config validate         Check the config file for unknown keys and bad values\n")
+		fmt.Fprintf(os.Stderr, "  config get <key>        Print the value a run would use for a config key\n")
+		fmt.Fprintf(os.Stderr, "  config set <key> <val>  Change one key in the config file, keeping its comments\n")
+		fmt.Fprintf(os.Stderr, "  doctor                  Check the config, server version and clipboard\n")
+		fmt.Fprintf(os.Stderr, "  models                  List the server's models, their sizes and which are loaded\n")
+		fmt.Fprintf(os.Stderr, "  tour                    Practice a session against a scripted model\n")
+		fmt.Fprintf(os.Stderr, "  push <name> [file]      Publish a finished prompt to a prompt registry\n")
+		fmt.Fprintf(os.Stderr, "  experiments report      Compare the system prompt variants of an experiment\n")
+		fmt.Fprintf(os.Stderr, "  prompts list            List the system prompts --prompt can pick\n")
+		fmt.Fprintf(os.Stderr, "  schema [format]         Print the JSON Schema of the tool's JSON output\n")
+		fmt.Fprintf(os.Stderr, "  extract [--block N]     Print a fenced code block from stdin\n")
+		fmt.Fprintf(os.Stderr, "  detect-complete         Exit 0 if stdin is a finished reply with a prompt\n")
+		fmt.Fprintf(os.Stderr, "  replay-stream <dump>    Parse the responses recorded with --debug-stream again\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
 		flag.PrintDefaults()
 	}
@@ -70,154 +255,1271 @@ func parseArgs() (*CLI, error) {
 
 	if *showVersion || *showVersionShort {
 		fmt.Printf("prompt-builder %s\n", version)
-		os.Exit(0)
+		exit(0)
 	}
 
+	cli.Delimiter = unescape(cli.Delimiter)
+	cli.Compare = parseModelList(*compare)
+	if len(cli.Compare) > 0 && cli.Resume != "" {
+		return nil, fmt.Errorf("--compare starts new conversations and cannot be combined with --resume")
+	}
+	if len(cli.Context) > 0 && cli.Resume != "" {
+		return nil, fmt.Errorf("--context cannot be combined with --resume; attach files when the conversation starts")
+	}
+	if cli.Messages != "" && (cli.Resume != "" || len(cli.Compare) > 0) {
+		return nil, fmt.Errorf("--messages cannot be combined with --resume or --compare")
+	}
+	if len(cli.Compare) > 0 && cli.Quiet >= QuietClipboard {
+		return nil, fmt.Errorf("--compare prints every prompt and cannot be combined with -qq or --silent")
+	}
+	if cli.Split && (cli.Resume != "" || len(cli.Compare) > 0) {
+		return nil, fmt.Errorf("--split cannot be combined with --resume or --compare; a resumed session keeps its own format")
+	}
+	if cli.Chain != "" && (cli.Resume != "" || len(cli.Compare) > 0 || cli.Split) {
+		return nil, fmt.Errorf("--chain cannot be combined with --resume, --compare or --split; a resumed session keeps its own format")
+	}
+	if cli.Tools != "" && cli.Resume != "" {
+		return nil, fmt.Errorf("--tools cannot be combined with --resume; a resumed session keeps its tools")
+	}
+	if cli.Fit != "" && (cli.Resume != "" || len(cli.Compare) > 0 || cli.Chain != "") {
+		return nil, fmt.Errorf("--fit cannot be combined with --resume, --compare or --chain")
+	}
+	if cli.Output != "" && len(cli.Compare) > 0 {
+		return nil, fmt.Errorf("--output writes one prompt and cannot be combined with --compare")
+	}
+	if cli.Force && cli.Output == "" {
+		return nil, fmt.Errorf("--force only applies to --output")
+	}
+	if cli.Live != "" && !serveBuilt {
+		return nil, fmt.Errorf("--live is not available: this binary was built with -tags noserve")
+	}
+	if cli.QR && !qrBuilt {
+		return nil, fmt.Errorf("--qr is not available: this binary was built with -tags noqr")
+	}
+	if cli.Live != "" && len(cli.Compare) > 0 {
+		return nil, fmt.Errorf("--live shows a conversation and cannot be combined with --compare")
+	}
+	if err := cli.Params.Validate(); err != nil {
+		return nil, err
+	}
+	if cli.NoCopy && cli.Quiet == QuietClipboard {
+		return nil, fmt.Errorf("-qq copies to the clipboard and cannot be combined with --no-copy")
+	}
+	if cli.QR && cli.Quiet == QuietSilent {
+		return nil, fmt.Errorf("--qr prints a QR code and cannot be combined with --silent")
+	}
+	if cli.Verbose && cli.Quiet > QuietNone {
+		return nil, fmt.Errorf("--verbose cannot be combined with -q, -qq or --silent")
+	}
+	// A file almost always wants the prompt, not the whole conversation
+	if cli.Quiet == QuietNone && !cli.Verbose && isRegularFile(os.Stdout) {
+		cli.Quiet = QuietPrompt
+	}
 	args := flag.Args()
+	if len(args) > 0 && args[0] == "-" {
+		if cli.IdeaFile != "" {
+			return nil, fmt.Errorf("--idea-file cannot be combined with an idea argument")
+		}
+		cli.IdeaFile, args = "-", args[1:]
+	}
+	if cli.IdeaFile == "-" && cli.Messages == "-" {
+		return nil, fmt.Errorf("stdin can hold the idea or the --messages conversation, not both")
+	}
+	// With the idea or conversation on stdin, nobody is there to answer
+	// questions
+	if (cli.Messages == "-" || cli.IdeaFile == "-") && cli.Quiet == QuietNone {
+		cli.Quiet = QuietPrompt
+	}
+
+	if len(args) > 0 && args[0] == "refine" {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("usage: prompt-builder [flags] refine <prompt-file> [instructions]")
+		}
+		if cli.Resume != "" || cli.Messages != "" || len(cli.Compare) > 0 {
+			return nil, fmt.Errorf("refine cannot be combined with --resume, --messages or --compare")
+		}
+		cli.Refine = args[1]
+		if len(args) == 3 {
+			if cli.IdeaFile != "" {
+				return nil, fmt.Errorf("--idea-file cannot be combined with refine instructions")
+			}
+			cli.Idea = args[2]
+		}
+		return cli, nil
+	}
+	if len(args) > 0 && args[0] == "reverse" {
+		if len(args) < 3 || (args[1] != "--examples" && args[1] != "-examples") {
+			return nil, fmt.Errorf("usage: prompt-builder [flags] reverse --examples <file>...")
+		}
+		if cli.Resume != "" || cli.Messages != "" || cli.IdeaFile != "" || len(cli.Compare) > 0 {
+			return nil, fmt.Errorf("reverse cannot be combined with --resume, --messages, --idea-file or --compare")
+		}
+		cli.Examples = args[2:]
+		return cli, nil
+	}
 	if len(args) < 1 {
+		if cli.Resume != "" || cli.Messages != "" || cli.IdeaFile != "" {
+			return cli, nil
+		}
+		// At a terminal the conversation starts by asking for the idea
+		if isStdinTTY() && isTTY() && cli.Quiet == QuietNone && len(cli.Compare) == 0 {
+			return cli, nil
+		}
 		return nil, fmt.Errorf("missing required argument: <idea>")
 	}
+	if cli.IdeaFile != "" {
+		return nil, fmt.Errorf("--idea-file cannot be combined with an idea argument")
+	}
 	cli.Idea = args[0]
 
 	return cli, nil
 }
 
-func defaultConfigPath() string {
-	home, err := os.UserHomeDir()
+// unescape interprets Go escape sequences such as \x1e or \n in s, so
+// control characters can be passed on the command line. Invalid sequences
+// leave s unchanged.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	if u, err := strconv.Unquote(`"` + strings.ReplaceAll(s, `"`, `\"`) + `"`); err == nil {
+		return u
+	}
+	return s
+}
+
+// readIdea reads an idea too long to quote on the command line from a
+// file, or from stdin when path is "-".
+func readIdea(path string, stdin io.Reader) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(ExpandPath(path))
+	}
 	if err != nil {
-		return ""
+		return "", fmt.Errorf("cannot read idea: %v", err)
+	}
+	idea := strings.TrimSpace(string(data))
+	if idea == "" {
+		if path == "-" {
+			path = "stdin"
+		}
+		return "", fmt.Errorf("the idea in %s is empty", path)
+	}
+	return idea, nil
+}
+
+// projectConfigName is a project's own config file, looked for in the
+// working directory and its parents.
+const projectConfigName = ".prompt-builder.yaml"
+
+// defaultConfigPath returns the user's config file, which need not exist
+// yet: the one in PROMPT_BUILDER_HOME if set, else the usual one for the
+// platform. A project config may be merged over it (see configFiles).
+func defaultConfigPath() string {
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, "config.yaml")
 	}
-	return filepath.Join(home, ".config", "prompt-builder", "config.yaml")
+	return userConfigPath(runtime.GOOS)
+}
+
+// findProjectConfig returns the projectConfigName file in dir or the
+// closest parent that has one, or "" if none does.
+func findProjectConfig(dir string) string {
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// userConfigPath returns the user's config file on goos: under %APPDATA%
+// on Windows, else under $XDG_CONFIG_HOME or ~/.config.
+func userConfigPath(goos string) string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if goos == "windows" {
+		dir = os.Getenv("APPDATA")
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "prompt-builder", "config.yaml")
 }
 
 func isTTY() bool {
 	return term.IsTerminal(int(os.Stdout.Fd()))
 }
 
-func runWithDeps(ctx context.Context, cli *CLI, deps *Deps) error {
-	_ = ctx // Context available for future cancellation support
+func isStdinTTY() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
 
+func isStderrTTY() bool {
+	return term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// isRegularFile reports whether f is a regular file, as stdout is when
+// redirected with >, rather than a terminal, pipe or device.
+func isRegularFile(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode().IsRegular()
+}
+
+// runSubcommand dispatches name to a subcommand handler. It reports false
+// when name is not a subcommand, in which case it is treated as the idea.
+func runSubcommand(ctx context.Context, name string, args []string) (int, bool) {
+	switch name {
+	case "discover":
+		return runDiscover(ctx, args, os.Stdout, os.Stderr), true
+	case "import":
+		return runImport(args, os.Stdout, os.Stderr), true
+	case "config":
+		return runConfig(ctx, args, os.Stdin, os.Stdout, os.Stderr), true
+	case "doctor":
+		return runDoctor(ctx, args, os.Stdout), true
+	case "models":
+		return runModels(ctx, args, os.Stdout, os.Stderr), true
+	case "tour":
+		return runTourCommand(ctx, os.Stdout, os.Stderr), true
+	case "push":
+		return runPush(ctx, args, os.Stdin, os.Stdout, os.Stderr), true
+	case "experiments":
+		return runExperiments(args, os.Stdout, os.Stderr), true
+	case "prompts":
+		return runPrompts(args, os.Stdout, os.Stderr), true
+	case "schema":
+		return runSchema(args, os.Stdout, os.Stderr), true
+	case "extract":
+		return runExtract(args, os.Stdin, os.Stdout, os.Stderr), true
+	case "detect-complete":
+		return runDetectComplete(args, os.Stdin, os.Stderr), true
+	case "replay-stream":
+		return runReplayStream(args, os.Stdout, os.Stderr), true
+	}
+	return 0, false
+}
+
+func runWithDeps(ctx context.Context, cli *CLI, deps *Deps) error {
 	// Initialize conversation
-	conv := NewConversation(deps.SystemPrompt)
+	params := deps.Config.GenerationParams.WithOverrides(cli.Params)
+	first := &Tab{Conv: NewConversation(deps.SystemPrompt), Params: params, AwaitingReply: true}
+	interactive := deps.IsTTY() && cli.Quiet == QuietNone
+	showConversation := cli.Quiet == QuietNone
+	// Progress goes to stderr, so it can show even when stdout is redirected
+	showSpinner := deps.StatusTTY() && showConversation
+	status := deps.Stderr
+	if cli.Quiet == QuietSilent {
+		status = io.Discard
+	}
 
-	// Prepare user's idea
-	userIdea := cli.Idea
-	tty := deps.IsTTY()
-	if !tty {
-		// Pipe mode: ask for immediate generation
-		userIdea = "Generate your best prompt without asking clarifying questions. User's idea: " + userIdea
+	first.Session = deps.Session
+	if first.Session == nil {
+		first.Session = &Session{Idea: cli.Idea, CreatedAt: time.Now()}
 	}
-	conv.AddUserMessage(userIdea)
+	reader := newLineReader(ctx, deps.Stdin)
 
-	// Conversation loop
-	reader := bufio.NewReader(deps.Stdin)
-	for {
-		// Get response from LLM with streaming
-		response, err := deps.Client.ChatStreamWithSpinner(conv.Messages, tty && !cli.Quiet, func(token string) error {
-			if !cli.Quiet {
-				fmt.Fprint(deps.Stdout, token)
+	if len(first.Session.Messages) > 0 {
+		// Resume: replay history; if the model already answered, show that
+		// answer and go straight to the prompt
+		first.Conv.Messages = append(first.Conv.Messages, first.Session.conversationMessages()...)
+		if last := first.Conv.Messages[len(first.Conv.Messages)-1]; last.Role == "assistant" {
+			first.Response = last.Content
+			first.AwaitingReply = false
+			if showConversation {
+				fmt.Fprintln(deps.Stdout, first.Response)
 			}
-			return nil
-		})
-		if err != nil {
-			return fmt.Errorf("LLM request failed: %v", err)
 		}
-		if !cli.Quiet {
-			fmt.Fprintln(deps.Stdout) // newline after streaming completes
+	} else if cli.Draft != "" {
+		// Refine: the existing prompt is the current draft
+		first.Response = seedDraft(first.Conv, cli.Draft)
+		first.AwaitingReply = false
+		if cli.Idea != "" {
+			instructions := cli.Idea
+			if !interactive {
+				instructions = refinePipePrefix + instructions
+			}
+			first.Conv.AddUserMessage(instructions)
+			first.AwaitingReply = true
+		} else if showConversation {
+			fmt.Fprintln(deps.Stdout, first.Response)
+		}
+	} else {
+		// Prepare user's idea
+		userIdea := cli.Idea
+		if first.Session.Idea == "" && interactive {
+			idea, err := askIdea(reader, deps.Stdout)
+			if err != nil || idea == "" {
+				return err
+			}
+			// Files attached with --context follow the idea
+			first.Session.Idea = idea
+			userIdea = strings.TrimSpace(idea + "\n\n" + cli.Idea)
 		}
+		if !interactive {
+			// Pipe mode: ask for immediate generation
+			userIdea = promptbuilder.PipeModePrefix + userIdea
+		}
+		first.Conv.AddUserMessage(userIdea)
+	}
 
-		conv.AddAssistantMessage(response)
+	tabs := NewTabs(deps.SystemPrompt, first)
+	tabs.Defaults = params
+	syncSessions := func() {
+		for _, t := range tabs.All() {
+			t.Session.Messages = t.Conv.Messages
+		}
+	}
+	defer syncSessions()
+
+	// Every tab of an interactive session is saved however the run ends,
+	// so its notes, pins and locks are kept, and a resumed one is saved back
+	savedAs := map[*Session]string{}
+	saved := false
+	saveOnExit := func() {
+		if deps.SaveSession == nil || saved || (!interactive && cli.Resume == "") {
+			return
+		}
+		saved = true
+		syncSessions()
+		for _, t := range tabs.All() {
+			if draftCount(t.Session.Messages) == 0 {
+				continue // nothing to resume yet
+			}
+			path, err := deps.SaveSession(t.Session)
+			if err != nil {
+				fmt.Fprintf(status, "Warning: %v\n", err)
+				continue
+			}
+			savedAs[t.Session] = path
+		}
+	}
+	defer saveOnExit()
+	start := time.Now()
+
+	var usage *usageMeter
+	if deps.Config.ShowUsage {
+		usage = &usageMeter{out: status}
+		defer usage.summary()
+	}
+	var title *titleWriter
+	if deps.Config.TerminalTitle && deps.StatusTTY() && cli.Quiet < QuietSilent {
+		title = newTitleWriter(deps.Stderr)
+		defer title.restore()
+	}
+
+	var reload func() (string, error)
+	if deps.ReloadSystemPrompt != nil {
+		reload = func() (string, error) {
+			prompt, err := deps.ReloadSystemPrompt()
+			if err != nil {
+				return "", err
+			}
+			tabs.SetSystemPrompt(prompt)
+			return deps.Config.systemPromptName(), nil
+		}
+	}
+
+	// Conversation loop
+	nudges := 0
+	assumed := 0   // automatic /assume rounds since the user last typed
+	restored := 0  // requests to restore locked sections since then
+	shortened := 0 // requests to shorten the prompt to the --fit budget
+	stalls := 0    // retries of the current request after a stalled stream
+	// Only the opening request of a new conversation can repeat a run
+	checkDuplicate := deps.ResponseCache != "" && first.AwaitingReply && len(first.Session.Messages) == 0
+	duplicateKey := ""
+	for {
+		tab := tabs.Current()
+		replied := tab.AwaitingReply // a fresh reply, not a tab switch
+		if tab.AwaitingReply {
+			select {
+			case <-deps.Hangup:
+				if name, err := reload(); err != nil {
+					fmt.Fprintf(status, "Warning: cannot reload the system prompt: %v\n", err)
+				} else {
+					fmt.Fprintf(status, "✓ Reloaded %s\n", name)
+				}
+			default:
+			}
+			readAnswer := func() (string, error) {
+				return reader.ReadLine(0)
+			}
+			messages, err := fitContext(tab, deps.Config, interactive, cli.Strict, readAnswer, status)
+			if err != nil {
+				return err
+			}
 
-		// Pipe mode: output result and exit (can't continue conversation)
-		if !tty {
-			if IsComplete(response) {
-				if cli.Quiet {
-					// In quiet mode, print only the extracted code block
-					finalPrompt := ExtractLastCodeBlock(response)
-					fmt.Fprintln(deps.Stdout, finalPrompt)
+			// Get response from LLM with streaming
+			messages = withPins(messages, tab.Session.Pins)
+			prefill := ""
+			if !interactive && deps.Config.Prefill != "" {
+				// The model was asked for the prompt outright, so start its
+				// reply for it, typically inside a code fence
+				prefill = deps.Config.Prefill
+				messages = append(slices.Clip(messages), Message{Role: "assistant", Content: prefill})
+			}
+			deps.Client.SetParams(tab.Params)
+			if deps.Live != nil {
+				deps.Live.Sync(tab.Conv)
+			}
+			unshown := prefill
+			shown := false
+			if title != nil {
+				title.thinking(time.Now())
+			}
+			onToken := func(token string) error {
+				if deps.Live != nil {
+					deps.Live.Token(token)
+				}
+				if title != nil {
+					title.token()
+				}
+				if showConversation {
+					fmt.Fprint(deps.Stdout, unshown+token)
+					unshown = ""
+					shown = true
 				}
-				// Non-quiet mode already streamed the response
 				return nil
 			}
-			return fmt.Errorf("LLM requested clarification but stdin is not a TTY")
+			limit := &responseLimit{max: int(deps.Config.MaxResponseSize)}
+			if limit.max > 0 {
+				onToken = limit.wrap(onToken)
+			}
+			reused, duplicate := "", false
+			if checkDuplicate {
+				checkDuplicate = false
+				duplicateKey = responseKey(deps.Config, tab.Session.Model, tab.Params, messages)
+				var err error
+				reused, duplicate, err = duplicateReply(deps.ResponseCache, duplicateKey, time.Duration(deps.Config.DuplicateWindow), interactive, readAnswer, status)
+				if err != nil {
+					return err
+				}
+			}
+			var response string
+			if duplicate {
+				response, err = reused, onToken(reused)
+			} else {
+				response, err = deps.Client.ChatStreamWithSpinner(ctx, messages, showSpinner, onToken)
+			}
+			var tooLarge *LLMError
+			cutOff := errors.As(err, &tooLarge) && tooLarge.Kind == errTooLarge
+			if cutOff {
+				// Keep what arrived as a draft rather than lose it
+				response, err = limit.received.String(), nil
+			}
+			response = prefill + response
+			if ctx.Err() != nil {
+				// Interrupted: end the half-streamed line and stop quietly
+				if showConversation {
+					fmt.Fprintln(deps.Stdout)
+				}
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return deliverLastDraft(ctx, cli, deps, tab)
+				}
+				return ctx.Err()
+			}
+			if err != nil {
+				var llmErr *LLMError
+				if errors.As(err, &llmErr) && llmErr.Kind == errStalled && stalls < maxStallRetries && (interactive || !shown) {
+					// Ask again, unless a script already got half a reply
+					stalls++
+					if shown {
+						fmt.Fprintln(deps.Stdout)
+					}
+					fmt.Fprintf(status, "(the stream stalled with %s; retrying)\n", llmErr.Message)
+					continue
+				}
+				if llmErr != nil {
+					return llmErr // already says what failed
+				}
+				return withKind(ErrLLM, fmt.Errorf("LLM request failed: %w", err))
+			}
+			stalls = 0
+			if duplicateKey != "" && !duplicate && !cutOff {
+				if err := storeResponse(deps.ResponseCache, duplicateKey, strings.TrimPrefix(response, prefill), time.Duration(deps.Config.DuplicateWindow), time.Now()); err != nil {
+					fmt.Fprintf(status, "Warning: %v\n", err)
+				}
+			}
+			duplicateKey = ""
+			if showConversation {
+				fmt.Fprintln(deps.Stdout) // newline after streaming completes
+				if !interactive && cli.Delimiter != "" {
+					// Marks the turn boundary for scripts splitting the stream
+					fmt.Fprintln(deps.Stdout, cli.Delimiter)
+				}
+			}
+			if usage != nil {
+				usage.record(deps.Client)
+			}
+
+			tab.Conv.AddAssistantMessage(response)
+			tab.Response = response
+			tab.AwaitingReply = false
+			if deps.Live != nil {
+				deps.Live.Sync(tab.Conv)
+			}
+			if title != nil {
+				title.done(time.Now(), draftCount(tab.Conv.Messages), promptbuilder.IsComplete(response))
+			}
+			if cutOff {
+				if !interactive {
+					return tooLarge
+				}
+				fmt.Fprintf(status, "Warning: the reply passed max_response_size (%s) and was stopped; what arrived is kept as the draft\n", formatSize(int64(limit.max)))
+			}
+
+			if promptbuilder.IsComplete(response) {
+				prompt := promptbuilder.ExtractLastCodeBlock(response)
+				for _, p := range missingPins(prompt, tab.Session.Pins) {
+					fmt.Fprintf(status, "Warning: the draft dropped pinned constraint %q\n", p)
+				}
+				if len(tab.Session.Tools) > 0 {
+					for _, name := range unknownTools(prompt, tab.Session.Tools) {
+						fmt.Fprintf(status, "Warning: the draft mentions tool %q, which is not in the tools file\n", name)
+					}
+					for _, name := range unusedTools(prompt, tab.Session.Tools) {
+						fmt.Fprintf(status, "Warning: the draft gives no guidance for tool %q\n", name)
+					}
+				}
+				if p := tab.Push; p != nil {
+					switch {
+					case includesRequirement(prompt, p.Requirement):
+						fmt.Fprintf(status, "✓ The draft now includes %q\n", p.Requirement)
+						tab.Push = nil
+					case p.escalate():
+						fmt.Fprintf(status, "(the draft still leaves out %q; insisting)\n", p.Requirement)
+						tab.Conv.AddUserMessage(p.next())
+						tab.AwaitingReply = true
+						continue
+					default:
+						fmt.Fprintf(status, "Warning: the draft still leaves out %q after %d pushes; /push tries again\n", p.Requirement, p.Level)
+					}
+				}
+				changed := changedLocks(prompt, tab.Session.Locks)
+				for _, l := range changed {
+					fmt.Fprintf(status, "Warning: the draft changed locked section %q\n", l.Name)
+				}
+				if len(changed) > 0 && restored < maxRestores {
+					restored++
+					fmt.Fprintln(status, "(restoring locked sections)")
+					tab.Conv.AddUserMessage(restoreMessage(changed))
+					tab.AwaitingReply = true
+					continue
+				}
+				if fit := tab.Session.Fit; fit != nil {
+					tokens, err := fit.Count(fitText(tab.Session, response))
+					if err != nil {
+						return err
+					}
+					if tokens > fit.Budget && shortened < maxShortens {
+						shortened++
+						fmt.Fprintf(status, "(the prompt is %d tokens, over the %s; shortening)\n", tokens, fit)
+						tab.Conv.AddUserMessage(shortenMessage(fit.Budget))
+						tab.AwaitingReply = true
+						continue
+					}
+					if tokens > fit.Budget {
+						err := fmt.Errorf("the prompt is still %d tokens after %d attempts to shorten it, over the %s", tokens, maxShortens, fit)
+						if !interactive {
+							return err
+						}
+						fmt.Fprintf(status, "Warning: %v; /shorten tries again\n", err)
+					} else if shortened > 0 {
+						fmt.Fprintf(status, "✓ The prompt fits: %d of %d tokens\n", tokens, fit.Budget)
+					}
+				}
+			}
+		}
+
+		// Pipe or quiet mode: output result and exit (can't continue conversation)
+		if !interactive {
+			if promptbuilder.IsComplete(tab.Response) {
+				return emitPrompt(ctx, cli, deps, tab)
+			}
+			// Small models often forget the fence; remind them before giving up
+			if nudges < deps.Config.MaxNudges {
+				nudges++
+				tab.Conv.AddUserMessage(deps.Config.CompletionNudge)
+				tab.AwaitingReply = true
+				continue
+			}
+			return withKind(ErrClarificationNeeded, fmt.Errorf("LLM requested clarification but the run is not interactive"))
+		}
+
+		// /assume always: answer questions for the user, but not forever
+		if tabs.AssumeAnswers && !promptbuilder.IsComplete(tab.Response) && assumed < maxAssumeRounds {
+			assumed++
+			fmt.Fprintln(deps.Stdout, "(answering with assumptions)")
+			tab.Conv.AddUserMessage(assumeMessage)
+			tab.AwaitingReply = true
+			continue
+		}
+		if replied && !promptbuilder.IsComplete(tab.Response) {
+			if err := alertQuestion(deps.Config.QuestionAlert, tab.Response, deps.Stdout); err != nil {
+				fmt.Fprintf(status, "Warning: question_alert: %v\n", err)
+			}
 		}
 
 		// Input loop: handle commands without calling LLM again
 		for {
 			fmt.Fprint(deps.Stdout, "> ")
-			userInput, err := reader.ReadString('\n')
-			if err != nil {
+			userInput, err := reader.ReadLine(time.Duration(deps.Config.IdleTimeout))
+			if errors.Is(err, errIdleTimeout) {
+				fmt.Fprintln(deps.Stdout)
+				syncSessions()
+				return autosaveIdle(tabs, deps)
+			}
+			if ctx.Err() != nil {
+				fmt.Fprintln(deps.Stdout)
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return deliverLastDraft(ctx, cli, deps, tab)
+				}
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) && strings.TrimSpace(userInput) == "" {
+				// Ctrl-D leaves as /bye does, with the same summary
+				fmt.Fprintln(deps.Stdout)
+				userInput, err = "/bye", nil
+			}
+			if err != nil && !errors.Is(err, io.EOF) {
 				return fmt.Errorf("failed to read input: %v", err)
 			}
 
 			userInput = strings.TrimSpace(userInput)
 
 			if IsCommand(userInput) {
-				shouldExit, err := HandleCommandWithClipboard(userInput, response, deps.Clipboard, deps.Stdout)
+				stamp := func(prompt string) (string, error) {
+					return stampPrompt(deps.Config, tab.Session, time.Now(), prompt)
+				}
+				env := &CommandEnv{
+					Conv:        tab.Conv,
+					Session:     tab.Session,
+					Params:      &tab.Params,
+					Tabs:        tabs,
+					Stamp:       stamp,
+					Share:       &deps.Config.Share,
+					Scrub:       &deps.Config.Scrub,
+					Review:      &deps.Config.Review,
+					Clipboard:   deps.Clipboard,
+					Out:         deps.Stdout,
+					OpenBrowser: deps.OpenBrowser,
+					Aliases:     deps.Config.Aliases,
+					Reload:      reload,
+				}
+				env.Save = func() (string, error) {
+					return saveTabPrompt(ctx, deps.Config, deps.Client, tab, time.Now())
+				}
+				if deps.NewClient != nil {
+					env.SetModel = func(name string) (string, error) {
+						model := deps.Config.resolveModel(name)
+						client, err := deps.NewClient(model)
+						if err != nil {
+							return "", err
+						}
+						deps.Client = client
+						for _, t := range tabs.All() {
+							if t.Session != nil {
+								t.Session.Model = model
+							}
+						}
+						return model, nil
+					}
+				}
+				shouldExit, err := HandleCommand(userInput, tab.Response, env)
 				if err != nil {
 					fmt.Fprintln(deps.Stderr, err)
 				}
 				if shouldExit {
+					if cli.QR && promptbuilder.ExtractLastCodeBlock(tab.Response) != "" {
+						prompt, err := finalPrompt(tab.Session, tab.Response, stamp)
+						if err == nil {
+							err = WriteQR(deps.Stderr, &deps.Config.Share, shareTitle(tab.Session), prompt)
+						}
+						if err != nil {
+							fmt.Fprintln(deps.Stderr, err)
+						}
+					}
+					if cli.Save && promptbuilder.ExtractLastCodeBlock(tab.Response) != "" {
+						if path, err := saveTabPrompt(ctx, deps.Config, deps.Client, tab, time.Now()); err != nil {
+							fmt.Fprintln(deps.Stderr, err)
+						} else {
+							fmt.Fprintf(deps.Stdout, "✓ Saved to %s\n", path)
+						}
+					}
+					if cli.Output != "" && promptbuilder.ExtractLastCodeBlock(tab.Response) != "" {
+						if err := outputTabPrompt(deps.Config, tab, cli.Output, cli.Force, time.Now()); err != nil {
+							fmt.Fprintln(deps.Stderr, err)
+						} else {
+							fmt.Fprintf(deps.Stdout, "✓ Wrote %s\n", cli.Output)
+						}
+					}
+					if tab.Session.Chain != "" && promptbuilder.ExtractLastCodeBlock(tab.Response) != "" {
+						if wrote, err := writeTabChain(deps.Config, tab, time.Now()); err != nil {
+							fmt.Fprintln(deps.Stderr, err)
+						} else {
+							fmt.Fprintln(deps.Stdout, wrote)
+						}
+					}
+					notifyCompletion(ctx, deps.Config, tab, func(format string, args ...any) {
+						fmt.Fprintf(deps.Stderr, format, args...)
+					})
+					if err := recordExperiment(experimentsDir(), tab, time.Now()); err != nil {
+						fmt.Fprintf(deps.Stderr, "Warning: experiment result not recorded: %v\n", err)
+					}
+					saveOnExit()
+					copied := err == nil && parseCommand(userInput) == "copy"
+					fmt.Fprintln(deps.Stdout, summarizeTab(tab, time.Since(start), copied, env.CopiedTo, savedAs[tab.Session]))
 					return nil
 				}
+				if tabs.Current() != tab || tab.AwaitingReply {
+					break // Tab switched or opened, or the command asked the model
+				}
 				continue // Stay in input loop, don't call LLM
 			}
 
-			conv.AddUserMessage(userInput)
+			tab.Conv.AddUserMessage(userInput)
+			tab.AwaitingReply = true
+			assumed, restored, shortened = 0, 0, 0
 			break // Exit input loop, call LLM with new message
 		}
 	}
 }
 
+// ideaGreeting opens an interactive run started without an idea.
+const ideaGreeting = "prompt-builder turns an idea into a prompt for an AI model. Describe what the prompt is for, in a word or a paragraph; the model will ask about anything unclear."
+
+// askIdea asks for the idea of an interactive run started without one.
+// It returns "" when the user leaves with /bye, /quit, /exit or Ctrl-D.
+func askIdea(reader *lineReader, out io.Writer) (string, error) {
+	fmt.Fprintln(out, ideaGreeting)
+	for {
+		fmt.Fprint(out, "What's your idea? ")
+		line, err := reader.ReadLine(0)
+		if errors.Is(err, io.EOF) && strings.TrimSpace(line) == "" {
+			fmt.Fprintln(out)
+			return "", nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		idea := strings.TrimSpace(line)
+		switch parseCommand(idea) {
+		case "":
+			if idea != "" {
+				return idea, nil
+			}
+		case "bye", "quit", "exit":
+			return "", nil
+		default:
+			fmt.Fprintln(out, "Commands work once the conversation has started; first, what is the prompt for?")
+		}
+	}
+}
+
+// maxAssumeRounds bounds how often /assume always answers for the user in
+// a row, in case the model keeps asking.
+const maxAssumeRounds = 2
+
+// emitPrompt delivers the final prompt of a non-interactive run according
+// to the quiet level. Without one, the streamed response already holds it.
+func emitPrompt(ctx context.Context, cli *CLI, deps *Deps, tab *Tab) error {
+	if cli.Save {
+		path, err := saveTabPrompt(ctx, deps.Config, deps.Client, tab, time.Now())
+		if err != nil {
+			return err
+		}
+		if cli.Quiet < QuietSilent {
+			fmt.Fprintf(deps.Stderr, "✓ Saved to %s\n", path)
+		}
+	}
+	if cli.Output != "" {
+		if err := outputTabPrompt(deps.Config, tab, cli.Output, cli.Force, time.Now()); err != nil {
+			return err
+		}
+		if cli.Quiet < QuietSilent {
+			fmt.Fprintf(deps.Stderr, "✓ Wrote %s\n", cli.Output)
+		}
+	}
+	if tab.Session != nil && tab.Session.Chain != "" {
+		wrote, err := writeTabChain(deps.Config, tab, time.Now())
+		if err != nil {
+			return err
+		}
+		if cli.Quiet < QuietSilent {
+			fmt.Fprintln(deps.Stderr, wrote)
+		}
+	}
+	notifyCompletion(ctx, deps.Config, tab, func(format string, args ...any) {
+		if cli.Quiet < QuietSilent {
+			fmt.Fprintf(deps.Stderr, format, args...)
+		}
+	})
+	if err := recordExperiment(experimentsDir(), tab, time.Now()); err != nil && cli.Quiet < QuietSilent {
+		fmt.Fprintf(deps.Stderr, "Warning: experiment result not recorded: %v\n", err)
+	}
+	if cli.Quiet == QuietSilent || (cli.Quiet == QuietNone && !cli.QR) {
+		return nil
+	}
+	prompt, err := finalPrompt(tab.Session, tab.Response, func(p string) (string, error) {
+		return stampPrompt(deps.Config, tab.Session, time.Now(), p)
+	})
+	if err != nil {
+		return withKind(ErrConfig, fmt.Errorf("invalid config: %v", err))
+	}
+	if cli.QR {
+		if err := WriteQR(deps.Stderr, &deps.Config.Share, shareTitle(tab.Session), prompt); err != nil {
+			return err
+		}
+	}
+	switch cli.Quiet {
+	case QuietNone:
+		return nil
+	case QuietClipboard:
+		if deps.Clipboard == nil {
+			return withKind(ErrNoClipboard, fmt.Errorf("clipboard not available"))
+		}
+		if err := deps.Clipboard.Write(prompt); err != nil {
+			return withKind(ErrNoClipboard, fmt.Errorf("clipboard not available: %v", err))
+		}
+		return nil
+	}
+	fmt.Fprintln(deps.Stdout, prompt)
+	return nil
+}
+
+// autosaveIdle saves every tab that has a conversation and prints how to
+// resume it.
+func autosaveIdle(tabs *Tabs, deps *Deps) error {
+	if deps.SaveSession == nil {
+		fmt.Fprintln(deps.Stdout, "Session idle, exiting.")
+		return nil
+	}
+	for _, t := range tabs.All() {
+		path, err := deps.SaveSession(t.Session)
+		if err != nil {
+			return fmt.Errorf("session idle, but autosave failed: %v", err)
+		}
+		ref := t.Session.ID
+		if t.Session.path != "" {
+			ref = t.Session.path
+		}
+		fmt.Fprintf(deps.Stdout, "Session idle, saved to %s\nResume with: prompt-builder --resume %s\n", path, ref)
+	}
+	return nil
+}
+
+// errIdleTimeout is returned by lineReader when no input arrives in time.
+var errIdleTimeout = errors.New("idle timeout")
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+// lineReader reads lines in the background so waiting for input can time
+// out. A read that timed out stays pending and is returned by the next call.
+type lineReader struct {
+	ctx     context.Context
+	reader  *bufio.Reader
+	pending chan lineResult
+}
+
+func newLineReader(ctx context.Context, r io.Reader) *lineReader {
+	return &lineReader{ctx: ctx, reader: bufio.NewReader(r)}
+}
+
+// ReadLine returns the next line, or errIdleTimeout if timeout (when
+// positive) passes first, or the context's error once it is cancelled.
+func (l *lineReader) ReadLine(timeout time.Duration) (string, error) {
+	if l.pending == nil {
+		ch := make(chan lineResult, 1)
+		go func() {
+			line, err := l.reader.ReadString('\n')
+			ch <- lineResult{line, err}
+		}()
+		l.pending = ch
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case r := <-l.pending:
+		l.pending = nil
+		return r.line, r.err
+	case <-expired:
+		return "", errIdleTimeout
+	case <-l.ctx.Done():
+		return "", l.ctx.Err()
+	}
+}
+
 func run(ctx context.Context, cli *CLI) error {
-	// Determine config path for client initialization
-	configPath := cli.ConfigPath
-	if configPath == "" {
-		configPath = defaultConfigPath()
+	if cli.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cli.Deadline)
+		defer cancel()
+	}
+	if cli.IdeaFile != "" {
+		idea, err := readIdea(cli.IdeaFile, os.Stdin)
+		if err != nil {
+			return err
+		}
+		cli.Idea = idea
+	}
+	attached, err := readContextFiles(cli.Context)
+	if err != nil {
+		return err
 	}
-	configPath = ExpandPath(configPath)
 
-	cfg, err := LoadConfig(configPath)
+	// Without a config file, the defaults and the built-in system prompt do
+	cfg, err := loadRunConfig(cli.ConfigPath, cli.Profile)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("config file not found: %s\n\nCreate it with:\n  mkdir -p ~/.config/prompt-builder\n  cat > ~/.config/prompt-builder/config.yaml << 'EOF'\n  model: llama3.2\n  host: http://localhost:11434\n  system_prompt_file: ~/.config/prompt-builder/prompt-architect.md\n  EOF", configPath)
+		if configPath := ExpandPath(cli.ConfigPath); os.IsNotExist(err) {
+			return withKind(ErrConfig, fmt.Errorf("config file not found: %s\n\nCreate it with:\n  mkdir -p %s\n  cat > %s << 'EOF'\n  model: llama3.2\n  host: http://localhost:11434\n  EOF", configPath, filepath.Dir(configPath), configPath))
+		}
+		return withKind(ErrConfig, fmt.Errorf("invalid config: %v", err))
+	}
+	if cli.Strict && len(cfg.Deprecations)+len(cfg.UnknownKeys) > 0 {
+		return &strictError{ExitDeprecatedConfig, strings.Join(slices.Concat(cfg.Deprecations, cfg.UnknownKeys), "; ")}
+	}
+	if cli.Quiet < QuietSilent {
+		for _, d := range cfg.Deprecations {
+			fmt.Fprintf(os.Stderr, "Warning: %s (or run: prompt-builder config migrate)\n", d)
+		}
+		for _, k := range cfg.UnknownKeys {
+			fmt.Fprintf(os.Stderr, "Warning: %s; it is ignored\n", k)
+		}
+	}
+	if cfg.pool != nil {
+		cfg.pool.strict = cli.Strict
+		if cli.Quiet < QuietSilent {
+			cfg.pool.warn = func(format string, args ...any) {
+				if isStderrTTY() {
+					fmt.Fprint(os.Stderr, "\r\033[K") // off the spinner's line
+				}
+				fmt.Fprintf(os.Stderr, format, args...)
+			}
+		}
+	}
+	if persona := cmp.Or(cli.Persona, cfg.Persona); persona != "" {
+		if err := cfg.applyPersona(persona); err != nil {
+			return withKind(ErrConfig, fmt.Errorf("invalid config: %v", err))
 		}
-		return fmt.Errorf("invalid config: %v", err)
 	}
 
-	// Apply CLI model override
-	model := cfg.Model
+	host := cfg.Host
+	switch {
+	case cfg.SSHTunnel != "":
+		spec, err := ParseTunnelSpec(cfg.SSHTunnel)
+		if err != nil {
+			return withKind(ErrConfig, fmt.Errorf("invalid config: %v", err))
+		}
+		tunnel, err := StartSSHTunnel(ctx, spec, 10*time.Second)
+		if err != nil {
+			return withKind(ErrLLM, err)
+		}
+		defer tunnel.Close()
+		host = tunnel.Host
+	case host == hostAuto:
+		host, err = resolveAutoHost(ctx)
+		if err != nil {
+			return withKind(ErrLLM, err)
+		}
+	}
+
+	// Warn about an outdated server now rather than failing mid-session;
+	// its version also goes into the session's environment
+	var server ServerInfo
+	if cfg.Provider == providerCompatible {
+		checkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		if info, err := DetectServer(checkCtx, newHTTPClient(cfg), host); err == nil {
+			server = info
+			if cli.Quiet < QuietSilent {
+				for _, w := range info.Warnings() {
+					fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+				}
+			}
+		}
+		cancel()
+	}
+
+	if cli.ShowUsage {
+		cfg.ShowUsage = true
+	}
+
+	// Apply CLI model override, and the settings that go with the model
+	model := cfg.resolveModel(cfg.Model)
 	if cli.Model != "" {
-		model = cli.Model
+		model = cfg.resolveModel(cli.Model)
+	}
+	cfg.applyModelDefaults(cmp.Or(cli.Model, cfg.Model))
+	if cli.Prompt != "" {
+		if cfg.SystemPromptFile, err = cfg.libraryPrompt(cli.Prompt); err != nil {
+			return withKind(ErrConfig, err)
+		}
 	}
 
 	// Validate model
-	if model == "" {
-		return fmt.Errorf("no model specified\n\nSet 'model' in config or use --model flag")
+	if model == "" && len(cli.Compare) == 0 {
+		return withKind(ErrNoModel, fmt.Errorf("no model specified\n\nSet 'model' in config or use --model flag"))
+	}
+
+	// An experiment picks the system prompt, unless a persona or --prompt
+	// already did
+	var experiment *ExperimentTag
+	if cfg.Experiment.Name != "" && cfg.Persona == "" && cli.Prompt == "" && cli.Resume == "" && len(cli.Compare) == 0 {
+		v, err := nextVariant(experimentsDir(), cfg.Experiment)
+		if err != nil {
+			return withKind(ErrConfig, fmt.Errorf("experiment %s: %v", cfg.Experiment.Name, err))
+		}
+		cfg.SystemPromptFile = v.SystemPromptFile
+		experiment = &ExperimentTag{Name: cfg.Experiment.Name, Variant: v.Name}
 	}
 
 	// Load system prompt
-	promptPath := ExpandPath(cfg.SystemPromptFile)
-	systemPrompt, err := os.ReadFile(promptPath)
+	prompt, err := cfg.systemPrompt()
+	if err != nil {
+		return withKind(ErrConfig, err)
+	}
+	systemPrompt := []byte(prompt)
+
+	if cli.DebugStream != "" {
+		dump, err := os.Create(ExpandPath(cli.DebugStream))
+		if err != nil {
+			return fmt.Errorf("cannot record stream: %v", err)
+		}
+		defer dump.Close()
+		cfg.StreamLog = NewStreamLog(dump, cfg.Provider)
+	}
+
+	if len(cli.Compare) > 0 {
+		var models []compareModel
+		for _, name := range cli.Compare {
+			client, err := newLLMClient(cfg, host, cfg.resolveModel(name))
+			if err != nil {
+				return err
+			}
+			client.SetParams(cfg.GenerationParams.WithOverrides(cli.Params))
+			if resolved := cfg.resolveModel(name); resolved != name {
+				name += " (" + resolved + ")"
+			}
+			models = append(models, compareModel{name, client})
+		}
+		status := io.Writer(os.Stderr)
+		if cli.Quiet >= QuietSilent {
+			status = io.Discard
+		}
+		if cli.Idea, err = condenseInput(ctx, models[0].Client, "the idea", cli.Idea, inputBudget(cfg), status); err != nil {
+			return err
+		}
+		if attached, err = condenseContextFiles(ctx, models[0].Client, attached, inputBudget(cfg), status); err != nil {
+			return err
+		}
+		return runCompare(ctx, models, string(systemPrompt), withContext(cli.Idea, attached), os.Stdout)
+	}
+
+	session := &Session{Idea: cli.Idea, Model: model, CreatedAt: time.Now(), Split: cli.Split, Chain: cli.Chain, Experiment: experiment}
+	if cli.Split {
+		systemPrompt = append(systemPrompt, splitInstruction...)
+	}
+	if cli.Chain != "" {
+		systemPrompt = append(systemPrompt, chainInstruction...)
+	}
+	if cli.Tools != "" {
+		schemas, err := os.ReadFile(ExpandPath(cli.Tools))
+		if err != nil {
+			return fmt.Errorf("cannot read tools: %v", err)
+		}
+		if session.Tools, err = parseTools(schemas); err != nil {
+			return fmt.Errorf("invalid tools file %s: %v", cli.Tools, err)
+		}
+		systemPrompt = append(systemPrompt, toolsInstruction(session.Tools, string(schemas))...)
+	}
+	if cli.Fit != "" {
+		if session.Fit, err = parseFit(cli.Fit, cfg.FitSlots, cfg.Tokenizers); err != nil {
+			return err
+		}
+	}
+	if len(cli.Examples) > 0 {
+		names, examples, err := readExamples(cli.Examples)
+		if err != nil {
+			return err
+		}
+		cli.Idea = reverseIdea(names, examples)
+		session.Idea = "prompt behind " + strings.Join(names, ", ")
+	}
+	if cli.Refine != "" {
+		draft, err := os.ReadFile(ExpandPath(cli.Refine))
+		if err != nil {
+			return fmt.Errorf("cannot read prompt to refine: %v", err)
+		}
+		if strings.TrimSpace(string(draft)) == "" {
+			return fmt.Errorf("cannot refine %s: the file is empty", cli.Refine)
+		}
+		cli.Draft = string(draft)
+		if session.Idea == "" {
+			session.Idea = "refine " + filepath.Base(cli.Refine)
+		}
+	}
+	if cli.Resume != "" {
+		session, err = LoadSession(sessionsDir(), ExpandPath(cli.Resume))
+		if err != nil {
+			return fmt.Errorf("cannot resume: %v", err)
+		}
+		if v, ok := cfg.Experiment.variant(session.Experiment); ok && cfg.Persona == "" && cli.Prompt == "" {
+			// Continue with the variant the session started with
+			cfg.SystemPromptFile = v.SystemPromptFile
+			if prompt, err = cfg.systemPrompt(); err != nil {
+				return err
+			}
+			systemPrompt = []byte(prompt)
+		} else {
+			// Results under another system prompt would skew the report
+			session.Experiment = nil
+		}
+	}
+
+	clipboardCmds := DetectClipboardCmds(cfg.ClipboardCmd)
+	if len(clipboardCmds) == 0 && cli.Quiet == QuietClipboard {
+		return withKind(ErrNoClipboard, fmt.Errorf("-qq needs a clipboard command; install wl-copy, xclip or xsel, or set clipboard_cmd in config"))
+	}
+	// Interactive runs offer /copy, which falls back to a file without a clipboard
+	if cli.Strict && len(clipboardCmds) == 0 && cli.Quiet == QuietNone && !cli.NoCopy {
+		return &strictError{ExitNoClipboard, "no clipboard command found; install wl-copy, xclip or xsel, set clipboard_cmd, or pass --no-copy"}
+	}
+	clipboard := NewClipboardWriter(clipboardCmds...)
+	if cfg.ClipboardSensitive {
+		clipboard = NewSensitiveClipboardWriter(clipboardCmds...)
+		if len(clipboardCmds) > 0 && sensitiveCopyCmd(clipboardCmds[0]) == nil {
+			tool := clipboardCmds[0]
+			if parts, err := splitCommand(tool); err == nil && len(parts) > 0 {
+				tool = filepath.Base(parts[0])
+			}
+			if cli.Strict {
+				return &strictError{ExitNoClipboard, fmt.Sprintf("%s cannot mark copies as sensitive (clipboard_sensitive)", tool)}
+			}
+			if cli.Quiet < QuietSilent {
+				fmt.Fprintf(os.Stderr, "Warning: %s cannot mark copies as sensitive; clipboard history may keep the prompt\n", tool)
+			}
+		}
+	}
+
+	client, err := newLLMClient(cfg, host, model)
 	if err != nil {
-		return fmt.Errorf("system prompt not found: %s", promptPath)
+		return withKind(ErrConfig, err)
+	}
+
+	// An idea or context files too long to send whole go to the model
+	// summarized
+	status := io.Writer(os.Stderr)
+	if cli.Quiet >= QuietSilent {
+		status = io.Discard
+	}
+	if cli.Idea, err = condenseInput(ctx, client, "the idea", cli.Idea, inputBudget(cfg), status); err != nil {
+		return err
+	}
+	if attached, err = condenseContextFiles(ctx, client, attached, inputBudget(cfg), status); err != nil {
+		return err
+	}
+
+	// The session keeps the idea short; the files go to the model
+	cli.Idea = withContext(cli.Idea, attached)
+	if cli.Messages != "" {
+		handed, err := readMessages(cli.Messages, os.Stdin)
+		if err != nil {
+			return err
+		}
+		session.Idea, session.Messages = handed.Idea, handed.Messages
+		if cli.Idea != "" {
+			// The idea is the next turn of the conversation
+			session.Messages = append(session.Messages, Message{Role: "user", Content: cli.Idea})
+		}
+	}
+	switch {
+	case cli.RedactEnv:
+		session.Environment = nil
+	case session.Environment == nil:
+		// A resumed session keeps the environment it started in
+		envCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		session.Environment = captureEnvironment(envCtx, cfg, host, model, server)
+		cancel()
 	}
 
 	// Create real dependencies
 	deps := &Deps{
-		Client:       NewChatClient(cfg.Host, model),
+		Client:       client,
 		Stdin:        os.Stdin,
 		Stdout:       os.Stdout,
 		Stderr:       os.Stderr,
-		Clipboard:    NewClipboardWriter(DetectClipboardCmd(cfg.ClipboardCmd)),
+		Clipboard:    clipboard,
 		IsTTY:        isTTY,
+		StatusTTY:    isStderrTTY,
 		SystemPrompt: string(systemPrompt),
+		Session:      session,
+		SaveSession:  saveSession,
+		OpenBrowser:  openBrowser,
+		Config:       cfg,
+		NewClient: func(model string) (LLMClient, error) {
+			return newLLMClient(cfg, host, model)
+		},
+	}
+	if cfg.DuplicateWindow > 0 {
+		deps.ResponseCache = responseCacheDir()
+	}
+	// What the flags added to the system prompt stays when it is reloaded
+	extra := string(systemPrompt[len(prompt):])
+	deps.ReloadSystemPrompt = func() (string, error) {
+		prompt, err := cfg.systemPrompt()
+		return prompt + extra, err
+	}
+	if deps.IsTTY() {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		defer signal.Stop(hangup)
+		deps.Hangup = hangup
+	}
+
+	if cli.Live != "" {
+		deps.Live = NewLiveFeed(session.Idea)
+		link, stop, err := startLive(cli.Live, deps.Live)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		if cli.Quiet < QuietSilent {
+			fmt.Fprintf(os.Stderr, "Live view (read-only): %s\n", link)
+		}
 	}
 
 	return runWithDeps(ctx, cli, deps)
 }
 
 func main() {
+	// Restore the terminal even if something panics
+	defer func() {
+		if r := recover(); r != nil {
+			runCleanup()
+			panic(r)
+		}
+	}()
+
 	// Set up signal handling
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -226,30 +1528,47 @@ func main() {
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigChan
+		// Cancel the in-flight request so run can return and clean up;
+		// a second signal, or anything not watching ctx, ends it anyway
 		cancel()
-		os.Exit(130) // Standard exit code for SIGINT
+		select {
+		case <-sigChan:
+		case <-time.After(2 * time.Second):
+		}
+		exit(130) // Standard exit code for SIGINT
 	}()
 
+	if offlineBuild {
+		// Before anything can connect; the config may allow more hosts
+		restrictConnections(OfflineConfig{})
+	}
+
+	if len(os.Args) > 1 {
+		if code, ok := runSubcommand(ctx, os.Args[1], os.Args[2:]); ok {
+			exit(code)
+		}
+	}
+
 	cli, err := parseArgs()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
 		flag.Usage()
-		os.Exit(ExitConfigError)
+		exit(ExitConfigError)
 	}
 
 	if err := run(ctx, cli); err != nil {
-		errStr := err.Error()
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-
-		switch {
-		case strings.Contains(errStr, "config") || strings.Contains(errStr, "system prompt"):
-			os.Exit(ExitConfigError)
-		case strings.Contains(errStr, "LLM") || strings.Contains(errStr, "connect"):
-			os.Exit(ExitLLMError)
-		case strings.Contains(errStr, "no model"):
-			os.Exit(ExitNoModel)
-		default:
-			os.Exit(1)
+		if errors.Is(err, context.Canceled) {
+			exit(130)
+		}
+		var deadline *deadlineError
+		if cli.Deadline > 0 && errors.Is(err, context.DeadlineExceeded) && !errors.As(err, &deadline) {
+			// Cut short somewhere other than the conversation, such as
+			// connecting or comparing models
+			err = &deadlineError{Deadline: cli.Deadline}
+		}
+		if cli.Quiet < QuietSilent {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
+		exit(exitCode(err))
 	}
 }
@@ -2,7 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestChatClient_ImplementsLLMClient(t *testing.T) {
@@ -13,3 +20,119 @@ func TestRunWithDeps_Exists(t *testing.T) {
 	// Just verify the function signature exists
 	var _ func(context.Context, *CLI, *Deps) error = runWithDeps
 }
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`\x1e`, "\x1e"},
+		{`---\n`, "---\n"},
+		{`plain`, "plain"},
+		{`say "hi"`, `say "hi"`},
+		{`bad \q escape`, `bad \q escape`},
+	}
+
+	for _, tt := range tests {
+		if got := unescape(tt.input); got != tt.want {
+			t.Errorf("unescape(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestQuietFlag(t *testing.T) {
+	tests := []struct {
+		args string
+		want QuietLevel
+	}{
+		{"", QuietNone},
+		{"-q", QuietPrompt},
+		{"-qq", QuietClipboard},
+		{"--silent", QuietSilent},
+		{"-silent -q", QuietSilent}, // the quietest level wins
+		{"-qq=false", QuietNone},
+	}
+
+	for _, tt := range tests {
+		var level QuietLevel
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Var(&quietFlag{&level, QuietPrompt}, "q", "")
+		fs.Var(&quietFlag{&level, QuietClipboard}, "qq", "")
+		fs.Var(&quietFlag{&level, QuietSilent}, "silent", "")
+
+		if err := fs.Parse(strings.Fields(tt.args)); err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.args, err)
+		}
+		if level != tt.want {
+			t.Errorf("Parse(%q) level = %d, want %d", tt.args, level, tt.want)
+		}
+	}
+}
+
+func TestReadIdea(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.md")
+	os.WriteFile(path, []byte("\nA reviewer for Go code.\n\nIt should check \"errors\" and naming.\n"), 0644)
+	idea, err := readIdea(path, nil)
+	if err != nil || idea != "A reviewer for Go code.\n\nIt should check \"errors\" and naming." {
+		t.Errorf("readIdea(file) = %q, %v", idea, err)
+	}
+	if idea, err := readIdea("-", strings.NewReader("from stdin\n")); err != nil || idea != "from stdin" {
+		t.Errorf("readIdea(-) = %q, %v", idea, err)
+	}
+	if _, err := readIdea("-", strings.NewReader(" \n")); err == nil || err.Error() != "the idea in stdin is empty" {
+		t.Errorf("empty stdin: error = %v", err)
+	}
+	if _, err := readIdea(filepath.Join(t.TempDir(), "gone.md"), nil); err == nil || !strings.Contains(err.Error(), "cannot read idea") {
+		t.Errorf("missing file: error = %v", err)
+	}
+}
+
+func TestIsRegularFile(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !isRegularFile(f) {
+		t.Error("isRegularFile(file) = false, want true")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if isRegularFile(w) {
+		t.Error("isRegularFile(pipe) = true, want false")
+	}
+}
+
+func TestLineReader_TimeoutKeepsPendingRead(t *testing.T) {
+	r, w := io.Pipe()
+	reader := newLineReader(context.Background(), r)
+
+	if _, err := reader.ReadLine(10 * time.Millisecond); err != errIdleTimeout {
+		t.Fatalf("expected errIdleTimeout, got: %v", err)
+	}
+
+	go w.Write([]byte("late answer\n"))
+	line, err := reader.ReadLine(time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if line != "late answer\n" {
+		t.Errorf("ReadLine() = %q, want %q", line, "late answer\n")
+	}
+}
+
+func TestLineReader_Cancel(t *testing.T) {
+	r, _ := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := newLineReader(ctx, r)
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := reader.ReadLine(0); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadLine() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,250 @@
+// mdns.go
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mdnsServices are the service types discover browses for. Ollama and LM
+// Studio do not announce themselves, but avahi service files for Ollama
+// commonly use _ollama._tcp, and _llm._tcp suits other servers announced
+// the same way.
+var mdnsServices = []string{"_llm._tcp.local.", "_ollama._tcp.local."}
+
+// mdnsGroup is the multicast address mDNS queries go to.
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// DNS record types and class used by mDNS service discovery.
+const (
+	dnsTypeA   = 1
+	dnsTypePTR = 12
+	dnsTypeSRV = 33
+	dnsClassIN = 1
+)
+
+// dnsQuestion asks for the records of one name and type.
+type dnsQuestion struct {
+	Name string
+	Type uint16
+}
+
+// dnsRecord is a resource record of a type service discovery uses; other
+// types are kept with only their name and type.
+type dnsRecord struct {
+	Name   string
+	Type   uint16
+	Target string // PTR: the instance; SRV: the host
+	Port   uint16 // SRV
+	IP     net.IP // A
+}
+
+// dnsMessage is a DNS message as mDNS sends it. Answers, authority and
+// additional records are read into Records together.
+type dnsMessage struct {
+	ID        uint16
+	Response  bool
+	Questions []dnsQuestion
+	Records   []dnsRecord
+}
+
+// packQuery encodes a query for the PTR records of services.
+func packQuery(services []string) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 0) // ID and flags
+	b = binary.BigEndian.AppendUint16(b, uint16(len(services)))
+	b = binary.BigEndian.AppendUint32(b, 0) // answers and authority
+	b = binary.BigEndian.AppendUint16(b, 0) // additional
+	for _, s := range services {
+		b = appendDNSName(b, s)
+		b = binary.BigEndian.AppendUint16(b, dnsTypePTR)
+		b = binary.BigEndian.AppendUint16(b, dnsClassIN)
+	}
+	return b
+}
+
+// appendDNSName appends name as a sequence of length-prefixed labels.
+func appendDNSName(b []byte, name string) []byte {
+	for label := range strings.SplitSeq(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+var errDNSShort = errors.New("dns message is truncated")
+
+// parseDNS decodes a DNS message. Names are lowercased, since DNS compares
+// them without regard to case.
+func parseDNS(b []byte) (dnsMessage, error) {
+	if len(b) < 12 {
+		return dnsMessage{}, errDNSShort
+	}
+	m := dnsMessage{
+		ID:       binary.BigEndian.Uint16(b),
+		Response: b[2]&0x80 != 0,
+	}
+	questions := int(binary.BigEndian.Uint16(b[4:]))
+	records := int(binary.BigEndian.Uint16(b[6:])) + int(binary.BigEndian.Uint16(b[8:])) + int(binary.BigEndian.Uint16(b[10:]))
+	off := 12
+	for range questions {
+		name, n, err := readDNSName(b, off)
+		if err != nil {
+			return m, err
+		}
+		if n+4 > len(b) {
+			return m, errDNSShort
+		}
+		m.Questions = append(m.Questions, dnsQuestion{Name: name, Type: binary.BigEndian.Uint16(b[n:])})
+		off = n + 4
+	}
+	for range records {
+		name, n, err := readDNSName(b, off)
+		if err != nil {
+			return m, err
+		}
+		if n+10 > len(b) {
+			return m, errDNSShort
+		}
+		r := dnsRecord{Name: name, Type: binary.BigEndian.Uint16(b[n:])}
+		start := n + 10
+		end := start + int(binary.BigEndian.Uint16(b[n+8:]))
+		if end > len(b) {
+			return m, errDNSShort
+		}
+		switch r.Type {
+		case dnsTypePTR:
+			if r.Target, _, err = readDNSName(b, start); err != nil {
+				return m, err
+			}
+		case dnsTypeSRV:
+			if end-start < 7 {
+				return m, errDNSShort
+			}
+			r.Port = binary.BigEndian.Uint16(b[start+4:])
+			if r.Target, _, err = readDNSName(b, start+6); err != nil {
+				return m, err
+			}
+		case dnsTypeA:
+			if end-start == 4 {
+				r.IP = net.IP(slices.Clone(b[start:end]))
+			}
+		}
+		m.Records = append(m.Records, r)
+		off = end
+	}
+	return m, nil
+}
+
+// readDNSName reads the name at off, following compression pointers, and
+// returns it with the offset just past it.
+func readDNSName(b []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1 // where the name ends, once a pointer has been followed
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, errDNSShort
+		}
+		n := int(b[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")) + ".", next, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(b) {
+				return "", 0, errDNSShort
+			}
+			// A pointer can only point back; a loop would never end
+			if jumps++; jumps > 32 {
+				return "", 0, errors.New("dns name has too many compression pointers")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3FFF)
+		default:
+			if off+1+n > len(b) {
+				return "", 0, errDNSShort
+			}
+			labels = append(labels, string(b[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// mdnsHosts returns the URL of each service in mdnsServices that msg
+// describes, such as http://192.168.1.20:11434. A service's address is
+// taken from the A record of its host, or is the sender's if msg has none.
+func mdnsHosts(msg dnsMessage, from net.IP) []string {
+	addrs := map[string]net.IP{}
+	for _, r := range msg.Records {
+		if r.Type == dnsTypeA && r.IP != nil {
+			addrs[r.Name] = r.IP
+		}
+	}
+	var hosts []string
+	for _, r := range msg.Records {
+		if r.Type != dnsTypeSRV || !slices.ContainsFunc(mdnsServices, func(s string) bool { return strings.HasSuffix(r.Name, "."+s) }) {
+			continue
+		}
+		ip := addrs[r.Target]
+		if ip == nil {
+			ip = from
+		}
+		hosts = append(hosts, "http://"+net.JoinHostPort(ip.String(), strconv.Itoa(int(r.Port))))
+	}
+	return hosts
+}
+
+// browseMDNS asks group for the services in mdnsServices and returns the
+// URLs of those that answer within wait, in the order they answered. It
+// finds nothing in offline mode, which keeps the tool off the LAN.
+func browseMDNS(ctx context.Context, group *net.UDPAddr, wait time.Duration) []string {
+	if offlineHosts.Load() != nil {
+		return nil
+	}
+	// From a port other than 5353, so responders answer this socket
+	// directly (a legacy unicast query in RFC 6762)
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	if _, err := conn.WriteToUDP(packQuery(mdnsServices), group); err != nil {
+		return nil
+	}
+	conn.SetReadDeadline(time.Now().Add(wait))
+
+	var hosts []string
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return hosts
+		}
+		msg, err := parseDNS(buf[:n])
+		if err != nil || !msg.Response {
+			continue
+		}
+		for _, h := range mdnsHosts(msg, from.IP) {
+			if !slices.Contains(hosts, h) {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+}
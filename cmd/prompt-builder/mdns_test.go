@@ -0,0 +1,121 @@
+// mdns_test.go
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// packResponse encodes the answer a responder such as avahi gives for an
+// Ollama server: its PTR, SRV and A records.
+func packResponse(instance, target string, port uint16, ip net.IP) []byte {
+	b := []byte{0, 0, 0x84, 0, 0, 0, 0, 3, 0, 0, 0, 0}
+	record := func(name string, typ uint16, data []byte) {
+		b = appendDNSName(b, name)
+		b = binary.BigEndian.AppendUint16(b, typ)
+		b = binary.BigEndian.AppendUint16(b, dnsClassIN)
+		b = binary.BigEndian.AppendUint32(b, 120)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+		b = append(b, data...)
+	}
+	record("_ollama._tcp.local.", dnsTypePTR, appendDNSName(nil, instance))
+	srv := binary.BigEndian.AppendUint32(nil, 0)
+	srv = binary.BigEndian.AppendUint16(srv, port)
+	record(instance, dnsTypeSRV, appendDNSName(srv, target))
+	record(target, dnsTypeA, ip.To4())
+	return b
+}
+
+func TestParseDNS(t *testing.T) {
+	got, err := parseDNS(packResponse("Ollama on gpu-box._ollama._tcp.local.", "GPU-Box.local.", 11434, net.IPv4(192, 168, 1, 20)))
+	if err != nil {
+		t.Fatalf("parseDNS: %v", err)
+	}
+	if !got.Response || len(got.Records) != 3 {
+		t.Fatalf("parseDNS = %+v", got)
+	}
+	if ptr := got.Records[0]; ptr.Target != "ollama on gpu-box._ollama._tcp.local." {
+		t.Errorf("PTR target = %q", ptr.Target)
+	}
+	if srv := got.Records[1]; srv.Port != 11434 || srv.Target != "gpu-box.local." {
+		t.Errorf("SRV = %+v, want port 11434 on gpu-box.local.", srv)
+	}
+	if hosts := mdnsHosts(got, net.IPv4(10, 0, 0, 1)); !slices.Equal(hosts, []string{"http://192.168.1.20:11434"}) {
+		t.Errorf("mdnsHosts = %v, want the address of the A record", hosts)
+	}
+
+	query, err := parseDNS(packQuery(mdnsServices))
+	if err != nil || query.Response || len(query.Questions) != 2 || query.Questions[1] != (dnsQuestion{"_ollama._tcp.local.", dnsTypePTR}) {
+		t.Errorf("parseDNS(packQuery) = %+v, %v", query, err)
+	}
+}
+
+func TestMDNSHosts_SenderAddress(t *testing.T) {
+	msg := dnsMessage{Response: true, Records: []dnsRecord{
+		{Name: "ollama on nas._ollama._tcp.local.", Type: dnsTypeSRV, Port: 11434, Target: "nas.local."},
+		{Name: "printer._ipp._tcp.local.", Type: dnsTypeSRV, Port: 631, Target: "nas.local."},
+	}}
+	if hosts := mdnsHosts(msg, net.IPv4(192, 168, 1, 5)); !slices.Equal(hosts, []string{"http://192.168.1.5:11434"}) {
+		t.Errorf("mdnsHosts = %v, want only the LLM service at the sender's address", hosts)
+	}
+}
+
+func TestParseDNS_Compression(t *testing.T) {
+	// A PTR record whose name points back to the question's name
+	b := packQuery([]string{"_llm._tcp.local."})
+	b[7] = 1 // one answer
+	b = append(b, 0xC0, 12, 0, dnsTypePTR, 0, dnsClassIN, 0, 0, 0, 120, 0, 4, 1, 'x', 0xC0, 12)
+
+	msg, err := parseDNS(b)
+	if err != nil {
+		t.Fatalf("parseDNS: %v", err)
+	}
+	if r := msg.Records[0]; r.Name != "_llm._tcp.local." || r.Target != "x._llm._tcp.local." {
+		t.Errorf("record = %+v", r)
+	}
+
+	loop := append(b[:12:12], 0xC0, 12) // a name that points to itself
+	loop[5] = 1
+	if _, err := parseDNS(loop); err == nil {
+		t.Error("expected an error for a pointer loop")
+	}
+}
+
+func TestBrowseMDNS(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	group := conn.LocalAddr().(*net.UDPAddr)
+	go func() {
+		// Answer the query as avahi would
+		buf := make([]byte, 9000)
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if q, err := parseDNS(buf[:n]); err == nil && !q.Response {
+			reply := packResponse("Ollama on gpu-box._ollama._tcp.local.", "gpu-box.local.", 11434, net.IPv4(127, 0, 0, 1))
+			conn.WriteToUDP(reply, from)
+		}
+	}()
+
+	hosts := browseMDNS(context.Background(), group, 300*time.Millisecond)
+	if !slices.Equal(hosts, []string{"http://127.0.0.1:11434"}) {
+		t.Errorf("browseMDNS = %v, want the announced server", hosts)
+	}
+}
+
+func TestBrowseMDNS_Offline(t *testing.T) {
+	offlineHosts.Store(&OfflineConfig{Enabled: true})
+	t.Cleanup(func() { offlineHosts.Store(nil) })
+
+	if hosts := browseMDNS(context.Background(), mdnsGroup, time.Second); hosts != nil {
+		t.Errorf("browseMDNS = %v, want nothing in offline mode", hosts)
+	}
+}
@@ -0,0 +1,134 @@
+// migrate.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renamedKeys maps config keys from earlier releases to their current names.
+var renamedKeys = []struct{ Old, New string }{
+	{"ollama_host", "host"},
+}
+
+// migrateLegacyKeys renames legacy top-level keys in a parsed config
+// document in place. A legacy key whose replacement is also set is
+// removed. It returns one warning per key changed.
+func migrateLegacyKeys(doc *yaml.Node) []string {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	m := doc.Content[0]
+
+	var warnings []string
+	for _, r := range renamedKeys {
+		oldAt, newAt := -1, -1
+		for i := 0; i < len(m.Content); i += 2 {
+			switch m.Content[i].Value {
+			case r.Old:
+				oldAt = i
+			case r.New:
+				newAt = i
+			}
+		}
+		switch {
+		case oldAt == -1:
+			continue
+		case newAt != -1:
+			m.Content = append(m.Content[:oldAt], m.Content[oldAt+2:]...)
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated and ignored because %s is set; remove it", r.Old, r.New))
+		default:
+			m.Content[oldAt].Value = r.New
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated; rename it to %s", r.Old, r.New))
+		}
+	}
+	return warnings
+}
+
+// runConfig implements the config subcommand.
+func runConfig(ctx context.Context, args []string, in io.Reader, out, errOut io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			return runConfigMigrate(args[1:], in, out, errOut)
+		case "validate":
+			return runConfigValidate(ctx, args[1:], out, errOut)
+		case "get":
+			return runConfigGet(args[1:], out, errOut)
+		case "set":
+			return runConfigSet(args[1:], out, errOut)
+		}
+	}
+	fmt.Fprintln(errOut, "Usage: prompt-builder config migrate [--yes] [--config path]")
+	fmt.Fprintln(errOut, "       prompt-builder config validate [--ping] [--profile name] [--config path]")
+	fmt.Fprintln(errOut, "       prompt-builder config get <key> [--profile name] [--config path]")
+	fmt.Fprintln(errOut, "       prompt-builder config set <key> <value> [--config path]")
+	return ExitConfigError
+}
+
+// runConfigMigrate implements config migrate.
+func runConfigMigrate(args []string, in io.Reader, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("config migrate", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	yes := fs.Bool("yes", false, "Rewrite without asking")
+	configPath := fs.String("config", defaultConfigPath(), "Path to config file")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	path := ExpandPath(*configPath)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		fmt.Fprintf(errOut, "Error: %s: %v\n", path, err)
+		return ExitConfigError
+	}
+
+	changes := migrateLegacyKeys(&doc)
+	if len(changes) == 0 {
+		fmt.Fprintf(out, "%s is up to date.\n", path)
+		return ExitSuccess
+	}
+	for _, c := range changes {
+		fmt.Fprintf(out, "  %s\n", c)
+	}
+
+	if !*yes {
+		fmt.Fprintf(out, "Rewrite %s? The original is kept as %s.bak [y/N] ", path, path)
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Fprintln(out, "Not changed.")
+			return ExitSuccess
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	if err := os.WriteFile(path+".bak", data, 0o600); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	fmt.Fprintf(out, "✓ Migrated %s\n", path)
+	return ExitSuccess
+}
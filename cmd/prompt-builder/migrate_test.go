@@ -0,0 +1,126 @@
+// migrate_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantHost     string
+		wantWarnings int
+	}{
+		{"current", "host: http://a:1\n", "http://a:1", 0},
+		{"legacy", "ollama_host: http://a:1\n", "http://a:1", 1},
+		{"both", "ollama_host: http://old:1\nhost: http://new:1\n", "http://new:1", 1},
+		{"empty", "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.input), &doc); err != nil {
+				t.Fatal(err)
+			}
+			warnings := migrateLegacyKeys(&doc)
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %q, want %d", warnings, tt.wantWarnings)
+			}
+
+			var cfg struct {
+				Host       string `yaml:"host"`
+				OllamaHost string `yaml:"ollama_host"`
+			}
+			if doc.Kind != 0 {
+				if err := doc.Decode(&cfg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if cfg.Host != tt.wantHost || cfg.OllamaHost != "" {
+				t.Errorf("host = %q, ollama_host = %q, want host %q only", cfg.Host, cfg.OllamaHost, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_LegacyHost(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("model: m\nollama_host: http://gpu:11434\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Host != "http://gpu:11434" {
+		t.Errorf("Host = %q, want the legacy ollama_host value", cfg.Host)
+	}
+	if len(cfg.Deprecations) != 1 || !strings.Contains(cfg.Deprecations[0], "rename it to host") {
+		t.Errorf("Deprecations = %q, want a rename warning", cfg.Deprecations)
+	}
+}
+
+func TestRunConfigMigrate(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	original := "# my settings\nmodel: m\nollama_host: http://gpu:11434 # the big box\n"
+	if err := os.WriteFile(configPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	code := runConfig(context.Background(), []string{"migrate", "--config", configPath}, strings.NewReader("y\n"), &out, &errOut)
+	if code != ExitSuccess {
+		t.Fatalf("runConfig() = %d, stderr: %s", code, errOut.String())
+	}
+
+	got, _ := os.ReadFile(configPath)
+	for _, want := range []string{"# my settings", "host: http://gpu:11434 # the big box"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("migrated config missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(string(got), "ollama_host") {
+		t.Errorf("migrated config still has ollama_host:\n%s", got)
+	}
+	if backup, _ := os.ReadFile(configPath + ".bak"); string(backup) != original {
+		t.Errorf("backup = %q, want the original", backup)
+	}
+
+	out.Reset()
+	if code := runConfig(context.Background(), []string{"migrate", "--config", configPath}, strings.NewReader(""), &out, &errOut); code != ExitSuccess || !strings.Contains(out.String(), "up to date") {
+		t.Errorf("second run = %d %q, want up to date", code, out.String())
+	}
+}
+
+func TestRunConfigMigrate_Declined(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	original := "ollama_host: http://gpu:11434\n"
+	if err := os.WriteFile(configPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	runConfig(context.Background(), []string{"migrate", "--config", configPath}, strings.NewReader("n\n"), &out, &errOut)
+	if got, _ := os.ReadFile(configPath); string(got) != original {
+		t.Errorf("config changed after declining:\n%s", got)
+	}
+	if _, err := os.Stat(configPath + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("backup written after declining")
+	}
+}
+
+func TestRunConfig_Usage(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := runConfig(context.Background(), nil, strings.NewReader(""), &out, &errOut); code != ExitConfigError || !strings.Contains(errOut.String(), "Usage") {
+		t.Errorf("runConfig(nil) = %d %q, want usage", code, errOut.String())
+	}
+}
@@ -0,0 +1,232 @@
+// models.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ModelInfo is one model a server offers. Size is 0 and Loaded false when
+// the server does not say.
+type ModelInfo struct {
+	Name   string
+	Size   int64
+	Loaded bool
+}
+
+// modelList is the result of listing a server's models. Sizes and
+// loaded state come only from Ollama's native API.
+type modelList struct {
+	Models    []ModelInfo
+	HasSize   bool
+	HasLoaded bool
+}
+
+type ollamaModels struct {
+	Models []struct {
+		Name   string `json:"name"`
+		Size   int64  `json:"size"`
+		Digest string `json:"digest"`
+	} `json:"models"`
+}
+
+// modelsGet fetches host+path into v. It reports false with no error when
+// the server answers 404, so callers can try another endpoint.
+func modelsGet(ctx context.Context, cfg *Config, host, path, apiKey string, v any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, host+path, nil)
+	if err != nil {
+		return false, err
+	}
+	if apiKey != "" {
+		if cfg.Provider == providerAnthropic {
+			req.Header.Set("X-Api-Key", apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+	}
+	if cfg.Provider == providerAnthropic {
+		req.Header.Set("Anthropic-Version", anthropicVersion)
+	}
+	resp, err := newHTTPClient(cfg).Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to LLM server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, newHTTPError(resp, "")
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(v); err != nil {
+		return false, fmt.Errorf("LLM request failed: %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// listModels asks the server at host for its models. An Ollama server is
+// asked through /api/tags and /api/ps, which give sizes and what is
+// loaded; anything else through /v1/models.
+func listModels(ctx context.Context, cfg *Config, host, apiKey string) (modelList, error) {
+	if cfg.Provider == providerCompatible {
+		var tags ollamaModels
+		ok, err := modelsGet(ctx, cfg, host, "/api/tags", apiKey, &tags)
+		if err != nil {
+			return modelList{}, err
+		}
+		if ok {
+			return ollamaModelList(ctx, cfg, host, apiKey, tags)
+		}
+	}
+
+	var models modelsResponse
+	ok, err := modelsGet(ctx, cfg, host, "/v1/models", apiKey, &models)
+	if err != nil {
+		return modelList{}, err
+	}
+	if !ok {
+		return modelList{}, fmt.Errorf("LLM request failed: %s has no /v1/models", host)
+	}
+	var list modelList
+	for _, m := range models.Data {
+		list.Models = append(list.Models, ModelInfo{Name: m.ID})
+	}
+	return list, nil
+}
+
+// ollamaModelList turns /api/tags into a modelList, marking the models
+// /api/ps reports as loaded when the server is new enough to have it.
+func ollamaModelList(ctx context.Context, cfg *Config, host, apiKey string, tags ollamaModels) (modelList, error) {
+	list := modelList{HasSize: true}
+	for _, m := range tags.Models {
+		list.Models = append(list.Models, ModelInfo{Name: m.Name, Size: m.Size})
+	}
+
+	if info, err := DetectServer(ctx, newHTTPClient(cfg), host); err != nil || !info.Supports(featureRunningModels) {
+		return list, nil
+	}
+	var running ollamaModels
+	ok, err := modelsGet(ctx, cfg, host, featureRunningModels, apiKey, &running)
+	if err != nil || !ok {
+		return list, nil
+	}
+	list.HasLoaded = true
+	for _, r := range running.Models {
+		for i := range list.Models {
+			if list.Models[i].Name == r.Name {
+				list.Models[i].Loaded = true
+			}
+		}
+	}
+	return list, nil
+}
+
+// formatSize prints n bytes the way Ollama does, e.g. "4.7 GB".
+func formatSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// printModels writes list as a table, leaving out columns the server
+// gave no data for. The configured model is marked with *.
+func printModels(out io.Writer, list modelList, current string) {
+	width := len("NAME")
+	for _, m := range list.Models {
+		width = max(width, len(m.Name))
+	}
+	header := fmt.Sprintf("  %-*s", width, "NAME")
+	if list.HasSize {
+		header += fmt.Sprintf("  %8s", "SIZE")
+	}
+	if list.HasLoaded {
+		header += "  LOADED"
+	}
+	fmt.Fprintln(out, header)
+
+	for _, m := range list.Models {
+		mark := " "
+		if m.Name == current {
+			mark = "*"
+		}
+		line := fmt.Sprintf("%s %-*s", mark, width, m.Name)
+		if list.HasSize {
+			line += fmt.Sprintf("  %8s", formatSize(m.Size))
+		}
+		if list.HasLoaded && m.Loaded {
+			line += "  yes"
+		}
+		fmt.Fprintln(out, line)
+	}
+}
+
+// runModels implements the models subcommand.
+func runModels(ctx context.Context, args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("models", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	configPath := fs.String("config", "", "Path to config file (default: your config and the project's)")
+	profile := fs.String("profile", "", "Profile from the config file to use")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+
+	cfg, err := loadRunConfig(*configPath, *profile)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: invalid config: %v\n", err)
+		return ExitConfigError
+	}
+	apiKey, err := resolveAPIKey(cfg)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+
+	host := cfg.Host
+	switch {
+	case cfg.SSHTunnel != "":
+		spec, err := ParseTunnelSpec(cfg.SSHTunnel)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error: invalid config: %v\n", err)
+			return ExitConfigError
+		}
+		tunnel, err := StartSSHTunnel(ctx, spec, 10*time.Second)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error: %v\n", err)
+			return ExitLLMError
+		}
+		defer tunnel.Close()
+		host = tunnel.Host
+	case host == hostAuto:
+		if host, err = resolveAutoHost(ctx); err != nil {
+			fmt.Fprintf(errOut, "Error: %v\n", err)
+			return ExitLLMError
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	list, err := listModels(ctx, cfg, host, apiKey)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitLLMError
+	}
+	if len(list.Models) == 0 {
+		fmt.Fprintf(errOut, "No models on %s.\n", host)
+		return ExitSuccess
+	}
+	printModels(out, list, cfg.resolveModel(cfg.Model))
+	return ExitSuccess
+}
@@ -0,0 +1,165 @@
+// models_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListModels_Ollama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/version":
+			fmt.Fprint(w, `{"version":"0.5.7"}`)
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest","size":2019393189},{"name":"qwen2.5:7b","size":4683087332}]}`)
+		case "/api/ps":
+			fmt.Fprint(w, `{"models":[{"name":"qwen2.5:7b","size":6000000000}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	list, err := listModels(context.Background(), &Config{Provider: providerCompatible}, server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ModelInfo{{"llama3.2:latest", 2019393189, false}, {"qwen2.5:7b", 4683087332, true}}
+	if !list.HasSize || !list.HasLoaded || fmt.Sprint(list.Models) != fmt.Sprint(want) {
+		t.Errorf("listModels() = %+v, want %v with sizes and loaded state", list, want)
+	}
+}
+
+func TestListModels_OldOllamaHasNoLoadedColumn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/version":
+			fmt.Fprint(w, `{"version":"0.1.30"}`)
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama2","size":3800000000}]}`)
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	list, err := listModels(context.Background(), &Config{Provider: providerCompatible}, server.URL, "")
+	if err != nil || list.HasLoaded || len(list.Models) != 1 {
+		t.Errorf("listModels() = %+v, %v, want one model and no loaded state", list, err)
+	}
+}
+
+func TestListModels_OpenAICompatible(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`)
+	}))
+	defer server.Close()
+
+	list, err := listModels(context.Background(), &Config{Provider: providerOpenAI}, server.URL, "sk-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.HasSize || list.HasLoaded || len(list.Models) != 2 || list.Models[0].Name != "gpt-4o" {
+		t.Errorf("listModels() = %+v, want two names only", list)
+	}
+	if auth != "Bearer sk-test" {
+		t.Errorf("Authorization = %q, want the API key", auth)
+	}
+}
+
+func TestListModels_AnthropicHeaders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		fmt.Fprint(w, `{"data":[{"id":"claude-sonnet"}]}`)
+	}))
+	defer server.Close()
+
+	if _, err := listModels(context.Background(), &Config{Provider: providerAnthropic}, server.URL, "sk-ant"); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Api-Key") != "sk-ant" || header.Get("Anthropic-Version") == "" || header.Get("Authorization") != "" {
+		t.Errorf("headers = %v, want X-Api-Key and Anthropic-Version", header)
+	}
+}
+
+func TestListModels_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad key", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := listModels(context.Background(), &Config{Provider: providerOpenAI}, server.URL, "sk")
+	if err == nil || !strings.Contains(err.Error(), "LLM") || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("error = %v, want an LLM error with the server's message", err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:        "512 B",
+		1500:       "1.5 kB",
+		2019393189: "2.0 GB",
+		4683087332: "4.7 GB",
+	} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestPrintModels(t *testing.T) {
+	var out bytes.Buffer
+	printModels(&out, modelList{
+		Models:    []ModelInfo{{"llama3.2", 2019393189, false}, {"qwen2.5:7b", 4683087332, true}},
+		HasSize:   true,
+		HasLoaded: true,
+	}, "llama3.2")
+	want := "  NAME            SIZE  LOADED\n" +
+		"* llama3.2      2.0 GB\n" +
+		"  qwen2.5:7b    4.7 GB  yes\n"
+	if out.String() != want {
+		t.Errorf("printModels() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRunModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"m"},{"id":"other"}]}`)
+	}))
+	defer server.Close()
+
+	var out, errOut bytes.Buffer
+	code := runModels(context.Background(), []string{"--config", writeDoctorConfig(t, server.URL)}, &out, &errOut)
+	if code != ExitSuccess {
+		t.Fatalf("runModels() = %d, want %d\n%s", code, ExitSuccess, errOut.String())
+	}
+	if !strings.Contains(out.String(), "* m") || !strings.Contains(out.String(), "  other") {
+		t.Errorf("output = %q, want both models with the configured one marked", out.String())
+	}
+}
+
+func TestRunModels_Unreachable(t *testing.T) {
+	var out, errOut bytes.Buffer
+	code := runModels(context.Background(), []string{"--config", writeDoctorConfig(t, "http://127.0.0.1:1")}, &out, &errOut)
+	if code != ExitLLMError {
+		t.Errorf("runModels() = %d, want %d", code, ExitLLMError)
+	}
+}
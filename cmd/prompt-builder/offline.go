@@ -0,0 +1,136 @@
+// offline.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// homeEnv names the variable that puts all of the tool's state, config
+// included, under one directory.
+const homeEnv = "PROMPT_BUILDER_HOME"
+
+// homeDir returns the directory homeEnv names, or "" when it is unset.
+func homeDir() string {
+	if dir := os.Getenv(homeEnv); dir != "" {
+		return ExpandPath(dir)
+	}
+	return ""
+}
+
+// OfflineConfig keeps the tool inside an air-gapped network (offline in
+// the config). Features that post to other services cannot be
+// configured, and every connection is refused before its host name is
+// resolved unless the host is loopback or in AllowedHosts.
+type OfflineConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	AllowedHosts []string `yaml:"allowed_hosts"` // such as the lab's LLM server
+}
+
+// active reports whether offline mode is on, by config or because the
+// binary was built with the offline tag.
+func (c OfflineConfig) active() bool {
+	return c.Enabled || offlineBuild
+}
+
+// allows reports whether host may be connected to.
+func (c OfflineConfig) allows(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	return slices.ContainsFunc(c.AllowedHosts, func(h string) bool { return strings.EqualFold(h, host) })
+}
+
+// validate checks cfg against offline mode: no feature that reaches
+// another service is set, and every server cfg names is allowed.
+func (c OfflineConfig) validate(cfg *Config) error {
+	if !c.active() {
+		return nil
+	}
+	for _, r := range []struct {
+		key string
+		set bool
+	}{
+		{"share", cfg.Share.URL != ""},
+		{"review", cfg.Review.URL != ""},
+		{"webhooks", len(cfg.Webhooks) > 0},
+		{"registry", cfg.Registry != RegistryConfig{}},
+	} {
+		if r.set {
+			return fmt.Errorf("%s is not available in offline mode; remove it from the config", r.key)
+		}
+	}
+
+	type server struct{ key, url string }
+	urls := []server{{"proxy", cfg.Proxy}}
+	if cfg.Host != hostAuto && cfg.SSHTunnel == "" {
+		urls = append(urls, server{"host", cfg.Host})
+	}
+	for _, h := range cfg.Hosts {
+		urls = append(urls, server{"hosts", h.URL})
+	}
+	for _, u := range urls {
+		parsed, err := url.Parse(u.url)
+		if u.url == "" || err != nil {
+			continue // checked elsewhere
+		}
+		if !c.allows(parsed.Hostname()) {
+			return fmt.Errorf("%s: %s is not in offline.allowed_hosts", u.key, parsed.Hostname())
+		}
+	}
+	if cfg.SSHTunnel != "" {
+		spec, err := ParseTunnelSpec(cfg.SSHTunnel)
+		if err != nil {
+			return err
+		}
+		host := spec.Destination[strings.LastIndex(spec.Destination, "@")+1:]
+		if !c.allows(host) {
+			return fmt.Errorf("ssh_tunnel: %s is not in offline.allowed_hosts", host)
+		}
+	}
+	return nil
+}
+
+// offlineHosts is the allowlist connections are checked against once
+// offline mode is on; nil allows every host.
+var offlineHosts atomic.Pointer[OfflineConfig]
+
+var guardDefaultTransport sync.Once
+
+// restrictConnections refuses, from now on, connections to hosts c does
+// not allow, both through http.DefaultTransport and newHTTPClient.
+func restrictConnections(c OfflineConfig) {
+	offlineHosts.Store(&c)
+	guardDefaultTransport.Do(func() {
+		t := http.DefaultTransport.(*http.Transport)
+		t.DialContext = guardDial(t.DialContext)
+	})
+}
+
+// guardDial wraps dial to refuse hosts offline mode does not allow,
+// before their names are resolved.
+func guardDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if c := offlineHosts.Load(); c != nil {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			if !c.allows(host) {
+				return nil, fmt.Errorf("offline mode: %s is not in offline.allowed_hosts", host)
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,101 @@
+// offline_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOfflineConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"local", "offline:\n  enabled: true\nhost: http://127.0.0.1:11434\n", ""},
+		{"default host", "offline:\n  enabled: true\n", ""},
+		{"allowed", "offline:\n  enabled: true\n  allowed_hosts: [gpu.lab]\nhost: http://GPU.lab:11434\n", ""},
+		{"not allowed", "offline:\n  enabled: true\nhost: http://gpu.lab:11434\n", "host: gpu.lab is not in offline.allowed_hosts"},
+		{"hosted provider", "offline:\n  enabled: true\nprovider: anthropic\n", "host: api.anthropic.com is not in offline.allowed_hosts"},
+		{"pool", "offline:\n  enabled: true\nhosts:\n  - url: http://localhost:11434\n  - url: http://b.lab:11434\n", "hosts: b.lab"},
+		{"proxy", "offline:\n  enabled: true\nproxy: http://proxy.corp:3128\n", "proxy: proxy.corp"},
+		{"tunnel", "offline:\n  enabled: true\nssh_tunnel: me@gpu.lab:11434\n", "ssh_tunnel: gpu.lab"},
+		{"share", "offline:\n  enabled: true\nshare:\n  url: https://paste.example.com\n  allowed_hosts: [paste.example.com]\n", "share is not available in offline mode"},
+		{"webhooks", "offline:\n  enabled: true\nwebhooks:\n  - url: http://localhost:9000/hook\n", "webhooks is not available"},
+		{"registry", "offline:\n  enabled: true\nregistry:\n  format: langsmith\n", "registry is not available"},
+		{"profile", "profiles:\n  lab:\n    offline:\n      enabled: true\nhost: http://gpu.lab:11434\nprofile: lab\n", "host: gpu.lab"},
+		{"off", "host: http://gpu.lab:11434\nwebhooks:\n  - url: http://hooks.example.com\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig([]byte(tt.config), "")
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("parseConfig() error = %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("parseConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuardDial(t *testing.T) {
+	t.Cleanup(func() { offlineHosts.Store(nil) })
+	var dialed []string
+	dial := guardDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, errors.New("not connecting in a test")
+	})
+
+	dial(context.Background(), "tcp", "example.com:443")
+	offlineHosts.Store(&OfflineConfig{Enabled: true, AllowedHosts: []string{"gpu.lab"}})
+	for _, addr := range []string{"localhost:11434", "127.0.0.1:11434", "[::1]:11434", "gpu.lab:11434"} {
+		if _, err := dial(context.Background(), "tcp", addr); err == nil || strings.Contains(err.Error(), "offline") {
+			t.Errorf("dial(%s) error = %v, want it let through", addr, err)
+		}
+	}
+	for _, addr := range []string{"example.com:443", "10.0.0.5:11434", "gpu.lab.evil.com:80"} {
+		if _, err := dial(context.Background(), "tcp", addr); err == nil || !strings.Contains(err.Error(), "not in offline.allowed_hosts") {
+			t.Errorf("dial(%s) error = %v, want it refused", addr, err)
+		}
+	}
+	want := "example.com:443 localhost:11434 127.0.0.1:11434 [::1]:11434 gpu.lab:11434"
+	if got := strings.Join(dialed, " "); got != want {
+		t.Errorf("dialed %q, want %q (refused hosts must not reach the dialer)", got, want)
+	}
+}
+
+func TestLoadConfig_OfflineRestrictsConnections(t *testing.T) {
+	t.Cleanup(func() { offlineHosts.Store(nil) })
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("offline:\n  enabled: true\n  allowed_hosts: [gpu.lab]\nhost: http://gpu.lab:11434\n"), 0644)
+	if _, err := LoadConfig(configPath); err != nil {
+		t.Fatal(err)
+	}
+	if c := offlineHosts.Load(); c == nil || !c.allows("gpu.lab") || c.allows("example.com") {
+		t.Errorf("allowlist = %+v, want gpu.lab and loopback only", c)
+	}
+	if _, err := newHTTPClient(&Config{}).Get("http://example.com/"); err == nil || !strings.Contains(err.Error(), "offline mode") {
+		t.Errorf("Get() error = %v, want it refused", err)
+	}
+}
+
+func TestHomeEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(homeEnv, home)
+	t.Setenv("XDG_DATA_HOME", "/elsewhere")
+	for _, tc := range []struct{ got, want string }{
+		{defaultConfigPath(), filepath.Join(home, "config.yaml")},
+		{sessionsDir(), filepath.Join(home, "data", "sessions")},
+		{lastPromptPath(), filepath.Join(home, "cache", "last-prompt.md")},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("path = %q, want %q", tc.got, tc.want)
+		}
+	}
+}
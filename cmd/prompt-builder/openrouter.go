@@ -0,0 +1,60 @@
+// openrouter.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// openRouterHeaders identify the app to OpenRouter, which lists traffic by
+// referer and title.
+var openRouterHeaders = map[string]string{
+	"HTTP-Referer": "https://github.com/jwp23/prompt-builder",
+	"X-Title":      "prompt-builder",
+}
+
+func init() {
+	promptbuilder.RegisterProvider(providerOpenRouter, promptbuilder.Provider{
+		New: func(host, model, apiKey string, hc *http.Client) LLMClient {
+			client := NewChatClient(host, model)
+			client.APIKey = apiKey
+			client.client = hc
+			client.Headers = openRouterHeaders
+			return client
+		},
+		Host:      "https://openrouter.ai/api",
+		APIKeyEnv: "OPENROUTER_API_KEY",
+		NeedsKey:  true,
+	})
+}
+
+// rateLimitInfo describes the rate limit headers of a failed response, as
+// sent by OpenRouter and others, or returns "" if there are none.
+func rateLimitInfo(h http.Header) string {
+	var parts []string
+	if remaining := h.Get("X-RateLimit-Remaining"); remaining != "" {
+		if limit := h.Get("X-RateLimit-Limit"); limit != "" {
+			parts = append(parts, fmt.Sprintf("%s of %s requests left", remaining, limit))
+		} else {
+			parts = append(parts, remaining+" requests left")
+		}
+	}
+	if reset := h.Get("X-RateLimit-Reset"); reset != "" {
+		// A Unix time in milliseconds
+		if ms, err := strconv.ParseInt(reset, 10, 64); err == nil {
+			parts = append(parts, "resets at "+time.UnixMilli(ms).Format("15:04:05"))
+		}
+	}
+	if retry := h.Get("Retry-After"); retry != "" {
+		parts = append(parts, "retry after "+retry+"s")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "rate limit: " + strings.Join(parts, ", ")
+}
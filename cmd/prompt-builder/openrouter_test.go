@@ -0,0 +1,97 @@
+// openrouter_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenRouter_Request(t *testing.T) {
+	var header http.Header
+	var path, model string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, path = r.Header, r.URL.Path
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		model = req.Model
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	client, err := newLLMClient(&Config{Provider: providerOpenRouter, APIKey: "sk-or"}, server.URL, "anthropic/claude-3.5-sonnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ChatStream(context.Background(), []Message{{Role: "user", Content: "idea"}}, func(string) error { return nil })
+
+	if path != "/v1/chat/completions" || model != "anthropic/claude-3.5-sonnet" {
+		t.Errorf("request to %s for %q, want the namespaced model on the chat API", path, model)
+	}
+	if header.Get("Authorization") != "Bearer sk-or" || header.Get("HTTP-Referer") == "" || header.Get("X-Title") != "prompt-builder" {
+		t.Errorf("headers = %v, want the key, referer and title", header)
+	}
+}
+
+func TestLoadConfig_OpenRouter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("provider: openrouter\nmodel: meta-llama/llama-3.1-8b-instruct\n"), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "https://openrouter.ai/api" {
+		t.Errorf("Host = %q", cfg.Host)
+	}
+
+	t.Setenv("OPENROUTER_API_KEY", "")
+	if _, err := resolveAPIKey(cfg); err == nil || !strings.Contains(err.Error(), "OPENROUTER_API_KEY") {
+		t.Errorf("resolveAPIKey() error = %v, want OPENROUTER_API_KEY named", err)
+	}
+}
+
+func TestChatClient_RateLimitInError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "20")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1792161000000")
+		http.Error(w, `{"error":{"message":"Rate limit exceeded"}}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := NewChatClient(server.URL, "m").ChatStream(context.Background(), nil, func(string) error { return nil })
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "0 of 20 requests left, resets at " + time.UnixMilli(1792161000000).Format("15:04:05")
+	if msg := err.Error(); !strings.Contains(msg, "LLM") || !strings.Contains(msg, "429") || !strings.Contains(msg, want) {
+		t.Errorf("error = %q, want the status and %q", msg, want)
+	}
+}
+
+func TestRateLimitInfo(t *testing.T) {
+	tests := []struct {
+		headers map[string]string
+		want    string
+	}{
+		{nil, ""},
+		{map[string]string{"Retry-After": "30"}, "rate limit: retry after 30s"},
+		{map[string]string{"X-RateLimit-Remaining": "3"}, "rate limit: 3 requests left"},
+		{map[string]string{"X-RateLimit-Reset": "soon"}, ""},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for k, v := range tt.headers {
+			h.Set(k, v)
+		}
+		if got := rateLimitInfo(h); got != tt.want {
+			t.Errorf("rateLimitInfo(%v) = %q, want %q", tt.headers, got, tt.want)
+		}
+	}
+}
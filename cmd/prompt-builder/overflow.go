@@ -0,0 +1,154 @@
+// overflow.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Policies for a request that is larger than the model's context window.
+const (
+	overflowAsk      = "ask"      // ask in a terminal, refuse otherwise
+	overflowRefuse   = "refuse"   // fail before sending
+	overflowTruncate = "truncate" // drop the oldest turns
+)
+
+// perMessageTokens approximates the chat template overhead of one message.
+const perMessageTokens = 4
+
+// EstimateTokens approximates the token count of s at four bytes per token,
+// which is close for English text with common BPE tokenizers. It is the
+// fallback for models without a known tokenizer.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// countMessages returns the prompt size of a request as t counts it.
+func countMessages(t Tokenizer, messages []Message) int {
+	n := 0
+	for _, m := range messages {
+		n += t.Count(m.Content) + perMessageTokens
+	}
+	return n
+}
+
+// contextBudget returns how many prompt tokens fit in window while leaving
+// room for the reply.
+func contextBudget(window int, params GenerationParams) int {
+	reserve := window / 8
+	if params.MaxTokens != nil {
+		reserve = *params.MaxTokens
+	}
+	return window - reserve
+}
+
+// TruncateHistory drops the oldest assistant/user pairs after the system
+// prompt and the first user message until messages fit in budget, keeping
+// roles alternating. The first user message gets a note saying how many
+// messages were left out. It returns the new messages and the number
+// dropped; if even the minimal history does not fit, it returns an error.
+func TruncateHistory(messages []Message, budget int, t Tokenizer) ([]Message, int, error) {
+	if countMessages(t, messages) <= budget {
+		return messages, 0, nil
+	}
+
+	// Keep system prompt and the idea; the rest are candidates to drop
+	keep := 0
+	for i, m := range messages {
+		if m.Role == "user" {
+			keep = i + 1
+			break
+		}
+	}
+	if keep == 0 {
+		return nil, 0, fmt.Errorf("request does not fit the context window")
+	}
+
+	const noteTokens = 25 // the omission note appended below
+	head := append([]Message(nil), messages[:keep]...)
+	tail := messages[keep:]
+	dropped := 0
+	for len(tail) > 2 && countMessages(t, head)+countMessages(t, tail)+noteTokens > budget {
+		tail = tail[2:]
+		dropped += 2
+	}
+
+	if dropped > 0 {
+		head[keep-1].Content += fmt.Sprintf("\n\n[Note: %d earlier messages were omitted to fit the model's context window.]", dropped)
+	}
+	result := append(head, tail...)
+	if countMessages(t, result) > budget {
+		return nil, 0, fmt.Errorf("request does not fit the context window even after dropping %d messages", dropped)
+	}
+	return result, dropped, nil
+}
+
+// fitContext checks the current tab's request against the configured
+// context window, applies the overflow policy and returns the messages to
+// send. Truncation shortens only this request: the conversation, and the
+// session saved from it, keep every turn. ask prompts on the terminal via
+// readLine; it is treated as refuse when there is none. In strict mode the
+// truncate policy fails instead of dropping turns unannounced, though
+// choosing truncate at the prompt still works.
+func fitContext(tab *Tab, cfg *Config, interactive, strict bool, readLine func() (string, error), out io.Writer) ([]Message, error) {
+	messages := tab.Conv.Messages
+	if cfg.ContextWindow <= 0 {
+		return messages, nil
+	}
+
+	model := cfg.Model
+	if tab.Session != nil && tab.Session.Model != "" {
+		model = tab.Session.Model
+	}
+	t, err := tokenizerFor(model, cfg.Tokenizers)
+	if err != nil {
+		return nil, err
+	}
+	budget := contextBudget(cfg.ContextWindow, tab.Params)
+	estimate := countMessages(t, messages)
+	if estimate <= budget {
+		return messages, nil
+	}
+
+	overflowErr := fmt.Errorf("request is ~%d tokens but only ~%d fit in context_window %d\n\nShorten the input, raise context_window, or set context_overflow: truncate", estimate, budget, cfg.ContextWindow)
+
+	policy := cfg.ContextOverflow
+	if policy == overflowAsk && !interactive {
+		policy = overflowRefuse
+	}
+	if policy == overflowTruncate && strict {
+		return nil, &strictError{ExitTruncated, fmt.Sprintf("request is ~%d tokens but only ~%d fit in context_window %d; context_overflow: truncate would drop earlier turns", estimate, budget, cfg.ContextWindow)}
+	}
+
+	if policy == overflowAsk {
+		fmt.Fprintf(out, "Warning: request is ~%d tokens but only ~%d fit in the model's context (%d).\n", estimate, budget, cfg.ContextWindow)
+		fmt.Fprint(out, "[t]runcate oldest turns, [s]end anyway, or [c]ancel? ")
+		answer, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "t", "truncate":
+			policy = overflowTruncate
+		case "s", "send":
+			return messages, nil
+		default:
+			return nil, overflowErr
+		}
+	}
+
+	switch policy {
+	case overflowTruncate:
+		msgs, dropped, err := TruncateHistory(messages, budget, t)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "Left out %d earlier messages to fit the context window; the session keeps them.\n", dropped)
+		return msgs, nil
+	case overflowRefuse:
+		return nil, overflowErr
+	default:
+		return nil, fmt.Errorf("invalid config: context_overflow must be ask, refuse, or truncate, got %q", cfg.ContextOverflow)
+	}
+}
@@ -0,0 +1,145 @@
+// overflow_test.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens(""); got != 0 {
+		t.Errorf("EstimateTokens(\"\") = %d, want 0", got)
+	}
+	if got := EstimateTokens(strings.Repeat("a", 400)); got != 100 {
+		t.Errorf("EstimateTokens(400 bytes) = %d, want 100", got)
+	}
+}
+
+func longConversation(turns int) []Message {
+	msgs := []Message{{Role: "system", Content: "system"}, {Role: "user", Content: "idea"}}
+	for i := 0; i < turns; i++ {
+		msgs = append(msgs,
+			Message{Role: "assistant", Content: strings.Repeat("a", 400)},
+			Message{Role: "user", Content: strings.Repeat("u", 400)},
+		)
+	}
+	return msgs
+}
+
+func TestTruncateHistory(t *testing.T) {
+	msgs := longConversation(5) // ~1050 tokens
+
+	got, dropped, err := TruncateHistory(msgs, 500, heuristicTokenizer{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dropped == 0 || dropped%2 != 0 {
+		t.Errorf("dropped = %d, want a positive even number", dropped)
+	}
+	if countMessages(heuristicTokenizer{}, got) > 500 {
+		t.Errorf("result is %d tokens, want <= 500", countMessages(heuristicTokenizer{}, got))
+	}
+	if got[0].Role != "system" || !strings.HasPrefix(got[1].Content, "idea") || !strings.Contains(got[1].Content, "omitted") {
+		t.Errorf("system prompt and idea must be kept with a note, got %+v", got[:2])
+	}
+	if got[2].Role != "assistant" || got[len(got)-1] != msgs[len(msgs)-1] {
+		t.Errorf("roles must alternate and the latest message must be kept")
+	}
+	if strings.Contains(msgs[1].Content, "omitted") {
+		t.Error("input messages must not be modified")
+	}
+}
+
+func TestTruncateHistory_TooLarge(t *testing.T) {
+	msgs := []Message{{Role: "system", Content: strings.Repeat("s", 4000)}, {Role: "user", Content: "idea"}}
+	if _, _, err := TruncateHistory(msgs, 100, heuristicTokenizer{}); err == nil {
+		t.Error("expected error when the system prompt alone overflows")
+	}
+}
+
+func TestFitContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		interactive bool
+		answer      string
+		wantErr     bool
+		wantDropped bool
+	}{
+		{"refuse", overflowRefuse, true, "", true, false},
+		{"truncate", overflowTruncate, false, "", false, true},
+		{"ask without terminal refuses", overflowAsk, false, "", true, false},
+		{"ask then truncate", overflowAsk, true, "t\n", false, true},
+		{"ask then send", overflowAsk, true, "s\n", false, false},
+		{"ask then cancel", overflowAsk, true, "c\n", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tab := &Tab{Conv: &Conversation{Messages: longConversation(5)}}
+			before := len(tab.Conv.Messages)
+			cfg := &Config{ContextWindow: 600, ContextOverflow: tt.policy}
+			readLine := func() (string, error) { return tt.answer, nil }
+
+			var out bytes.Buffer
+			messages, err := fitContext(tab, cfg, tt.interactive, false, readLine, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fitContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if dropped := err == nil && len(messages) < before; dropped != tt.wantDropped {
+				t.Errorf("request truncated = %v, want %v", dropped, tt.wantDropped)
+			}
+			if len(tab.Conv.Messages) != before || strings.Contains(tab.Conv.Messages[1].Content, "omitted") {
+				t.Error("the conversation must keep every message")
+			}
+		})
+	}
+}
+
+func TestFitContext_Strict(t *testing.T) {
+	cfg := &Config{ContextWindow: 600, ContextOverflow: overflowTruncate}
+	tab := &Tab{Conv: &Conversation{Messages: longConversation(5)}}
+	before := len(tab.Conv.Messages)
+
+	_, err := fitContext(tab, cfg, false, true, nil, &bytes.Buffer{})
+	var strict *strictError
+	if !errors.As(err, &strict) || strict.Code != ExitTruncated {
+		t.Fatalf("fitContext() error = %v, want strict truncation error", err)
+	}
+	if len(tab.Conv.Messages) != before {
+		t.Error("strict mode must not truncate history")
+	}
+
+	// Choosing truncate at the prompt is explicit, so it is allowed
+	cfg.ContextOverflow = overflowAsk
+	readLine := func() (string, error) { return "t\n", nil }
+	if _, err := fitContext(tab, cfg, true, true, readLine, &bytes.Buffer{}); err != nil {
+		t.Errorf("fitContext() after answering truncate: %v", err)
+	}
+}
+
+func TestFitContext_Tokenizer(t *testing.T) {
+	// Each long message is one piece to this model, where the estimate
+	// makes it a hundred tokens
+	path := writeSentencePiece(t, false, false, []spPiece{
+		{"▁" + strings.Repeat("a", 400), -1, 0}, {"▁" + strings.Repeat("u", 400), -1, 0}, {"▁system", -1, 0}, {"▁idea", -1, 0},
+	})
+	cfg := &Config{ContextWindow: 600, ContextOverflow: overflowRefuse, Tokenizers: map[string]string{"tiny": "sentencepiece:" + path}}
+	tab := &Tab{Conv: &Conversation{Messages: longConversation(5)}, Session: &Session{Model: "tiny-1b"}}
+	if _, err := fitContext(tab, cfg, false, false, nil, &bytes.Buffer{}); err != nil {
+		t.Errorf("fitContext() with the model's tokenizer: %v", err)
+	}
+	tab.Session.Model = "other"
+	if _, err := fitContext(tab, cfg, false, false, nil, &bytes.Buffer{}); err == nil {
+		t.Error("fitContext() with the estimate: want the overflow error")
+	}
+}
+
+func TestFitContext_Disabled(t *testing.T) {
+	tab := &Tab{Conv: &Conversation{Messages: longConversation(50)}}
+	if _, err := fitContext(tab, &Config{ContextOverflow: overflowRefuse}, false, false, nil, &bytes.Buffer{}); err != nil {
+		t.Errorf("no context_window means no check, got: %v", err)
+	}
+}
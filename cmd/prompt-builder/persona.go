@@ -0,0 +1,102 @@
+// persona.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Persona is a named set of defaults for one kind of prompt, such as
+// coding or marketing, selected with --persona or persona in the config.
+// Unset fields keep the config's value; flags still override both.
+type Persona struct {
+	SystemPromptFile string   `yaml:"system_prompt_file"`
+	Model            string   `yaml:"model"` // a model or an alias
+	PostProcess      []string `yaml:"post_process"`
+
+	GenerationParams `yaml:",inline"`
+}
+
+// validate reports the first setting of persona name that cannot work.
+func (p Persona) validate(name string) error {
+	if err := p.GenerationParams.Validate(); err != nil {
+		return fmt.Errorf("personas: %s: %v", name, err)
+	}
+	for _, cmd := range p.PostProcess {
+		if err := validatePostProcess(cmd); err != nil {
+			return fmt.Errorf("personas: %s: post_process: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// applyPersona overlays the persona called name on c. Its post-processors
+// run after the config's own.
+func (c *Config) applyPersona(name string) error {
+	p, ok := c.Personas[name]
+	if !ok {
+		names := make([]string, 0, len(c.Personas))
+		for n := range c.Personas {
+			names = append(names, n)
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("unknown persona %q; define it under personas in the config", name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("unknown persona %q; the config defines: %s", name, strings.Join(names, ", "))
+	}
+	if p.SystemPromptFile != "" {
+		c.SystemPromptFile = p.SystemPromptFile
+	}
+	if p.Model != "" {
+		c.Model = p.Model
+	}
+	c.GenerationParams = c.GenerationParams.WithOverrides(p.GenerationParams)
+	c.PostProcess = append(slices.Clip(c.PostProcess), p.PostProcess...)
+	c.Persona = name
+	return nil
+}
+
+func validatePostProcess(cmd string) error {
+	parts, err := splitCommand(cmd)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	return nil
+}
+
+// postProcess pipes prompt through each command in turn, each reading the
+// prompt on stdin and writing its replacement to stdout.
+func postProcess(cmds []string, prompt string) (string, error) {
+	for _, cmd := range cmds {
+		parts, err := splitCommand(cmd)
+		if err != nil || len(parts) == 0 {
+			return "", fmt.Errorf("post_process %q: invalid command", cmd)
+		}
+		c := exec.Command(parts[0], parts[1:]...)
+		c.Stdin = strings.NewReader(prompt)
+		var stdout, stderr bytes.Buffer
+		c.Stdout, c.Stderr = &stdout, &stderr
+		if err := c.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("post_process %q: %v: %s", cmd, err, msg)
+			}
+			return "", fmt.Errorf("post_process %q: %v", cmd, err)
+		}
+		if strings.TrimSpace(stdout.String()) == "" {
+			return "", fmt.Errorf("post_process %q: the command printed nothing", cmd)
+		}
+		out := stdout.String()
+		if !strings.HasSuffix(prompt, "\n") {
+			out = strings.TrimSuffix(out, "\n")
+		}
+		prompt = out
+	}
+	return prompt, nil
+}
@@ -0,0 +1,92 @@
+// persona_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyPersona(t *testing.T) {
+	temp, low, seed, maxTokens := 0.7, 0.2, 42, 4000
+	cfg := &Config{
+		Model:            "llama3.2",
+		SystemPromptFile: "architect.md",
+		PostProcess:      []string{"cat"},
+		GenerationParams: GenerationParams{Temperature: &temp, Seed: &seed},
+		Personas: map[string]Persona{
+			"coding": {Model: "fast", PostProcess: []string{"tr a-z A-Z"}, GenerationParams: GenerationParams{Temperature: &low, MaxTokens: &maxTokens}},
+		},
+	}
+	if err := cfg.applyPersona("coding"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Model != "fast" || cfg.SystemPromptFile != "architect.md" {
+		t.Errorf("model = %q, system prompt = %q, want the persona's model and the config's system prompt", cfg.Model, cfg.SystemPromptFile)
+	}
+	if *cfg.Temperature != 0.2 || *cfg.MaxTokens != 4000 || *cfg.Seed != 42 {
+		t.Errorf("params = %+v, want the persona's temperature and max_tokens over the config's", cfg.GenerationParams)
+	}
+	if strings.Join(cfg.PostProcess, "|") != "cat|tr a-z A-Z" {
+		t.Errorf("PostProcess = %q, want the config's then the persona's", cfg.PostProcess)
+	}
+}
+
+func TestApplyPersona_Unknown(t *testing.T) {
+	cfg := &Config{Personas: map[string]Persona{"marketing": {}, "coding": {}}}
+	err := cfg.applyPersona("legal")
+	if err == nil || !strings.Contains(err.Error(), "coding, marketing") {
+		t.Errorf("error = %v, want the defined personas", err)
+	}
+}
+
+func TestLoadConfig_Personas(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("persona: coding\npersonas:\n  coding:\n    model: qwen2.5-coder\n    temperature: 0.2\n    post_process: [\"tr a-z A-Z\"]\n"), 0644)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p := cfg.Personas["coding"]; p.Model != "qwen2.5-coder" || *p.Temperature != 0.2 || len(p.PostProcess) != 1 {
+		t.Errorf("coding = %+v", p)
+	}
+
+	for config, want := range map[string]string{
+		"persona: legal\n":                                 "not defined",
+		"personas:\n  coding:\n    temperature: 3\n":       "personas: coding",
+		"personas:\n  coding:\n    post_process: [\"\"]\n": "empty command",
+		"post_process: [\"sed 's/a/b\"]\n":                 "post_process",
+	} {
+		os.WriteFile(configPath, []byte(config), 0644)
+		if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error = %v, want %q", config, err, want)
+		}
+	}
+}
+
+func TestPostProcess(t *testing.T) {
+	got, err := postProcess([]string{"tr a-z A-Z", "sed s/HELLO/Hi/"}, "hello world")
+	if err != nil || got != "Hi WORLD" {
+		t.Errorf("postProcess() = %q, %v, want %q", got, err, "Hi WORLD")
+	}
+
+	if _, err := postProcess([]string{"sh -c 'echo broken >&2; exit 1'"}, "p"); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("failing command: error = %v, want its stderr", err)
+	}
+	if _, err := postProcess([]string{"true"}, "p"); err == nil || !strings.Contains(err.Error(), "printed nothing") {
+		t.Errorf("silent command: error = %v, want an error", err)
+	}
+}
+
+func TestStampPrompt_PostProcess(t *testing.T) {
+	cfg := &Config{PostProcess: []string{"tr a-z A-Z"}, PromptFooter: "generated {{.Date.Format \"2006-01-02\"}}"}
+	got, err := stampPrompt(cfg, nil, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), "be brief")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "BE BRIEF\n") || strings.Contains(got, "GENERATED") {
+		t.Errorf("stampPrompt() = %q, want the footer added after post-processing", got)
+	}
+}
@@ -0,0 +1,93 @@
+// pin.go
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// handlePin implements /pin: with a constraint it pins it, without one it
+// lists the pins so far.
+func handlePin(args string, env *CommandEnv) error {
+	if env.Session == nil {
+		return fmt.Errorf("Pins are not available here")
+	}
+
+	constraint := strings.TrimSpace(strings.Trim(args, `"'`))
+	if constraint == "" {
+		if len(env.Session.Pins) == 0 {
+			fmt.Fprintln(env.Out, `No pinned constraints. Add one with /pin "<constraint>"`)
+			return nil
+		}
+		for i, p := range env.Session.Pins {
+			fmt.Fprintf(env.Out, "  %d. %s\n", i+1, p)
+		}
+		return nil
+	}
+
+	env.Session.Pins = append(env.Session.Pins, constraint)
+	fmt.Fprintf(env.Out, "Pinned %d: %s\n", len(env.Session.Pins), constraint)
+	return nil
+}
+
+// handleUnpin implements /unpin <n>.
+func handleUnpin(args string, env *CommandEnv) error {
+	if env.Session == nil {
+		return fmt.Errorf("Pins are not available here")
+	}
+	n, err := strconv.Atoi(args)
+	if err != nil {
+		return fmt.Errorf("Usage: /unpin <n>")
+	}
+	if n < 1 || n > len(env.Session.Pins) {
+		return fmt.Errorf("No pin %d. Type /pin to list them.", n)
+	}
+	removed := env.Session.Pins[n-1]
+	env.Session.Pins = append(env.Session.Pins[:n-1], env.Session.Pins[n:]...)
+	fmt.Fprintf(env.Out, "Unpinned: %s\n", removed)
+	return nil
+}
+
+// withPins returns messages with the pinned constraints restated after the
+// last user message, so the model sees them on every turn. The
+// conversation itself is left unchanged.
+func withPins(messages []Message, pins []string) []Message {
+	if len(pins) == 0 {
+		return messages
+	}
+	last := len(messages) - 1
+	for last >= 0 && messages[last].Role != "user" {
+		last--
+	}
+	if last < 0 {
+		return messages
+	}
+
+	var b strings.Builder
+	b.WriteString(messages[last].Content)
+	b.WriteString("\n\nThe prompt must keep these constraints word for word:\n")
+	for _, p := range pins {
+		fmt.Fprintf(&b, "- %s\n", p)
+	}
+
+	pinned := append([]Message(nil), messages...)
+	pinned[last].Content = strings.TrimSuffix(b.String(), "\n")
+	return pinned
+}
+
+// missingPins returns the pins that do not appear in prompt. Case and
+// runs of whitespace are ignored.
+func missingPins(prompt string, pins []string) []string {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	}
+	text := normalize(prompt)
+	var missing []string
+	for _, p := range pins {
+		if !strings.Contains(text, normalize(p)) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,93 @@
+// pin_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHandleCommand_Pin(t *testing.T) {
+	var out bytes.Buffer
+	env := &CommandEnv{Session: &Session{}, Out: &out}
+
+	HandleCommand("/pin", "", env)
+	if !strings.Contains(out.String(), "No pinned constraints") {
+		t.Errorf("output = %q, want the empty-list hint", out.String())
+	}
+
+	HandleCommand(`/pin "output must be valid JSON"`, "", env)
+	HandleCommand("/pin under 200 words", "", env)
+	if want := []string{"output must be valid JSON", "under 200 words"}; strings.Join(env.Session.Pins, "|") != strings.Join(want, "|") {
+		t.Errorf("Pins = %q, want %q", env.Session.Pins, want)
+	}
+
+	out.Reset()
+	HandleCommand("/pin", "", env)
+	if !strings.Contains(out.String(), "1. output must be valid JSON") || !strings.Contains(out.String(), "2. under 200 words") {
+		t.Errorf("list = %q, want both pins numbered", out.String())
+	}
+
+	if _, err := HandleCommand("/unpin 1", "", env); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.Session.Pins) != 1 || env.Session.Pins[0] != "under 200 words" {
+		t.Errorf("Pins after /unpin 1 = %q", env.Session.Pins)
+	}
+	for _, bad := range []string{"/unpin", "/unpin 5", "/unpin x"} {
+		if _, err := HandleCommand(bad, "", env); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestWithPins(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "idea"},
+		{Role: "assistant", Content: "draft"},
+		{Role: "user", Content: "shorter"},
+	}
+
+	if got := withPins(messages, nil); &got[0] != &messages[0] {
+		t.Error("withPins without pins should return messages unchanged")
+	}
+
+	got := withPins(messages, []string{"valid JSON"})
+	if !strings.HasPrefix(got[3].Content, "shorter\n\n") || !strings.HasSuffix(got[3].Content, "- valid JSON") {
+		t.Errorf("last user message = %q, want the pins appended", got[3].Content)
+	}
+	if got[1].Content != "idea" || messages[3].Content != "shorter" {
+		t.Error("withPins changed earlier messages or the conversation")
+	}
+}
+
+func TestMissingPins(t *testing.T) {
+	prompt := "# Output\nOutput must be  valid\nJSON."
+	got := missingPins(prompt, []string{"output must be valid JSON", "under 200 words"})
+	if len(got) != 1 || got[0] != "under 200 words" {
+		t.Errorf("missingPins() = %q, want only the absent pin", got)
+	}
+}
+
+func TestRunWithDeps_PinWarnsWhenDraftDropsIt(t *testing.T) {
+	deps := newTestDeps(
+		withResponses(
+			"```\nReply in valid JSON.\n```",
+			"```\nReply briefly.\n```",
+		),
+		withStdin("/pin valid JSON\nshorter\n/quit\n"),
+	)
+	if err := runWithDeps(context.Background(), &CLI{Idea: "idea"}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if got := stderr(deps); !strings.Contains(got, `dropped pinned constraint "valid JSON"`) {
+		t.Errorf("stderr = %q, want a warning about the dropped pin", got)
+	}
+
+	last := deps.Client.(*mockLLM).last
+	if !strings.Contains(last[len(last)-1].Content, "- valid JSON") {
+		t.Errorf("last request = %q, want the pin restated", last[len(last)-1].Content)
+	}
+}
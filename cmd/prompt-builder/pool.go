@@ -0,0 +1,187 @@
+// pool.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// poolCooldown is how long a host that failed is passed over while
+// other hosts in the pool are up.
+const poolCooldown = 30 * time.Second
+
+// PoolHost is one server of a load-balanced host pool (hosts in the
+// config), serving the same models as the others.
+type PoolHost struct {
+	URL    string `yaml:"url"`
+	Weight int    `yaml:"weight"` // share of requests relative to the others; 1 when unset
+}
+
+// hostPool spreads requests over several hosts by weight. A host that
+// refuses a connection or answers 502, 503 or 504 is marked down for
+// poolCooldown and the request goes to the next host.
+type hostPool struct {
+	mu      sync.Mutex
+	base    string // requests to this URL are sent to a pool member
+	members []*poolMember
+	intN    func(n int) int
+	now     func() time.Time
+
+	// strict fails a request instead of sending it to another host, as
+	// --strict does for other fallbacks
+	strict bool
+	// warn reports a request sent to another host; nil keeps it quiet
+	warn func(format string, args ...any)
+}
+
+type poolMember struct {
+	PoolHost
+	downUntil time.Time
+}
+
+// newHostPool returns a pool of hosts, whose first URL stands for the
+// pool in requests.
+func newHostPool(hosts []PoolHost) *hostPool {
+	p := &hostPool{base: hosts[0].URL, intN: rand.IntN, now: time.Now}
+	for _, h := range hosts {
+		if h.Weight == 0 {
+			h.Weight = 1
+		}
+		p.members = append(p.members, &poolMember{PoolHost: h})
+	}
+	return p
+}
+
+// validatePool checks the hosts config value.
+func validatePool(hosts []PoolHost) error {
+	for i, h := range hosts {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("hosts: entry %d needs an http or https url, got %q", i+1, h.URL)
+		}
+		if h.Weight < 0 {
+			return fmt.Errorf("hosts: %s has a negative weight", h.URL)
+		}
+	}
+	return nil
+}
+
+// pick returns a member not in tried: one that is up, chosen by weight,
+// or if all are down, the one that comes back first.
+func (p *hostPool) pick(tried map[*poolMember]bool) *poolMember {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	var up []*poolMember
+	total := 0
+	var soonest *poolMember
+	for _, m := range p.members {
+		if tried[m] {
+			continue
+		}
+		if !now.Before(m.downUntil) {
+			up = append(up, m)
+			total += m.Weight
+		} else if soonest == nil || m.downUntil.Before(soonest.downUntil) {
+			soonest = m
+		}
+	}
+	if len(up) == 0 {
+		return soonest
+	}
+	n := p.intN(total)
+	for _, m := range up {
+		if n < m.Weight {
+			return m
+		}
+		n -= m.Weight
+	}
+	return up[len(up)-1]
+}
+
+func (p *hostPool) setDown(m *poolMember, down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if down {
+		m.downUntil = p.now().Add(poolCooldown)
+	} else {
+		m.downUntil = time.Time{}
+	}
+}
+
+// transport wraps next so requests to the pool go to its members.
+func (p *hostPool) transport(next http.RoundTripper) http.RoundTripper {
+	return poolTransport{p, next}
+}
+
+type poolTransport struct {
+	pool *hostPool
+	next http.RoundTripper
+}
+
+func (t poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rest, ok := strings.CutPrefix(req.URL.String(), t.pool.base)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	tried := map[*poolMember]bool{}
+	for {
+		m := t.pool.pick(tried)
+		tried[m] = true
+		out, err := memberRequest(req, m, rest, len(tried) > 1)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.next.RoundTrip(out)
+		if err == nil && !poolRetryable(resp.StatusCode) {
+			t.pool.setDown(m, false)
+			return resp, nil
+		}
+		t.pool.setDown(m, true)
+		canRetry := req.Body == nil || req.GetBody != nil
+		if req.Context().Err() != nil || len(tried) == len(t.pool.members) || !canRetry {
+			return resp, err
+		}
+		reason := err
+		if resp != nil {
+			reason = errors.New(resp.Status)
+			resp.Body.Close()
+		}
+		if t.pool.strict {
+			return nil, &strictError{ExitFailover, fmt.Sprintf("pool host %s failed (%v); not trying another host", m.URL, reason)}
+		}
+		if t.pool.warn != nil {
+			t.pool.warn("Warning: pool host %s failed (%v); sending the request to another host\n", m.URL, reason)
+		}
+	}
+}
+
+// memberRequest returns req sent to member m instead; rest is the part of
+// the URL after the pool's base. A retry needs a fresh copy of the body.
+func memberRequest(req *http.Request, m *poolMember, rest string, retry bool) (*http.Request, error) {
+	u, err := url.Parse(m.URL + rest)
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	out.URL = u
+	out.Host = ""
+	if retry && req.GetBody != nil {
+		if out.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// poolRetryable reports whether a status means the host, not the
+// request, is the problem, so another host may do better.
+func poolRetryable(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
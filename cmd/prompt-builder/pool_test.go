@@ -0,0 +1,144 @@
+// pool_test.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostPool_PickByWeight(t *testing.T) {
+	p := newHostPool([]PoolHost{{URL: "http://gpu1", Weight: 3}, {URL: "http://gpu2"}})
+	counts := map[string]int{}
+	for n := range 4 {
+		p.intN = func(int) int { return n }
+		counts[p.pick(nil).URL]++
+	}
+	if counts["http://gpu1"] != 3 || counts["http://gpu2"] != 1 {
+		t.Errorf("picks = %v, want gpu1 three times as often as gpu2", counts)
+	}
+}
+
+func TestHostPool_SkipsDownHosts(t *testing.T) {
+	now := time.Now()
+	p := newHostPool([]PoolHost{{URL: "http://gpu1"}, {URL: "http://gpu2"}})
+	p.now = func() time.Time { return now }
+	p.intN = func(int) int { return 0 }
+
+	p.setDown(p.members[0], true)
+	if got := p.pick(nil).URL; got != "http://gpu2" {
+		t.Errorf("pick() = %s, want the host that is up", got)
+	}
+	p.setDown(p.members[1], true)
+	if got := p.pick(nil).URL; got != "http://gpu1" {
+		t.Errorf("all down: pick() = %s, want the one back first", got)
+	}
+	now = now.Add(poolCooldown)
+	if got := p.pick(nil).URL; got != "http://gpu1" {
+		t.Errorf("after the cooldown: pick() = %s, want gpu1 back in turn", got)
+	}
+}
+
+func TestHostPool_FailsOver(t *testing.T) {
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "loading model", http.StatusServiceUnavailable)
+	}))
+	defer busy.Close()
+	var body []byte
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", "pooled")
+	}))
+	defer healthy.Close()
+
+	cfg := &Config{Provider: providerCompatible, Host: busy.URL}
+	cfg.pool = newHostPool([]PoolHost{{URL: busy.URL}, {URL: healthy.URL}})
+	cfg.pool.intN = func(int) int { return 0 } // the busy host first
+	var warned string
+	cfg.pool.warn = func(format string, args ...any) { warned += fmt.Sprintf(format, args...) }
+	client, _ := newLLMClient(cfg, cfg.Host, "m")
+
+	reply, err := client.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, func(string) error { return nil })
+	if err != nil || reply != "pooled" {
+		t.Fatalf("ChatStream() = %q, %v, want the reply from the healthy host", reply, err)
+	}
+	if !strings.Contains(warned, busy.URL) || !strings.Contains(warned, "503") {
+		t.Errorf("warning = %q, want the busy host and its status", warned)
+	}
+	if !strings.Contains(string(body), `"content":"hi"`) {
+		t.Errorf("healthy host got body %q, want the request sent again in full", body)
+	}
+	if cfg.pool.members[0].downUntil.IsZero() {
+		t.Error("the busy host was not marked down")
+	}
+}
+
+func TestHostPool_StrictFailover(t *testing.T) {
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "loading model", http.StatusServiceUnavailable)
+	}))
+	defer busy.Close()
+	asked := false
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asked = true
+	}))
+	defer healthy.Close()
+
+	cfg := &Config{Provider: providerCompatible, Host: busy.URL}
+	cfg.pool = newHostPool([]PoolHost{{URL: busy.URL}, {URL: healthy.URL}})
+	cfg.pool.intN = func(int) int { return 0 }
+	cfg.pool.strict = true
+	client, _ := newLLMClient(cfg, cfg.Host, "m")
+
+	_, err := client.ChatStream(context.Background(), nil, func(string) error { return nil })
+	if got := exitCode(err); got != ExitFailover {
+		t.Errorf("exitCode(%v) = %d, want %d", err, got, ExitFailover)
+	}
+	if asked {
+		t.Error("--strict sent the request to another host")
+	}
+}
+
+func TestHostPool_AllDown(t *testing.T) {
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusBadGateway)
+	}))
+	defer busy.Close()
+
+	cfg := &Config{Provider: providerCompatible, Host: busy.URL}
+	cfg.pool = newHostPool([]PoolHost{{URL: busy.URL}, {URL: "http://127.0.0.1:1"}})
+	client, _ := newLLMClient(cfg, cfg.Host, "m")
+	if _, err := client.ChatStream(context.Background(), nil, func(string) error { return nil }); err == nil {
+		t.Error("ChatStream() succeeded with every host down")
+	}
+}
+
+func TestLoadConfig_Hosts(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("hosts:\n  - url: http://gpu1:11434\n    weight: 2\n  - url: http://gpu2:11434\n"), 0644)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Host != "http://gpu1:11434" || cfg.pool == nil || len(cfg.pool.members) != 2 || cfg.pool.members[1].Weight != 1 {
+		t.Errorf("host = %q, pool = %+v, want a pool of two standing in for host", cfg.Host, cfg.pool)
+	}
+
+	for config, want := range map[string]string{
+		"host: http://a\nhosts:\n  - url: http://b\n": "set only one",
+		"hosts:\n  - url: gpu1:11434\n":               "http or https url",
+		"hosts:\n  - url: http://b\n    weight: -1\n": "negative weight",
+	} {
+		os.WriteFile(configPath, []byte(config), 0644)
+		if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error = %v, want %q", config, err, want)
+		}
+	}
+}
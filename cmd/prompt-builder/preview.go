@@ -0,0 +1,373 @@
+// preview.go
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// RenderMarkdown converts the Markdown found in prompts to HTML: headings,
+// paragraphs, nested lists, tables, block quotes, rules, fenced code and
+// inline emphasis, code and links. It is not a full CommonMark parser.
+func RenderMarkdown(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case trimmed == "":
+			i++
+		case strings.HasPrefix(trimmed, "```"):
+			i = renderFence(&b, lines, i)
+		case headingLevel(trimmed) > 0:
+			n := headingLevel(trimmed)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", n, renderInline(strings.TrimSpace(trimmed[n:])), n)
+			i++
+		case isRule(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+		case isTableStart(lines, i):
+			i = renderTable(&b, lines, i)
+		case parseListItem(lines[i]) != nil:
+			i = renderList(&b, lines, i)
+		case strings.HasPrefix(trimmed, ">"):
+			i = renderQuote(&b, lines, i)
+		default:
+			i = renderParagraph(&b, lines, i)
+		}
+	}
+	return b.String()
+}
+
+// startsBlock reports whether line i begins something other than paragraph text.
+func startsBlock(lines []string, i int) bool {
+	trimmed := strings.TrimSpace(lines[i])
+	return strings.HasPrefix(trimmed, "```") || headingLevel(trimmed) > 0 || isRule(trimmed) ||
+		isTableStart(lines, i) || parseListItem(lines[i]) != nil || strings.HasPrefix(trimmed, ">")
+}
+
+func headingLevel(line string) int {
+	n := 0
+	for n < len(line) && n < 6 && line[n] == '#' {
+		n++
+	}
+	if n == 0 || n == len(line) || line[n] != ' ' {
+		return 0
+	}
+	return n
+}
+
+func isRule(line string) bool {
+	s := strings.ReplaceAll(line, " ", "")
+	if len(s) < 3 || !strings.ContainsRune("-*_", rune(s[0])) {
+		return false
+	}
+	return strings.Count(s, s[:1]) == len(s)
+}
+
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	lang := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "```"))
+	var code []string
+	for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+		code = append(code, lines[i])
+	}
+	if lang != "" {
+		fmt.Fprintf(b, "<pre><code class=\"language-%s\">", html.EscapeString(lang))
+	} else {
+		b.WriteString("<pre><code>")
+	}
+	b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+	b.WriteString("</code></pre>\n")
+	return i + 1
+}
+
+var tableSeparator = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+func isTableStart(lines []string, i int) bool {
+	return strings.Contains(lines[i], "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "-") && tableSeparator.MatchString(lines[i+1])
+}
+
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i, c := range cells {
+		cells[i] = renderInline(strings.TrimSpace(c))
+	}
+	return cells
+}
+
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	b.WriteString("<table>\n<thead><tr>")
+	for _, c := range tableCells(lines[i]) {
+		fmt.Fprintf(b, "<th>%s</th>", c)
+	}
+	b.WriteString("</tr></thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		b.WriteString("<tr>")
+		for _, c := range tableCells(lines[i]) {
+			fmt.Fprintf(b, "<td>%s</td>", c)
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+type listItem struct {
+	indent  int
+	ordered bool
+	text    string
+}
+
+var listItemPattern = regexp.MustCompile(`^([ \t]*)([-*+]|\d+[.)])\s+(.*)$`)
+
+func parseListItem(line string) *listItem {
+	m := listItemPattern.FindStringSubmatch(line)
+	if m == nil || isRule(strings.TrimSpace(line)) {
+		return nil
+	}
+	return &listItem{
+		indent:  len(strings.ReplaceAll(m[1], "\t", "    ")),
+		ordered: m[2][0] >= '0' && m[2][0] <= '9',
+		text:    m[3],
+	}
+}
+
+// renderList writes consecutive list items, nesting by indentation.
+// Indented lines that are not items continue the previous item.
+func renderList(b *strings.Builder, lines []string, i int) int {
+	var stack []*listItem
+	tag := func(l *listItem) string {
+		if l.ordered {
+			return "ol"
+		}
+		return "ul"
+	}
+	closeTop := func() {
+		fmt.Fprintf(b, "</li>\n</%s>\n", tag(stack[len(stack)-1]))
+		stack = stack[:len(stack)-1]
+	}
+	open := func(item *listItem) {
+		fmt.Fprintf(b, "<%s>\n<li>", tag(item))
+		stack = append(stack, item)
+	}
+
+	for i < len(lines) {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			// A blank line continues the list only if another item follows
+			j := i + 1
+			for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+				j++
+			}
+			if j == len(lines) || parseListItem(lines[j]) == nil {
+				break
+			}
+			i = j
+			continue
+		}
+
+		item := parseListItem(line)
+		if item == nil {
+			if line[0] != ' ' && line[0] != '\t' {
+				break
+			}
+			b.WriteString(" " + renderInline(strings.TrimSpace(line)))
+			i++
+			continue
+		}
+
+		for len(stack) > 0 && item.indent < stack[len(stack)-1].indent {
+			closeTop()
+		}
+		switch top := len(stack) - 1; {
+		case top < 0 || item.indent > stack[top].indent:
+			open(item)
+		case item.ordered != stack[top].ordered:
+			closeTop()
+			open(item)
+		default:
+			b.WriteString("</li>\n<li>")
+		}
+		b.WriteString(renderInline(item.text))
+		i++
+	}
+	for len(stack) > 0 {
+		closeTop()
+	}
+	return i
+}
+
+func renderQuote(b *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+		line := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+		inner = append(inner, strings.TrimPrefix(line, " "))
+	}
+	fmt.Fprintf(b, "<blockquote>\n%s</blockquote>\n", RenderMarkdown(strings.Join(inner, "\n")))
+	return i
+}
+
+func renderParagraph(b *strings.Builder, lines []string, i int) int {
+	var text []string
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		if len(text) > 0 && startsBlock(lines, i) {
+			break
+		}
+		text = append(text, renderInline(strings.TrimSpace(lines[i])))
+	}
+	fmt.Fprintf(b, "<p>%s</p>\n", strings.Join(text, "\n"))
+	return i
+}
+
+var (
+	boldPattern     = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	italicPattern   = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	linkPattern     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	linkPlaceholder = regexp.MustCompile("\x00[0-9]+\x00")
+)
+
+// linkSchemes are the only link targets the preview makes clickable. The
+// Markdown comes from the model, and a javascript: link would run in the
+// page.
+var linkSchemes = []string{"http", "https", "mailto"}
+
+// renderInline escapes text and applies code spans, links and emphasis.
+func renderInline(s string) string {
+	parts := strings.Split(s, "`")
+	var b strings.Builder
+	for i, p := range parts {
+		switch {
+		case i%2 == 1 && i < len(parts)-1:
+			b.WriteString("<code>" + html.EscapeString(p) + "</code>")
+		case i%2 == 1:
+			// Unmatched backtick
+			b.WriteString(renderEmphasis("`" + p))
+		default:
+			b.WriteString(renderEmphasis(p))
+		}
+	}
+	return b.String()
+}
+
+// renderEmphasis escapes s and renders its links and emphasis. Links are
+// set aside first, so emphasis is applied to text and never to an href.
+func renderEmphasis(s string) string {
+	var links []string
+	s = linkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := linkPattern.FindStringSubmatch(m)
+		links = append(links, renderLink(m, sub[1], sub[2]))
+		return fmt.Sprintf("\x00%d\x00", len(links)-1)
+	})
+	s = emphasize(html.EscapeString(s))
+	return linkPlaceholder.ReplaceAllStringFunc(s, func(p string) string {
+		n, _ := strconv.Atoi(strings.Trim(p, "\x00"))
+		return links[n]
+	})
+}
+
+// renderLink renders the link m as an anchor, or as plain text when its
+// href is not one of linkSchemes.
+func renderLink(m, label, href string) string {
+	u, err := url.Parse(href)
+	if err != nil || !slices.Contains(linkSchemes, u.Scheme) {
+		return emphasize(html.EscapeString(m))
+	}
+	return `<a href="` + html.EscapeString(href) + `">` + emphasize(html.EscapeString(label)) + "</a>"
+}
+
+// emphasize turns the bold and italic markers in escaped text into tags.
+func emphasize(s string) string {
+	s = boldPattern.ReplaceAllString(s, "<strong>$1$2</strong>")
+	return italicPattern.ReplaceAllString(s, "<em>$1</em>")
+}
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{if .Title}}{{.Title}} · {{end}}prompt preview</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; max-width: 50rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; line-height: 1.5; }
+pre { background: #f6f8fa; border: 1px solid #d0d7de; border-radius: .5rem; padding: 1rem; overflow-x: auto; }
+code { background: #f6f8fa; padding: .1rem .3rem; border-radius: .25rem; }
+pre code { padding: 0; }
+table { border-collapse: collapse; margin: 1rem 0; }
+th, td { border: 1px solid #d0d7de; padding: .4rem .75rem; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+blockquote { margin: 1rem 0; padding: 0 1rem; border-left: 4px solid #d0d7de; color: #59636e; }
+</style>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
+
+// RenderPreview writes prompt as a standalone HTML page.
+func RenderPreview(w io.Writer, title, prompt string) error {
+	return previewPage.Execute(w, struct {
+		Title string
+		Body  template.HTML
+	}{title, template.HTML(RenderMarkdown(prompt))})
+}
+
+// openBrowser opens path with the desktop's default handler.
+func openBrowser(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	return cmd.Start()
+}
+
+// handlePreview implements /preview.
+func handlePreview(lastResponse string, env *CommandEnv) error {
+	if lastResponse == "" {
+		return fmt.Errorf("No response to preview")
+	}
+	prompt := promptbuilder.ExtractLastCodeBlock(lastResponse)
+	if prompt == "" {
+		return fmt.Errorf("No code block to preview")
+	}
+	if env.OpenBrowser == nil {
+		return fmt.Errorf("Preview not available here")
+	}
+
+	f, err := os.CreateTemp("", "prompt-preview-*.html")
+	if err != nil {
+		return fmt.Errorf("Preview failed: %v", err)
+	}
+	defer f.Close()
+
+	title := ""
+	if env.Session != nil {
+		title = firstLine(env.Session.Idea, 60)
+	}
+	if err := RenderPreview(f, title, prompt); err != nil {
+		return fmt.Errorf("Preview failed: %v", err)
+	}
+	if err := env.OpenBrowser(f.Name()); err != nil {
+		return fmt.Errorf("Cannot open browser (%v). Preview saved to %s", err, f.Name())
+	}
+	fmt.Fprintf(env.Out, "✓ Preview opened: %s\n", f.Name())
+	return nil
+}
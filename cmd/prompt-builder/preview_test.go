@@ -0,0 +1,99 @@
+// preview_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"heading", "## Role", "<h2>Role</h2>\n"},
+		{"not a heading", "#hashtag", "<p>#hashtag</p>\n"},
+		{"paragraph", "line one\nline two", "<p>line one\nline two</p>\n"},
+		{"inline", "Use **bold**, *em*, `a<b>` and [docs](https://x.io)", `<p>Use <strong>bold</strong>, <em>em</em>, <code>a&lt;b&gt;</code> and <a href="https://x.io">docs</a></p>` + "\n"},
+		{"escapes html", "<script>", "<p>&lt;script&gt;</p>\n"},
+		{"script link", "[x](javascript:alert(document.cookie))", "<p>[x](javascript:alert(document.cookie))</p>\n"},
+		{"mailto link", "[mail](mailto:a@x.io)", `<p><a href="mailto:a@x.io">mail</a></p>` + "\n"},
+		{"emphasis around a link", "**[a_b_c](https://x.io/a__b__c)**", `<p><strong><a href="https://x.io/a__b__c">a_b_c</a></strong></p>` + "\n"},
+		{"href left alone", "[x](https://x.io/*a*b*)", `<p><a href="https://x.io/*a*b*">x</a></p>` + "\n"},
+		{"rule", "---", "<hr>\n"},
+		{"fence", "```go\nx := <-ch\n```", "<pre><code class=\"language-go\">x := &lt;-ch</code></pre>\n"},
+		{"quote", "> be brief", "<blockquote>\n<p>be brief</p>\n</blockquote>\n"},
+		{
+			"table",
+			"| Field | Type |\n|---|:---:|\n| id | `int` |",
+			"<table>\n<thead><tr><th>Field</th><th>Type</th></tr></thead>\n<tbody>\n<tr><td>id</td><td><code>int</code></td></tr>\n</tbody>\n</table>\n",
+		},
+		{
+			"nested list",
+			"- a\n  - b\n  - c\n- d\n\n1. one\n2. two",
+			"<ul>\n<li>a<ul>\n<li>b</li>\n<li>c</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n",
+		},
+		{"list continuation", "- first\n  more\n\ntext", "<ul>\n<li>first more</li>\n</ul>\n<p>text</p>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderMarkdown(tt.in); got != tt.want {
+				t.Errorf("RenderMarkdown(%q) =\n%q\nwant\n%q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlePreview(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	var opened string
+	var out bytes.Buffer
+	env := &CommandEnv{
+		Session:     &Session{Idea: "a reviewer"},
+		Out:         &out,
+		OpenBrowser: func(path string) error { opened = path; return nil },
+	}
+
+	if _, err := HandleCommand("/preview", "Done:\n```\n# Role\n- strict\n```", env); err != nil {
+		t.Fatalf("HandleCommand() error = %v", err)
+	}
+	page, err := os.ReadFile(opened)
+	if err != nil {
+		t.Fatalf("preview file not written: %v", err)
+	}
+	for _, want := range []string{"<title>a reviewer · prompt preview</title>", "<h1>Role</h1>", "<li>strict</li>"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("preview missing %q", want)
+		}
+	}
+	if !strings.Contains(out.String(), opened) {
+		t.Errorf("output should name the file, got %q", out.String())
+	}
+}
+
+func TestHandlePreview_Errors(t *testing.T) {
+	open := func(string) error { return nil }
+	tests := []struct {
+		name     string
+		response string
+		open     func(string) error
+		wantErr  string
+	}{
+		{"no response", "", open, "No response to preview"},
+		{"no code block", "plain", open, "No code block to preview"},
+		{"no browser", "```\nx\n```", nil, "Preview not available here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := &CommandEnv{Out: &bytes.Buffer{}, OpenBrowser: tt.open}
+			_, err := HandleCommand("/preview", tt.response, env)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// prompts.go
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// promptsDir returns the directory --prompt picks system prompts from:
+// prompts_dir, else prompts next to the user's config.
+func (c *Config) promptsDir() string {
+	if c.PromptsDir != "" {
+		return ExpandPath(c.PromptsDir)
+	}
+	return filepath.Join(filepath.Dir(defaultConfigPath()), "prompts")
+}
+
+// libraryPrompts returns the names of the system prompts in dir, the
+// .md files without their extension, sorted.
+func libraryPrompts(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".md")
+		if ok && e.Type().IsRegular() && validPromptName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// libraryPrompt returns the file of the system prompt called name in the
+// prompts directory (--prompt).
+func (c *Config) libraryPrompt(name string) (string, error) {
+	dir := c.promptsDir()
+	if !validPromptName.MatchString(name) {
+		return "", fmt.Errorf("invalid prompt name %q; use the file name in %s without .md", name, dir)
+	}
+	path := filepath.Join(dir, name+".md")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	names, err := libraryPrompts(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("unknown prompt %q; %s has no prompts (add %s.md there)", name, dir, name)
+	}
+	return "", fmt.Errorf("unknown prompt %q; %s has: %s", name, dir, strings.Join(names, ", "))
+}
+
+// promptSummary returns the first line of the prompt at path with any
+// heading marks removed, shortened to fit a listing.
+func promptSummary(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimLeft(scanner.Text(), "#"))
+		if line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > 60 {
+			line = string(r[:59]) + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// runPrompts implements the prompts subcommand.
+func runPrompts(args []string, out, errOut io.Writer) int {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Fprintln(errOut, "Usage: prompt-builder prompts list [--config path]")
+		return ExitConfigError
+	}
+	fs := flag.NewFlagSet("prompts list", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	configPath := fs.String("config", "", "Path to config file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return ExitConfigError
+	}
+
+	cfg, err := loadRunConfig(*configPath, "")
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: invalid config: %v\n", err)
+		return ExitConfigError
+	}
+	dir := cfg.promptsDir()
+	names, err := libraryPrompts(dir)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(out, "No prompts in %s. Add NAME.md files there and pick one with --prompt NAME.\n", dir)
+		return ExitSuccess
+	}
+	width := 0
+	for _, n := range names {
+		width = max(width, len(n))
+	}
+	fmt.Fprintf(out, "Prompts in %s:\n", dir)
+	for _, n := range names {
+		fmt.Fprintf(out, "  %-*s  %s\n", width, n, promptSummary(filepath.Join(dir, n+".md")))
+	}
+	return ExitSuccess
+}
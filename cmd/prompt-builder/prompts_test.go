@@ -0,0 +1,69 @@
+// prompts_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLibraryPrompt(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "coding.md"), []byte("# Coding architect\nYou design prompts for coding agents."), 0644)
+	os.WriteFile(filepath.Join(dir, "marketing.md"), []byte("You write copy."), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a prompt"), 0644)
+	cfg := &Config{PromptsDir: dir}
+
+	if path, err := cfg.libraryPrompt("coding"); err != nil || path != filepath.Join(dir, "coding.md") {
+		t.Errorf("libraryPrompt(coding) = %q, %v", path, err)
+	}
+	for name, want := range map[string]string{
+		"legal":     "unknown prompt \"legal\"; " + dir + " has: coding, marketing",
+		"../x":      "invalid prompt name",
+		"notes":     "unknown prompt",
+		"coding.md": "unknown prompt",
+	} {
+		if _, err := cfg.libraryPrompt(name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("libraryPrompt(%q) error = %v, want %q", name, err, want)
+		}
+	}
+
+	empty := &Config{PromptsDir: filepath.Join(dir, "missing")}
+	if _, err := empty.libraryPrompt("coding"); err == nil || !strings.Contains(err.Error(), "has no prompts") {
+		t.Errorf("missing directory: error = %v", err)
+	}
+}
+
+func TestPromptsDir_Default(t *testing.T) {
+	t.Setenv(homeEnv, "/lab")
+	if got, want := (&Config{}).promptsDir(), filepath.Join("/lab", "prompts"); got != want {
+		t.Errorf("promptsDir() = %q, want %q", got, want)
+	}
+}
+
+func TestRunPrompts_List(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "coding.md"), []byte("\n# Coding architect\nYou design prompts."), 0644)
+	os.WriteFile(filepath.Join(dir, "long-one.md"), []byte(strings.Repeat("word ", 20)), 0644)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("prompts_dir: "+dir+"\n"), 0644)
+
+	var out, errOut bytes.Buffer
+	if code := runPrompts([]string{"list", "--config", configPath}, &out, &errOut); code != ExitSuccess {
+		t.Fatalf("runPrompts() = %d: %s", code, errOut.String())
+	}
+	want := "Prompts in " + dir + ":\n" +
+		"  coding    Coding architect\n" +
+		"  long-one  " + strings.Repeat("word ", 11) + "word…\n"
+	if out.String() != want {
+		t.Errorf("output =\n%q\nwant\n%q", out.String(), want)
+	}
+
+	os.WriteFile(configPath, []byte("prompts_dir: "+filepath.Join(dir, "none")+"\n"), 0644)
+	out.Reset()
+	if code := runPrompts([]string{"list", "--config", configPath}, &out, &errOut); code != ExitSuccess || !strings.HasPrefix(out.String(), "No prompts in ") {
+		t.Errorf("empty library: %d %q", code, out.String())
+	}
+}
@@ -0,0 +1,148 @@
+// provider.go
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// Providers selectable with the provider config key. Each registers
+// itself with promptbuilder.RegisterProvider in an init in its own file.
+const (
+	providerCompatible = "openai-compatible" // a local or self-hosted server (default)
+	providerOpenAI     = "openai"            // api.openai.com
+	providerAnthropic  = "anthropic"         // api.anthropic.com, Messages API
+	providerOpenRouter = "openrouter"        // openrouter.ai, models named vendor/model
+)
+
+// applyProviderDefaults validates cfg.Provider and fills in its default host.
+func applyProviderDefaults(cfg *Config) error {
+	if cfg.Provider == "" {
+		cfg.Provider = providerCompatible
+	}
+	p, ok := promptbuilder.LookupProvider(cfg.Provider)
+	if !ok {
+		return fmt.Errorf("provider must be one of %s, got %q", strings.Join(promptbuilder.ProviderNames(), ", "), cfg.Provider)
+	}
+	if cfg.Host == "" {
+		cfg.Host = p.Host
+	}
+	if cfg.Prefill != "" && !p.Prefill {
+		return fmt.Errorf("prefill is not supported by provider %s; use anthropic or openai-compatible", cfg.Provider)
+	}
+	// Anthropic rejects a prefill that ends in whitespace
+	cfg.Prefill = strings.TrimRight(cfg.Prefill, " \t\r\n")
+	return nil
+}
+
+// resolveAPIKey returns the API key for cfg: api_key, else the variable
+// named by api_key_env, else the provider's usual variable. It is an
+// error for a provider that needs a key to have none.
+func resolveAPIKey(cfg *Config) (string, error) {
+	if cfg.APIKey != "" {
+		return cfg.APIKey, nil
+	}
+	p, _ := promptbuilder.LookupProvider(cfg.Provider)
+	env := cfg.APIKeyEnv
+	if env == "" {
+		env = p.APIKeyEnv
+	}
+	if env != "" {
+		if key := os.Getenv(env); key != "" {
+			return key, nil
+		}
+		if cfg.APIKeyEnv != "" || p.NeedsKey {
+			return "", fmt.Errorf("config: %s is not set (api_key_env)", env)
+		}
+	}
+	return "", nil
+}
+
+// newLLMClient creates the client for cfg's provider.
+func newLLMClient(cfg *Config, host, model string) (LLMClient, error) {
+	p, ok := promptbuilder.LookupProvider(cfg.Provider)
+	if !ok {
+		return nil, fmt.Errorf("config: unknown provider %q", cfg.Provider)
+	}
+	key, err := resolveAPIKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := p.New(host, model, key, newHTTPClient(cfg))
+	if c, ok := client.(*ChatClient); ok && cfg.ShowUsage {
+		// OpenAI-style servers report usage only when asked
+		c.IncludeUsage = true
+	}
+	return client, nil
+}
+
+// newHTTPClient returns an HTTP client with cfg's timeouts, stall
+// detection, proxy, headers and host pool. Zero values keep Go's defaults:
+// a 30 second connect timeout, no request limit and the proxy from
+// HTTPS_PROXY and friends.
+func newHTTPClient(cfg *Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if d := time.Duration(cfg.ConnectTimeout); d > 0 {
+		transport.DialContext = guardDial((&net.Dialer{Timeout: d, KeepAlive: 30 * time.Second}).DialContext)
+		transport.TLSHandshakeTimeout = d
+	}
+	if cfg.Proxy != "" {
+		// LoadConfig has checked the URL
+		proxy, _ := url.Parse(cfg.Proxy)
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	var rt http.RoundTripper = transport
+	if d := time.Duration(cfg.StallTimeout); d > 0 {
+		rt = stallTransport{d, rt}
+	}
+	if len(cfg.Headers) > 0 {
+		rt = headerTransport{cfg.Headers, rt}
+	}
+	if cfg.StreamLog != nil {
+		rt = cfg.StreamLog.transport(rt)
+	}
+	if cfg.pool != nil {
+		rt = cfg.pool.transport(rt)
+	}
+	return &http.Client{Transport: rt, Timeout: time.Duration(cfg.RequestTimeout)}
+}
+
+// headerTransport sets the configured headers on every request, replacing
+// any the client set itself.
+type headerTransport struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// validateProxy checks a proxy config value.
+func validateProxy(proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("proxy: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("proxy must be an http, https or socks5 URL, got %q", proxy)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy %q has no host", proxy)
+	}
+	return nil
+}
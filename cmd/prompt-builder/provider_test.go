@@ -0,0 +1,219 @@
+// provider_test.go
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+func TestLoadConfig_Provider(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		wantHost string
+		wantErr  bool
+	}{
+		{"default", "model: m\n", "http://localhost:11434", false},
+		{"openai", "provider: openai\n", "https://api.openai.com", false},
+		{"openai with host", "provider: openai\nhost: https://proxy.example\n", "https://proxy.example", false},
+		{"anthropic", "provider: anthropic\n", "https://api.anthropic.com", false},
+		{"unknown", "provider: bard\n", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			os.WriteFile(path, []byte(tt.config), 0644)
+			cfg, err := LoadConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.Host != tt.wantHost {
+				t.Errorf("Host = %q, want %q", cfg.Host, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Prefill(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("provider: anthropic\nprefill: \"```\\n\"\n"), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil || cfg.Prefill != "```" {
+		t.Errorf("LoadConfig() prefill = %q, %v, want the fence without the newline", cfg.Prefill, err)
+	}
+
+	os.WriteFile(path, []byte("provider: openai\nprefill: \"```\"\n"), 0644)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "not supported by provider openai") {
+		t.Errorf("LoadConfig() error = %v, want prefill rejected for openai", err)
+	}
+}
+
+func TestResolveAPIKey(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "from-default-env")
+	t.Setenv("MY_KEY", "from-my-env")
+	t.Setenv("EMPTY_KEY", "")
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{"inline key wins", Config{Provider: providerOpenAI, APIKey: "inline", APIKeyEnv: "MY_KEY"}, "inline", false},
+		{"named env", Config{Provider: providerOpenAI, APIKeyEnv: "MY_KEY"}, "from-my-env", false},
+		{"provider env", Config{Provider: providerOpenAI}, "from-default-env", false},
+		{"named env unset", Config{Provider: providerCompatible, APIKeyEnv: "EMPTY_KEY"}, "", true},
+		{"local server needs none", Config{Provider: providerCompatible}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveAPIKey(&tt.cfg)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("resolveAPIKey() = %q, %v, want %q, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	t.Setenv("OPENAI_API_KEY", "")
+	if _, err := resolveAPIKey(&Config{Provider: providerOpenAI}); err == nil {
+		t.Error("expected error when openai has no key")
+	}
+}
+
+func TestChatClient_SendsAPIKey(t *testing.T) {
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	client, err := newLLMClient(&Config{Provider: providerCompatible, APIKey: "sk-test"}, server.URL, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ChatStream(context.Background(), nil, func(string) error { return nil })
+	NewChatClient(server.URL, "m").ChatStream(context.Background(), nil, func(string) error { return nil })
+
+	if len(auth) != 2 || auth[0] != "Bearer sk-test" || auth[1] != "" {
+		t.Errorf("Authorization headers = %q, want the key only when configured", auth)
+	}
+}
+
+// testBackendHost and testBackendKey record what the test-backend
+// provider's factory was called with. Registering happens once per
+// process, as it does for real providers.
+var testBackendHost, testBackendKey string
+
+func init() {
+	promptbuilder.RegisterProvider("test-backend", promptbuilder.Provider{
+		New: func(host, model, apiKey string, _ *http.Client) LLMClient {
+			testBackendHost, testBackendKey = host, apiKey
+			return &mockLLM{}
+		},
+		Host:      "http://backend.test",
+		APIKeyEnv: "TEST_BACKEND_KEY",
+	})
+}
+
+func TestRegisterProvider(t *testing.T) {
+	t.Setenv("TEST_BACKEND_KEY", "k")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("provider: test-backend\nmodel: m\n"), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := newLLMClient(cfg, cfg.Host, cfg.Model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(*mockLLM); !ok || testBackendHost != "http://backend.test" || testBackendKey != "k" {
+		t.Errorf("client %T created with host %q key %q, want the registered factory with its defaults", client, testBackendHost, testBackendKey)
+	}
+}
+
+func TestApplyProviderDefaults_ListsProviders(t *testing.T) {
+	err := applyProviderDefaults(&Config{Provider: "bard"})
+	if err == nil || !strings.Contains(err.Error(), "anthropic, openai, openai-compatible") {
+		t.Errorf("error = %v, want the registered providers listed", err)
+	}
+}
+
+func TestNewLLMClient_RequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cfg := &Config{Provider: providerCompatible, RequestTimeout: Duration(50 * time.Millisecond)}
+	client, err := newLLMClient(cfg, server.URL, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = client.ChatStream(context.Background(), nil, func(string) error { return nil })
+	if err == nil || time.Since(start) > time.Second {
+		t.Errorf("ChatStream() = %v after %v, want a timeout after about 50ms", err, time.Since(start))
+	}
+}
+
+func TestNewLLMClient_Headers(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	cfg := &Config{Provider: providerAnthropic, APIKey: "k", Headers: map[string]string{"X-Tenant": "acme", "Anthropic-Version": "2099-01-01"}}
+	client, err := newLLMClient(cfg, server.URL, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, func(string) error { return nil })
+	if got.Get("X-Tenant") != "acme" || got.Get("Anthropic-Version") != "2099-01-01" || got.Get("X-Api-Key") != "k" {
+		t.Errorf("headers = %v, want the configured headers alongside the client's own", got)
+	}
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.URL.String()
+		w.Write([]byte(`{"version": "0.5.7"}`))
+	}))
+	defer proxy.Close()
+
+	info, err := DetectServer(context.Background(), newHTTPClient(&Config{Proxy: proxy.URL}), "http://llm.internal:11434")
+	if err != nil || info.Version != "0.5.7" || target != "http://llm.internal:11434/api/version" {
+		t.Errorf("DetectServer() = %+v, %v via %q, want the request to go through the proxy", info, err, target)
+	}
+}
+
+func TestLoadConfig_Proxy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	for proxy, valid := range map[string]bool{
+		"http://proxy.corp:3128":  true,
+		"socks5://127.0.0.1:1080": true,
+		"proxy.corp:3128":         false,
+		"ftp://proxy.corp":        false,
+		"http://":                 false,
+	} {
+		os.WriteFile(path, []byte("proxy: "+proxy+"\n"), 0644)
+		if _, err := LoadConfig(path); (err == nil) != valid {
+			t.Errorf("LoadConfig() with proxy %q: error = %v, want valid = %v", proxy, err, valid)
+		}
+	}
+}
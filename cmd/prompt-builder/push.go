@@ -0,0 +1,52 @@
+// push.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+)
+
+// Formats understood by registry.format and push --format.
+const (
+	registrySimple      = "registry"    // prompt-builder's own registry API, below
+	registryLangSmith   = "langsmith"   // LangSmith prompt hub
+	registryPromptLayer = "promptlayer" // PromptLayer prompt templates
+)
+
+// RegistryConfig configures push: where finished prompts are published.
+type RegistryConfig struct {
+	URL      string `yaml:"url"`       // base URL; LangSmith and PromptLayer have defaults
+	Format   string `yaml:"format"`    // registry (default), langsmith or promptlayer
+	TokenEnv string `yaml:"token_env"` // environment variable holding the API key
+}
+
+// validate checks the registry config.
+func (c RegistryConfig) validate() error {
+	switch c.Format {
+	case "", registrySimple, registryLangSmith, registryPromptLayer:
+		return nil
+	}
+	return fmt.Errorf("registry.format must be registry, langsmith or promptlayer, got %q", c.Format)
+}
+
+// parseInterspersed parses args with fs, allowing flags after positional
+// arguments, and returns the positional ones.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// validPromptName matches the names push accepts, which every supported
+// registry takes in a URL path.
+var validPromptName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
@@ -0,0 +1,17 @@
+//go:build nopublish
+
+// push_nopublish.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// runPush implements the push subcommand, which this binary was built
+// without.
+func runPush(ctx context.Context, args []string, in io.Reader, out, errOut io.Writer) int {
+	fmt.Fprintln(errOut, "Error: push is not available: this binary was built with -tags nopublish")
+	return ExitConfigError
+}
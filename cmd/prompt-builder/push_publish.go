@@ -0,0 +1,312 @@
+//go:build !nopublish
+
+// push_publish.go
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// pushTimeout bounds each request to a registry.
+const pushTimeout = 30 * time.Second
+
+// registryAdapter knows how to publish to one kind of registry.
+type registryAdapter struct {
+	URL      string // default base URL
+	TokenEnv string // default variable for the API key
+	push     func(ctx context.Context, r *registryClient, p pushRequest) (pushResult, error)
+}
+
+var registryAdapters = map[string]registryAdapter{
+	registrySimple:      {push: pushSimple},
+	registryLangSmith:   {URL: "https://api.smith.langchain.com", TokenEnv: "LANGSMITH_API_KEY", push: pushLangSmith},
+	registryPromptLayer: {URL: "https://api.promptlayer.com", TokenEnv: "PROMPTLAYER_API_KEY", push: pushPromptLayer},
+}
+
+// pushRequest is a prompt to publish under Name.
+type pushRequest struct {
+	Name    string
+	Prompt  string
+	Message string // describes this version, like a commit message
+}
+
+// pushResult is what a registry reports back; fields it does not report
+// are empty.
+type pushResult struct {
+	Version string
+	URL     string
+}
+
+// registryClient sends authenticated JSON requests to a registry.
+type registryClient struct {
+	base   string
+	header string // how the API key is sent: Authorization or a custom header
+	key    string
+	http   *http.Client
+}
+
+// newRegistryClient returns a client for cfg, with the adapter's defaults
+// for what cfg leaves unset.
+func newRegistryClient(cfg RegistryConfig) (*registryClient, registryAdapter, error) {
+	format := cmp.Or(cfg.Format, registrySimple)
+	a, ok := registryAdapters[format]
+	if !ok {
+		return nil, a, fmt.Errorf("unknown registry format %q; use registry, langsmith or promptlayer", cfg.Format)
+	}
+	base := cmp.Or(cfg.URL, a.URL)
+	if base == "" {
+		return nil, a, fmt.Errorf("no registry URL; pass --registry or set registry.url")
+	}
+	if u, err := url.Parse(base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, a, fmt.Errorf("registry URL is not an http(s) URL: %q", base)
+	}
+
+	c := &registryClient{
+		base: strings.TrimSuffix(base, "/"),
+		http: &http.Client{
+			Timeout: pushTimeout,
+			// The API key must not follow a redirect to another host
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+	switch format {
+	case registryLangSmith:
+		c.header = "X-API-Key"
+	case registryPromptLayer:
+		c.header = "X-API-KEY"
+	default:
+		c.header = "Authorization"
+	}
+	if env := cmp.Or(cfg.TokenEnv, a.TokenEnv); env != "" {
+		if c.key = os.Getenv(env); c.key == "" {
+			return nil, a, fmt.Errorf("%s is not set", env)
+		}
+	}
+	return c, a, nil
+}
+
+// do sends body as JSON to path and decodes a JSON reply into out, if
+// given. It returns the status code with any error.
+func (c *registryClient) do(ctx context.Context, method, path string, body, out any) (int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "prompt-builder/"+version)
+	if c.key != "" {
+		value := c.key
+		if c.header == "Authorization" {
+			value = "Bearer " + c.key
+		}
+		req.Header.Set(c.header, value)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := firstLine(strings.TrimSpace(string(reply)), 200)
+		if msg == "" {
+			return resp.StatusCode, fmt.Errorf("%s %s returned %s", method, path, resp.Status)
+		}
+		return resp.StatusCode, fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, msg)
+	}
+	if out != nil && len(bytes.TrimSpace(reply)) > 0 {
+		if err := json.Unmarshal(reply, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("unexpected reply from the registry: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// pushSimple publishes to prompt-builder's registry API:
+//
+//	PUT {url}/prompts/{name}
+//	{"name": ..., "prompt": ..., "message": ..., "metadata": {"tool": "prompt-builder/1.4.0"}}
+//
+// A registry stores it as the next version of name and may answer with
+// {"version": ..., "url": ...}.
+func pushSimple(ctx context.Context, c *registryClient, p pushRequest) (pushResult, error) {
+	body := map[string]any{
+		"name":     p.Name,
+		"prompt":   p.Prompt,
+		"message":  p.Message,
+		"metadata": map[string]string{"tool": "prompt-builder/" + version},
+	}
+	var reply struct {
+		Version json.RawMessage `json:"version"`
+		URL     string          `json:"url"`
+	}
+	if _, err := c.do(ctx, http.MethodPut, "/prompts/"+url.PathEscape(p.Name), body, &reply); err != nil {
+		return pushResult{}, err
+	}
+	return pushResult{Version: strings.Trim(string(reply.Version), `"`), URL: reply.URL}, nil
+}
+
+// pushLangSmith commits the prompt to the LangSmith prompt hub as a
+// chat prompt with one system message, creating the private repo first
+// if it does not exist yet.
+func pushLangSmith(ctx context.Context, c *registryClient, p pushRequest) (pushResult, error) {
+	lc := func(id []string, kwargs map[string]any) map[string]any {
+		return map[string]any{"lc": 1, "type": "constructor", "id": id, "kwargs": kwargs}
+	}
+	template := lc([]string{"langchain", "prompts", "prompt", "PromptTemplate"}, map[string]any{
+		"input_variables": []string{},
+		"template":        p.Prompt,
+		// Single braces, as in JSON examples, stay text; f-strings would
+		// take them for variables
+		"template_format": "mustache",
+	})
+	manifest := lc([]string{"langchain", "prompts", "chat", "ChatPromptTemplate"}, map[string]any{
+		"input_variables": []string{},
+		"messages": []any{lc([]string{"langchain", "prompts", "chat", "SystemMessagePromptTemplate"}, map[string]any{
+			"prompt": template,
+		})},
+	})
+	path := "/api/v1/commits/-/" + url.PathEscape(p.Name)
+	body := map[string]any{"manifest": manifest, "parent_commit": nil}
+
+	var reply struct {
+		Commit struct {
+			CommitHash string `json:"commit_hash"`
+		} `json:"commit"`
+	}
+	status, err := c.do(ctx, http.MethodPost, path, body, &reply)
+	if status == http.StatusNotFound {
+		repo := map[string]any{"repo_handle": p.Name, "is_public": false, "description": p.Message}
+		if _, err := c.do(ctx, http.MethodPost, "/api/v1/repos/", repo, nil); err != nil {
+			return pushResult{}, fmt.Errorf("cannot create prompt %s: %v", p.Name, err)
+		}
+		_, err = c.do(ctx, http.MethodPost, path, body, &reply)
+	}
+	if err != nil {
+		return pushResult{}, err
+	}
+	return pushResult{Version: reply.Commit.CommitHash}, nil
+}
+
+// pushPromptLayer publishes the prompt as a new version of a PromptLayer
+// completion template.
+func pushPromptLayer(ctx context.Context, c *registryClient, p pushRequest) (pushResult, error) {
+	body := map[string]any{
+		"prompt_template": map[string]any{"prompt_name": p.Name},
+		"prompt_version": map[string]any{
+			"prompt_template": map[string]any{
+				"type":            "completion",
+				"content":         []map[string]string{{"type": "text", "text": p.Prompt}},
+				"input_variables": []string{},
+			},
+			"commit_message": p.Message,
+		},
+	}
+	var reply struct {
+		VersionNumber json.RawMessage `json:"version_number"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/rest/prompt-templates", body, &reply); err != nil {
+		return pushResult{}, err
+	}
+	return pushResult{Version: strings.Trim(string(reply.VersionNumber), `"`)}, nil
+}
+
+// PushPrompt publishes p to the registry cfg describes.
+func PushPrompt(ctx context.Context, cfg RegistryConfig, p pushRequest) (pushResult, error) {
+	if !validPromptName.MatchString(p.Name) {
+		return pushResult{}, fmt.Errorf("invalid prompt name %q; use letters, digits, '.', '_' and '-'", p.Name)
+	}
+	if strings.TrimSpace(p.Prompt) == "" {
+		return pushResult{}, fmt.Errorf("the prompt is empty")
+	}
+	c, a, err := newRegistryClient(cfg)
+	if err != nil {
+		return pushResult{}, err
+	}
+	return a.push(ctx, c, p)
+}
+
+// runPush implements the push subcommand: it publishes a finished prompt,
+// read from a file or stdin, to a prompt registry.
+func runPush(ctx context.Context, args []string, in io.Reader, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("push", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	registry := fs.String("registry", "", "Registry base URL (overrides registry.url)")
+	format := fs.String("format", "", "Registry API: registry, langsmith or promptlayer (overrides registry.format)")
+	tokenEnv := fs.String("token-env", "", "Environment variable holding the API key (overrides registry.token_env)")
+	message := fs.String("message", "", "Describe this version of the prompt")
+	configPath := fs.String("config", "", "Path to config file")
+	profile := fs.String("profile", "", "Profile from the config file to use")
+	fs.Usage = func() {
+		fmt.Fprintln(errOut, "Usage: prompt-builder push <name> [file] [--registry URL] [--format registry|langsmith|promptlayer]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return ExitConfigError
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		fs.Usage()
+		return ExitConfigError
+	}
+
+	cfg, err := loadRunConfig(*configPath, *profile)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: invalid config: %v\n", err)
+		return ExitConfigError
+	}
+	if cfg.Offline.active() {
+		fmt.Fprintln(errOut, "Error: push is not available in offline mode")
+		return ExitConfigError
+	}
+	rc := cfg.Registry
+	if *format != "" && *format != rc.Format {
+		// Another registry: the config's URL and key belong to the old one
+		rc = RegistryConfig{Format: *format}
+	}
+	rc.URL = cmp.Or(*registry, rc.URL)
+	rc.TokenEnv = cmp.Or(*tokenEnv, rc.TokenEnv)
+
+	var prompt []byte
+	if len(positional) == 2 && positional[1] != "-" {
+		prompt, err = os.ReadFile(ExpandPath(positional[1]))
+	} else {
+		prompt, err = io.ReadAll(in)
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+
+	name := positional[0]
+	result, err := PushPrompt(ctx, rc, pushRequest{Name: name, Prompt: string(prompt), Message: *message})
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: push failed: %v\n", err)
+		return ExitConfigError
+	}
+	where := cmp.Or(rc.URL, registryAdapters[cmp.Or(rc.Format, registrySimple)].URL)
+	fmt.Fprintf(out, "✓ Pushed %s to %s", name, where)
+	if result.Version != "" {
+		fmt.Fprintf(out, " (version %s)", result.Version)
+	}
+	fmt.Fprintln(out)
+	if result.URL != "" {
+		fmt.Fprintln(out, result.URL)
+	}
+	return ExitSuccess
+}
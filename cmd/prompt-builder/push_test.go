@@ -0,0 +1,192 @@
+//go:build !nopublish
+
+// push_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPush_Registry(t *testing.T) {
+	t.Setenv("TEST_REGISTRY_TOKEN", "tok")
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/prompts/support-bot" || r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("%s %s, Authorization %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"version": 3, "url": "https://prompts.example.com/support-bot/3"}`))
+	}))
+	defer srv.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, nil, 0644)
+
+	var out, errOut bytes.Buffer
+	args := []string{"support-bot", "--registry", srv.URL + "/", "--token-env", "TEST_REGISTRY_TOKEN", "--message", "friendlier", "--config", configPath}
+	code := runPush(context.Background(), args, strings.NewReader("Be kind.\n"), &out, &errOut)
+	if code != ExitSuccess {
+		t.Fatalf("runPush() = %d: %s", code, errOut.String())
+	}
+	if got["name"] != "support-bot" || got["prompt"] != "Be kind.\n" || got["message"] != "friendlier" {
+		t.Errorf("body = %v", got)
+	}
+	want := "✓ Pushed support-bot to " + srv.URL + "/ (version 3)\nhttps://prompts.example.com/support-bot/3\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunPush_FromFileWithConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["prompt"] != "From a file." {
+			t.Errorf("prompt = %q", body["prompt"])
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	os.WriteFile(configPath, []byte("registry:\n  url: "+srv.URL+"\n"), 0644)
+	promptPath := filepath.Join(dir, "prompt.md")
+	os.WriteFile(promptPath, []byte("From a file."), 0644)
+
+	var out, errOut bytes.Buffer
+	if code := runPush(context.Background(), []string{"--config", configPath, "reviewer", promptPath}, nil, &out, &errOut); code != ExitSuccess {
+		t.Fatalf("runPush() = %d: %s", code, errOut.String())
+	}
+	if out.String() != "✓ Pushed reviewer to "+srv.URL+"\n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestPushPrompt_LangSmithCreatesRepo(t *testing.T) {
+	t.Setenv("LANGSMITH_API_KEY", "ls-key")
+	var calls []string
+	var manifest map[string]any
+	created := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Header.Get("X-API-Key") != "ls-key" {
+			t.Errorf("X-API-Key = %q", r.Header.Get("X-API-Key"))
+		}
+		switch r.URL.Path {
+		case "/api/v1/commits/-/support-bot":
+			if !created {
+				http.Error(w, `{"detail": "Repo not found"}`, http.StatusNotFound)
+				return
+			}
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			manifest = body["manifest"].(map[string]any)
+			w.Write([]byte(`{"commit": {"commit_hash": "abc123"}}`))
+		case "/api/v1/repos/":
+			created = true
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	result, err := PushPrompt(context.Background(), RegistryConfig{URL: srv.URL, Format: registryLangSmith}, pushRequest{Name: "support-bot", Prompt: `Reply as {"ok": true}`})
+	if err != nil {
+		t.Fatalf("PushPrompt() error = %v", err)
+	}
+	if result.Version != "abc123" {
+		t.Errorf("version = %q", result.Version)
+	}
+	want := "POST /api/v1/commits/-/support-bot|POST /api/v1/repos/|POST /api/v1/commits/-/support-bot"
+	if strings.Join(calls, "|") != want {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	data, _ := json.Marshal(manifest)
+	for _, s := range []string{"ChatPromptTemplate", "SystemMessagePromptTemplate", `"template_format":"mustache"`, `Reply as {\"ok\": true}`} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("manifest lacks %s: %s", s, data)
+		}
+	}
+}
+
+func TestPushPrompt_PromptLayer(t *testing.T) {
+	t.Setenv("PROMPTLAYER_API_KEY", "pl-key")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/prompt-templates" || r.Header.Get("X-API-KEY") != "pl-key" {
+			t.Errorf("%s, X-API-KEY %q", r.URL.Path, r.Header.Get("X-API-KEY"))
+		}
+		var body struct {
+			Template struct {
+				Name string `json:"prompt_name"`
+			} `json:"prompt_template"`
+			Version struct {
+				Template struct {
+					Content []struct{ Text string } `json:"content"`
+				} `json:"prompt_template"`
+				Message string `json:"commit_message"`
+			} `json:"prompt_version"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Template.Name != "triage" || body.Version.Template.Content[0].Text != "Triage tickets." || body.Version.Message != "first" {
+			t.Errorf("body = %+v", body)
+		}
+		w.Write([]byte(`{"id": 7, "version_number": 2}`))
+	}))
+	defer srv.Close()
+
+	result, err := PushPrompt(context.Background(), RegistryConfig{URL: srv.URL, Format: registryPromptLayer}, pushRequest{Name: "triage", Prompt: "Triage tickets.", Message: "first"})
+	if err != nil || result.Version != "2" {
+		t.Errorf("PushPrompt() = %+v, %v, want version 2", result, err)
+	}
+}
+
+func TestPushPrompt_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "name is taken", http.StatusConflict)
+	}))
+	defer srv.Close()
+	t.Setenv("LANGSMITH_API_KEY", "")
+
+	for _, tc := range []struct {
+		cfg  RegistryConfig
+		req  pushRequest
+		want string
+	}{
+		{RegistryConfig{URL: srv.URL}, pushRequest{Name: "../etc", Prompt: "p"}, "invalid prompt name"},
+		{RegistryConfig{URL: srv.URL}, pushRequest{Name: "x", Prompt: " \n"}, "the prompt is empty"},
+		{RegistryConfig{}, pushRequest{Name: "x", Prompt: "p"}, "no registry URL"},
+		{RegistryConfig{Format: "hub"}, pushRequest{Name: "x", Prompt: "p"}, "unknown registry format"},
+		{RegistryConfig{Format: registryLangSmith}, pushRequest{Name: "x", Prompt: "p"}, "LANGSMITH_API_KEY is not set"},
+		{RegistryConfig{URL: srv.URL}, pushRequest{Name: "x", Prompt: "p"}, "409 Conflict: name is taken"},
+	} {
+		if _, err := PushPrompt(context.Background(), tc.cfg, tc.req); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: error = %v, want %q", tc, err, tc.want)
+		}
+	}
+}
+
+func TestRunPush_Usage(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := runPush(context.Background(), nil, nil, &out, &errOut); code != ExitConfigError || !strings.Contains(errOut.String(), "Usage: prompt-builder push") {
+		t.Errorf("runPush() = %d, %q, want usage", code, errOut.String())
+	}
+}
+
+func TestRunPush_Offline(t *testing.T) {
+	t.Cleanup(func() { offlineHosts.Store(nil) })
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("offline:\n  enabled: true\n"), 0644)
+	var out, errOut bytes.Buffer
+	args := []string{"x", "--registry", "http://localhost:9000", "--config", configPath}
+	if code := runPush(context.Background(), args, strings.NewReader("p"), &out, &errOut); code != ExitConfigError || !strings.Contains(errOut.String(), "offline mode") {
+		t.Errorf("runPush() = %d, %q, want it refused", code, errOut.String())
+	}
+}
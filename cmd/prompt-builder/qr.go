@@ -0,0 +1,563 @@
+//go:build !noqr
+
+// qr.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// QR codes are encoded in byte mode at error correction level L, which
+// holds the most text; terminal output is sharp enough not to need more
+// redundancy. The construction follows ISO/IEC 18004.
+
+// qrBuilt reports whether this binary can show QR codes with --qr and
+// /qr; build with -tags noqr to leave them out.
+const qrBuilt = true
+
+// qrMaxBytes is what version 40 at level L holds in byte mode.
+const qrMaxBytes = 2953
+
+// Per-version error correction layout for level L, indexed by version.
+var (
+	qrECCPerBlock = [41]int{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30}
+	qrNumBlocks   = [41]int{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25}
+)
+
+// QRCode is a square matrix of modules; true is dark.
+type QRCode struct {
+	Size    int
+	modules [][]bool
+	isFunc  [][]bool
+}
+
+// Dark reports whether the module at column x, row y is dark.
+func (q *QRCode) Dark(x, y int) bool {
+	return q.modules[y][x]
+}
+
+// EncodeQR encodes data in the smallest QR code that holds it, choosing
+// the mask with the lowest penalty.
+func EncodeQR(data []byte) (*QRCode, error) {
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if qrDataBits(len(data), v) <= qrDataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%d bytes is too long for a QR code (at most %d)", len(data), qrMaxBytes)
+	}
+
+	var best *QRCode
+	bestPenalty := -1
+	codewords := qrCodewords(data, version)
+	for mask := 0; mask < 8; mask++ {
+		q := newQRCode(version)
+		q.drawCodewords(codewords)
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = q, p
+		}
+	}
+	return best, nil
+}
+
+func qrCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// qrDataBits is the bit length of n bytes in byte mode, with its header.
+func qrDataBits(n, version int) int {
+	return 4 + qrCountBits(version) + 8*n
+}
+
+// qrRawModules is the number of modules available for codewords.
+func qrRawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+func qrDataCodewords(version int) int {
+	return qrRawModules(version)/8 - qrECCPerBlock[version]*qrNumBlocks[version]
+}
+
+// qrCodewords builds the data codewords for data, splits them into
+// blocks, adds error correction and interleaves the result.
+func qrCodewords(data []byte, version int) []byte {
+	var bits qrBits
+	bits.append(0b0100, 4) // byte mode
+	bits.append(len(data), qrCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := qrDataCodewords(version) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	dataCodewords := bits.bytes()
+
+	numBlocks, eccLen := qrNumBlocks[version], qrECCPerBlock[version]
+	raw := qrRawModules(version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+	divisor := rsDivisor(eccLen)
+
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		block := make([]byte, shortLen+1)
+		copy(block, dataCodewords[k:k+n])
+		copy(block[len(block)-eccLen:], rsRemainder(dataCodewords[k:k+n], divisor))
+		blocks[i] = block
+		k += n
+	}
+
+	result := make([]byte, 0, raw)
+	for i := 0; i <= shortLen; i++ {
+		for j, block := range blocks {
+			// Short blocks have a gap where long blocks have one more data codeword
+			if i != shortLen-eccLen || j >= numShort {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+type qrBits []bool
+
+func (b *qrBits) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 == 1)
+	}
+}
+
+func (b qrBits) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the given
+// degree, highest coefficient first, without the leading 1.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords for data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// newQRCode draws the function patterns of a version; format bits are
+// reserved and drawn once the mask is known.
+func newQRCode(version int) *QRCode {
+	size := version*4 + 17
+	q := &QRCode{Size: size, modules: make([][]bool, size), isFunc: make([][]bool, size)}
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.isFunc[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		q.setFunc(6, i, i%2 == 0)
+		q.setFunc(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(size-4, 3)
+	q.drawFinder(3, size-4)
+
+	align := qrAlignmentPositions(version)
+	last := len(align) - 1
+	for i, x := range align {
+		for j, y := range align {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // finder corners
+			}
+			q.drawAlignment(x, y)
+		}
+	}
+
+	q.drawFormatBits(0)
+	q.drawVersion(version)
+	return q
+}
+
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	result := make([]int, n)
+	result[0] = 6
+	for i, pos := n-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func (q *QRCode) setFunc(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunc[y][x] = true
+}
+
+func (q *QRCode) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= q.Size || y >= q.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			q.setFunc(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func (q *QRCode) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.setFunc(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// qrFormatBits returns the 15-bit format information for level L and
+// mask, protected by a BCH code.
+func qrFormatBits(mask int) int {
+	data := 0b01<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// qrVersionBits returns the 18-bit version information for version.
+func qrVersionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+// drawFormatBits writes the error correction level and mask, twice.
+func (q *QRCode) drawFormatBits(mask int) {
+	bits := qrFormatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunc(8, i, bit(i))
+	}
+	q.setFunc(8, 7, bit(6))
+	q.setFunc(8, 8, bit(7))
+	q.setFunc(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunc(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.setFunc(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunc(8, q.Size-15+i, bit(i))
+	}
+	q.setFunc(8, q.Size-8, true) // always dark
+}
+
+// drawVersion writes the version number for versions 7 and up, twice.
+func (q *QRCode) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	bits := qrVersionBits(version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 == 1
+		a, b := q.Size-11+i%3, i/3
+		q.setFunc(a, b, dark)
+		q.setFunc(b, a, dark)
+	}
+}
+
+// drawCodewords places data in the zigzag order, two columns at a time
+// from the bottom right, skipping the vertical timing pattern.
+func (q *QRCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert // upward
+				}
+				if !q.isFunc[y][x] && i < len(data)*8 {
+					q.modules[y][x] = data[i>>3]>>(7-i&7)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *QRCode) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.isFunc[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, following the four rules
+// of the standard: long runs, 2x2 blocks, finder-like patterns and an
+// unbalanced dark/light ratio.
+func (q *QRCode) penalty() int {
+	n := q.Size
+	p := 0
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+	finderLike := [2][11]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < n; y++ {
+			run := 1
+			for x := 1; x <= n; x++ {
+				if x < n && at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			for x := 0; x+11 <= n; x++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if at(x+k, y, transpose) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						p += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					p += 3
+				}
+			}
+		}
+	}
+	total := n * n
+	p += abs(dark*100/total-50) / 5 * 10
+	return p
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// qrText returns what the QR code for prompt encodes: the prompt, or if
+// it does not fit and share is configured, the link to a shared copy.
+// link reports which.
+func qrText(share *ShareConfig, title, prompt string) (text string, link bool, err error) {
+	if len(prompt) <= qrMaxBytes {
+		return prompt, false, nil
+	}
+	if share == nil || share.URL == "" {
+		return "", false, fmt.Errorf("prompt is too long for a QR code (%d bytes, at most %d); set share.url to show a link to it instead", len(prompt), qrMaxBytes)
+	}
+	url, err := SharePrompt(*share, title, prompt)
+	if err != nil {
+		return "", false, fmt.Errorf("prompt is too long for a QR code (%d bytes) and sharing it failed: %v", len(prompt), err)
+	}
+	return url, true, nil
+}
+
+// WriteQR draws prompt as a QR code on w, or a link to it as qrText does.
+func WriteQR(w io.Writer, share *ShareConfig, title, prompt string) error {
+	if err := writeQR(w, share, title, prompt); err != nil {
+		return fmt.Errorf("cannot show QR code: %v", err)
+	}
+	return nil
+}
+
+func writeQR(w io.Writer, share *ShareConfig, title, prompt string) error {
+	text, link, err := qrText(share, title, prompt)
+	if err != nil {
+		return err
+	}
+	q, err := EncodeQR([]byte(text))
+	if err != nil {
+		return err
+	}
+	if link {
+		fmt.Fprintf(w, "The prompt is too long for a QR code; this one links to %s\n", text)
+	}
+	_, err = io.WriteString(w, q.RenderTerminal())
+	return err
+}
+
+// handleQR implements /qr.
+func handleQR(lastResponse string, env *CommandEnv) error {
+	if lastResponse == "" {
+		return fmt.Errorf("No response to show")
+	}
+	prompt := promptbuilder.ExtractLastCodeBlock(lastResponse)
+	if prompt == "" {
+		return fmt.Errorf("No code block to show")
+	}
+	if env.Stamp != nil {
+		stamped, err := env.Stamp(prompt)
+		if err != nil {
+			return fmt.Errorf("Cannot show QR code: %v", err)
+		}
+		prompt = stamped
+	}
+	if err := writeQR(env.Out, env.Share, shareTitle(env.Session), prompt); err != nil {
+		return fmt.Errorf("Cannot show QR code: %v", err)
+	}
+	return nil
+}
+
+// RenderTerminal draws q with half-block characters, two rows per line,
+// inside a quiet zone. Light modules are drawn, so the code reads
+// correctly on the usual dark terminal background.
+func (q *QRCode) RenderTerminal() string {
+	const quiet = 4 // the width ISO/IEC 18004 requires
+	light := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		if x < 0 || y < 0 || x >= q.Size || y >= q.Size {
+			return true
+		}
+		return !q.modules[y][x]
+	}
+
+	var b strings.Builder
+	total := q.Size + 2*quiet
+	for y := 0; y < total; y += 2 {
+		for x := 0; x < total; x++ {
+			top, bottom := light(x, y), y+1 < total && light(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,23 @@
+//go:build noqr
+
+// qr_noqr.go
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// qrBuilt reports whether this binary can show QR codes with --qr and
+// /qr.
+const qrBuilt = false
+
+// WriteQR fails: this binary was built without QR codes.
+func WriteQR(w io.Writer, share *ShareConfig, title, prompt string) error {
+	return fmt.Errorf("cannot show QR code: this binary was built with -tags noqr")
+}
+
+// handleQR fails: this binary was built without QR codes.
+func handleQR(lastResponse string, env *CommandEnv) error {
+	return fmt.Errorf("QR codes are not available: this binary was built with -tags noqr")
+}
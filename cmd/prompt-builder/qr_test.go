@@ -0,0 +1,152 @@
+//go:build !noqr
+
+// qr_test.go
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// Version 1-M "HELLO WORLD" from the standard's worked example
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestQRFormatAndVersionBits(t *testing.T) {
+	if got := qrFormatBits(0); got != 0b111011111000100 {
+		t.Errorf("qrFormatBits(0) = %015b, want 111011111000100", got)
+	}
+	if got := qrVersionBits(7); got != 0b000111110010010100 {
+		t.Errorf("qrVersionBits(7) = %018b, want 000111110010010100", got)
+	}
+}
+
+func TestQRCapacity(t *testing.T) {
+	for v := 1; v <= 40; v++ {
+		ecc := qrECCPerBlock[v] * qrNumBlocks[v]
+		if qrDataCodewords(v) <= 0 || qrRawModules(v)/8 <= ecc {
+			t.Fatalf("version %d layout is inconsistent", v)
+		}
+	}
+	if got := (qrDataCodewords(40)*8 - qrDataBits(0, 40)) / 8; got != qrMaxBytes {
+		t.Errorf("version 40 holds %d bytes, want %d", got, qrMaxBytes)
+	}
+
+	tests := []struct {
+		n        int
+		wantSize int
+	}{
+		{17, 21}, // version 1 limit
+		{18, 25}, // version 2
+		{qrMaxBytes, 177},
+	}
+	for _, tt := range tests {
+		q, err := EncodeQR(bytes.Repeat([]byte("a"), tt.n))
+		if err != nil {
+			t.Fatalf("EncodeQR(%d bytes) error = %v", tt.n, err)
+		}
+		if q.Size != tt.wantSize {
+			t.Errorf("EncodeQR(%d bytes) size = %d, want %d", tt.n, q.Size, tt.wantSize)
+		}
+	}
+	if _, err := EncodeQR(make([]byte, qrMaxBytes+1)); err == nil {
+		t.Error("expected error above the version 40 capacity")
+	}
+}
+
+func TestQRFinderPatterns(t *testing.T) {
+	q, err := EncodeQR([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Top-left finder: dark ring, light ring, dark 3x3 center
+	for _, c := range []struct {
+		x, y int
+		dark bool
+	}{{0, 0, true}, {6, 6, true}, {1, 1, false}, {3, 3, true}, {7, 7, false}, {q.Size - 1, 0, true}, {0, q.Size - 1, true}} {
+		if q.Dark(c.x, c.y) != c.dark {
+			t.Errorf("module (%d,%d) dark = %v, want %v", c.x, c.y, !c.dark, c.dark)
+		}
+	}
+	if !q.Dark(8, q.Size-8) {
+		t.Error("the dark module must be set")
+	}
+}
+
+func TestQRRenderTerminal(t *testing.T) {
+	q, err := EncodeQR([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(q.RenderTerminal(), "\n"), "\n")
+	width := q.Size + 8 // four modules of quiet zone on each side
+	if len(lines) != (width+1)/2 {
+		t.Errorf("got %d lines, want %d", len(lines), (width+1)/2)
+	}
+	for _, l := range lines {
+		if utf8.RuneCountInString(l) != width {
+			t.Fatalf("line width = %d, want %d", utf8.RuneCountInString(l), width)
+		}
+	}
+	for i := range 2 {
+		if lines[i] != strings.Repeat("█", width) {
+			t.Errorf("line %d should be quiet zone", i)
+		}
+	}
+}
+
+func TestHandleQR(t *testing.T) {
+	var out bytes.Buffer
+	env := &CommandEnv{Out: &out}
+
+	if _, err := HandleCommand("/qr", "```\nshort prompt\n```", env); err != nil {
+		t.Fatalf("HandleCommand() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "▀") {
+		t.Errorf("expected a QR code, got %q", out.String())
+	}
+
+	_, err := HandleCommand("/qr", "```\n"+strings.Repeat("x", qrMaxBytes+1)+"```", env)
+	if err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("expected too long error, got: %v", err)
+	}
+	if _, err := HandleCommand("/qr", "", env); err == nil || err.Error() != "No response to show" {
+		t.Errorf("expected no response error, got: %v", err)
+	}
+}
+
+func TestHandleQR_SharesLongPrompt(t *testing.T) {
+	requireBuild(t, publishBuilt, "nopublish")
+	var uploaded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		uploaded = string(body)
+		w.Header().Set("Location", "/p/abc")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	env := &CommandEnv{Out: &out, Share: &ShareConfig{URL: server.URL, AllowedHosts: []string{"127.0.0.1"}}}
+	prompt := strings.Repeat("x", qrMaxBytes+1) + "\n"
+	if _, err := HandleCommand("/qr", "```\n"+prompt+"```", env); err != nil {
+		t.Fatalf("HandleCommand() error = %v", err)
+	}
+	if uploaded != prompt {
+		t.Errorf("shared %d bytes, want the whole prompt", len(uploaded))
+	}
+	if want := "links to " + server.URL + "/p/abc"; !strings.Contains(out.String(), want) || !strings.Contains(out.String(), "▀") {
+		t.Errorf("expected a QR code that %s, got %q", want, out.String())
+	}
+}
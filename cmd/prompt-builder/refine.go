@@ -0,0 +1,21 @@
+// refine.go
+package main
+
+import "strings"
+
+// refineIntro hands the model an existing prompt to work on.
+const refineIntro = "Here is an existing prompt. Treat it as your current draft and revise it as I ask."
+
+// refinePipePrefix asks for the revision without clarifying questions.
+const refinePipePrefix = "Revise the prompt without asking clarifying questions. Instructions: "
+
+// seedDraft starts conv with draft as the model's current draft, as if it
+// had written it.
+func seedDraft(conv *Conversation, draft string) string {
+	draft = strings.TrimSuffix(draft, "\n")
+	fence := fenceFor(draft)
+	reply := fence + "\n" + draft + "\n" + fence
+	conv.AddUserMessage(refineIntro)
+	conv.AddAssistantMessage(reply)
+	return reply
+}
@@ -0,0 +1,75 @@
+// refine_test.go
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+func TestSeedDraft(t *testing.T) {
+	conv := NewConversation("sys")
+	reply := seedDraft(conv, "# Role\n```json\n{}\n```\n")
+	if got := promptbuilder.ExtractLastCodeBlock(reply); got != "# Role\n```json\n{}\n```\n" {
+		t.Errorf("draft round trip = %q", got)
+	}
+	if len(conv.Messages) != 3 || conv.Messages[2].Role != "assistant" {
+		t.Errorf("messages = %+v, want the draft as the model's reply", conv.Messages)
+	}
+}
+
+func TestRunWithDeps_Refine(t *testing.T) {
+	deps := newTestDeps(withResponses("```\nstricter prompt\n```"), withStdin("/copy\n"))
+	cli := &CLI{Refine: "p.md", Draft: "old prompt\n", Idea: "make it stricter about citations"}
+	if err := runWithDeps(context.Background(), cli, deps); err != nil {
+		t.Fatal(err)
+	}
+
+	mock := deps.Client.(*mockLLM)
+	if mock.calls != 1 {
+		t.Fatalf("calls = %d, want 1", mock.calls)
+	}
+	roles := ""
+	for _, m := range mock.last {
+		roles += m.Role[:1]
+	}
+	if roles != "suau" {
+		t.Errorf("roles sent = %q, want system, intro, draft, instructions", roles)
+	}
+	if mock.last[2].Content != "```\nold prompt\n```" || mock.last[3].Content != "make it stricter about citations" {
+		t.Errorf("sent %+v", mock.last)
+	}
+	if got := clipboardWritten(deps); got != "stricter prompt\n" {
+		t.Errorf("copied %q", got)
+	}
+}
+
+func TestRunWithDeps_RefineWithoutInstructions(t *testing.T) {
+	deps := newTestDeps(withResponses("```\nshorter\n```"), withStdin("shorter please\n/copy\n"))
+	if err := runWithDeps(context.Background(), &CLI{Refine: "p.md", Draft: "old prompt"}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stdout(deps), "```\nold prompt\n```\n> ") {
+		t.Errorf("stdout = %q, want the draft shown before the first input", stdout(deps))
+	}
+	if got := deps.Client.(*mockLLM).last; got[len(got)-1].Content != "shorter please" {
+		t.Errorf("last message = %q", got[len(got)-1].Content)
+	}
+}
+
+func TestRunWithDeps_RefinePipe(t *testing.T) {
+	deps := newTestDeps(withResponses("```\nstricter prompt\n```"), withTTY(false))
+	cli := &CLI{Refine: "p.md", Draft: "old prompt", Idea: "stricter", Quiet: QuietPrompt}
+	if err := runWithDeps(context.Background(), cli, deps); err != nil {
+		t.Fatal(err)
+	}
+	last := deps.Client.(*mockLLM).last
+	if got := last[len(last)-1].Content; got != refinePipePrefix+"stricter" {
+		t.Errorf("pipe request = %q", got)
+	}
+	if stdout(deps) != "stricter prompt\n\n" {
+		t.Errorf("stdout = %q", stdout(deps))
+	}
+}
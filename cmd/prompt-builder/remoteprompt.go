@@ -0,0 +1,90 @@
+// remoteprompt.go
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxRemotePrompt bounds a system prompt fetched over HTTPS.
+const maxRemotePrompt = 1 << 20
+
+// promptHTTPClient fetches remote system prompts. It has no LLM server
+// headers, and offline mode restricts it like any other connection.
+var promptHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// isPromptURL reports whether a system_prompt_file names a URL rather
+// than a file.
+func isPromptURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// remotePromptsDir holds the cached copies of remote system prompts.
+func remotePromptsDir() string {
+	return filepath.Join(cacheDir(), "system-prompts")
+}
+
+// remotePrompt returns the system prompt at url. The copy cached in dir
+// is sent with its ETag, so an unchanged prompt is not downloaded again,
+// and is used as it is when the server cannot be reached or fails.
+func remotePrompt(ctx context.Context, client *http.Client, dir, url string) (string, error) {
+	sum := sha256.Sum256([]byte(url))
+	cached := filepath.Join(dir, hex.EncodeToString(sum[:8])+".md")
+	kept, cacheErr := os.ReadFile(cached)
+	etag, _ := os.ReadFile(cached + ".etag")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("system prompt %s: %v", url, err)
+	}
+	if cacheErr == nil && len(etag) > 0 {
+		req.Header.Set("If-None-Match", string(etag))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if cacheErr == nil {
+			return string(kept), nil // offline: the last copy will do
+		}
+		return "", fmt.Errorf("system prompt %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cacheErr == nil:
+		return string(kept), nil
+	case resp.StatusCode >= 500 && cacheErr == nil:
+		return string(kept), nil
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("system prompt %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemotePrompt+1))
+	if err != nil {
+		if cacheErr == nil {
+			return string(kept), nil
+		}
+		return "", fmt.Errorf("system prompt %s: %v", url, err)
+	}
+	if len(data) > maxRemotePrompt {
+		return "", fmt.Errorf("system prompt %s: larger than %s", url, formatSize(maxRemotePrompt))
+	}
+
+	// A failed cache write costs a download next time, not this run
+	if err := os.MkdirAll(dir, 0o700); err == nil {
+		if err := os.WriteFile(cached, data, 0o600); err == nil {
+			if tag := resp.Header.Get("ETag"); tag != "" {
+				os.WriteFile(cached+".etag", []byte(tag), 0o600)
+			} else {
+				os.Remove(cached + ".etag")
+			}
+		}
+	}
+	return string(data), nil
+}
@@ -0,0 +1,94 @@
+// remoteprompt_test.go
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemotePrompt_Cache(t *testing.T) {
+	body, etag := "You are the team's prompt architect.", `"v1"`
+	var requests, downloads int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	dir := t.TempDir()
+	url := srv.URL + "/prompt-architect.md"
+	ctx := context.Background()
+
+	for range 2 {
+		got, err := remotePrompt(ctx, srv.Client(), dir, url)
+		if err != nil || got != body {
+			t.Fatalf("remotePrompt() = %q, %v", got, err)
+		}
+	}
+	if requests != 2 || downloads != 1 {
+		t.Errorf("%d requests, %d downloads, want the second answered from the cache", requests, downloads)
+	}
+
+	body, etag = "Updated.", `"v2"`
+	if got, _ := remotePrompt(ctx, srv.Client(), dir, url); got != "Updated." {
+		t.Errorf("after a change: %q, want the new prompt", got)
+	}
+
+	srv.Close()
+	if got, err := remotePrompt(ctx, srv.Client(), dir, url); err != nil || got != "Updated." {
+		t.Errorf("offline: %q, %v, want the cached copy", got, err)
+	}
+	if _, err := remotePrompt(ctx, srv.Client(), dir, srv.URL+"/other.md"); err == nil || !strings.Contains(err.Error(), "system prompt "+srv.URL+"/other.md") {
+		t.Errorf("offline without a copy: error = %v", err)
+	}
+}
+
+func TestRemotePrompt_Errors(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("prompt"))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	url := srv.URL + "/p.md"
+	if _, err := remotePrompt(context.Background(), srv.Client(), dir, url); err != nil {
+		t.Fatal(err)
+	}
+
+	status = http.StatusBadGateway
+	if got, err := remotePrompt(context.Background(), srv.Client(), dir, url); err != nil || got != "prompt" {
+		t.Errorf("502: %q, %v, want the cached copy", got, err)
+	}
+	status = http.StatusNotFound
+	if _, err := remotePrompt(context.Background(), srv.Client(), dir, url); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("404: error = %v, want it reported", err)
+	}
+
+	cfg := &Config{SystemPromptFile: "http://example.com/p.md"}
+	if _, err := cfg.systemPrompt(); err == nil || !strings.Contains(err.Error(), "use an https URL") {
+		t.Errorf("http URL: error = %v", err)
+	}
+}
+
+func TestResolvePromptPaths_URL(t *testing.T) {
+	dir := t.TempDir()
+	projectPath := filepath.Join(dir, projectConfigName)
+	os.WriteFile(projectPath, []byte("system_prompt_file: https://example.com/prompt-architect.md\n"), 0644)
+	cfg, err := LoadFiles([]string{projectPath}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SystemPromptFile != "https://example.com/prompt-architect.md" {
+		t.Errorf("SystemPromptFile = %q, want the URL unchanged", cfg.SystemPromptFile)
+	}
+}
@@ -0,0 +1,108 @@
+// responsecache.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultDuplicateWindow is how recent an identical request must be to
+// count as an accidental re-run.
+const defaultDuplicateWindow = 2 * time.Minute
+
+// cachedResponse is the first reply of a recent run, kept so that running
+// the same idea again by mistake does not pay for it twice.
+type cachedResponse struct {
+	Response  string    `json:"response"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// responseCacheDir holds the first replies of recent runs.
+func responseCacheDir() string {
+	return filepath.Join(cacheDir(), "responses")
+}
+
+// responseKey identifies a request by everything that shapes its reply:
+// the server, the model, the sampling settings and the messages.
+func responseKey(cfg *Config, model string, params GenerationParams, messages []Message) string {
+	data, _ := json.Marshal(struct {
+		Provider string           `json:"provider"`
+		Host     string           `json:"host"`
+		Model    string           `json:"model"`
+		Params   GenerationParams `json:"params"`
+		Messages []Message        `json:"messages"`
+	}{cfg.Provider, cfg.Host, model, params, messages})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// recentResponse returns the reply cached in dir for key if it is no
+// older than window.
+func recentResponse(dir, key string, window time.Duration, now time.Time) (cachedResponse, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return cachedResponse{}, false
+	}
+	var c cachedResponse
+	if json.Unmarshal(data, &c) != nil || now.Sub(c.CreatedAt) > window {
+		return cachedResponse{}, false
+	}
+	return c, true
+}
+
+// storeResponse caches response under key in dir and removes the replies
+// that have passed window, so the cache holds only recent runs.
+func storeResponse(dir, key, response string, window time.Duration, now time.Time) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to cache the reply: %w", err)
+	}
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && now.Sub(info.ModTime()) > window {
+				os.Remove(filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	data, err := json.Marshal(cachedResponse{Response: response, CreatedAt: now})
+	if err != nil {
+		return fmt.Errorf("failed to cache the reply: %w", err)
+	}
+	// Replies can hold whatever the idea did, so keep them private
+	if err := os.WriteFile(filepath.Join(dir, key+".json"), data, 0o600); err != nil {
+		return fmt.Errorf("failed to cache the reply: %w", err)
+	}
+	return nil
+}
+
+// duplicateReply looks for the reply to the request key in dir. A run
+// that repeats a request sent within window is most likely a mistake,
+// such as a command picked from the shell history twice: an interactive
+// run asks whether to send it again, and any other run reuses the reply.
+// It reports the reply to reuse, if any.
+func duplicateReply(dir, key string, window time.Duration, interactive bool, readLine func() (string, error), out io.Writer) (string, bool, error) {
+	c, ok := recentResponse(dir, key, window, time.Now())
+	if !ok {
+		return "", false, nil
+	}
+	ago := time.Since(c.CreatedAt).Round(time.Second)
+	if !interactive {
+		fmt.Fprintf(out, "(the same request was sent %s ago; reusing its reply)\n", ago)
+		return c.Response, true, nil
+	}
+	fmt.Fprintf(out, "The same request was sent %s ago. Send it again? [y/N] ", ago)
+	answer, err := readLine()
+	if err != nil {
+		return "", false, err
+	}
+	if a := strings.ToLower(strings.TrimSpace(answer)); a == "y" || a == "yes" {
+		return "", false, nil
+	}
+	return c.Response, true, nil
+}
@@ -0,0 +1,132 @@
+// responsecache_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseKey(t *testing.T) {
+	cfg := defaultConfig()
+	messages := []Message{{Role: "user", Content: "test idea"}}
+	key := responseKey(&cfg, "llama3.2", GenerationParams{}, messages)
+	if key != responseKey(&cfg, "llama3.2", GenerationParams{}, messages) {
+		t.Error("the same request gave two keys")
+	}
+	temp := 0.2
+	for name, other := range map[string]string{
+		"model":    responseKey(&cfg, "qwen2.5", GenerationParams{}, messages),
+		"params":   responseKey(&cfg, "llama3.2", GenerationParams{Temperature: &temp}, messages),
+		"messages": responseKey(&cfg, "llama3.2", GenerationParams{}, []Message{{Role: "user", Content: "other idea"}}),
+	} {
+		if other == key {
+			t.Errorf("a different %s gave the same key", name)
+		}
+	}
+}
+
+func TestRecentResponse(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	if err := storeResponse(dir, "old", "stale reply", time.Minute, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(dir, "old.json"), now.Add(-time.Hour), now.Add(-time.Hour))
+	if err := storeResponse(dir, "new", "fresh reply", time.Minute, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, ok := recentResponse(dir, "new", time.Minute, now.Add(30*time.Second)); !ok || c.Response != "fresh reply" {
+		t.Errorf("within the window: %+v, %v", c, ok)
+	}
+	if _, ok := recentResponse(dir, "new", time.Minute, now.Add(2*time.Minute)); ok {
+		t.Error("past the window: want no reply")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); !os.IsNotExist(err) {
+		t.Errorf("the stale reply was kept: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "new.json")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("cached reply: %v, %v, want mode 0600", info, err)
+	}
+}
+
+func TestDuplicateReply(t *testing.T) {
+	dir := t.TempDir()
+	storeResponse(dir, "key", "cached reply", time.Minute, time.Now())
+	answer := func(s string) func() (string, error) {
+		return func() (string, error) { return s, nil }
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		interactive bool
+		answer      string
+		want        string
+		wantReuse   bool
+		wantOut     string
+	}{
+		{"new request", "other", true, "", "", false, ""},
+		{"pipe", "key", false, "", "cached reply", true, "reusing its reply"},
+		{"declined", "key", true, "\n", "cached reply", true, "Send it again? [y/N]"},
+		{"confirmed", "key", true, "y\n", "", false, "Send it again? [y/N]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, reuse, err := duplicateReply(dir, tt.key, time.Minute, tt.interactive, answer(tt.answer), &out)
+			if err != nil || got != tt.want || reuse != tt.wantReuse {
+				t.Errorf("duplicateReply() = %q, %v, %v, want %q, %v", got, reuse, err, tt.want, tt.wantReuse)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestRun_DuplicateRequest(t *testing.T) {
+	dir := t.TempDir()
+	reply := "```\nfinal prompt\n```"
+
+	deps := newTestDeps(withResponses(reply), withTTY(false))
+	deps.ResponseCache = dir
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatal(err)
+	}
+
+	deps = newTestDeps(withResponses(), withTTY(false))
+	deps.ResponseCache = dir
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("repeated pipe run: %v", err)
+	}
+	if !strings.Contains(stdout(deps), "final prompt") || !strings.Contains(stderr(deps), "reusing its reply") {
+		t.Errorf("stdout = %q, stderr = %q, want the cached reply", stdout(deps), stderr(deps))
+	}
+
+	deps = newTestDeps(withResponses(reply), withTTY(false))
+	deps.ResponseCache = dir
+	if err := runWithDeps(context.Background(), &CLI{Idea: "another idea"}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if deps.Client.(*mockLLM).calls != 1 {
+		t.Error("a different idea reused the cached reply")
+	}
+
+	deps = newTestDeps(withResponses("What is it for?"), withStdin("/bye\n"))
+	deps.ResponseCache = dir
+	runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps)
+	deps = newTestDeps(withResponses("What audience?"), withStdin("y\n/bye\n"))
+	deps.ResponseCache = dir
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if deps.Client.(*mockLLM).calls != 1 || !strings.Contains(stdout(deps), "What audience?") {
+		t.Errorf("confirmed interactive run: stdout = %q, want the request sent again", stdout(deps))
+	}
+}
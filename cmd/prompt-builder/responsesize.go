@@ -0,0 +1,85 @@
+// responsesize.go
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultMaxResponseSize is max_response_size when unset: far more than
+// any prompt, so only a model that streams without end reaches it.
+const defaultMaxResponseSize = 4_000_000
+
+// ByteSize is a size in bytes written as "4MB", "512kB" or a plain number
+// of bytes in YAML.
+type ByteSize int64
+
+func (s *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	n, err := parseByteSize(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid size %q (use e.g. 4MB, 512kB or 0 for no limit)", value.Line, value.Value)
+	}
+	*s = ByteSize(n)
+	return nil
+}
+
+func (s ByteSize) MarshalYAML() (any, error) {
+	return int64(s), nil
+}
+
+// byteUnits are the suffixes parseByteSize accepts, longest first so
+// "MiB" is not read as "B".
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+	{"B", 1},
+}
+
+// parseByteSize reads a size such as "4MB" or "1048576".
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if num, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, unit = strings.TrimSpace(num), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// responseLimit stops a streamed reply once it passes max bytes, keeping
+// what arrived up to then.
+type responseLimit struct {
+	max      int
+	received strings.Builder
+}
+
+// wrap returns onToken guarded by the limit. The token that passes it is
+// cut at the limit and the stream is stopped with an errTooLarge error.
+func (l *responseLimit) wrap(onToken StreamCallback) StreamCallback {
+	return func(token string) error {
+		room := l.max - l.received.Len()
+		if len(token) <= room {
+			l.received.WriteString(token)
+			return onToken(token)
+		}
+		// Cut on a character boundary
+		token = strings.ToValidUTF8(token[:room], "")
+		l.received.WriteString(token)
+		if err := onToken(token); err != nil {
+			return err
+		}
+		return &LLMError{Kind: errTooLarge, Message: "stopped after " + formatSize(int64(l.max))}
+	}
+}
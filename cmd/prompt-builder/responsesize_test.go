@@ -0,0 +1,88 @@
+// responsesize_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1048576", 1048576},
+		{"4MB", 4_000_000},
+		{"512kB", 512_000},
+		{"1.5 GB", 1_500_000_000},
+		{"2MiB", 2 << 20},
+		{"100B", 100},
+	} {
+		if got, err := parseByteSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "lots", "-1MB", "4 TB"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded, want an error", in)
+		}
+	}
+
+	cfg, err := parseConfig([]byte("max_response_size: 8MB\n"), "")
+	if err != nil || cfg.MaxResponseSize != 8_000_000 {
+		t.Errorf("max_response_size: 8MB = %d, %v", cfg.MaxResponseSize, err)
+	}
+	if _, err := parseConfig([]byte("max_response_size: huge\n"), ""); err == nil || !strings.Contains(err.Error(), `invalid size "huge"`) {
+		t.Errorf("bad size: error = %v", err)
+	}
+}
+
+func TestResponseLimit(t *testing.T) {
+	limit := &responseLimit{max: 9}
+	var shown strings.Builder
+	onToken := limit.wrap(func(token string) error {
+		shown.WriteString(token)
+		return nil
+	})
+	for _, token := range []string{"abc", "def", "ghé", "never"} {
+		if err := onToken(token); err != nil {
+			var llmErr *LLMError
+			if !errors.As(err, &llmErr) || llmErr.Kind != errTooLarge {
+				t.Fatalf("error = %v, want errTooLarge", err)
+			}
+			break
+		}
+	}
+	// é is two bytes and does not fit whole
+	if limit.received.String() != "abcdefgh" || shown.String() != "abcdefgh" {
+		t.Errorf("received %q, shown %q, want the reply up to the limit", limit.received.String(), shown.String())
+	}
+}
+
+func TestRun_MaxResponseSize(t *testing.T) {
+	runaway := "Let me think. " + strings.Repeat("again and ", 50)
+
+	deps := newTestDeps(withResponses(runaway), withStdin("/bye\n"))
+	deps.Config.MaxResponseSize = 40
+	deps.Session = &Session{Idea: "test idea"}
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps); err != nil {
+		t.Fatalf("interactive: %v", err)
+	}
+	if !strings.Contains(stderr(deps), "passed max_response_size (40 B)") {
+		t.Errorf("stderr = %q, want the cut-off reported", stderr(deps))
+	}
+	if msgs := deps.Session.Messages; len(msgs) == 0 || msgs[len(msgs)-1].Content != runaway[:40] {
+		t.Errorf("session messages = %+v, want the partial reply kept", msgs)
+	}
+
+	deps = newTestDeps(withResponses(runaway), withTTY(false))
+	deps.Config.MaxResponseSize = 40
+	err := runWithDeps(context.Background(), &CLI{Idea: "test idea"}, deps)
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) || llmErr.Kind != errTooLarge || !strings.Contains(err.Error(), "stopped after 40 B") {
+		t.Errorf("pipe mode: error = %v, want errTooLarge", err)
+	}
+}
@@ -0,0 +1,38 @@
+// reverse_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReverseIdea(t *testing.T) {
+	idea := reverseIdea([]string{"a.md", "b.md"}, []string{"Dear team,\n", "```go\nx\n```"})
+	if !strings.HasPrefix(idea, reverseIntro) {
+		t.Errorf("idea = %q, want the intro first", idea)
+	}
+	if !strings.Contains(idea, "Example 1 (a.md):\n```\nDear team,\n```") {
+		t.Errorf("idea = %q, want the first example fenced", idea)
+	}
+	if !strings.Contains(idea, "Example 2 (b.md):\n````\n```go\nx\n```\n````") {
+		t.Errorf("idea = %q, want an example with fences inside a longer fence", idea)
+	}
+}
+
+func TestReadExamples(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "one.md"), []byte("first"), 0644)
+	os.WriteFile(filepath.Join(dir, "empty.md"), []byte(" \n"), 0644)
+
+	names, examples, err := readExamples([]string{filepath.Join(dir, "one.md")})
+	if err != nil || len(names) != 1 || names[0] != "one.md" || examples[0] != "first" {
+		t.Errorf("readExamples() = %q, %q, %v", names, examples, err)
+	}
+	for _, bad := range []string{"empty.md", "missing.md"} {
+		if _, _, err := readExamples([]string{filepath.Join(dir, bad)}); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
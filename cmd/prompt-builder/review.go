@@ -0,0 +1,337 @@
+// review.go
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// Formats understood by review.format.
+const (
+	reviewJSON  = "json"  // the draft and transcript as JSON, for custom services
+	reviewSlack = "slack" // Slack incoming webhook
+	reviewTeams = "teams" // Microsoft Teams incoming webhook
+)
+
+// Review statuses.
+const (
+	reviewPending  = "pending"
+	reviewApproved = "approved"
+	reviewRejected = "rejected"
+)
+
+// defaultReviewWait is how long /request-review waits for a decision
+// before handing the session back.
+const defaultReviewWait = 2 * time.Minute
+
+// reviewPollInterval is the time between checks for a decision.
+var reviewPollInterval = 5 * time.Second
+
+// ReviewConfig configures /request-review. Requests are posted to URL and
+// the decision is read from StatusURL, a template of the review ID such
+// as https://approvals.example.com/reviews/{{.ID}}. Only hosts in
+// AllowedHosts are ever contacted.
+type ReviewConfig struct {
+	URL          string   `yaml:"url"`
+	StatusURL    string   `yaml:"status_url"`
+	Format       string   `yaml:"format"`    // json (default), slack or teams
+	TokenEnv     string   `yaml:"token_env"` // environment variable holding a bearer token
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	Required     bool     `yaml:"required"` // /save and --save only save approved prompts
+	Wait         Duration `yaml:"wait"`     // how long /request-review waits; default 2m
+}
+
+// Review is a request for approval of a prompt and its outcome.
+type Review struct {
+	ID          string    `json:"id"`
+	Prompt      string    `json:"prompt"` // the prompt as sent for review
+	Status      string    `json:"status"`
+	Reviewer    string    `json:"reviewer,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// validate checks the review config.
+func (c ReviewConfig) validate() error {
+	switch c.Format {
+	case "", reviewJSON, reviewSlack, reviewTeams:
+	default:
+		return fmt.Errorf("review.format must be json, slack or teams, got %q", c.Format)
+	}
+	if c.Required && c.URL == "" {
+		return fmt.Errorf("review.required needs review.url")
+	}
+	if c.URL != "" && c.StatusURL == "" {
+		return fmt.Errorf("review.url needs review.status_url to read decisions from")
+	}
+	if _, err := reviewStatusURL(c, "id"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// reviewStatusURL returns where the decision on review id is read.
+func reviewStatusURL(c ReviewConfig, id string) (string, error) {
+	tmpl, err := template.New("status_url").Option("missingkey=error").Parse(c.StatusURL)
+	if err != nil {
+		return "", fmt.Errorf("review.status_url: %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, struct{ ID string }{id}); err != nil {
+		return "", fmt.Errorf("review.status_url: %v", err)
+	}
+	return b.String(), nil
+}
+
+// reviewMessage is the text posted to chat webhooks.
+func reviewMessage(r *Review, s *Session, messages []Message) string {
+	var b strings.Builder
+	idea := "a prompt"
+	if s != nil && s.Idea != "" {
+		idea = fmt.Sprintf("%q", firstLine(s.Idea, 80))
+	}
+	fmt.Fprintf(&b, "Review requested for %s (review ID %s)\n\n```\n%s\n```\n", idea, r.ID, strings.TrimRight(r.Prompt, "\n"))
+	fmt.Fprintf(&b, "\nTranscript (%d drafts):\n", draftCount(messages))
+	for _, m := range messages {
+		if m.Role != "system" {
+			fmt.Fprintf(&b, "> %s: %s\n", m.Role, firstLine(m.Content, 200))
+		}
+	}
+	return b.String()
+}
+
+// reviewBody returns the request body that asks for review r.
+func reviewBody(cfg ReviewConfig, r *Review, s *Session, messages []Message) ([]byte, error) {
+	switch cfg.Format {
+	case reviewSlack, reviewTeams:
+		return json.Marshal(map[string]string{"text": reviewMessage(r, s, messages)})
+	}
+	var transcript []Message
+	for _, m := range messages {
+		if m.Role != "system" {
+			transcript = append(transcript, m)
+		}
+	}
+	payload := map[string]any{"id": r.ID, "prompt": r.Prompt, "transcript": transcript}
+	if s != nil {
+		payload["idea"], payload["model"] = s.Idea, s.Model
+	}
+	return json.Marshal(payload)
+}
+
+// reviewRequest returns a request to an allowlisted review endpoint.
+func reviewRequest(cfg ReviewConfig, method, key, rawURL string, body []byte) (*http.Request, error) {
+	if err := checkAllowedHost("review", key, rawURL, cfg.AllowedHosts); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cfg.TokenEnv != "" {
+		token := os.Getenv(cfg.TokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("%s is not set", cfg.TokenEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// RequestReview posts prompt with the conversation that led to it to the
+// review webhook and returns the pending review.
+func RequestReview(cfg ReviewConfig, s *Session, messages []Message, prompt string) (*Review, error) {
+	id := make([]byte, 4)
+	rand.Read(id)
+	r := &Review{ID: hex.EncodeToString(id), Prompt: prompt, Status: reviewPending, RequestedAt: time.Now()}
+
+	body, err := reviewBody(cfg, r, s, messages)
+	if err != nil {
+		return nil, err
+	}
+	req, err := reviewRequest(cfg, http.MethodPost, "url", cfg.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := allowlistClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("review webhook returned %s", resp.Status)
+	}
+	return r, nil
+}
+
+// CheckReview reads the decision on r from the status URL and records
+// it. The service answers with JSON such as
+// {"status": "approved", "reviewer": "ana", "comment": "ship it"};
+// 404 means nobody has decided yet.
+func CheckReview(cfg ReviewConfig, r *Review) error {
+	statusURL, err := reviewStatusURL(cfg, r.ID)
+	if err != nil {
+		return err
+	}
+	req, err := reviewRequest(cfg, http.MethodGet, "status_url", statusURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := allowlistClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("review status returned %s", resp.Status)
+	}
+
+	var decision struct {
+		Status   string `json:"status"`
+		Reviewer string `json:"reviewer"`
+		Comment  string `json:"comment"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&decision); err != nil {
+		return fmt.Errorf("review status is not JSON: %v", err)
+	}
+	switch status := strings.ToLower(decision.Status); status {
+	case "", reviewPending:
+	case reviewApproved, reviewRejected:
+		r.Status, r.Reviewer, r.Comment = status, decision.Reviewer, decision.Comment
+	default:
+		return fmt.Errorf("review status %q is not pending, approved or rejected", decision.Status)
+	}
+	return nil
+}
+
+// decision describes the outcome of a decided review.
+func (r *Review) decision() string {
+	s := r.Status
+	if r.Reviewer != "" {
+		s += " by " + r.Reviewer
+	}
+	if r.Comment != "" {
+		s += ": " + r.Comment
+	}
+	return s
+}
+
+// reviewGate returns an error unless saving prompt from session s is
+// allowed: review.required is off, or the prompt was approved as it is.
+func reviewGate(cfg ReviewConfig, s *Session, prompt string) error {
+	if !cfg.Required {
+		return nil
+	}
+	var r *Review
+	if s != nil {
+		r = s.Review
+	}
+	switch {
+	case r == nil:
+		return fmt.Errorf("review.required is set and this prompt has not been reviewed; run /request-review")
+	case r.Prompt != prompt:
+		return fmt.Errorf("the prompt changed since review %s; run /request-review again", r.ID)
+	case r.Status == reviewPending:
+		return fmt.Errorf("review %s is still pending", r.ID)
+	case r.Status == reviewRejected:
+		return fmt.Errorf("review %s was %s", r.ID, r.decision())
+	}
+	return nil
+}
+
+// handleRequestReview implements /request-review: it sends the current
+// draft for review, unless it is already waiting for one, then waits a
+// while for the decision.
+func handleRequestReview(lastResponse string, env *CommandEnv) error {
+	if env.Review == nil || env.Review.URL == "" {
+		return fmt.Errorf("Review is not configured. Set review.url, review.status_url and review.allowed_hosts in config")
+	}
+	if env.Session == nil || env.Conv == nil {
+		return fmt.Errorf("/request-review is not available here")
+	}
+	prompt := promptbuilder.ExtractLastCodeBlock(lastResponse)
+	if prompt == "" {
+		return fmt.Errorf("No draft to review")
+	}
+
+	r := env.Session.Review
+	switch {
+	case r != nil && r.Prompt == prompt && r.Status == reviewApproved:
+		fmt.Fprintf(env.Out, "This draft is already %s\n", r.decision())
+		return nil
+	case r != nil && r.Prompt == prompt && r.Status == reviewPending:
+		fmt.Fprintf(env.Out, "Review %s is pending; checking again\n", r.ID)
+	default:
+		var err error
+		if r, err = RequestReview(*env.Review, env.Session, env.Conv.Messages, prompt); err != nil {
+			return fmt.Errorf("Review request failed: %v", err)
+		}
+		env.Session.Review = r
+		fmt.Fprintf(env.Out, "Review %s requested; waiting for a decision…\n", r.ID)
+	}
+
+	wait := time.Duration(env.Review.Wait)
+	if wait == 0 {
+		wait = defaultReviewWait
+	}
+	for deadline := time.Now().Add(wait); ; time.Sleep(reviewPollInterval) {
+		if err := CheckReview(*env.Review, r); err != nil {
+			return fmt.Errorf("Cannot check review %s: %v", r.ID, err)
+		}
+		if r.Status != reviewPending {
+			break
+		}
+		if time.Now().Add(reviewPollInterval).After(deadline) {
+			fmt.Fprintf(env.Out, "No decision yet. /request-review checks again, and /save checks before saving\n")
+			return nil
+		}
+	}
+	if r.Status == reviewApproved {
+		fmt.Fprintf(env.Out, "✓ Review %s %s. /save adds the prompt to the library\n", r.ID, r.decision())
+	} else {
+		fmt.Fprintf(env.Out, "✗ Review %s %s\n", r.ID, r.decision())
+	}
+	return nil
+}
+
+// handleSave implements /save: it saves the final prompt as --save does,
+// first checking a pending review when approval is required.
+func handleSave(lastResponse string, env *CommandEnv) error {
+	if env.Save == nil {
+		return fmt.Errorf("/save is not available here")
+	}
+	prompt := promptbuilder.ExtractLastCodeBlock(lastResponse)
+	if prompt == "" {
+		return fmt.Errorf("No code block to save")
+	}
+	if env.Review != nil && env.Review.Required && env.Session != nil {
+		if r := env.Session.Review; r != nil && r.Prompt == prompt && r.Status == reviewPending {
+			if err := CheckReview(*env.Review, r); err != nil {
+				return fmt.Errorf("Cannot check review %s: %v", r.ID, err)
+			}
+		}
+	}
+	path, err := env.Save()
+	if err != nil {
+		return fmt.Errorf("Save failed: %v", err)
+	}
+	fmt.Fprintf(env.Out, "✓ Saved to %s\n", path)
+	return nil
+}
@@ -0,0 +1,188 @@
+// review_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reviewServer is a webhook and status endpoint that decides every review
+// with decision once it has been asked checks times.
+func reviewServer(t *testing.T, decision string, checks int) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var posted []map[string]any
+	asked := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/hook":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			posted = append(posted, body)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/reviews/"):
+			if asked++; asked < checks {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(decision))
+		default:
+			http.Error(w, "unexpected", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &posted
+}
+
+func testReviewConfig(srv *httptest.Server) ReviewConfig {
+	return ReviewConfig{
+		URL:          srv.URL + "/hook",
+		StatusURL:    srv.URL + "/reviews/{{.ID}}",
+		AllowedHosts: []string{"127.0.0.1"},
+		Required:     true,
+	}
+}
+
+func TestRequestReview_Formats(t *testing.T) {
+	srv, posted := reviewServer(t, "", 1)
+	s := &Session{Idea: "support bot", Model: "llama3.2"}
+	messages := []Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "support bot"},
+		{Role: "assistant", Content: "```\nBe kind.\n```"},
+	}
+
+	cfg := testReviewConfig(srv)
+	r, err := RequestReview(cfg, s, messages, "Be kind.\n")
+	if err != nil {
+		t.Fatalf("RequestReview() error = %v", err)
+	}
+	if r.Status != reviewPending || r.ID == "" {
+		t.Errorf("review = %+v, want a pending review with an ID", r)
+	}
+	body := (*posted)[0]
+	if body["id"] != r.ID || body["prompt"] != "Be kind.\n" || body["idea"] != "support bot" || len(body["transcript"].([]any)) != 2 {
+		t.Errorf("json body = %v, want the draft and the transcript without the system prompt", body)
+	}
+
+	cfg.Format = reviewSlack
+	if r, err = RequestReview(cfg, s, messages, "Be kind.\n"); err != nil {
+		t.Fatalf("RequestReview() error = %v", err)
+	}
+	text, _ := (*posted)[1]["text"].(string)
+	for _, want := range []string{`"support bot"`, "review ID " + r.ID, "```\nBe kind.\n```", "> user: support bot"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("slack text lacks %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "system prompt") {
+		t.Errorf("slack text includes the system prompt:\n%s", text)
+	}
+}
+
+func TestRequestReview_RefusesUnlistedHost(t *testing.T) {
+	cfg := ReviewConfig{URL: "https://hooks.example.com/x", StatusURL: "https://hooks.example.com/{{.ID}}", AllowedHosts: []string{"hooks.other.com"}}
+	if _, err := RequestReview(cfg, nil, nil, "p"); err == nil || !strings.Contains(err.Error(), "review.allowed_hosts") {
+		t.Errorf("error = %v, want an allowlist error", err)
+	}
+}
+
+func TestCheckReview(t *testing.T) {
+	srv, _ := reviewServer(t, `{"status": "Rejected", "reviewer": "ana", "comment": "too vague"}`, 2)
+	cfg := testReviewConfig(srv)
+	r := &Review{ID: "abc", Status: reviewPending}
+
+	if err := CheckReview(cfg, r); err != nil || r.Status != reviewPending {
+		t.Fatalf("first check: %v, status %s, want still pending on 404", err, r.Status)
+	}
+	if err := CheckReview(cfg, r); err != nil {
+		t.Fatalf("CheckReview() error = %v", err)
+	}
+	if r.decision() != "rejected by ana: too vague" {
+		t.Errorf("decision = %q", r.decision())
+	}
+}
+
+func TestReviewGate(t *testing.T) {
+	cfg := ReviewConfig{Required: true}
+	approved := &Session{Review: &Review{ID: "abc", Prompt: "p", Status: reviewApproved}}
+	if err := reviewGate(cfg, approved, "p"); err != nil {
+		t.Errorf("approved prompt: %v", err)
+	}
+	if err := reviewGate(ReviewConfig{}, &Session{}, "p"); err != nil {
+		t.Errorf("review not required: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		s    *Session
+		want string
+	}{
+		"unreviewed": {&Session{}, "has not been reviewed"},
+		"changed":    {approved, "changed since review abc"},
+		"pending":    {&Session{Review: &Review{ID: "abc", Prompt: "q", Status: reviewPending}}, "still pending"},
+		"rejected":   {&Session{Review: &Review{ID: "abc", Prompt: "q", Status: reviewRejected, Reviewer: "ana"}}, "was rejected by ana"},
+	} {
+		if err := reviewGate(cfg, tc.s, "q"); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestReviewConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  ReviewConfig
+		want string
+	}{
+		{ReviewConfig{Format: "discord"}, "review.format"},
+		{ReviewConfig{Required: true}, "review.required needs review.url"},
+		{ReviewConfig{URL: "https://a"}, "review.status_url"},
+		{ReviewConfig{URL: "https://a", StatusURL: "https://a/{{.Name}}"}, "review.status_url"},
+	} {
+		if err := tc.cfg.validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: error = %v, want %q", tc.cfg, err, tc.want)
+		}
+	}
+}
+
+func TestRun_RequestReviewThenSave(t *testing.T) {
+	reviewPollInterval = time.Millisecond
+	t.Cleanup(func() { reviewPollInterval = 5 * time.Second })
+	srv, posted := reviewServer(t, `{"status": "approved", "reviewer": "ana"}`, 3)
+	dir := t.TempDir()
+
+	deps := newTestDeps(withResponses("```\nBe kind.\n```"), withStdin("/save\n/request-review\n/save\n/bye\n"), withTTY(true))
+	deps.Config.Review = testReviewConfig(srv)
+	deps.Config.Save.Dir = dir
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support bot", NoCopy: true}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(stderr(deps), "Save failed: not saved: review.required is set") {
+		t.Errorf("stderr = %q, want the first /save refused", stderr(deps))
+	}
+	if len(*posted) != 1 {
+		t.Errorf("posted %d review requests, want 1", len(*posted))
+	}
+	if !strings.Contains(stdout(deps), "approved by ana. /save adds the prompt to the library") {
+		t.Errorf("stdout = %q, want the approval", stdout(deps))
+	}
+	saved, _ := os.ReadFile(filepath.Join(dir, "support-bot.md"))
+	if string(saved) != "Be kind.\n" {
+		t.Errorf("saved %q, want the approved prompt", saved)
+	}
+}
+
+func TestRun_SaveFlagNeedsApproval(t *testing.T) {
+	srv, _ := reviewServer(t, "", 1)
+	deps := newTestDeps(withResponses("```\nBe kind.\n```"), withTTY(false))
+	deps.Config.Review = testReviewConfig(srv)
+	deps.Config.Save.Dir = t.TempDir()
+	err := runWithDeps(context.Background(), &CLI{Idea: "support bot", Quiet: QuietPrompt, Save: true}, deps)
+	if err == nil || !strings.Contains(err.Error(), "has not been reviewed") {
+		t.Errorf("error = %v, want --save refused without an approved review", err)
+	}
+}
@@ -0,0 +1,314 @@
+// save.go
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// SaveConfig decides where --save writes the final prompt. A prompt whose
+// category matches an entry in Categories goes to that entry's dir and
+// file; anything else uses Dir and File.
+type SaveConfig struct {
+	Dir        string               `yaml:"dir"`   // default: the current directory
+	File       string               `yaml:"file"`  // filename template, default defaultSaveFile
+	Title      string               `yaml:"title"` // source of {{.Slug}}: idea (default) or model
+	Categories map[string]SaveRoute `yaml:"categories"`
+}
+
+// SaveRoute is where prompts of one category are saved. Empty fields fall
+// back to the top-level save settings.
+type SaveRoute struct {
+	Dir      string   `yaml:"dir"`
+	File     string   `yaml:"file"`
+	Keywords []string `yaml:"keywords"` // added to the built-in ones for the category
+}
+
+const defaultSaveFile = "{{.Slug}}.md"
+
+// uncategorized is the category of a prompt no route matches.
+const uncategorized = "general"
+
+// categoryKeywords are the built-in words that detect a category.
+var categoryKeywords = map[string][]string{
+	"coding": {"code", "coding", "programming", "function", "bug", "debug", "refactor", "api",
+		"unit test", "code review", "pull request", "sql", "python", "golang", "javascript", "typescript", "compiler"},
+	"support": {"support", "customer", "customers", "ticket", "tickets", "help desk", "helpdesk",
+		"complaint", "refund", "troubleshoot", "troubleshooting", "faq"},
+	"marketing": {"marketing", "campaign", "brand", "seo", "ad copy", "ads", "copywriting",
+		"newsletter", "social media", "landing page", "tagline", "slogan"},
+}
+
+// SaveName holds the fields available to filename templates.
+type SaveName struct {
+	Category string
+	Slug     string // the idea, lowercased and hyphenated
+	Date     string // YYYY-MM-DD
+	Model    string
+}
+
+// parseSaveFile parses a filename template and checks it against the
+// fields it can use, so mistakes show up when the config is loaded.
+func parseSaveFile(text string) (*template.Template, error) {
+	tmpl, err := template.New("file").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, SaveName{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Values for save.title.
+const (
+	titleFromIdea  = "idea"
+	titleFromModel = "model"
+)
+
+// validate checks every filename template in c.
+func (c SaveConfig) validate() error {
+	if c.Title != "" && c.Title != titleFromIdea && c.Title != titleFromModel {
+		return fmt.Errorf("save.title must be idea or model, got %q", c.Title)
+	}
+	if _, err := parseSaveFile(c.File); err != nil {
+		return fmt.Errorf("save.file: %v", err)
+	}
+	for name, route := range c.Categories {
+		if _, err := parseSaveFile(route.File); err != nil {
+			return fmt.Errorf("save.categories.%s.file: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// normalizeWords lowercases s and reduces it to words separated by single
+// spaces, with a space at each end so whole words can be matched.
+func normalizeWords(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	})
+	return " " + strings.Join(words, " ") + " "
+}
+
+// detectCategory returns the configured category whose keywords occur
+// most often in text, or uncategorized when none occur. Ties go to the
+// name that sorts first.
+func detectCategory(text string, routes map[string]SaveRoute) string {
+	text = normalizeWords(text)
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	best, bestScore := uncategorized, 0
+	for _, name := range names {
+		score := 0
+		for _, kw := range slices.Concat(categoryKeywords[name], routes[name].Keywords) {
+			if kw = normalizeWords(kw); kw != "  " && strings.Contains(text, kw) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+	return best
+}
+
+// slugify turns s into a short lowercase filename part.
+func slugify(s string) string {
+	slug := strings.ReplaceAll(strings.TrimSpace(normalizeWords(s)), " ", "-")
+	const maxSlug = 60
+	if len(slug) > maxSlug {
+		slug = slug[:maxSlug]
+		if i := strings.LastIndex(slug, "-"); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	if slug == "" {
+		return "prompt"
+	}
+	return slug
+}
+
+// titlePrompt asks the model to name a prompt.
+const titlePrompt = "Suggest a title of at most five words for the prompt below, to use as its filename. Reply with the title only.\n\n"
+
+// suggestTitle asks client for a short title for prompt.
+func suggestTitle(ctx context.Context, client LLMClient, prompt string) (string, error) {
+	messages := []Message{{Role: "user", Content: titlePrompt + prompt}}
+	reply, err := client.ChatStream(ctx, messages, func(string) error { return nil })
+	if err != nil {
+		return "", fmt.Errorf("LLM request failed: %w", err)
+	}
+	title, _, _ := strings.Cut(strings.TrimSpace(reply), "\n")
+	return strings.Trim(title, "\"'`*# "), nil
+}
+
+// createUnique creates path for writing, or, if it exists, the first free
+// name with -2, -3 and so on before the extension.
+func createUnique(path string) (*os.File, string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; n <= 1000; n++ {
+		candidate := path
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, candidate, err
+	}
+	return nil, "", fmt.Errorf("%s: too many files with this name", path)
+}
+
+// savePath returns where a prompt with the given fields is saved.
+func savePath(cfg SaveConfig, name SaveName) (string, error) {
+	route := cfg.Categories[name.Category]
+	dir := cmp.Or(route.Dir, cfg.Dir, ".")
+	file := cmp.Or(route.File, cfg.File, defaultSaveFile)
+
+	tmpl, err := parseSaveFile(file)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, name); err != nil {
+		return "", err
+	}
+	return filepath.Join(ExpandPath(dir), b.String()), nil
+}
+
+// saveTabPrompt saves the final prompt of tab, with header and footer, as
+// --save does. With save.title: model, client names the file; if that
+// fails the idea is used. With review.required, only an approved prompt
+// is saved.
+func saveTabPrompt(ctx context.Context, cfg *Config, client LLMClient, tab *Tab, now time.Time) (string, error) {
+	final := promptbuilder.ExtractLastCodeBlock(tab.Response)
+	if err := reviewGate(cfg.Review, tab.Session, final); err != nil {
+		return "", fmt.Errorf("not saved: %v", err)
+	}
+	prompt, err := finalPrompt(tab.Session, tab.Response, func(p string) (string, error) {
+		return stampPrompt(cfg, tab.Session, now, p)
+	})
+	if err != nil {
+		return "", withKind(ErrConfig, fmt.Errorf("invalid config: %v", err))
+	}
+	title := ""
+	if cfg.Save.Title == titleFromModel && client != nil {
+		title, _ = suggestTitle(ctx, client, final)
+	}
+	path, err := savePrompt(cfg, tab.Session, title, prompt, now)
+	if err != nil {
+		return "", fmt.Errorf("cannot save prompt: %v", err)
+	}
+	return path, nil
+}
+
+// outputTabPrompt writes the final prompt of tab, with header and footer,
+// to path for --output. Like saveTabPrompt, it honours review.required.
+func outputTabPrompt(cfg *Config, tab *Tab, path string, force bool, now time.Time) error {
+	if err := reviewGate(cfg.Review, tab.Session, promptbuilder.ExtractLastCodeBlock(tab.Response)); err != nil {
+		return fmt.Errorf("not written: %v", err)
+	}
+	prompt, err := finalPrompt(tab.Session, tab.Response, func(p string) (string, error) {
+		return stampPrompt(cfg, tab.Session, now, p)
+	})
+	if err != nil {
+		return withKind(ErrConfig, fmt.Errorf("invalid config: %v", err))
+	}
+	if err := writeOutput(ExpandPath(path), prompt, force); err != nil {
+		return fmt.Errorf("cannot write prompt: %v", err)
+	}
+	return nil
+}
+
+// writeOutput writes prompt to path. It writes a temporary file next to
+// path and moves it into place, so nothing ever reads half a prompt, and
+// it replaces an existing file only when force is set.
+func writeOutput(path, prompt string, force bool) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if !strings.HasSuffix(prompt, "\n") {
+		prompt += "\n"
+	}
+	if _, err := tmp.WriteString(prompt); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if force {
+		return os.Rename(tmp.Name(), path)
+	}
+	// A link fails if path exists, where a rename would replace it
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+		}
+		return err
+	}
+	return nil
+}
+
+// savePrompt writes prompt, which came from session s, to the file the
+// save config routes it to, and returns the path. The filename comes from
+// title, or the idea when title is empty. It never overwrites: a taken
+// name gets a -2, -3 suffix.
+func savePrompt(cfg *Config, s *Session, title, prompt string, now time.Time) (string, error) {
+	name := SaveName{Date: now.Format("2006-01-02")}
+	idea := ""
+	if s != nil {
+		idea, name.Model = s.Idea, s.Model
+	}
+	name.Slug = slugify(cmp.Or(title, idea))
+	name.Category = detectCategory(idea+"\n"+prompt, cfg.Save.Categories)
+
+	path, err := savePath(cfg.Save, name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	f, path, err := createUnique(path)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(prompt, "\n") {
+		prompt += "\n"
+	}
+	if _, err := f.WriteString(prompt); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
@@ -0,0 +1,248 @@
+// save_test.go
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectCategory(t *testing.T) {
+	routes := map[string]SaveRoute{
+		"coding":    {},
+		"support":   {},
+		"marketing": {},
+		"legal":     {Keywords: []string{"contract", "nda"}},
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"a code reviewer for Python pull requests", "coding"},
+		{"reply to customer refund tickets", "support"},
+		{"taglines for a social media campaign", "marketing"},
+		{"summarize an NDA", "legal"},
+		{"a haiku about autumn", uncategorized},
+		// Whole words only: "adsorption" is not "ads"
+		{"explain adsorption", uncategorized},
+	}
+	for _, tt := range tests {
+		if got := detectCategory(tt.text, routes); got != tt.want {
+			t.Errorf("detectCategory(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	// Only configured categories are candidates
+	if got := detectCategory("debug this function", map[string]SaveRoute{"support": {}}); got != uncategorized {
+		t.Errorf("unrouted category detected: %q", got)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"A code reviewer for Go!":   "a-code-reviewer-for-go",
+		"  --  ":                    "prompt",
+		strings.Repeat("word ", 20): "word-word-word-word-word-word-word-word-word-word-word-word",
+	}
+	for in, want := range tests {
+		if got := slugify(in); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSavePath(t *testing.T) {
+	cfg := SaveConfig{
+		Dir: "/prompts",
+		Categories: map[string]SaveRoute{
+			"coding":  {Dir: "/prompts/eng", File: "{{.Date}}-{{.Slug}}.md"},
+			"support": {File: "support/{{.Slug}}.txt"},
+		},
+	}
+	name := SaveName{Slug: "idea", Date: "2026-10-16"}
+	tests := map[string]string{
+		"coding":      "/prompts/eng/2026-10-16-idea.md",
+		"support":     "/prompts/support/idea.txt",
+		uncategorized: "/prompts/idea.md",
+	}
+	for category, want := range tests {
+		name.Category = category
+		got, err := savePath(cfg, name)
+		if err != nil || got != filepath.FromSlash(want) {
+			t.Errorf("savePath(%s) = %q, %v, want %q", category, got, err, want)
+		}
+	}
+}
+
+func TestLoadConfig_SaveTemplates(t *testing.T) {
+	for config, wantErr := range map[string]bool{
+		"save:\n  file: \"{{.Category}}/{{.Slug}}.md\"\n":                       false,
+		"save:\n  file: \"{{.Title}}.md\"\n":                                    true,
+		"save:\n  categories:\n    coding:\n      file: \"{{.Slug\"\n":          true,
+		"save:\n  categories:\n    coding:\n      dir: ~/prompts/engineering\n": false,
+		"save:\n  title: model\n":                                               false,
+		"save:\n  title: random\n":                                              true,
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(path, []byte(config), 0644)
+		if _, err := LoadConfig(path); (err != nil) != wantErr {
+			t.Errorf("LoadConfig(%q) error = %v, wantErr %v", config, err, wantErr)
+		}
+	}
+}
+
+func TestSavePrompt(t *testing.T) {
+	dir := t.TempDir()
+	cfg := defaultConfig()
+	cfg.Save = SaveConfig{Dir: dir, Categories: map[string]SaveRoute{"coding": {File: "coding/{{.Slug}}.md"}}}
+	s := &Session{Idea: "Review Go pull requests"}
+
+	path, err := savePrompt(&cfg, s, "", "You review code.", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "coding", "review-go-pull-requests.md"); path != want {
+		t.Errorf("path = %q, want %q", path, want)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "You review code.\n" {
+		t.Errorf("saved %q", data)
+	}
+
+	// A taken name gets a suffix instead of being overwritten
+	for _, want := range []string{"review-go-pull-requests-2.md", "review-go-pull-requests-3.md"} {
+		path, err := savePrompt(&cfg, s, "", "You review code again.", time.Now())
+		if err != nil || path != filepath.Join(dir, "coding", want) {
+			t.Errorf("path = %q, %v, want %s", path, err, want)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "coding", "review-go-pull-requests.md")); string(data) != "You review code.\n" {
+		t.Errorf("first file changed to %q", data)
+	}
+
+	path, err = savePrompt(&cfg, s, "Go PR Reviewer", "You review code.", time.Now())
+	if err != nil || filepath.Base(path) != "go-pr-reviewer.md" {
+		t.Errorf("path with title = %q, %v, want go-pr-reviewer.md", path, err)
+	}
+}
+
+func TestCreateUnique_NoExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes")
+	for _, want := range []string{path, path + "-2"} {
+		f, got, err := createUnique(path)
+		if err != nil || got != want {
+			t.Fatalf("createUnique() = %q, %v, want %q", got, err, want)
+		}
+		f.Close()
+	}
+}
+
+func TestSuggestTitle(t *testing.T) {
+	title, err := suggestTitle(context.Background(), &mockLLM{responses: []string{"\"Go PR Reviewer\"\nA title for the prompt."}}, "prompt")
+	if err != nil || title != "Go PR Reviewer" {
+		t.Errorf("suggestTitle(%q) = %q, %v, want the first line unquoted", "prompt", title, err)
+	}
+}
+
+func TestRunWithDeps_Save(t *testing.T) {
+	dir := t.TempDir()
+	deps := newTestDeps(withResponses("```\nfinal prompt\n```"), withTTY(false))
+	deps.Config.Save.Dir = dir
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "a haiku", Quiet: QuietPrompt, Save: true}, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a-haiku.md")); string(data) != "final prompt\n" {
+		t.Errorf("saved %q", data)
+	}
+	if !strings.Contains(stderr(deps), "Saved to") {
+		t.Errorf("stderr = %q, want the saved path", stderr(deps))
+	}
+}
+
+func TestRunWithDeps_SaveModelTitle(t *testing.T) {
+	dir := t.TempDir()
+	deps := newTestDeps(withResponses("```\nfinal prompt\n```", "Haiku Writer"), withTTY(false))
+	deps.Config.Save = SaveConfig{Dir: dir, Title: titleFromModel}
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "a haiku", Quiet: QuietPrompt, Save: true}, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "haiku-writer.md")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunWithDeps_SaveInteractive(t *testing.T) {
+	dir := t.TempDir()
+	deps := newTestDeps(withResponses("```\nfinal prompt\n```"), withStdin("/quit\n"))
+	deps.Config.Save.Dir = dir
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "a haiku", Save: true}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a-haiku.md")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prompts", "review.md")
+	if err := writeOutput(path, "first", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeOutput(path, "second", false); err == nil || !strings.Contains(err.Error(), "use --force") {
+		t.Errorf("existing file: error = %v, want a hint at --force", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first\n" {
+		t.Errorf("refused write changed the file to %q", data)
+	}
+	if err := writeOutput(path, "second", true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "second\n" {
+		t.Errorf("forced write left %q", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestRunWithDeps_Output(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt.md")
+	deps := newTestDeps(withResponses("```\nfinal prompt\n```"), withTTY(false))
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "a haiku", Quiet: QuietPrompt, Output: path}, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "final prompt\n" {
+		t.Errorf("wrote %q", data)
+	}
+	if strings.TrimSpace(stdout(deps)) != "final prompt" || !strings.Contains(stderr(deps), "Wrote "+path) {
+		t.Errorf("stdout = %q, stderr = %q, want the prompt printed as well", stdout(deps), stderr(deps))
+	}
+
+	deps = newTestDeps(withResponses("```\nfinal prompt\n```"), withTTY(false))
+	err = runWithDeps(context.Background(), &CLI{Idea: "a haiku", Quiet: QuietSilent, Output: path}, deps)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second run: error = %v, want no-clobber", err)
+	}
+}
+
+func TestRunWithDeps_OutputInteractive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt.md")
+	deps := newTestDeps(withResponses("```\nfinal prompt\n```"), withStdin("/quit\n"))
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "a haiku", Output: path}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "final prompt\n" {
+		t.Errorf("wrote %q", data)
+	}
+}
@@ -0,0 +1,147 @@
+// schema.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the version of the JSON formats the tool writes. Raise
+// it when a change could break a reader: a field removed, renamed or
+// given another type. New optional fields do not need a new version.
+const schemaVersion = 1
+
+// schemaID identifies this version of the schema.
+var schemaID = fmt.Sprintf("urn:prompt-builder:schema:%d", schemaVersion)
+
+// schemaFormats are the JSON documents the tool writes, by the names
+// schema takes. Each schema is derived from the type that is encoded, so
+// it cannot drift from the output.
+var schemaFormats = []struct {
+	name, desc string
+	t          reflect.Type
+}{
+	{"session", "A saved session, as stored in the sessions directory and read by --resume", reflect.TypeFor[Session]()},
+	{"split_prompt", "The prompt --split prints and copies", reflect.TypeFor[SplitPrompt]()},
+	{"experiment_result", "One line of an experiment's results file (JSON Lines)", reflect.TypeFor[experimentResult]()},
+	{"debug_stream", "One line of a --debug-stream recording (JSON Lines)", reflect.TypeFor[streamRecord]()},
+	{"webhook", "The body posted to webhooks when a session completes", reflect.TypeFor[webhookPayload]()},
+	{"live_event", "The data of one server-sent event of the --live view", reflect.TypeFor[liveEvent]()},
+}
+
+// typeSchema returns the JSON Schema of the JSON encoding of t.
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]any)
+		var required []string
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			s := typeSchema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+				switch f.Type.Kind() {
+				case reflect.Pointer, reflect.Slice, reflect.Map:
+					s["type"] = []any{s["type"], "null"} // nil is encoded as null
+				}
+			}
+			properties[name] = s
+		}
+		s := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	}
+	return map[string]any{}
+}
+
+// schemaDocument returns the schema of the named format, or of every
+// format under $defs when name is empty.
+func schemaDocument(name string) (map[string]any, error) {
+	doc := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"version": schemaVersion,
+	}
+	if name == "" {
+		defs := make(map[string]any)
+		for _, f := range schemaFormats {
+			s := typeSchema(f.t)
+			s["description"] = f.desc
+			defs[f.name] = s
+		}
+		doc["$id"], doc["title"], doc["$defs"] = schemaID, "prompt-builder JSON formats", defs
+		return doc, nil
+	}
+
+	var names []string
+	for _, f := range schemaFormats {
+		if f.name == name {
+			for k, v := range typeSchema(f.t) {
+				doc[k] = v
+			}
+			doc["$id"], doc["title"] = schemaID+":"+name, f.desc
+			return doc, nil
+		}
+		names = append(names, f.name)
+	}
+	return nil, fmt.Errorf("unknown format %q; choose one of %s", name, strings.Join(names, ", "))
+}
+
+// runSchema implements the schema subcommand.
+func runSchema(args []string, out, errOut io.Writer) int {
+	if len(args) > 1 || (len(args) == 1 && strings.HasPrefix(args[0], "-")) {
+		fmt.Fprintln(errOut, "Usage: prompt-builder schema [format]")
+		return ExitConfigError
+	}
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	doc, err := schemaDocument(name)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return ExitConfigError
+	}
+	out.Write(b.Bytes())
+	return ExitSuccess
+}
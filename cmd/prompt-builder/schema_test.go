@@ -0,0 +1,131 @@
+// schema_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSchema_Golden fails when a JSON format changes, so that the change
+// is made on purpose: accept it with -update, and raise schemaVersion if
+// it could break a reader.
+func TestSchema_Golden(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := runSchema(nil, &out, &errOut); code != ExitSuccess {
+		t.Fatalf("code %d: %s", code, errOut.String())
+	}
+	path := filepath.Join("testdata", "schema.json")
+	if *updateTranscripts {
+		os.WriteFile(path, out.Bytes(), 0644)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != string(want) {
+		t.Errorf("the JSON formats changed; run go test -run TestSchema_Golden -update to accept, and raise schemaVersion if a reader could break\n%s", out.String())
+	}
+}
+
+// checkSchema reports the keys of v that s does not describe and the
+// required keys v lacks, recursing into objects and arrays.
+func checkSchema(t *testing.T, path string, s map[string]any, v any) {
+	t.Helper()
+	switch v := v.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		if extra, ok := s["additionalProperties"].(map[string]any); ok {
+			for k, item := range v {
+				checkSchema(t, path+"."+k, extra, item)
+			}
+			return
+		}
+		for k, item := range v {
+			p, ok := props[k].(map[string]any)
+			if !ok {
+				t.Errorf("%s.%s is not in the schema", path, k)
+				continue
+			}
+			checkSchema(t, path+"."+k, p, item)
+		}
+		required, _ := s["required"].([]string)
+		for _, k := range required {
+			if _, ok := v[k]; !ok {
+				t.Errorf("%s lacks required %s", path, k)
+			}
+		}
+	case []any:
+		items, _ := s["items"].(map[string]any)
+		for i, item := range v {
+			checkSchema(t, fmt.Sprintf("%s[%d]", path, i), items, item)
+		}
+	}
+}
+
+func TestSchema_MatchesOutput(t *testing.T) {
+	now := time.Now()
+	session := &Session{
+		ID: "2024-06-01-1", Idea: "a code reviewer", Model: "llama3.2", CreatedAt: now,
+		Messages:    []Message{{Role: "user", Content: "a code reviewer"}, {Role: "assistant", Content: "```\nReview.\n```"}},
+		Notes:       []Note{{Draft: 1, Text: "good", CreatedAt: now}},
+		Locks:       []Lock{{Name: "Role", Text: "## Role"}},
+		Fit:         &Fit{Model: "gpt-4o", Slot: "system", Budget: 1500, Encoding: "o200k_base"},
+		Review:      &Review{ID: "r1", Prompt: "Review.", Status: "approved", RequestedAt: now},
+		Environment: &Environment{Version: "1.0.0", OS: "linux/amd64", Provider: "openai-compatible"},
+	}
+	for name, v := range map[string]any{
+		"session":      session,
+		"webhook":      completionPayload(&Tab{Session: session, Conv: &Conversation{Messages: session.Messages}}, "Review.", now),
+		"live_event":   liveEvent{Type: "token", Content: "Re"},
+		"debug_stream": streamRecord{Stream: 1, Data: "data: {}", Ms: 12},
+	} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded any
+		json.Unmarshal(data, &decoded)
+		s, err := schemaDocument(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSchema(t, name, s, decoded)
+	}
+}
+
+func TestRunSchema(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := runSchema([]string{"split_prompt"}, &out, &errOut); code != ExitSuccess {
+		t.Fatalf("code %d: %s", code, errOut.String())
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["$id"] != schemaID+":split_prompt" || doc["type"] != "object" || !slices.Equal(toStrings(doc["required"]), []string{"system", "user"}) {
+		t.Errorf("schema split_prompt = %s", out.String())
+	}
+
+	errOut.Reset()
+	if code := runSchema([]string{"transcript"}, &out, &errOut); code != ExitConfigError || !strings.Contains(errOut.String(), "choose one of session, split_prompt") {
+		t.Errorf("unknown format: code %d, stderr %q", code, errOut.String())
+	}
+}
+
+// toStrings converts a decoded JSON array of strings.
+func toStrings(v any) []string {
+	var s []string
+	items, _ := v.([]any)
+	for _, item := range items {
+		str, _ := item.(string)
+		s = append(s, str)
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+// scrub.go
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ScrubConfig configures /export public, which prepares a session for
+// sharing outside the organization, such as in a talk or a blog post.
+type ScrubConfig struct {
+	Terms     map[string]string `yaml:"terms"`      // sensitive terms and what to show instead
+	KeepHosts []string          `yaml:"keep_hosts"` // public hosts whose URLs are kept
+}
+
+// Placeholders for what a public export replaces.
+const (
+	scrubURL      = "https://example.com"
+	scrubEmail    = "someone@example.com"
+	scrubUser     = "user"
+	scrubHost     = "host"
+	scrubRedacted = "[redacted]"
+)
+
+var (
+	urlPattern   = regexp.MustCompile(`\b(?:https?|ftp|ssh|git)://[^\s<>"'` + "`" + `)\]]+`)
+	emailPattern = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+)
+
+// scrubRule replaces the matches of a pattern.
+type scrubRule struct {
+	re   *regexp.Regexp
+	with string
+}
+
+// Scrubber removes internal names, URLs and the configured terms from text.
+type Scrubber struct {
+	rules     []scrubRule
+	keepHosts []string
+}
+
+// newScrubber builds a Scrubber from cfg. Besides the configured terms it
+// removes the names of this machine: the home directory, user name and
+// hostname, which show up in pasted paths and logs.
+func newScrubber(cfg ScrubConfig) *Scrubber {
+	s := &Scrubber{keepHosts: cfg.KeepHosts}
+
+	// Longest first, so "Acme Cloud" is replaced before "Acme"
+	terms := make([]string, 0, len(cfg.Terms))
+	for term := range cfg.Terms {
+		if strings.TrimSpace(term) != "" {
+			terms = append(terms, term)
+		}
+	}
+	slices.SortFunc(terms, func(a, b string) int { return cmp.Or(len(b)-len(a), strings.Compare(a, b)) })
+	for _, term := range terms {
+		s.rules = append(s.rules, scrubRule{wordPattern(term), cmp.Or(cfg.Terms[term], scrubRedacted)})
+	}
+
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		s.rules = append(s.rules, scrubRule{regexp.MustCompile(regexp.QuoteMeta(home)), "~"})
+	}
+	// Short and generic names would match ordinary words, as in root cause
+	generic := []string{"root", "admin", "user", "localhost"}
+	if u, err := user.Current(); err == nil && len(u.Username) >= 3 && !slices.Contains(generic, u.Username) {
+		s.rules = append(s.rules, scrubRule{wordPattern(u.Username), scrubUser})
+	}
+	if h, err := os.Hostname(); err == nil && len(h) >= 3 && !slices.Contains(generic, h) {
+		s.rules = append(s.rules, scrubRule{wordPattern(h), scrubHost})
+	}
+	return s
+}
+
+// wordPattern matches term case-insensitively, not inside a longer word.
+func wordPattern(term string) *regexp.Regexp {
+	p := regexp.QuoteMeta(term)
+	if isWordByte(term[0]) {
+		p = `\b` + p
+	}
+	if isWordByte(term[len(term)-1]) {
+		p += `\b`
+	}
+	return regexp.MustCompile("(?i)" + p)
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// Scrub returns text with URLs, email addresses, the configured terms and
+// the names of this machine replaced. URLs on keep_hosts stay.
+func (s *Scrubber) Scrub(text string) string {
+	text = urlPattern.ReplaceAllStringFunc(text, func(match string) string {
+		// Punctuation after a URL ends the sentence, not the URL
+		raw := strings.TrimRight(match, ".,;:!?")
+		if u, err := url.Parse(raw); err == nil && s.keepHost(u.Hostname()) {
+			return match
+		}
+		return scrubURL + match[len(raw):]
+	})
+	text = emailPattern.ReplaceAllString(text, scrubEmail)
+	for _, r := range s.rules {
+		text = r.re.ReplaceAllLiteralString(text, r.with)
+	}
+	return text
+}
+
+// keepHost reports whether host or a domain it is under is in keep_hosts.
+func (s *Scrubber) keepHost(host string) bool {
+	for _, h := range s.keepHosts {
+		if strings.EqualFold(host, h) || strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScrubTranscript returns t ready to share publicly: its text scrubbed and
+// the session ID and environment left out.
+func (s *Scrubber) ScrubTranscript(t Transcript) Transcript {
+	t.ID, t.Environment = "", nil
+	t.Idea = s.Scrub(t.Idea)
+	t.FinalPrompt = s.Scrub(t.FinalPrompt)
+	messages := make([]TranscriptMessage, len(t.Messages))
+	for i, m := range t.Messages {
+		notes := make([]Note, len(m.Notes))
+		for j, n := range m.Notes {
+			n.Text = s.Scrub(n.Text)
+			notes[j] = n
+		}
+		messages[i] = TranscriptMessage{Role: m.Role, Content: s.Scrub(m.Content), Notes: notes}
+	}
+	t.Messages = messages
+	return t
+}
+
+// validate rejects terms a public export could not find.
+func (c ScrubConfig) validate() error {
+	for term := range c.Terms {
+		if strings.TrimSpace(term) == "" {
+			return fmt.Errorf("scrub.terms: a term is empty")
+		}
+	}
+	return nil
+}
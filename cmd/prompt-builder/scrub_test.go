@@ -0,0 +1,84 @@
+// scrub_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrubber_Scrub(t *testing.T) {
+	s := newScrubber(ScrubConfig{
+		Terms:     map[string]string{"Acme": "Example Inc", "Acme Cloud": "the cloud", "Project Falcon": ""},
+		KeepHosts: []string{"go.dev"},
+	})
+	tests := []struct{ in, want string }{
+		{"You support Acme Cloud customers for Acme.", "You support the cloud customers for Example Inc."},
+		{"acme's handbook, not Acmetech", "Example Inc's handbook, not Acmetech"},
+		{"Ship project falcon on time", "Ship [redacted] on time"},
+		{"See https://wiki.acme.internal/runbooks/oncall?team=sre.", "See https://example.com."},
+		{"Follow https://go.dev/doc/effective_go and https://pkg.go.dev/fmt", "Follow https://go.dev/doc/effective_go and https://pkg.go.dev/fmt"},
+		{"Escalate to jane.doe@acme.com", "Escalate to someone@example.com"},
+	}
+	for _, tt := range tests {
+		if got := s.Scrub(tt.in); got != tt.want {
+			t.Errorf("Scrub(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScrubber_MachineNames(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil || len(home) < 2 {
+		t.Skip("no home directory")
+	}
+	got := newScrubber(ScrubConfig{}).Scrub("Logs are in " + filepath.Join(home, "logs", "app.log"))
+	if strings.Contains(got, home) || !strings.Contains(got, filepath.Join("~", "logs", "app.log")) {
+		t.Errorf("home directory not replaced: %q", got)
+	}
+}
+
+func TestScrubber_ScrubTranscript(t *testing.T) {
+	s := &Session{ID: "2024-06-01-1", Idea: "an Acme support bot", Environment: &Environment{Version: "1.0.0"},
+		Notes: []Note{{Draft: 1, Text: "ask Acme legal"}}}
+	msgs := []Message{
+		{Role: "user", Content: "an Acme support bot"},
+		{Role: "assistant", Content: "```\nYou answer for Acme.\n```"},
+	}
+	original := NewTranscript(s, msgs, time.Now())
+	got := newScrubber(ScrubConfig{Terms: map[string]string{"acme": "Example Inc"}}).ScrubTranscript(original)
+
+	if got.ID != "" || got.Environment != nil {
+		t.Errorf("session ID and environment must be left out: %+v", got)
+	}
+	if got.Idea != "an Example Inc support bot" || strings.TrimSpace(got.FinalPrompt) != "You answer for Example Inc." ||
+		got.Messages[1].Notes[0].Text != "ask Example Inc legal" {
+		t.Errorf("ScrubTranscript() = %+v", got)
+	}
+	if original.Messages[1].Notes[0].Text != "ask Acme legal" {
+		t.Error("the original transcript must not be modified")
+	}
+}
+
+func TestHandleCommand_ExportPublic(t *testing.T) {
+	t.Chdir(t.TempDir())
+	conv := NewConversation("system")
+	conv.AddUserMessage("an Acme support bot, see https://wiki.acme.internal/support")
+	conv.AddAssistantMessage("```\nYou answer for Acme.\n```")
+	env := &CommandEnv{Conv: conv, Session: &Session{ID: "s1", Idea: "an Acme support bot"}, Out: &bytes.Buffer{},
+		Scrub: &ScrubConfig{Terms: map[string]string{"Acme": "Example Inc"}}}
+
+	if _, err := HandleCommand("/export public", "", env); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("an-example-inc-support-bot-public.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page := string(data); strings.Contains(page, "Acme") || strings.Contains(page, "acme.internal") || !strings.Contains(page, "You answer for Example Inc.") {
+		t.Errorf("public export leaks or lost text:\n%s", page)
+	}
+}
@@ -0,0 +1,251 @@
+// sentencepiece.go
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// SentencePiece piece types, from sentencepiece_model.proto, that text
+// is split into. Control, unknown, unused and byte pieces are not.
+const (
+	spNormal      = 1
+	spUserDefined = 4
+)
+
+// spSpace is the character SentencePiece puts in place of spaces.
+const spSpace = "▁"
+
+// sentencePiece counts tokens with a SentencePiece model, the tokenizer
+// of the Llama, Mistral and Gemma families. It reads the tokenizer.model
+// file those models ship with and segments as the library does, with a
+// unigram or BPE model; the normalization rule table is not applied, which
+// only matters for text that NFKC would change.
+type sentencePiece struct {
+	spec              string
+	bpe               bool
+	pieces            map[string]float32 // pieces text can be split into, with their scores
+	maxLen            int                // bytes in the longest piece
+	unkScore          float32            // the cost of an unknown character in a unigram model
+	byteFallback      bool               // unknown characters become one token per UTF-8 byte
+	addDummyPrefix    bool
+	removeExtraSpaces bool
+}
+
+// loadSentencePiece reads a SentencePiece .model file.
+func loadSentencePiece(path string) (*sentencePiece, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the SentencePiece tokenizer: %v", err)
+	}
+	m := &sentencePiece{pieces: make(map[string]float32), addDummyPrefix: true, removeExtraSpaces: true}
+	minScore := float32(0)
+	err = readProto(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1: // pieces
+			var piece string
+			var score float32
+			kind := uint64(spNormal)
+			err := readProto(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					piece = string(b)
+				case 2:
+					score = math.Float32frombits(uint32(v))
+				case 3:
+					kind = v
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if kind == spNormal || kind == spUserDefined {
+				m.pieces[piece] = score
+				m.maxLen = max(m.maxLen, len(piece))
+				minScore = min(minScore, score)
+			}
+		case 2: // trainer_spec
+			return readProto(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 3:
+					m.bpe = v == 2
+				case 35:
+					m.byteFallback = v != 0
+				}
+				return nil
+			})
+		case 3: // normalizer_spec
+			return readProto(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 3:
+					m.addDummyPrefix = v != 0
+				case 4:
+					m.removeExtraSpaces = v != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil || len(m.pieces) == 0 {
+		return nil, fmt.Errorf("cannot load the SentencePiece tokenizer: %s is not a SentencePiece model", path)
+	}
+	// As the library does, an unknown character costs more than any piece
+	m.unkScore = minScore - 10
+	return m, nil
+}
+
+var errBadProto = errors.New("malformed protobuf")
+
+// readProto calls fn for each field of the protobuf message in data, with
+// the value of a varint or fixed-size field in v and the bytes of a
+// length-delimited one in b.
+func readProto(data []byte, fn func(field int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errBadProto
+		}
+		data = data[n:]
+		var v uint64
+		var b []byte
+		switch key & 7 {
+		case 0:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errBadProto
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return errBadProto
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errBadProto
+			}
+			b, data = data[n:n+int(size)], data[n+int(size):]
+		case 5:
+			if len(data) < 4 {
+				return errBadProto
+			}
+			v, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return errBadProto
+		}
+		if err := fn(int(key>>3), v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *sentencePiece) Spec() string { return m.spec }
+
+// Count returns the number of pieces text is split into.
+func (m *sentencePiece) Count(text string) int {
+	if m.removeExtraSpaces {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	if text == "" {
+		return 0
+	}
+	text = strings.ReplaceAll(text, " ", spSpace)
+	if m.addDummyPrefix {
+		text = spSpace + text
+	}
+	if m.bpe {
+		return m.countBPE(text)
+	}
+	return m.countUnigram(text)
+}
+
+// unknown returns the tokens an unknown character takes.
+func (m *sentencePiece) unknown(r string) int {
+	if m.byteFallback {
+		return len(r)
+	}
+	return 1
+}
+
+// countUnigram finds the most likely segmentation of text, the one whose
+// pieces have the highest total score, and counts its pieces.
+func (m *sentencePiece) countUnigram(text string) int {
+	best := make([]float64, len(text)+1)
+	count := make([]int, len(text)+1)
+	for i := 1; i <= len(text); i++ {
+		best[i] = math.Inf(-1)
+	}
+	for i := 0; i < len(text); i++ {
+		if math.IsInf(best[i], -1) {
+			continue // inside a character
+		}
+		reach := func(end int, score float64, n int) {
+			if score > best[end] {
+				best[end], count[end] = score, count[i]+n
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		reach(i+size, best[i]+float64(m.unkScore), m.unknown(text[i:i+size]))
+		for end := i + 1; end <= min(len(text), i+m.maxLen); end++ {
+			if score, ok := m.pieces[text[i:end]]; ok {
+				reach(end, best[i]+float64(score), 1)
+			}
+		}
+	}
+	return count[len(text)]
+}
+
+// countBPE merges characters into pieces, the highest-scoring pair
+// first, and counts the pieces left. Pieces do not span the start of a
+// word, so each word is merged on its own.
+func (m *sentencePiece) countBPE(text string) int {
+	n := 0
+	for len(text) > 0 {
+		// A word is a run of spaces and the characters up to the next one
+		rest := strings.TrimLeft(text, spSpace)
+		end := len(text)
+		if i := strings.Index(rest, spSpace); i >= 0 {
+			end = len(text) - len(rest) + i
+		}
+		n += m.countBPEWord(text[:end])
+		text = text[end:]
+	}
+	return n
+}
+
+func (m *sentencePiece) countBPEWord(word string) int {
+	var symbols []string
+	for _, r := range word {
+		symbols = append(symbols, string(r))
+	}
+	for {
+		best, at := float32(0), -1
+		for i := 0; i+1 < len(symbols); i++ {
+			if score, ok := m.pieces[symbols[i]+symbols[i+1]]; ok && (at < 0 || score > best) {
+				best, at = score, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		symbols[at] += symbols[at+1]
+		symbols = append(symbols[:at+1], symbols[at+2:]...)
+	}
+	n := 0
+	for _, s := range symbols {
+		if _, ok := m.pieces[s]; ok {
+			n++
+		} else {
+			n += m.unknown(s)
+		}
+	}
+	return n
+}
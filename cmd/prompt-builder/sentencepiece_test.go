@@ -0,0 +1,114 @@
+// sentencepiece_test.go
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// spPiece is a piece of a test SentencePiece model.
+type spPiece struct {
+	text  string
+	score float32
+	kind  uint64
+}
+
+// writeSentencePiece writes a SentencePiece model file with the given
+// pieces and returns its path.
+func writeSentencePiece(t *testing.T, bpe, byteFallback bool, pieces []spPiece) string {
+	t.Helper()
+	field := func(b []byte, n int, data []byte) []byte {
+		b = binary.AppendUvarint(b, uint64(n<<3|2))
+		b = binary.AppendUvarint(b, uint64(len(data)))
+		return append(b, data...)
+	}
+	varint := func(b []byte, n int, v uint64) []byte {
+		return binary.AppendUvarint(binary.AppendUvarint(b, uint64(n<<3)), v)
+	}
+
+	var model []byte
+	for _, p := range pieces {
+		piece := field(nil, 1, []byte(p.text))
+		piece = binary.LittleEndian.AppendUint32(binary.AppendUvarint(piece, 2<<3|5), math.Float32bits(p.score))
+		if p.kind != 0 {
+			piece = varint(piece, 3, p.kind)
+		}
+		model = field(model, 1, piece)
+	}
+	var trainer []byte
+	if bpe {
+		trainer = varint(trainer, 3, 2)
+	}
+	if byteFallback {
+		trainer = varint(trainer, 35, 1)
+	}
+	model = field(model, 2, trainer)
+	model = field(model, 3, varint(nil, 4, 0)) // keep extra whitespace, as Llama does
+
+	path := filepath.Join(t.TempDir(), "tokenizer.model")
+	if err := os.WriteFile(path, model, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSentencePiece_Unigram(t *testing.T) {
+	pieces := []spPiece{{"<unk>", 0, 2}, {"<s>", 0, 3}, {"▁hello", -1, 0}, {"▁world", -1, 0}, {"▁", -5, 0}}
+	for _, c := range "helowrd" {
+		pieces = append(pieces, spPiece{string(c), -3, 0})
+	}
+	m, err := loadSentencePiece(writeSentencePiece(t, false, false, pieces))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]int{
+		"":            0,
+		"hello world": 2,
+		"hello there": 7, // ▁hello ▁ t(unknown) h e r e
+		"<s>":         4, // ▁ and three unknown characters: control pieces are not matched in text
+	}
+	for text, want := range tests {
+		if got := m.Count(text); got != want {
+			t.Errorf("Count(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestSentencePiece_BPE(t *testing.T) {
+	pieces := []spPiece{{"<unk>", 0, 2}, {"<0xC3>", 0, 6}, {"<0xBC>", 0, 6},
+		{"▁", -1, 0}, {"h", -2, 0}, {"e", -3, 0}, {"l", -4, 0}, {"o", -5, 0},
+		{"ll", -6, 0}, {"he", -7, 0}, {"hell", -8, 0}, {"hello", -9, 0}, {"▁hello", -10, 0}, {"▁▁", -11, 0}}
+	m, err := loadSentencePiece(writeSentencePiece(t, true, true, pieces))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]int{
+		"hello hello":  2,
+		"hello  hello": 3, // ▁hello ▁ ▁hello, as ▁▁ loses to ▁hello
+		"hello ü":      4, // ▁hello ▁ and two byte tokens for ü
+		"hell":         2, // ▁ hell
+	}
+	for text, want := range tests {
+		if got := m.Count(text); got != want {
+			t.Errorf("Count(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestLoadSentencePiece_Errors(t *testing.T) {
+	dir := t.TempDir()
+	notModel := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notModel, []byte("just some notes\n"), 0644)
+	for path, want := range map[string]string{
+		filepath.Join(dir, "gone.model"): "cannot load the SentencePiece tokenizer",
+		notModel:                         "is not a SentencePiece model",
+	} {
+		if _, err := loadSentencePiece(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("loadSentencePiece(%s) error = %v, want %q", filepath.Base(path), err, want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// server.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Ollama features whose availability depends on the server version.
+const (
+	featureKeepAlive     = "keep_alive"
+	featureChatAPI       = "OpenAI-compatible chat API"
+	featureRunningModels = "/api/ps"
+)
+
+// ollamaFeature records the first Ollama release with a feature. Required
+// features are ones this tool cannot work without.
+type ollamaFeature struct {
+	Name     string
+	Since    string
+	Required bool
+	Missing  string // what goes wrong without it
+}
+
+var ollamaFeatures = []ollamaFeature{
+	{featureKeepAlive, "0.1.23", false, "models unload after the server's default idle time"},
+	{featureChatAPI, "0.1.24", true, "chat requests will fail with 404"},
+	{featureRunningModels, "0.1.38", false, "loaded models cannot be listed"},
+}
+
+// ServerInfo describes the LLM server behind host.
+type ServerInfo struct {
+	// Version is the Ollama version, or empty when the server is not
+	// Ollama or did not say.
+	Version string
+}
+
+// DetectServer asks host for its Ollama version through hc. Servers
+// without /api/version, such as LM Studio or llama.cpp, give an empty
+// ServerInfo; an error means the server could not be reached.
+func DetectServer(ctx context.Context, hc *http.Client, host string) (ServerInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, host+"/api/version", nil)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ServerInfo{}, nil
+	}
+	var body struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body); err != nil {
+		return ServerInfo{}, nil
+	}
+	return ServerInfo{Version: body.Version}, nil
+}
+
+// Supports reports whether the server has feature. Unknown servers and
+// development builds are assumed to have everything.
+func (s ServerInfo) Supports(feature string) bool {
+	v, ok := parseVersion(s.Version)
+	if !ok || v == [3]int{} {
+		return true
+	}
+	for _, f := range ollamaFeatures {
+		if f.Name == feature {
+			since, _ := parseVersion(f.Since)
+			return compareVersions(v, since) >= 0
+		}
+	}
+	return true
+}
+
+// Warnings describes missing features this tool needs.
+func (s ServerInfo) Warnings() []string {
+	var warnings []string
+	for _, f := range ollamaFeatures {
+		if f.Required && !s.Supports(f.Name) {
+			warnings = append(warnings, fmt.Sprintf("Ollama %s has no %s (added in %s); %s. Upgrade Ollama.", s.Version, f.Name, f.Since, f.Missing))
+		}
+	}
+	return warnings
+}
+
+// parseVersion parses "0.5.7" or "v0.5.7-rc1" into its numeric parts.
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i != -1 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
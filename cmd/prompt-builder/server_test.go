@@ -0,0 +1,106 @@
+// server_test.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeOllama answers /api/version with version, or 404 when it is empty.
+func fakeOllama(version string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/version" || version == "" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"version":%q}`, version)
+	}))
+}
+
+func TestDetectServer(t *testing.T) {
+	server := fakeOllama("0.5.7")
+	defer server.Close()
+
+	info, err := DetectServer(context.Background(), http.DefaultClient, server.URL)
+	if err != nil || info.Version != "0.5.7" {
+		t.Errorf("DetectServer() = %+v, %v, want version 0.5.7", info, err)
+	}
+}
+
+func TestDetectServer_NotOllama(t *testing.T) {
+	server := fakeOllama("")
+	defer server.Close()
+
+	info, err := DetectServer(context.Background(), http.DefaultClient, server.URL)
+	if err != nil || info.Version != "" {
+		t.Errorf("DetectServer() = %+v, %v, want unknown version", info, err)
+	}
+}
+
+func TestDetectServer_Unreachable(t *testing.T) {
+	if _, err := DetectServer(context.Background(), http.DefaultClient, "http://127.0.0.1:1"); err == nil {
+		t.Error("expected error for unreachable server")
+	}
+}
+
+func TestServerInfo_Supports(t *testing.T) {
+	tests := []struct {
+		version string
+		feature string
+		want    bool
+	}{
+		{"0.5.7", featureRunningModels, true},
+		{"0.1.32", featureRunningModels, false},
+		{"0.1.38", featureRunningModels, true},
+		{"0.1.23", featureChatAPI, false},
+		{"0.1.23", featureKeepAlive, true},
+		{"v0.1.38-rc2", featureRunningModels, true},
+		{"0.0.0", featureChatAPI, true}, // development build
+		{"", featureChatAPI, true},
+		{"0.5.7", "unknown feature", true},
+	}
+	for _, tt := range tests {
+		if got := (ServerInfo{Version: tt.version}).Supports(tt.feature); got != tt.want {
+			t.Errorf("ServerInfo{%q}.Supports(%q) = %v, want %v", tt.version, tt.feature, got, tt.want)
+		}
+	}
+}
+
+func TestServerInfo_Warnings(t *testing.T) {
+	if w := (ServerInfo{Version: "0.1.38"}).Warnings(); len(w) != 0 {
+		t.Errorf("Warnings() = %q, want none", w)
+	}
+	// Only features the tool needs are worth a warning at startup
+	if w := (ServerInfo{Version: "0.1.30"}).Warnings(); len(w) != 0 {
+		t.Errorf("Warnings() = %q, want none for optional features", w)
+	}
+	w := (ServerInfo{Version: "0.1.20"}).Warnings()
+	if len(w) != 1 || !strings.Contains(w[0], featureChatAPI) || !strings.Contains(w[0], "Upgrade Ollama") {
+		t.Errorf("Warnings() = %q, want chat API warning", w)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  [3]int
+		ok    bool
+	}{
+		{"0.5.7", [3]int{0, 5, 7}, true},
+		{"v1.2", [3]int{1, 2, 0}, true},
+		{"0.1.32-rc1", [3]int{0, 1, 32}, true},
+		{"", [3]int{}, false},
+		{"abc", [3]int{}, false},
+		{"1.2.3.4", [3]int{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseVersion(tt.input)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseVersion(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
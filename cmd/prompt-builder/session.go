@@ -0,0 +1,163 @@
+// session.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Session is a saved conversation that can be resumed with --resume.
+type Session struct {
+	ID        string    `json:"id"`
+	Idea      string    `json:"idea"`
+	Model     string    `json:"model,omitempty"`
+	Source    string    `json:"source,omitempty"` // e.g. "chatgpt" for imported sessions
+	CreatedAt time.Time `json:"created_at"`
+	Messages  []Message `json:"messages"`
+	Notes     []Note    `json:"notes,omitempty"`
+	Pins      []string  `json:"pins,omitempty"` // constraints every draft must keep
+	Locks     []Lock    `json:"locks,omitempty"`
+	Split     bool      `json:"split,omitempty"` // deliver system and user messages (--split)
+	Chain     string    `json:"chain,omitempty"` // directory a prompt chain is written to (--chain)
+	Tools     []Tool    `json:"tools,omitempty"` // the target agent's tools (--tools)
+	Fit       *Fit      `json:"fit,omitempty"`   // token budget for the final prompt (--fit)
+	Review    *Review   `json:"review,omitempty"`
+
+	Experiment  *ExperimentTag `json:"experiment,omitempty"`  // the system prompt variant this session ran
+	Environment *Environment   `json:"environment,omitempty"` // what the session ran with; none with --redact-env
+
+	path string // the file it was loaded from, which it is saved back to
+}
+
+// Note is a reviewer annotation on a draft. Notes are saved and exported
+// but never sent to the model.
+type Note struct {
+	Draft     int       `json:"draft"` // 1-based assistant turn the note refers to
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// dataDir returns the directory for state the tool writes itself.
+func dataDir() string {
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, "data")
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "prompt-builder")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "prompt-builder")
+}
+
+// cacheDir returns the directory for files that are safe to lose.
+func cacheDir() string {
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, "cache")
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "prompt-builder")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache", "prompt-builder")
+}
+
+func sessionsDir() string {
+	return filepath.Join(dataDir(), "sessions")
+}
+
+// NewSessionID returns the next free "YYYY-MM-DD-N" ID in dir.
+func NewSessionID(dir string, now time.Time) string {
+	date := now.Format("2006-01-02")
+	for n := 1; ; n++ {
+		id := fmt.Sprintf("%s-%d", date, n)
+		if _, err := os.Stat(filepath.Join(dir, id+".json")); os.IsNotExist(err) {
+			return id
+		}
+	}
+}
+
+// SaveSession writes s back to the file it was loaded from, or else to
+// dir, assigning an ID if it has none, and returns the file path.
+func SaveSession(dir string, s *Session) (string, error) {
+	path := s.path
+	if path == "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to save session: %w", err)
+		}
+		if s.ID == "" {
+			s.ID = NewSessionID(dir, s.CreatedAt)
+		}
+		path = filepath.Join(dir, s.ID+".json")
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to save session: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save session: %w", err)
+	}
+	return path, nil
+}
+
+// saveSession saves s in the default sessions directory.
+func saveSession(s *Session) (string, error) {
+	return SaveSession(sessionsDir(), s)
+}
+
+// LoadSession reads a session by file path, or by ID from dir.
+func LoadSession(dir, idOrPath string) (*Session, error) {
+	path := idOrPath
+	if !strings.HasSuffix(path, ".json") {
+		path = filepath.Join(dir, idOrPath+".json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session not found: %s", idOrPath)
+		}
+		return nil, err
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid session %s: %w", path, err)
+	}
+	s.path = path
+	return &s, nil
+}
+
+// draftCount returns the number of assistant turns in messages.
+func draftCount(messages []Message) int {
+	n := 0
+	for _, m := range messages {
+		if m.Role == "assistant" {
+			n++
+		}
+	}
+	return n
+}
+
+// conversationMessages returns the session's messages after the system
+// prompt, which is always taken from the current config on resume.
+func (s *Session) conversationMessages() []Message {
+	var msgs []Message
+	for _, m := range s.Messages {
+		if m.Role != "system" {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
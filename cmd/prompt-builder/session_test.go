@@ -0,0 +1,95 @@
+// session_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewSessionID(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	if got := NewSessionID(dir, now); got != "2026-06-01-1" {
+		t.Errorf("NewSessionID() = %q, want %q", got, "2026-06-01-1")
+	}
+
+	os.WriteFile(filepath.Join(dir, "2026-06-01-1.json"), []byte("{}"), 0644)
+	if got := NewSessionID(dir, now); got != "2026-06-01-2" {
+		t.Errorf("NewSessionID() = %q, want %q", got, "2026-06-01-2")
+	}
+}
+
+func TestSaveLoadSession(t *testing.T) {
+	dir := t.TempDir()
+	s := &Session{
+		Idea:      "an idea",
+		CreatedAt: time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC),
+		Messages:  []Message{{Role: "user", Content: "an idea"}},
+	}
+
+	path, err := SaveSession(dir, s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(path) != "2026-06-01-1.json" {
+		t.Errorf("path = %q, want 2026-06-01-1.json", path)
+	}
+
+	for _, ref := range []string{s.ID, path} {
+		loaded, err := LoadSession(dir, ref)
+		if err != nil {
+			t.Fatalf("LoadSession(%q) error: %v", ref, err)
+		}
+		if loaded.Idea != "an idea" || len(loaded.Messages) != 1 {
+			t.Errorf("LoadSession(%q) = %+v", ref, loaded)
+		}
+	}
+}
+
+func TestSaveSession_LoadedByPath(t *testing.T) {
+	dir, elsewhere := t.TempDir(), t.TempDir()
+	path := filepath.Join(elsewhere, "x.json")
+	os.WriteFile(path, []byte(`{"id": "2026-06-01-1", "idea": "an idea"}`), 0644)
+
+	s, err := LoadSession(dir, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Notes = append(s.Notes, Note{Draft: 1, Text: "shorter"})
+	saved, err := SaveSession(dir, s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved != path {
+		t.Errorf("saved to %q, want %q where it was loaded from", saved, path)
+	}
+	if reloaded, err := LoadSession(dir, path); err != nil || len(reloaded.Notes) != 1 {
+		t.Errorf("reloaded = %+v, %v, want the note saved", reloaded, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("sessions dir has %d files, want none", len(entries))
+	}
+}
+
+func TestLoadSession_NotFound(t *testing.T) {
+	_, err := LoadSession(t.TempDir(), "missing")
+	if err == nil {
+		t.Error("expected error for missing session")
+	}
+}
+
+func TestSession_ConversationMessages(t *testing.T) {
+	s := &Session{Messages: []Message{
+		{Role: "system", Content: "old system prompt"},
+		{Role: "user", Content: "idea"},
+		{Role: "assistant", Content: "question?"},
+	}}
+
+	msgs := s.conversationMessages()
+	if len(msgs) != 2 || msgs[0].Role != "user" {
+		t.Errorf("conversationMessages() = %+v, want user and assistant only", msgs)
+	}
+}
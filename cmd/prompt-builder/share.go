@@ -0,0 +1,66 @@
+// share.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Formats understood by share.format.
+const (
+	shareRaw  = "raw"  // POST the prompt as text/plain
+	shareGist = "gist" // GitHub gists API
+)
+
+// ShareConfig configures /share. Sharing is off unless URL is set, and
+// only hosts in AllowedHosts are ever contacted.
+type ShareConfig struct {
+	URL          string   `yaml:"url"`
+	Format       string   `yaml:"format"`
+	TokenEnv     string   `yaml:"token_env"` // environment variable holding a bearer token
+	AllowedHosts []string `yaml:"allowed_hosts"`
+}
+
+// checkShareHost refuses endpoints whose host is not allowlisted.
+func checkShareHost(cfg ShareConfig) error {
+	return checkAllowedHost("share", "url", cfg.URL, cfg.AllowedHosts)
+}
+
+// checkAllowedHost refuses rawURL, the value of section.key in the config,
+// unless it is an http(s) URL whose host is in section.allowed_hosts.
+func checkAllowedHost(section, key, rawURL string, allowed []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s.%s is not an http(s) URL: %q", section, key, rawURL)
+	}
+	for _, h := range allowed {
+		if strings.EqualFold(h, u.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not in %s.allowed_hosts", u.Hostname(), section)
+}
+
+// allowlistTimeout bounds a request to an allowlisted endpoint so a dead
+// service cannot hang the session.
+const allowlistTimeout = 15 * time.Second
+
+// allowlistClient returns an HTTP client for allowlisted endpoints.
+func allowlistClient() *http.Client {
+	return &http.Client{
+		Timeout: allowlistTimeout,
+		// A redirect could leave the allowlist
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// shareTitle names a prompt shared from session after its idea.
+func shareTitle(session *Session) string {
+	if session == nil || session.Idea == "" {
+		return "Prompt"
+	}
+	return firstLine(session.Idea, 60)
+}
@@ -0,0 +1,23 @@
+//go:build nopublish
+
+// share_nopublish.go
+package main
+
+import "fmt"
+
+// publishBuilt reports whether this binary can send prompts to other
+// services with /share, push and webhooks.
+const publishBuilt = false
+
+// SharePrompt fails: this binary was built without sharing.
+func SharePrompt(cfg ShareConfig, title, prompt string) (string, error) {
+	return "", fmt.Errorf("sharing is not available: this binary was built with -tags nopublish")
+}
+
+// handleShare implements /share, which this binary was built without.
+func handleShare(lastResponse string, env *CommandEnv) error {
+	if env.Share == nil || env.Share.URL == "" {
+		return fmt.Errorf("Sharing is disabled. Set share.url and share.allowed_hosts in config")
+	}
+	return fmt.Errorf("Sharing is not available: this binary was built with -tags nopublish")
+}
@@ -0,0 +1,133 @@
+//go:build !nopublish
+
+// share_publish.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// publishBuilt reports whether this binary can send prompts to other
+// services with /share, push and webhooks; build with -tags nopublish for
+// one that never does.
+const publishBuilt = true
+
+// SharePrompt uploads prompt to the configured paste service and returns
+// the URL where it can be read.
+func SharePrompt(cfg ShareConfig, title, prompt string) (string, error) {
+	if err := checkShareHost(cfg); err != nil {
+		return "", err
+	}
+
+	var body []byte
+	contentType := "text/plain; charset=utf-8"
+	switch cfg.Format {
+	case "", shareRaw:
+		body = []byte(prompt)
+	case shareGist:
+		var err error
+		body, err = json.Marshal(map[string]any{
+			"description": title,
+			"public":      false,
+			"files":       map[string]any{"prompt.md": map[string]string{"content": prompt}},
+		})
+		if err != nil {
+			return "", err
+		}
+		contentType = "application/json"
+	default:
+		return "", fmt.Errorf("share.format must be raw or gist, got %q", cfg.Format)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if cfg.Format == shareGist {
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+	if cfg.TokenEnv != "" {
+		token := os.Getenv(cfg.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("%s is not set", cfg.TokenEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := allowlistClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("paste service returned %s", resp.Status)
+	}
+	return pasteURL(resp, respBody)
+}
+
+// pasteURL finds the link to an upload in a paste service's response:
+// the Location header, a url or html_url JSON field, or a bare URL body.
+func pasteURL(resp *http.Response, body []byte) (string, error) {
+	if loc, err := resp.Location(); err == nil {
+		return loc.String(), nil
+	}
+	var parsed struct {
+		URL     string `json:"url"`
+		HTMLURL string `json:"html_url"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		// GitHub's url is the API resource; html_url is the page
+		if parsed.HTMLURL != "" {
+			return parsed.HTMLURL, nil
+		}
+		if parsed.URL != "" {
+			return parsed.URL, nil
+		}
+	}
+	if text := strings.TrimSpace(string(body)); strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://") {
+		return text, nil
+	}
+	return "", fmt.Errorf("paste service response has no URL")
+}
+
+// handleShare implements /share.
+func handleShare(lastResponse string, env *CommandEnv) error {
+	if env.Share == nil || env.Share.URL == "" {
+		return fmt.Errorf("Sharing is disabled. Set share.url and share.allowed_hosts in config")
+	}
+	if lastResponse == "" {
+		return fmt.Errorf("No response to share")
+	}
+	prompt := promptbuilder.ExtractLastCodeBlock(lastResponse)
+	if prompt == "" {
+		return fmt.Errorf("No code block to share")
+	}
+	if env.Stamp != nil {
+		stamped, err := env.Stamp(prompt)
+		if err != nil {
+			return fmt.Errorf("Share failed: %v", err)
+		}
+		prompt = stamped
+	}
+
+	link, err := SharePrompt(*env.Share, shareTitle(env.Session), prompt)
+	if err != nil {
+		return fmt.Errorf("Share failed: %v", err)
+	}
+	fmt.Fprintf(env.Out, "✓ Shared: %s\n", link)
+	return nil
+}
@@ -0,0 +1,130 @@
+//go:build !nopublish
+
+// share_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSharePrompt_Raw(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+		w.Header().Set("Location", "/p/abc")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	link, err := SharePrompt(ShareConfig{URL: server.URL + "/api", AllowedHosts: []string{"127.0.0.1"}}, "title", "the prompt")
+	if err != nil {
+		t.Fatalf("SharePrompt() error = %v", err)
+	}
+	if got != "the prompt" {
+		t.Errorf("uploaded %q, want the prompt", got)
+	}
+	if link != server.URL+"/p/abc" {
+		t.Errorf("link = %q, want resolved Location", link)
+	}
+}
+
+func TestSharePrompt_Gist(t *testing.T) {
+	t.Setenv("TEST_GIST_TOKEN", "secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		var req struct {
+			Public bool `json:"public"`
+			Files  map[string]struct {
+				Content string `json:"content"`
+			} `json:"files"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Public || req.Files["prompt.md"].Content != "the prompt" {
+			t.Errorf("unexpected gist request: %+v", req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"url": "https://api.github.com/gists/1", "html_url": "https://gist.github.com/1"}`))
+	}))
+	defer server.Close()
+
+	cfg := ShareConfig{URL: server.URL, Format: shareGist, TokenEnv: "TEST_GIST_TOKEN", AllowedHosts: []string{"127.0.0.1"}}
+	link, err := SharePrompt(cfg, "title", "the prompt")
+	if err != nil {
+		t.Fatalf("SharePrompt() error = %v", err)
+	}
+	if link != "https://gist.github.com/1" {
+		t.Errorf("link = %q, want html_url", link)
+	}
+}
+
+func TestSharePrompt_Refused(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		cfg     ShareConfig
+		wantErr string
+	}{
+		{"host not allowed", ShareConfig{URL: server.URL, AllowedHosts: []string{"paste.example.com"}}, "not in share.allowed_hosts"},
+		{"no allowlist", ShareConfig{URL: server.URL}, "not in share.allowed_hosts"},
+		{"not http", ShareConfig{URL: "file:///etc/passwd", AllowedHosts: []string{""}}, "not an http(s) URL"},
+		{"missing token", ShareConfig{URL: server.URL, TokenEnv: "PB_UNSET_TOKEN", AllowedHosts: []string{"127.0.0.1"}}, "PB_UNSET_TOKEN is not set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SharePrompt(tt.cfg, "title", "prompt")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if requests != 0 {
+		t.Errorf("refused shares must not contact the server, got %d requests", requests)
+	}
+}
+
+func TestSharePrompt_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := SharePrompt(ShareConfig{URL: server.URL, AllowedHosts: []string{"127.0.0.1"}}, "title", "prompt")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected status error, got: %v", err)
+	}
+}
+
+func TestHandleShare(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("https://paste.example.com/xyz\n"))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	env := &CommandEnv{Out: &out, Share: &ShareConfig{URL: server.URL, AllowedHosts: []string{"127.0.0.1"}}}
+	if _, err := HandleCommand("/share", "```\nprompt\n```", env); err != nil {
+		t.Fatalf("HandleCommand() error = %v", err)
+	}
+	if out.String() != "✓ Shared: https://paste.example.com/xyz\n" {
+		t.Errorf("output = %q", out.String())
+	}
+
+	_, err := HandleCommand("/share", "```\nprompt\n```", &CommandEnv{Out: &out, Share: &ShareConfig{}})
+	if err == nil || !strings.HasPrefix(err.Error(), "Sharing is disabled") {
+		t.Errorf("expected disabled error, got: %v", err)
+	}
+}
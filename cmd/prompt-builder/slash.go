@@ -2,10 +2,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
 )
 
 // ClipboardWriter abstracts clipboard operations for testing.
@@ -13,41 +21,111 @@ type ClipboardWriter interface {
 	Write(text string) error
 }
 
-// clipboardFunc adapts a function to ClipboardWriter.
+// errNoClipboard means no clipboard command is configured or installed.
+var errNoClipboard = errors.New("no clipboard command found")
+
+// clipboardTimeout bounds a clipboard command. Copying returns at once
+// when it works; tools like xclip without a display can block forever.
+var clipboardTimeout = 3 * time.Second
+
+// clipboardFunc adapts clipboard commands to ClipboardWriter. Commands
+// are tried in order until one succeeds.
 type clipboardFunc struct {
-	cmd string
+	cmds      []string
+	sensitive bool
 }
 
 func (c *clipboardFunc) Write(text string) error {
-	return CopyToClipboard(text, c.cmd)
+	if len(c.cmds) == 0 {
+		return errNoClipboard
+	}
+	var errs []error
+	for _, cmd := range c.cmds {
+		var err error
+		if sc := sensitiveCopyCmd(cmd); c.sensitive && sc != nil {
+			err = runClipboardCmd(sc, text)
+		} else {
+			err = CopyToClipboard(text, cmd)
+		}
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
-// NewClipboardWriter creates a ClipboardWriter from a command string.
-func NewClipboardWriter(cmd string) ClipboardWriter {
-	return &clipboardFunc{cmd: cmd}
+// NewClipboardWriter creates a ClipboardWriter from command strings, the
+// first preferred and the rest used if it fails.
+func NewClipboardWriter(cmds ...string) ClipboardWriter {
+	return &clipboardFunc{cmds: cmds}
+}
+
+// NewSensitiveClipboardWriter creates a ClipboardWriter that asks
+// clipboard history managers not to keep what it copies, where the
+// clipboard tool allows it. Otherwise it copies normally.
+func NewSensitiveClipboardWriter(cmds ...string) ClipboardWriter {
+	return &clipboardFunc{cmds: cmds, sensitive: true}
+}
+
+// concealedPasteboardScript copies stdin on macOS with the
+// org.nspasteboard.ConcealedType and TransientType markers, which
+// clipboard managers use to skip passwords and other secrets.
+const concealedPasteboardScript = `ObjC.import('AppKit');
+var data = $.NSFileHandle.fileHandleWithStandardInput.readDataToEndOfFile;
+var text = $.NSString.alloc.initWithDataEncoding(data, $.NSUTF8StringEncoding);
+var pb = $.NSPasteboard.generalPasteboard;
+pb.clearContents;
+pb.setStringForType(text, 'public.utf8-plain-text');
+pb.setStringForType($(''), 'org.nspasteboard.ConcealedType');
+pb.setStringForType($(''), 'org.nspasteboard.TransientType');`
+
+// sensitiveCopyCmd returns a command that copies stdin and marks the
+// entry as confidential, or nil if the clipboard tool cannot. The
+// Wayland and X11 tools offer a single MIME type per copy, so they cannot
+// add the x-kde-passwordManagerHint that Linux managers look for.
+func sensitiveCopyCmd(cmd string) *exec.Cmd {
+	parts, err := splitCommand(cmd)
+	if err != nil || len(parts) == 0 {
+		return nil
+	}
+	switch parts[0] {
+	case "pbcopy":
+		return exec.Command("osascript", "-l", "JavaScript", "-e", concealedPasteboardScript)
+	}
+	return nil
+}
+
+// clipboardCandidates are the clipboard tools tried, in order.
+var clipboardCandidates = []string{
+	"wl-copy",
+	"xclip -selection clipboard",
+	"xsel --clipboard --input",
+	"pbcopy",
 }
 
 // DetectClipboardCmd returns the clipboard command to use.
 func DetectClipboardCmd(override string) string {
-	if override != "" {
-		return override
+	if cmds := DetectClipboardCmds(override); len(cmds) > 0 {
+		return cmds[0]
 	}
+	return ""
+}
 
-	candidates := []string{
-		"wl-copy",
-		"xclip -selection clipboard",
-		"xsel --clipboard --input",
-		"pbcopy",
+// DetectClipboardCmds returns the override, if any, followed by every
+// installed clipboard tool, as fallbacks in order of preference.
+func DetectClipboardCmds(override string) []string {
+	var cmds []string
+	if override != "" {
+		cmds = append(cmds, override)
 	}
-
-	for _, cmd := range candidates {
-		parts := strings.Split(cmd, " ")
-		if _, err := exec.LookPath(parts[0]); err == nil {
-			return cmd
+	for _, cmd := range clipboardCandidates {
+		parts, _ := splitCommand(cmd)
+		if _, err := exec.LookPath(parts[0]); err == nil && cmd != override {
+			cmds = append(cmds, cmd)
 		}
 	}
-
-	return ""
+	return cmds
 }
 
 // CopyToClipboard copies text to the clipboard using the given command.
@@ -56,44 +134,114 @@ func CopyToClipboard(text string, cmd string) error {
 		return nil // No clipboard available, silently skip
 	}
 
-	parts := strings.Split(cmd, " ")
-	c := exec.Command(parts[0], parts[1:]...)
-	c.Stdin = strings.NewReader(text)
-	return c.Run()
+	parts, err := splitCommand(cmd)
+	if err != nil {
+		return fmt.Errorf("clipboard_cmd: %v", err)
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return runClipboardCmd(exec.Command(parts[0], parts[1:]...), text)
 }
 
-// ExtractLastCodeBlock extracts the content of the last code block from text.
-func ExtractLastCodeBlock(text string) string {
-	const marker = "```"
-
-	lastStart := strings.LastIndex(text, marker)
-	if lastStart == -1 {
-		return ""
+// splitCommand splits a command line into words the way a POSIX shell
+// would, honouring single quotes, double quotes and backslash escapes.
+// It does no expansion of variables, globs or ~.
+func splitCommand(cmd string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune // ' or " while inside quotes
+		escaped bool
+	)
+	for _, r := range cmd {
+		switch {
+		case escaped:
+			// Inside double quotes a backslash only escapes these
+			if quote == '"' && !strings.ContainsRune(`"\$`+"`", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
 	}
-
-	// Find the opening marker for this block
-	beforeLast := text[:lastStart]
-	openStart := strings.LastIndex(beforeLast, marker)
-	if openStart == -1 {
-		return ""
+	switch {
+	case escaped:
+		return nil, fmt.Errorf("trailing backslash in %q", cmd)
+	case quote != 0:
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, cmd)
 	}
-
-	// Extract content between markers
-	// Skip past the opening ``` and any language identifier on that line
-	contentStart := openStart + len(marker)
-	if idx := strings.Index(text[contentStart:lastStart], "\n"); idx != -1 {
-		contentStart += idx + 1
+	if inWord {
+		words = append(words, word.String())
 	}
+	return words, nil
+}
 
-	return text[contentStart:lastStart]
+// lastPromptPath is where /copy saves the prompt when there is no clipboard.
+func lastPromptPath() string {
+	return filepath.Join(cacheDir(), "last-prompt.md")
+}
+
+// savePromptFallback writes text to lastPromptPath and returns the path.
+func savePromptFallback(text string) (string, error) {
+	path := lastPromptPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
-// IsComplete returns true if the response contains a code block and doesn't end with a question.
-func IsComplete(response string) bool {
-	hasCodeBlock := strings.Contains(response, "```")
-	trimmed := strings.TrimSpace(response)
-	endsWithQuestion := strings.HasSuffix(trimmed, "?")
-	return hasCodeBlock && !endsWithQuestion
+// runClipboardCmd runs c with text on stdin, killing it after
+// clipboardTimeout.
+func runClipboardCmd(c *exec.Cmd, text string) error {
+	c.Stdin = strings.NewReader(text)
+	c.WaitDelay = time.Second
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("%s: %v", filepath.Base(c.Path), err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s failed: %v", filepath.Base(c.Path), err)
+		}
+		return nil
+	case <-time.After(clipboardTimeout):
+		c.Process.Kill()
+		<-done
+		return fmt.Errorf("%s did not finish within %v (is a display available?)", filepath.Base(c.Path), clipboardTimeout)
+	}
 }
 
 // IsCommand returns true if input starts with a slash.
@@ -103,47 +251,360 @@ func IsCommand(input string) bool {
 
 // parseCommand extracts the command name (lowercase, no slash) from input.
 func parseCommand(input string) string {
+	cmd, _ := parseCommandArgs(input)
+	return cmd
+}
+
+// parseCommandArgs splits input into the command name (lowercase, no slash)
+// and the rest of the line.
+func parseCommandArgs(input string) (cmd, args string) {
 	trimmed := strings.TrimSpace(input)
 	if !strings.HasPrefix(trimmed, "/") {
-		return ""
+		return "", ""
 	}
-	cmd := strings.TrimPrefix(trimmed, "/")
-	return strings.ToLower(cmd)
+	cmd, args, _ = strings.Cut(strings.TrimPrefix(trimmed, "/"), " ")
+	return strings.ToLower(cmd), strings.TrimSpace(args)
+}
+
+// commandHelp lists the slash commands in the order /help shows them.
+var commandHelp = []struct{ usage, desc string }{
+	{"/copy", "Copy last code block to clipboard and exit"},
+	{"/export html", "Save the conversation as a standalone HTML page"},
+	{"/export public", "Save it as HTML for sharing, without internal names and URLs"},
+	{"/preview", "Open the final prompt as a web page in the browser"},
+	{"/qr", "Show the final prompt as a QR code"},
+	{"/share", "Upload the final prompt to the configured paste service"},
+	{"/save", "Save the final prompt to the library chosen by the save config"},
+	{"/request-review", "Send the draft for approval before /save (see review config)"},
+	{"/assume [always]", "Let the model answer its own questions (/assume off to stop)"},
+	{"/as <who> <text>", "Send feedback attributed to a stakeholder, e.g. /as legal ..."},
+	{`/pin "<text>"`, "Keep a constraint in every draft (/pin alone lists them)"},
+	{"/unpin <n>", "Stop enforcing pinned constraint n"},
+	{"/lock <heading>", "Keep a section of the draft verbatim (/lock alone lists them)"},
+	{"/unlock <n>", "Allow changes to locked section n again"},
+	{"/note <text>", "Attach a note to the current draft (not sent to the model)"},
+	{"/shorten [n]", "Ask for a shorter prompt, at most n tokens or the --fit budget"},
+	{`/push "<text>"`, "Insist on a requirement the draft left out, more firmly each time"},
+	{"/temp <n>", "Set temperature for the next turns"},
+	{"/max-tokens <n>", "Set the response token limit for the next turns"},
+	{"/seed <n>", "Set the sampling seed for the next turns"},
+	{"/params", "Show active generation settings"},
+	{"/model [name]", "Show or switch the model for the next turns (aliases work)"},
+	{"/reload", "Re-read the system prompt file for the next turns"},
+	{`/new "<idea>"`, "Start another conversation in a new tab"},
+	{"/tabs", "List open conversations"},
+	{"/switch <n>", "Switch to conversation n"},
+	{"/bye", "Exit conversation"},
+	{"/quit", "Exit conversation"},
+	{"/exit", "Exit conversation"},
+	{"/help", "Show this help"},
 }
 
-// HandleCommandWithClipboard executes a slash command.
+// CommandEnv is the session state available to slash commands.
+type CommandEnv struct {
+	Conv        *Conversation
+	Session     *Session
+	Params      *GenerationParams // applied to every subsequent turn
+	Tabs        *Tabs
+	Stamp       func(prompt string) (string, error) // adds header and footer to copied prompts
+	Share       *ShareConfig
+	Scrub       *ScrubConfig // what /export public removes
+	Review      *ReviewConfig
+	Save        func() (string, error) // saves the final prompt as --save does
+	Clipboard   ClipboardWriter
+	CopiedTo    string // set by /copy to the file the prompt went to when there is no clipboard
+	Out         io.Writer
+	OpenBrowser func(path string) error
+	Aliases     map[string]string                 // the config's model aliases, for /model
+	SetModel    func(name string) (string, error) // switches every tab to a model or alias
+	Reload      func() (string, error)            // re-reads the system prompt into every tab; returns its name
+}
+
+// HandleCommandWithClipboard executes a slash command that only needs the
+// last response and the clipboard.
 func HandleCommandWithClipboard(input, lastResponse string, clipboard ClipboardWriter, out io.Writer) (shouldExit bool, err error) {
-	cmd := parseCommand(input)
+	return HandleCommand(input, lastResponse, &CommandEnv{Clipboard: clipboard, Out: out})
+}
+
+// HandleCommand executes a slash command.
+func HandleCommand(input, lastResponse string, env *CommandEnv) (shouldExit bool, err error) {
+	cmd, args := parseCommandArgs(input)
+	clipboard, out := env.Clipboard, env.Out
 
 	switch cmd {
 	case "bye", "quit", "exit":
 		fmt.Fprintln(out, "Goodbye")
 		return true, nil
 	case "copy":
-		codeBlock := ExtractLastCodeBlock(lastResponse)
+		codeBlock := promptbuilder.ExtractLastCodeBlock(lastResponse)
 		if lastResponse == "" {
 			return false, fmt.Errorf("No response to copy from")
 		}
 		if codeBlock == "" {
 			return false, fmt.Errorf("No code block to copy")
 		}
-		if clipboard == nil {
-			return false, fmt.Errorf("Clipboard not available")
+		stamp := env.Stamp
+		if stamp == nil {
+			stamp = func(p string) (string, error) { return p, nil }
 		}
-		if err := clipboard.Write(codeBlock); err != nil {
-			return false, fmt.Errorf("Clipboard not available")
+		codeBlock, err := finalPrompt(env.Session, lastResponse, stamp)
+		if err != nil {
+			return false, fmt.Errorf("Cannot copy: %v", err)
+		}
+		err = errNoClipboard
+		if clipboard != nil {
+			err = clipboard.Write(codeBlock)
+		}
+		if errors.Is(err, errNoClipboard) {
+			path, err := savePromptFallback(codeBlock)
+			if err != nil {
+				return false, fmt.Errorf("Clipboard not available and cannot save prompt: %v", err)
+			}
+			fmt.Fprintf(out, "Warning: no clipboard found. Prompt saved to %s\n", path)
+			env.CopiedTo = path
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("Clipboard not available: %v", err)
 		}
 		fmt.Fprintln(out, "\u2713 Copied to clipboard")
 		return true, nil
+	case "export":
+		return false, handleExport(args, env)
+	case "preview":
+		return false, handlePreview(lastResponse, env)
+	case "qr":
+		return false, handleQR(lastResponse, env)
+	case "share":
+		return false, handleShare(lastResponse, env)
+	case "save":
+		return false, handleSave(lastResponse, env)
+	case "request-review":
+		return false, handleRequestReview(lastResponse, env)
+	case "assume":
+		return false, handleAssume(args, env)
+	case "as":
+		return false, handleAs(args, env)
+	case "pin":
+		return false, handlePin(args, env)
+	case "unpin":
+		return false, handleUnpin(args, env)
+	case "lock":
+		return false, handleLock(args, lastResponse, env)
+	case "unlock":
+		return false, handleUnlock(args, env)
+	case "note":
+		return false, handleNote(args, env)
+	case "shorten":
+		return false, handleShorten(args, lastResponse, env)
+	case "push":
+		return false, handlePush(args, env)
+	case "temp", "max-tokens", "seed":
+		return false, handleSetParam(cmd, args, env)
+	case "params":
+		printParams(env)
+		return false, nil
+	case "model":
+		return false, handleModel(args, env)
+	case "reload":
+		return false, handleReload(env)
+	case "new":
+		return false, handleNewTab(args, env)
+	case "tabs":
+		return false, handleListTabs(env)
+	case "switch":
+		return false, handleSwitchTab(args, env)
 	case "help":
-		fmt.Fprintln(out, `Commands:
-  /copy   Copy last code block to clipboard and exit
-  /bye    Exit conversation
-  /quit   Exit conversation
-  /exit   Exit conversation
-  /help   Show this help`)
+		fmt.Fprintln(out, "Commands:")
+		for _, h := range commandHelp {
+			fmt.Fprintf(out, "  %-17s%s\n", h.usage, h.desc)
+		}
 		return false, nil
 	default:
 		return false, fmt.Errorf("Unknown command: /%s. Type /help for available commands.", cmd)
 	}
 }
+
+// assumeMessage asks the model to stop asking and write the prompt.
+const assumeMessage = "Answer your own open questions with sensible assumptions, list those assumptions briefly, and write the final prompt now."
+
+// handleAssume implements /assume. Without arguments it sends
+// assumeMessage once; "always" also repeats it whenever a later reply asks
+// questions instead of giving a prompt, until "off".
+func handleAssume(args string, env *CommandEnv) error {
+	if env.Tabs == nil || env.Conv == nil {
+		return fmt.Errorf("/assume is not available here")
+	}
+	tab := env.Tabs.Current()
+
+	switch strings.ToLower(args) {
+	case "":
+		if promptbuilder.IsComplete(tab.Response) {
+			return fmt.Errorf("No open questions; the last reply already has a prompt")
+		}
+	case "always":
+		env.Tabs.AssumeAnswers = true
+		fmt.Fprintln(env.Out, "The model will answer its own questions for the rest of the session. /assume off to stop.")
+		if promptbuilder.IsComplete(tab.Response) {
+			return nil
+		}
+	case "off":
+		env.Tabs.AssumeAnswers = false
+		fmt.Fprintln(env.Out, "The model may ask questions again.")
+		return nil
+	default:
+		return fmt.Errorf("Usage: /assume [always|off]")
+	}
+
+	env.Conv.AddUserMessage(assumeMessage)
+	tab.AwaitingReply = true
+	return nil
+}
+
+// handleNote implements /note: with text it annotates the current draft,
+// without text it lists the notes so far.
+func handleNote(args string, env *CommandEnv) error {
+	if env.Session == nil || env.Conv == nil {
+		return fmt.Errorf("Notes are not available here")
+	}
+
+	if args == "" {
+		if len(env.Session.Notes) == 0 {
+			fmt.Fprintln(env.Out, "No notes yet. Add one with /note <text>")
+			return nil
+		}
+		for _, n := range env.Session.Notes {
+			fmt.Fprintf(env.Out, "  draft %d: %s\n", n.Draft, n.Text)
+		}
+		return nil
+	}
+
+	draft := draftCount(env.Conv.Messages)
+	if draft == 0 {
+		return fmt.Errorf("No draft to annotate yet")
+	}
+	env.Session.Notes = append(env.Session.Notes, Note{Draft: draft, Text: args, CreatedAt: time.Now()})
+	fmt.Fprintf(env.Out, "Note added to draft %d\n", draft)
+	return nil
+}
+
+// handleSetParam implements /temp, /max-tokens and /seed. The value
+// "default" clears the setting so the server default applies.
+func handleSetParam(cmd, args string, env *CommandEnv) error {
+	if env.Params == nil {
+		return fmt.Errorf("Generation settings are not available here")
+	}
+	if args == "" {
+		return fmt.Errorf("Usage: /%s <value|default>", cmd)
+	}
+	reset := strings.EqualFold(args, "default")
+
+	switch cmd {
+	case "temp":
+		if reset {
+			env.Params.Temperature = nil
+			break
+		}
+		v, err := strconv.ParseFloat(args, 64)
+		if err != nil || v < 0 || v > 2 {
+			return fmt.Errorf("Temperature must be a number between 0 and 2")
+		}
+		env.Params.Temperature = &v
+	case "max-tokens":
+		if reset {
+			env.Params.MaxTokens = nil
+			break
+		}
+		v, err := strconv.Atoi(args)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("Max tokens must be a positive integer")
+		}
+		env.Params.MaxTokens = &v
+	case "seed":
+		if reset {
+			env.Params.Seed = nil
+			break
+		}
+		v, err := strconv.Atoi(args)
+		if err != nil {
+			return fmt.Errorf("Seed must be an integer")
+		}
+		env.Params.Seed = &v
+	}
+
+	printParams(env)
+	return nil
+}
+
+// handleModel implements /model: without arguments it shows the model and
+// the configured aliases, with a name or alias it switches to that model.
+func handleModel(args string, env *CommandEnv) error {
+	if env.Session == nil {
+		return fmt.Errorf("/model is not available here")
+	}
+	if args == "" {
+		fmt.Fprintf(env.Out, "Model: %s\n", env.Session.Model)
+		aliases := make([]string, 0, len(env.Aliases))
+		for alias := range env.Aliases {
+			aliases = append(aliases, alias)
+		}
+		slices.Sort(aliases)
+		for _, alias := range aliases {
+			fmt.Fprintf(env.Out, "  %s → %s\n", alias, env.Aliases[alias])
+		}
+		return nil
+	}
+	if env.SetModel == nil {
+		return fmt.Errorf("/model is not available here")
+	}
+	model, err := env.SetModel(args)
+	if err != nil {
+		return fmt.Errorf("Cannot switch model: %v", err)
+	}
+	if model != args {
+		fmt.Fprintf(env.Out, "Model: %s (%s)\n", model, args)
+	} else {
+		fmt.Fprintf(env.Out, "Model: %s\n", model)
+	}
+	return nil
+}
+
+// handleReload implements /reload.
+func handleReload(env *CommandEnv) error {
+	if env.Reload == nil {
+		return fmt.Errorf("/reload is not available here")
+	}
+	name, err := env.Reload()
+	if err != nil {
+		return fmt.Errorf("Cannot reload the system prompt: %v", err)
+	}
+	fmt.Fprintf(env.Out, "✓ Reloaded %s\n", name)
+	return nil
+}
+
+// printParams shows the generation settings used for the next turn.
+func printParams(env *CommandEnv) {
+	p := GenerationParams{}
+	if env.Params != nil {
+		p = *env.Params
+	}
+	show := func(name string, set bool, value any) {
+		if set {
+			fmt.Fprintf(env.Out, "  %-12s %v\n", name, value)
+		} else {
+			fmt.Fprintf(env.Out, "  %-12s default\n", name)
+		}
+	}
+	show("temperature", p.Temperature != nil, deref(p.Temperature))
+	show("top_p", p.TopP != nil, deref(p.TopP))
+	show("max_tokens", p.MaxTokens != nil, deref(p.MaxTokens))
+	show("seed", p.Seed != nil, deref(p.Seed))
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -3,9 +3,14 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDetectClipboardCmd(t *testing.T) {
@@ -34,74 +39,122 @@ func TestDetectClipboardCmd_Override(t *testing.T) {
 	}
 }
 
-func TestExtractLastCodeBlock(t *testing.T) {
-	tests := []struct {
-		name  string
-		input string
-		want  string
-	}{
-		{
-			name:  "single code block",
-			input: "Here is your prompt:\n```\n# Role\nYou are an expert.\n```\n",
-			want:  "# Role\nYou are an expert.\n",
-		},
-		{
-			name:  "multiple code blocks - returns last",
-			input: "Example:\n```\nfirst block\n```\n\nHere is the final:\n```\nsecond block\n```\n",
-			want:  "second block\n",
-		},
-		{
-			name:  "no code block",
-			input: "Just plain text",
-			want:  "",
-		},
+func TestDetectClipboardCmds_OverrideFirst(t *testing.T) {
+	cmds := DetectClipboardCmds("custom-clipboard")
+	if len(cmds) == 0 || cmds[0] != "custom-clipboard" {
+		t.Errorf("DetectClipboardCmds() = %q, want override first", cmds)
 	}
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := ExtractLastCodeBlock(tt.input)
-			if got != tt.want {
-				t.Errorf("ExtractLastCodeBlock() = %q, want %q", got, tt.want)
-			}
-		})
+func TestClipboardWriter_FallsBackToNextCommand(t *testing.T) {
+	if err := NewClipboardWriter("false", "cat").Write("text"); err != nil {
+		t.Errorf("Write() error = %v, want fallback to succeed", err)
+	}
+
+	err := NewClipboardWriter("false", "no-such-clipboard-tool").Write("text")
+	if err == nil || !strings.Contains(err.Error(), "false failed") || !strings.Contains(err.Error(), "no-such-clipboard-tool") {
+		t.Errorf("expected both failures reported, got: %v", err)
+	}
+}
+
+func TestClipboardWriter_Timeout(t *testing.T) {
+	old := clipboardTimeout
+	clipboardTimeout = 50 * time.Millisecond
+	defer func() { clipboardTimeout = old }()
+
+	start := time.Now()
+	err := NewClipboardWriter("sleep 10").Write("text")
+	if err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Errorf("expected timeout error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Write() took %v, want it killed after the timeout", elapsed)
 	}
 }
 
-func TestIsComplete(t *testing.T) {
+func TestHandleCommand_Assume(t *testing.T) {
+	newEnv := func(response string) (*CommandEnv, *Tab) {
+		tab := &Tab{Conv: NewConversation("system"), Response: response}
+		return &CommandEnv{Conv: tab.Conv, Tabs: NewTabs("system", tab), Out: &bytes.Buffer{}}, tab
+	}
+
+	env, tab := newEnv("Who is the audience?")
+	if _, err := HandleCommand("/assume", tab.Response, env); err != nil {
+		t.Fatalf("/assume error = %v", err)
+	}
+	if !tab.AwaitingReply || tab.Conv.Messages[len(tab.Conv.Messages)-1].Content != assumeMessage {
+		t.Error("/assume should ask the model to answer its questions")
+	}
+	if env.Tabs.AssumeAnswers {
+		t.Error("/assume without always should not persist")
+	}
+
+	env, tab = newEnv("```\nprompt\n```")
+	if _, err := HandleCommand("/assume", tab.Response, env); err == nil || tab.AwaitingReply {
+		t.Errorf("/assume after a finished prompt: error = %v, awaiting = %v", err, tab.AwaitingReply)
+	}
+	if _, err := HandleCommand("/assume always", tab.Response, env); err != nil || !env.Tabs.AssumeAnswers || tab.AwaitingReply {
+		t.Errorf("/assume always after a finished prompt should only set the mode, err = %v", err)
+	}
+	if _, err := HandleCommand("/assume off", tab.Response, env); err != nil || env.Tabs.AssumeAnswers {
+		t.Errorf("/assume off: err = %v, mode still on = %v", err, env.Tabs.AssumeAnswers)
+	}
+	if _, err := HandleCommand("/assume sometimes", tab.Response, env); err == nil || !strings.Contains(err.Error(), "Usage") {
+		t.Errorf("/assume sometimes: error = %v, want usage", err)
+	}
+	if _, err := HandleCommandWithClipboard("/assume", "question?", nil, &bytes.Buffer{}); err == nil {
+		t.Error("/assume without tabs should fail")
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
 	tests := []struct {
-		name  string
-		input string
-		want  bool
+		cmd  string
+		want []string
 	}{
-		{
-			name:  "code block without question - complete",
-			input: "Here is your prompt:\n```\ncontent\n```\n",
-			want:  true,
-		},
-		{
-			name:  "code block with trailing question - not complete",
-			input: "Here is a draft:\n```\ncontent\n```\nDoes this look right?",
-			want:  false,
-		},
-		{
-			name:  "question only - not complete",
-			input: "What is your target audience?",
-			want:  false,
-		},
-		{
-			name:  "no code block no question - not complete",
-			input: "Let me think about that.",
-			want:  false,
-		},
+		{"wl-copy", []string{"wl-copy"}},
+		{"xclip -selection clipboard", []string{"xclip", "-selection", "clipboard"}},
+		{"  xsel\t--clipboard   --input ", []string{"xsel", "--clipboard", "--input"}},
+		{`sh -c 'cat > "/tmp/a b"'`, []string{"sh", "-c", `cat > "/tmp/a b"`}},
+		{`copy --title "My Prompts" --flag=x`, []string{"copy", "--title", "My Prompts", "--flag=x"}},
+		{`tool --name="a b" c`, []string{"tool", "--name=a b", "c"}},
+		{`tool a\ b`, []string{"tool", "a b"}},
+		{`tool "say \"hi\" \n"`, []string{"tool", `say "hi" \n`}},
+		{`tool 'it'\''s'`, []string{"tool", "it's"}},
+		{`tool ""`, []string{"tool", ""}},
+		{"", nil},
 	}
-
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := IsComplete(tt.input)
-			if got != tt.want {
-				t.Errorf("IsComplete() = %v, want %v", got, tt.want)
-			}
-		})
+		got, err := splitCommand(tt.cmd)
+		if err != nil {
+			t.Errorf("splitCommand(%q) error = %v", tt.cmd, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommand_Errors(t *testing.T) {
+	for _, cmd := range []string{`tool 'open`, `tool "open`, `tool \`} {
+		if _, err := splitCommand(cmd); err == nil {
+			t.Errorf("splitCommand(%q) succeeded, want error", cmd)
+		}
+	}
+}
+
+func TestCopyToClipboard_QuotedArguments(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "copied text.txt")
+	if err := CopyToClipboard("hello", `sh -c 'cat > "$0"' '`+out+`'`); err != nil {
+		t.Fatalf("CopyToClipboard() error = %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("copied %q, want %q", got, "hello")
 	}
 }
 
@@ -140,6 +193,7 @@ func TestParseCommand(t *testing.T) {
 		{"mixed case", "/Copy", "copy"},
 		{"with whitespace", "  /HELP  ", "help"},
 		{"exit", "/exit", "exit"},
+		{"with args", "/Export html out.html", "export"},
 	}
 
 	for _, tt := range tests {
@@ -210,11 +264,36 @@ func TestHandleCommandWithClipboard_Help(t *testing.T) {
 	}
 
 	wantOutput := `Commands:
-  /copy   Copy last code block to clipboard and exit
-  /bye    Exit conversation
-  /quit   Exit conversation
-  /exit   Exit conversation
-  /help   Show this help
+  /copy            Copy last code block to clipboard and exit
+  /export html     Save the conversation as a standalone HTML page
+  /export public   Save it as HTML for sharing, without internal names and URLs
+  /preview         Open the final prompt as a web page in the browser
+  /qr              Show the final prompt as a QR code
+  /share           Upload the final prompt to the configured paste service
+  /save            Save the final prompt to the library chosen by the save config
+  /request-review  Send the draft for approval before /save (see review config)
+  /assume [always] Let the model answer its own questions (/assume off to stop)
+  /as <who> <text> Send feedback attributed to a stakeholder, e.g. /as legal ...
+  /pin "<text>"    Keep a constraint in every draft (/pin alone lists them)
+  /unpin <n>       Stop enforcing pinned constraint n
+  /lock <heading>  Keep a section of the draft verbatim (/lock alone lists them)
+  /unlock <n>      Allow changes to locked section n again
+  /note <text>     Attach a note to the current draft (not sent to the model)
+  /shorten [n]     Ask for a shorter prompt, at most n tokens or the --fit budget
+  /push "<text>"   Insist on a requirement the draft left out, more firmly each time
+  /temp <n>        Set temperature for the next turns
+  /max-tokens <n>  Set the response token limit for the next turns
+  /seed <n>        Set the sampling seed for the next turns
+  /params          Show active generation settings
+  /model [name]    Show or switch the model for the next turns (aliases work)
+  /reload          Re-read the system prompt file for the next turns
+  /new "<idea>"    Start another conversation in a new tab
+  /tabs            List open conversations
+  /switch <n>      Switch to conversation n
+  /bye             Exit conversation
+  /quit            Exit conversation
+  /exit            Exit conversation
+  /help            Show this help
 `
 	if out.String() != wantOutput {
 		t.Errorf("HandleCommandWithClipboard() output = %q, want %q", out.String(), wantOutput)
@@ -271,15 +350,200 @@ func TestHandleCommandWithClipboard_Copy_NoCodeBlock(t *testing.T) {
 }
 
 func TestHandleCommandWithClipboard_Copy_NoClipboard(t *testing.T) {
-	lastResponse := "```\ncode\n```"
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	want := filepath.Join(cache, "prompt-builder", "last-prompt.md")
+
+	for name, clipboard := range map[string]ClipboardWriter{"nil": nil, "no commands": NewClipboardWriter()} {
+		os.Remove(want)
+		var out bytes.Buffer
+		shouldExit, err := HandleCommandWithClipboard("/copy", "```\ncode\n```", clipboard, &out)
+		if err != nil {
+			t.Fatalf("%s: HandleCommandWithClipboard() error = %v, want fallback to a file", name, err)
+		}
+		if !shouldExit {
+			t.Errorf("%s: shouldExit = false, want true", name)
+		}
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("%s: output %q does not mention %s", name, out.String(), want)
+		}
+		got, err := os.ReadFile(want)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(got) != "code\n" {
+			t.Errorf("%s: saved %q, want %q", name, got, "code\n")
+		}
+	}
+}
+
+func TestHandleCommandWithClipboard_Copy_ClipboardFails(t *testing.T) {
 	var out bytes.Buffer
-	_, err := HandleCommandWithClipboard("/copy", lastResponse, nil, &out)
+	_, err := HandleCommandWithClipboard("/copy", "```\ncode\n```", NewClipboardWriter("false"), &out)
+	if err == nil || !strings.HasPrefix(err.Error(), "Clipboard not available: ") {
+		t.Errorf("HandleCommandWithClipboard() error = %v, want clipboard failure reported", err)
+	}
+}
+
+func TestHandleCommand_Copy_Stamped(t *testing.T) {
+	clipboard := &mockClipboard{}
+	env := &CommandEnv{
+		Clipboard: clipboard,
+		Out:       &bytes.Buffer{},
+		Stamp: func(prompt string) (string, error) {
+			return "header\n" + prompt + "footer\n", nil
+		},
+	}
+
+	if _, err := HandleCommand("/copy", "```\ncode\n```", env); err != nil {
+		t.Fatalf("HandleCommand() error = %v", err)
+	}
+	if want := "header\ncode\nfooter\n"; clipboard.written != want {
+		t.Errorf("clipboard.written = %q, want %q", clipboard.written, want)
+	}
+}
+
+func TestHandleCommand_Note(t *testing.T) {
+	conv := NewConversation("system")
+	conv.AddUserMessage("idea")
+	conv.AddAssistantMessage("first draft")
+	session := &Session{}
+
+	var out bytes.Buffer
+	env := &CommandEnv{Conv: conv, Session: session, Out: &out}
+
+	if _, err := HandleCommand("/note tone is too formal", "", env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(session.Notes) != 1 || session.Notes[0].Draft != 1 || session.Notes[0].Text != "tone is too formal" {
+		t.Errorf("Notes = %+v, want one note on draft 1", session.Notes)
+	}
+	if len(conv.Messages) != 3 {
+		t.Errorf("note must not be added to the conversation, got %d messages", len(conv.Messages))
+	}
+
+	out.Reset()
+	HandleCommand("/note", "", env)
+	if !strings.Contains(out.String(), "draft 1: tone is too formal") {
+		t.Errorf("expected note listing, got: %s", out.String())
+	}
+}
+
+func TestHandleCommand_NoteBeforeDraft(t *testing.T) {
+	conv := NewConversation("system")
+	conv.AddUserMessage("idea")
 
+	var out bytes.Buffer
+	_, err := HandleCommand("/note early", "", &CommandEnv{Conv: conv, Session: &Session{}, Out: &out})
 	if err == nil {
-		t.Error("HandleCommandWithClipboard() expected error when clipboard unavailable")
+		t.Error("expected error when there is no draft yet")
 	}
-	wantErr := "Clipboard not available"
-	if err.Error() != wantErr {
-		t.Errorf("HandleCommandWithClipboard() error = %q, want %q", err.Error(), wantErr)
+}
+
+func TestHandleCommand_SetParams(t *testing.T) {
+	var out bytes.Buffer
+	params := &GenerationParams{}
+	env := &CommandEnv{Params: params, Out: &out}
+
+	for _, input := range []string{"/temp 0.9", "/max-tokens 2000", "/seed 42"} {
+		if _, err := HandleCommand(input, "", env); err != nil {
+			t.Fatalf("HandleCommand(%q) error = %v", input, err)
+		}
+	}
+	if params.Temperature == nil || *params.Temperature != 0.9 {
+		t.Errorf("Temperature = %v, want 0.9", params.Temperature)
+	}
+	if params.MaxTokens == nil || *params.MaxTokens != 2000 {
+		t.Errorf("MaxTokens = %v, want 2000", params.MaxTokens)
+	}
+	if params.Seed == nil || *params.Seed != 42 {
+		t.Errorf("Seed = %v, want 42", params.Seed)
+	}
+
+	out.Reset()
+	HandleCommand("/temp default", "", env)
+	if params.Temperature != nil {
+		t.Error("/temp default should clear temperature")
+	}
+	if !strings.Contains(out.String(), "temperature  default") || !strings.Contains(out.String(), "seed         42") {
+		t.Errorf("unexpected /params output: %q", out.String())
+	}
+}
+
+func TestHandleCommand_Model(t *testing.T) {
+	var out bytes.Buffer
+	session := &Session{Model: "llama3.2"}
+	aliases := map[string]string{"best": "openai/gpt-4o", "fast": "llama3.2:3b-instruct-q4"}
+	var switched string
+	env := &CommandEnv{Session: session, Aliases: aliases, Out: &out, SetModel: func(name string) (string, error) {
+		switched = name
+		session.Model = aliases[name]
+		return session.Model, nil
+	}}
+
+	if _, err := HandleCommand("/model", "", env); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Model: llama3.2\n  best → openai/gpt-4o\n  fast → ") {
+		t.Errorf("/model output = %q, want the model and sorted aliases", out.String())
+	}
+
+	out.Reset()
+	if _, err := HandleCommand("/model best", "", env); err != nil {
+		t.Fatal(err)
+	}
+	if switched != "best" || out.String() != "Model: openai/gpt-4o (best)\n" {
+		t.Errorf("/model best: switched = %q, output = %q", switched, out.String())
+	}
+
+	env.SetModel = nil
+	if _, err := HandleCommand("/model best", "", env); err == nil {
+		t.Error("/model without a client to switch succeeded, want an error")
+	}
+}
+
+func TestHandleCommand_Reload(t *testing.T) {
+	var out bytes.Buffer
+	env := &CommandEnv{Out: &out}
+	if _, err := HandleCommand("/reload", "", env); err == nil {
+		t.Error("/reload without a system prompt to reload succeeded, want an error")
+	}
+
+	env.Reload = func() (string, error) { return "/home/me/prompt.md", nil }
+	if _, err := HandleCommand("/reload", "", env); err != nil || out.String() != "✓ Reloaded /home/me/prompt.md\n" {
+		t.Errorf("/reload: %v, output %q", err, out.String())
+	}
+
+	env.Reload = func() (string, error) { return "", errors.New("system prompt not found") }
+	if _, err := HandleCommand("/reload", "", env); err == nil || !strings.Contains(err.Error(), "Cannot reload the system prompt: system prompt not found") {
+		t.Errorf("/reload of a missing file: error = %v", err)
+	}
+}
+
+func TestHandleCommand_SetParamsInvalid(t *testing.T) {
+	tests := []string{"/temp hot", "/temp 3", "/max-tokens 0", "/seed x", "/temp"}
+	for _, input := range tests {
+		var out bytes.Buffer
+		if _, err := HandleCommand(input, "", &CommandEnv{Params: &GenerationParams{}, Out: &out}); err == nil {
+			t.Errorf("HandleCommand(%q) expected error", input)
+		}
+	}
+}
+
+func TestSensitiveCopyCmd(t *testing.T) {
+	if c := sensitiveCopyCmd("pbcopy"); c == nil || c.Args[0] != "osascript" || !strings.Contains(c.Args[len(c.Args)-1], "org.nspasteboard.ConcealedType") {
+		t.Errorf("pbcopy should copy through osascript with the concealed type, got %v", c)
+	}
+	for _, cmd := range []string{"", "wl-copy", "xclip -selection clipboard"} {
+		if c := sensitiveCopyCmd(cmd); c != nil {
+			t.Errorf("sensitiveCopyCmd(%q) = %v, want nil", cmd, c.Args)
+		}
+	}
+}
+
+func TestSensitiveClipboardWriter_FallsBack(t *testing.T) {
+	// No sensitive variant for cat, so it runs as the plain command
+	if err := NewSensitiveClipboardWriter("cat").Write("text"); err != nil {
+		t.Errorf("Write() error = %v", err)
 	}
 }
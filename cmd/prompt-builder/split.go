@@ -0,0 +1,67 @@
+// split.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// splitInstruction is added to the system prompt by --split.
+const splitInstruction = `
+
+Deliver the final prompt as two fenced code blocks instead of one: first a block tagged system (` + "```system" + `) with the system message, holding the role, rules and output format; then a block tagged user (` + "```user" + `) with the user message, holding the task and anything that changes from request to request. Use {{placeholders}} in the user message for those parts.`
+
+// SplitPrompt is a final prompt delivered as a system and user message pair.
+type SplitPrompt struct {
+	System string `json:"system"`
+	User   string `json:"user"`
+}
+
+// splitPrompt finds the last system and user blocks in response. It
+// reports false unless both are present.
+func splitPrompt(response string) (SplitPrompt, bool) {
+	var p SplitPrompt
+	var hasSystem, hasUser bool
+	for _, b := range promptbuilder.CodeBlocks(response) {
+		switch strings.ToLower(b.Info) {
+		case "system":
+			p.System, hasSystem = strings.TrimSpace(b.Body), true
+		case "user":
+			p.User, hasUser = strings.TrimSpace(b.Body), true
+		}
+	}
+	return p, hasSystem && hasUser
+}
+
+// finalPrompt returns the prompt in response that is copied, saved and
+// printed, with stamp applied. For a session started with --split it is
+// the system and user messages as a JSON object, stamped in the system
+// message only; a reply without both blocks falls back to its last code
+// block. It returns "" when response has no code block.
+func finalPrompt(s *Session, response string, stamp func(string) (string, error)) (string, error) {
+	if s != nil && s.Split {
+		if p, ok := splitPrompt(response); ok {
+			system, err := stamp(p.System)
+			if err != nil {
+				return "", err
+			}
+			p.System = strings.TrimSuffix(system, "\n")
+			var b bytes.Buffer
+			enc := json.NewEncoder(&b)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(p); err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(b.String(), "\n"), nil
+		}
+	}
+	block := promptbuilder.ExtractLastCodeBlock(response)
+	if block == "" {
+		return "", nil
+	}
+	return stamp(block)
+}
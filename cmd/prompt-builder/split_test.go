@@ -0,0 +1,78 @@
+// split_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const splitReply = "Here it is.\n\n```system\nYou are a support agent.\n```\n\n```user\nAnswer this ticket: {{ticket}}\n```\n"
+
+func TestSplitPrompt(t *testing.T) {
+	p, ok := splitPrompt(splitReply)
+	if !ok || p.System != "You are a support agent." || p.User != "Answer this ticket: {{ticket}}" {
+		t.Errorf("splitPrompt() = %+v, %v, want both messages", p, ok)
+	}
+
+	if _, ok := splitPrompt("```\none block\n```"); ok {
+		t.Error("splitPrompt() found a pair in a single untagged block")
+	}
+}
+
+func TestFinalPrompt_Split(t *testing.T) {
+	stamp := func(p string) (string, error) { return "<!-- v1 -->\n" + p + "\n", nil }
+	got, err := finalPrompt(&Session{Split: true}, splitReply, stamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p SplitPrompt
+	if err := json.Unmarshal([]byte(got), &p); err != nil {
+		t.Fatalf("finalPrompt() = %q, not JSON: %v", got, err)
+	}
+	if p.System != "<!-- v1 -->\nYou are a support agent." || p.User != "Answer this ticket: {{ticket}}" {
+		t.Errorf("finalPrompt() = %+v, want the header on the system message only", p)
+	}
+	if strings.Contains(got, `\u003c`) {
+		t.Errorf("finalPrompt() = %q, want HTML left unescaped", got)
+	}
+}
+
+func TestFinalPrompt_FallsBackToLastBlock(t *testing.T) {
+	noStamp := func(p string) (string, error) { return p, nil }
+	got, err := finalPrompt(&Session{Split: true}, "```\nsingle prompt\n```", noStamp)
+	if err != nil || got != "single prompt\n" {
+		t.Errorf("finalPrompt() = %q, %v, want the last block", got, err)
+	}
+
+	got, err = finalPrompt(&Session{}, splitReply, noStamp)
+	if err != nil || got != "Answer this ticket: {{ticket}}\n" {
+		t.Errorf("finalPrompt() without split = %q, %v, want the last block", got, err)
+	}
+}
+
+func TestRun_Split_QuietPrintsJSON(t *testing.T) {
+	deps := newTestDeps(withResponses(splitReply), withTTY(false))
+	deps.Session = &Session{Idea: "support bot", Split: true}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support bot", Quiet: QuietPrompt, Split: true}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var p SplitPrompt
+	if err := json.Unmarshal([]byte(stdout(deps)), &p); err != nil || p.System == "" || p.User == "" {
+		t.Errorf("stdout = %q, want a JSON object with both messages (%v)", stdout(deps), err)
+	}
+}
+
+func TestHandleCommand_CopySplit(t *testing.T) {
+	clipboard := &mockClipboard{}
+	env := &CommandEnv{Session: &Session{Split: true}, Clipboard: clipboard, Out: &bytes.Buffer{}}
+	if _, err := HandleCommand("/copy", splitReply, env); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(clipboard.written, `"system": "You are a support agent."`) {
+		t.Errorf("copied %q, want the JSON pair", clipboard.written)
+	}
+}
@@ -0,0 +1,62 @@
+// stakeholder.go
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// stakeholderPrefix starts a user message sent with /as.
+const stakeholderPrefix = "Feedback from "
+
+// stakeholderMessage attributes text to label, and asks the model to
+// reconcile when others have already weighed in.
+func stakeholderMessage(label, text string, others []string) string {
+	msg := stakeholderPrefix + label + ":\n" + text
+	if len(others) > 0 {
+		msg += fmt.Sprintf("\n\nEarlier feedback came from %s. Where these asks conflict, say so and propose how to reconcile them in the prompt.", strings.Join(others, ", "))
+	}
+	return msg
+}
+
+// stakeholders returns the labels of earlier /as messages, in the order
+// they first spoke.
+func stakeholders(messages []Message) []string {
+	var labels []string
+	for _, m := range messages {
+		rest, ok := strings.CutPrefix(m.Content, stakeholderPrefix)
+		if m.Role != "user" || !ok {
+			continue
+		}
+		label, _, ok := strings.Cut(rest, ":\n")
+		seen := slices.ContainsFunc(labels, func(l string) bool { return strings.EqualFold(l, label) })
+		if ok && !seen {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// handleAs implements /as <stakeholder> <text>: it sends text as feedback
+// attributed to the stakeholder.
+func handleAs(args string, env *CommandEnv) error {
+	if env.Tabs == nil || env.Conv == nil {
+		return fmt.Errorf("/as is not available here")
+	}
+	label, text, _ := strings.Cut(args, " ")
+	text = strings.TrimSpace(text)
+	if label == "" || text == "" {
+		return fmt.Errorf("Usage: /as <stakeholder> <feedback>")
+	}
+
+	var others []string
+	for _, s := range stakeholders(env.Conv.Messages) {
+		if !strings.EqualFold(s, label) {
+			others = append(others, s)
+		}
+	}
+	env.Conv.AddUserMessage(stakeholderMessage(label, text, others))
+	env.Tabs.Current().AwaitingReply = true
+	return nil
+}
@@ -0,0 +1,74 @@
+// stakeholder_test.go
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHandleCommand_As(t *testing.T) {
+	conv := NewConversation("sys")
+	conv.AddUserMessage("idea")
+	conv.AddAssistantMessage("draft")
+	tabs := NewTabs("sys", &Tab{Conv: conv})
+	env := &CommandEnv{Conv: conv, Tabs: tabs}
+
+	for _, bad := range []string{"/as", "/as legal", "/as legal   "} {
+		if _, err := HandleCommand(bad, "", env); err == nil {
+			t.Errorf("%q: expected usage error", bad)
+		}
+	}
+
+	if _, err := HandleCommand("/as PM ship it by Friday", "", env); err != nil {
+		t.Fatal(err)
+	}
+	if !tabs.Current().AwaitingReply {
+		t.Error("/as should ask the model")
+	}
+	first := conv.Messages[len(conv.Messages)-1].Content
+	if first != "Feedback from PM:\nship it by Friday" {
+		t.Errorf("first message = %q", first)
+	}
+
+	HandleCommand("/as pm also keep it short", "", env)
+	if got := conv.Messages[len(conv.Messages)-1].Content; strings.Contains(got, "reconcile") {
+		t.Errorf("same stakeholder again = %q, want no reconcile request", got)
+	}
+
+	HandleCommand("/as legal no customer names", "", env)
+	last := conv.Messages[len(conv.Messages)-1].Content
+	if !strings.HasPrefix(last, "Feedback from legal:\nno customer names") || !strings.Contains(last, "Earlier feedback came from PM.") {
+		t.Errorf("second stakeholder message = %q, want attribution and a reconcile request", last)
+	}
+}
+
+func TestStakeholders(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "Feedback from PM:\nx"},
+		{Role: "assistant", Content: "Feedback from model:\ny"},
+		{Role: "user", Content: "Feedback from legal:\nz"},
+		{Role: "user", Content: "Feedback from pm:\nagain"},
+		{Role: "user", Content: "plain message"},
+	}
+	if got := strings.Join(stakeholders(messages), ","); got != "PM,legal" {
+		t.Errorf("stakeholders() = %q, want PM,legal", got)
+	}
+}
+
+func TestRunWithDeps_AsSendsAttributedFeedback(t *testing.T) {
+	deps := newTestDeps(
+		withResponses("```\ndraft\n```", "```\nrevised\n```"),
+		withStdin("/as legal drop the customer names\n/quit\n"),
+	)
+	if err := runWithDeps(context.Background(), &CLI{Idea: "idea"}, deps); err != nil {
+		t.Fatal(err)
+	}
+	mock := deps.Client.(*mockLLM)
+	if mock.calls != 2 {
+		t.Fatalf("calls = %d, want /as to start a turn", mock.calls)
+	}
+	if got := mock.last[len(mock.last)-1].Content; got != "Feedback from legal:\ndrop the customer names" {
+		t.Errorf("sent %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+// stall.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// defaultStallTimeout is how long a response may send nothing before it
+// is given up. It is generous, since a large model can pause for a while
+// between tokens on slow hardware.
+const defaultStallTimeout = 2 * time.Minute
+
+// maxStallRetries bounds how often a stalled request is sent again.
+const maxStallRetries = 1
+
+// stallTransport aborts a response whose body sends nothing for timeout,
+// so a server that hangs mid-stream does not leave the tool waiting
+// forever. The wait starts once the response headers arrive.
+type stallTransport struct {
+	timeout time.Duration
+	next    http.RoundTripper
+}
+
+func (t stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &stallBody{ReadCloser: resp.Body, timeout: t.timeout, cancel: cancel}
+	body.timer = time.AfterFunc(t.timeout, func() {
+		body.stalled.Store(true)
+		cancel()
+	})
+	resp.Body = body
+	return resp, nil
+}
+
+type stallBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+	cancel  context.CancelFunc
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.stalled.Load() {
+		return n, &LLMError{Kind: errStalled, Message: fmt.Sprintf("no data for %s", b.timeout)}
+	}
+	b.timer.Reset(b.timeout)
+	return n, err
+}
+
+func (b *stallBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
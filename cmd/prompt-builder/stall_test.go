@@ -0,0 +1,100 @@
+// stall_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStallTransport_AbortsSilentStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // hang like a stuck server
+	}))
+	defer server.Close()
+
+	cfg := &Config{Provider: providerCompatible, StallTimeout: Duration(50 * time.Millisecond)}
+	client, err := newLLMClient(cfg, server.URL, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	_, err = client.ChatStream(context.Background(), nil, func(token string) error {
+		got += token
+		return nil
+	})
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) || llmErr.Kind != errStalled {
+		t.Fatalf("error = %v, want a stalled stream", err)
+	}
+	if got != "Hel" || !strings.Contains(llmErr.Error(), "the stream stalled - no data for 50ms") {
+		t.Errorf("tokens = %q, error = %q", got, llmErr.Error())
+	}
+}
+
+func TestStallTransport_SlowStreamIsFine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, token := range []string{"a", "b", "c"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", token)
+			w.(http.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	cfg := &Config{Provider: providerCompatible, StallTimeout: Duration(200 * time.Millisecond)}
+	client, _ := newLLMClient(cfg, server.URL, "m")
+	reply, err := client.ChatStream(context.Background(), nil, func(string) error { return nil })
+	if err != nil || reply != "abc" {
+		t.Errorf("ChatStream() = %q, %v, want the whole reply", reply, err)
+	}
+}
+
+// stallOnceLLM stalls on its first request, then answers like mockLLM.
+type stallOnceLLM struct {
+	*mockLLM
+	stalled bool
+}
+
+func (s *stallOnceLLM) ChatStreamWithSpinner(ctx context.Context, messages []Message, tty bool, onToken StreamCallback) (string, error) {
+	if !s.stalled {
+		s.stalled = true
+		onToken("Half a ")
+		return "", fmt.Errorf("error reading stream: %w", &LLMError{Kind: errStalled, Message: "no data for 2m0s"})
+	}
+	return s.mockLLM.ChatStreamWithSpinner(ctx, messages, tty, onToken)
+}
+
+func TestRun_StalledStreamIsRetried(t *testing.T) {
+	deps := newTestDeps(withResponses("```\nprompt\n```"), withTTY(false))
+	deps.Client = &stallOnceLLM{mockLLM: deps.Client.(*mockLLM)}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "test", Quiet: QuietPrompt}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(stdout(deps)) != "prompt" {
+		t.Errorf("stdout = %q, want the prompt from the retry", stdout(deps))
+	}
+	if !strings.Contains(stderr(deps), "the stream stalled with no data for 2m0s; retrying") {
+		t.Errorf("stderr = %q, want a note about the retry", stderr(deps))
+	}
+}
+
+func TestRun_StalledStreamAfterOutputFails(t *testing.T) {
+	// A script reading the conversation already has half a reply
+	deps := newTestDeps(withResponses("```\nprompt\n```"), withTTY(false))
+	deps.Client = &stallOnceLLM{mockLLM: deps.Client.(*mockLLM)}
+
+	err := runWithDeps(context.Background(), &CLI{Idea: "test"}, deps)
+	if err == nil || !strings.HasPrefix(err.Error(), "LLM request failed: the stream stalled") {
+		t.Errorf("error = %v, want the stall reported", err)
+	}
+}
@@ -0,0 +1,130 @@
+// summarize.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSummaryPasses bounds how often summaries are summarized again.
+const maxSummaryPasses = 3
+
+// minSummaryTokens keeps per-chunk summaries useful when there are many chunks.
+const minSummaryTokens = 100
+
+const summarizeSystemPrompt = "You condense reference material for a prompt engineer. Keep requirements, names, numbers, interfaces and constraints. Drop examples, boilerplate and repetition. Reply with the summary only."
+
+// ChunkText splits text into pieces of at most maxTokens estimated tokens,
+// breaking at blank lines where possible, then at line ends, then anywhere.
+func ChunkText(text string, maxTokens int) []string {
+	return chunkBy(text, max(maxTokens, 1)*4, []string{"\n\n", "\n"})
+}
+
+func chunkBy(text string, maxBytes int, seps []string) []string {
+	if len(text) <= maxBytes {
+		return []string{text}
+	}
+	if len(seps) == 0 {
+		var chunks []string
+		for len(text) > maxBytes {
+			cut := maxBytes
+			for !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			chunks = append(chunks, text[:cut])
+			text = text[cut:]
+		}
+		return append(chunks, text)
+	}
+
+	sep := seps[0]
+	var chunks []string
+	var cur strings.Builder
+	for _, part := range strings.Split(text, sep) {
+		if cur.Len() > 0 && cur.Len()+len(sep)+len(part) > maxBytes {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+		}
+		if len(part) > maxBytes {
+			chunks = append(chunks, chunkBy(part, maxBytes, seps[1:])...)
+			continue
+		}
+		if cur.Len() > 0 {
+			cur.WriteString(sep)
+		}
+		cur.WriteString(part)
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
+
+// SummarizeToFit returns text unchanged when it fits in budget tokens.
+// Otherwise it has the model summarize each chunk (map) and joins the
+// summaries, summarizing those again while they are still too large
+// (reduce). name labels the material in the summarization requests.
+func SummarizeToFit(ctx context.Context, client LLMClient, name, text string, budget int) (string, error) {
+	for pass := 0; pass < maxSummaryPasses; pass++ {
+		if EstimateTokens(text) <= budget {
+			return text, nil
+		}
+		chunks := ChunkText(text, budget)
+		target := max(budget/len(chunks), minSummaryTokens)
+
+		summaries := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			summary, err := summarizeChunk(ctx, client, name, chunk, i+1, len(chunks), target)
+			if err != nil {
+				return "", err
+			}
+			summaries = append(summaries, summary)
+		}
+		text = strings.Join(summaries, "\n\n")
+	}
+	if EstimateTokens(text) > budget {
+		return "", fmt.Errorf("%s is still ~%d tokens after summarizing, over the budget of %d", name, EstimateTokens(text), budget)
+	}
+	return text, nil
+}
+
+func summarizeChunk(ctx context.Context, client LLMClient, name, chunk string, n, total, targetTokens int) (string, error) {
+	messages := []Message{
+		{Role: "system", Content: summarizeSystemPrompt},
+		{Role: "user", Content: fmt.Sprintf("Summarize part %d of %d of %s in at most %d words.\n\n%s", n, total, name, targetTokens*3/4, chunk)},
+	}
+	summary, err := client.ChatStream(ctx, messages, func(string) error { return nil })
+	if err != nil {
+		return "", withKind(ErrLLM, fmt.Errorf("LLM request failed while summarizing %s: %w", name, err))
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+// defaultInputBudget is how many tokens one input may take before it is
+// summarized when context_window is not set: about 100 KB of text.
+const defaultInputBudget = 25_000
+
+// inputBudget returns how many tokens one input, the idea or the attached
+// files, may take before it is summarized: a quarter of what fits in the
+// context window, which leaves room for the system prompt, the other
+// inputs and the conversation.
+func inputBudget(cfg *Config) int {
+	if cfg.ContextWindow > 0 {
+		return contextBudget(cfg.ContextWindow, cfg.GenerationParams) / 4
+	}
+	return defaultInputBudget
+}
+
+// condenseInput returns text, summarized with SummarizeToFit when it does
+// not fit in budget tokens. It tells status before the summary starts, as
+// that takes a request per chunk.
+func condenseInput(ctx context.Context, client LLMClient, name, text string, budget int, status io.Writer) (string, error) {
+	if EstimateTokens(text) <= budget {
+		return text, nil
+	}
+	fmt.Fprintf(status, "Summarizing %s (~%d tokens) to fit in ~%d tokens...\n", name, EstimateTokens(text), budget)
+	return SummarizeToFit(ctx, client, name, text, budget)
+}
@@ -0,0 +1,101 @@
+// summarize_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestChunkText(t *testing.T) {
+	paras := []string{strings.Repeat("a", 30), strings.Repeat("b", 30), strings.Repeat("c", 30)}
+	text := strings.Join(paras, "\n\n")
+
+	chunks := ChunkText(text, 20) // 80 bytes
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2: %q", len(chunks), chunks)
+	}
+	if chunks[0] != paras[0]+"\n\n"+paras[1] || chunks[1] != paras[2] {
+		t.Errorf("chunks should break at blank lines, got %q", chunks)
+	}
+}
+
+func TestChunkText_LongParagraph(t *testing.T) {
+	text := strings.Repeat("é", 100) // 200 bytes, no separators
+
+	chunks := ChunkText(text, 10)
+	if strings.Join(chunks, "") != text {
+		t.Error("chunks do not reassemble the input")
+	}
+	for _, c := range chunks {
+		if EstimateTokens(c) > 10 || !strings.HasPrefix(c, "é") {
+			t.Errorf("chunk %q exceeds the limit or splits a rune", c)
+		}
+	}
+}
+
+func TestSummarizeToFit_Fits(t *testing.T) {
+	client := &mockLLM{}
+
+	got, err := SummarizeToFit(context.Background(), client, "spec.md", "short", 100)
+	if err != nil || got != "short" {
+		t.Errorf("SummarizeToFit(%q) = %q, %v; want text unchanged", "short", got, err)
+	}
+	if client.calls != 0 {
+		t.Errorf("model was called %d times, want 0", client.calls)
+	}
+}
+
+func TestSummarizeToFit_MapReduce(t *testing.T) {
+	text := strings.Repeat(strings.Repeat("x", 300)+"\n\n", 3)
+	client := &mockLLM{responses: []string{"one", "two", "three", "four"}}
+
+	got, err := SummarizeToFit(context.Background(), client, "spec.md", text, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "one") || EstimateTokens(got) > 100 {
+		t.Errorf("SummarizeToFit(spec.md) = %q, want joined summaries within budget", got)
+	}
+	if client.last[0].Content != summarizeSystemPrompt || !strings.Contains(client.last[1].Content, "spec.md") {
+		t.Errorf("summary request missing instructions or name: %+v", client.last)
+	}
+}
+
+func TestSummarizeToFit_LLMError(t *testing.T) {
+	client := &mockLLM{}
+
+	_, err := SummarizeToFit(context.Background(), client, "spec.md", strings.Repeat("x", 1000), 10)
+	if err == nil || !strings.Contains(err.Error(), "LLM request failed") {
+		t.Errorf("expected LLM error, got: %v", err)
+	}
+}
+
+func TestInputBudget(t *testing.T) {
+	if got := inputBudget(&Config{}); got != defaultInputBudget {
+		t.Errorf("inputBudget without context_window = %d, want %d", got, defaultInputBudget)
+	}
+	if got := inputBudget(&Config{ContextWindow: 8192}); got != 8192*7/8/4 {
+		t.Errorf("inputBudget(8192) = %d, want a quarter of the prompt budget", got)
+	}
+}
+
+func TestCondenseInput(t *testing.T) {
+	client := &mockLLM{responses: []string{"the gist", "and the rest"}}
+	var status bytes.Buffer
+
+	got, err := condenseInput(context.Background(), client, "the idea", "short idea", 100, &status)
+	if err != nil || got != "short idea" || client.calls != 0 || status.Len() != 0 {
+		t.Errorf("condenseInput(%q) = %q, %v after %d calls; want it unchanged and unannounced", "short idea", got, err, client.calls)
+	}
+
+	long := strings.Repeat("word ", 200)
+	got, err = condenseInput(context.Background(), client, "the idea", long, 200, &status)
+	if err != nil || got != "the gist\n\nand the rest" {
+		t.Errorf("condenseInput(long idea) = %q, %v; want the summaries of its two chunks", got, err)
+	}
+	if !strings.Contains(status.String(), "Summarizing the idea") {
+		t.Errorf("status = %q, want a note about the summary", status.String())
+	}
+}
@@ -0,0 +1,58 @@
+// summary.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// sessionSummary describes what an interactive session produced. It is
+// printed on exit so it is clear which artifacts exist and where.
+type sessionSummary struct {
+	Turns        int
+	Elapsed      time.Duration
+	PromptTokens int    // estimated size of the final prompt, 0 if none
+	Copied       bool   // the final prompt went to the clipboard
+	CopiedTo     string // the file it went to instead, when there was no clipboard
+	SavedAs      string // session file, if saved
+}
+
+func (s sessionSummary) String() string {
+	turns := "turns"
+	if s.Turns == 1 {
+		turns = "turn"
+	}
+	parts := []string{
+		fmt.Sprintf("%d %s", s.Turns, turns),
+		s.Elapsed.Round(time.Second).String(),
+	}
+	if s.PromptTokens > 0 {
+		parts = append(parts, fmt.Sprintf("final prompt ~%d tokens", s.PromptTokens))
+	}
+	switch {
+	case s.CopiedTo != "":
+		parts = append(parts, "saved to "+s.CopiedTo)
+	case s.Copied:
+		parts = append(parts, "copied to clipboard")
+	}
+	if s.SavedAs != "" {
+		parts = append(parts, "saved as "+s.SavedAs)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// summarizeTab builds the exit summary for tab. copiedTo is the file /copy
+// saved the prompt to when there was no clipboard.
+func summarizeTab(tab *Tab, elapsed time.Duration, copied bool, copiedTo, savedAs string) sessionSummary {
+	return sessionSummary{
+		Turns:        draftCount(tab.Conv.Messages),
+		Elapsed:      elapsed,
+		PromptTokens: EstimateTokens(promptbuilder.ExtractLastCodeBlock(tab.Response)),
+		Copied:       copied,
+		CopiedTo:     copiedTo,
+		SavedAs:      savedAs,
+	}
+}
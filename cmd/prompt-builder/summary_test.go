@@ -0,0 +1,60 @@
+// summary_test.go
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSessionSummary_String(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary sessionSummary
+		want    string
+	}{
+		{
+			"everything",
+			sessionSummary{Turns: 4, Elapsed: 133*time.Second + 400*time.Millisecond, PromptTokens: 412, Copied: true, SavedAs: "sessions/2024-06-01-1.json"},
+			"4 turns · 2m13s · final prompt ~412 tokens · copied to clipboard · saved as sessions/2024-06-01-1.json",
+		},
+		{
+			"no prompt yet",
+			sessionSummary{Turns: 1, Elapsed: 9 * time.Second},
+			"1 turn · 9s",
+		},
+		{
+			"no clipboard",
+			sessionSummary{Turns: 2, Elapsed: time.Minute, Copied: true, CopiedTo: "/tmp/last-prompt.md"},
+			"2 turns · 1m0s · saved to /tmp/last-prompt.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.summary.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeTab(t *testing.T) {
+	conv := NewConversation("system")
+	conv.AddUserMessage("idea")
+	conv.AddAssistantMessage("Who is it for?")
+	conv.AddUserMessage("me")
+	conv.AddAssistantMessage("```\n" + string(make([]byte, 40)) + "```")
+	tab := &Tab{Conv: conv, Response: conv.Messages[len(conv.Messages)-1].Content}
+
+	got := summarizeTab(tab, time.Minute, true, "", "")
+	if got.Turns != 2 || got.PromptTokens != 10 || !got.Copied {
+		t.Errorf("summarizeTab() = %+v, want 2 turns, 10 tokens, copied", got)
+	}
+
+	t.Setenv(homeEnv, t.TempDir())
+	env := &CommandEnv{Out: &bytes.Buffer{}}
+	if _, err := HandleCommand("/copy", tab.Response, env); err != nil || env.CopiedTo != lastPromptPath() {
+		t.Errorf("/copy without a clipboard: CopiedTo = %q, %v; want %s", env.CopiedTo, err, lastPromptPath())
+	}
+}
@@ -0,0 +1,136 @@
+// tabs.go
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tab is one conversation in an interactive run.
+type Tab struct {
+	Conv          *Conversation
+	Session       *Session
+	Params        GenerationParams
+	Response      string     // last assistant reply
+	AwaitingReply bool       // the model owes a reply to the last user message
+	Push          *pushState // the requirement /push insists on, until a draft has it
+}
+
+// Tabs holds the parallel conversations of an interactive run. They share
+// the LLM client; only the current tab talks to the model.
+type Tabs struct {
+	list         []*Tab
+	current      int
+	systemPrompt string
+
+	// Defaults are the generation settings a new tab starts with
+	Defaults GenerationParams
+
+	// AssumeAnswers makes the model answer its own questions in every tab
+	AssumeAnswers bool
+}
+
+// NewTabs starts with first as the only, current tab.
+func NewTabs(systemPrompt string, first *Tab) *Tabs {
+	return &Tabs{list: []*Tab{first}, systemPrompt: systemPrompt}
+}
+
+// Current returns the tab receiving input.
+func (t *Tabs) Current() *Tab {
+	return t.list[t.current]
+}
+
+// All returns every tab in opening order.
+func (t *Tabs) All() []*Tab {
+	return t.list
+}
+
+// Open starts a conversation for idea in a new tab and makes it current.
+func (t *Tabs) Open(idea string) *Tab {
+	session := &Session{Idea: idea, CreatedAt: time.Now()}
+	if s := t.list[0].Session; s != nil {
+		// Same system prompt, so the same experiment variant
+		session.Model, session.Experiment = s.Model, s.Experiment
+	}
+	tab := &Tab{
+		Conv:          NewConversation(t.systemPrompt),
+		Session:       session,
+		Params:        t.Defaults,
+		AwaitingReply: true,
+	}
+	tab.Conv.AddUserMessage(idea)
+	t.list = append(t.list, tab)
+	t.current = len(t.list) - 1
+	return tab
+}
+
+// SetSystemPrompt replaces the system message of every tab, and the
+// system prompt tabs opened later start with.
+func (t *Tabs) SetSystemPrompt(prompt string) {
+	t.systemPrompt = prompt
+	for _, tab := range t.list {
+		if msgs := tab.Conv.Messages; len(msgs) > 0 && msgs[0].Role == "system" {
+			msgs[0].Content = prompt
+		}
+	}
+}
+
+// Switch makes tab n (1-based) current.
+func (t *Tabs) Switch(n int) error {
+	if n < 1 || n > len(t.list) {
+		return fmt.Errorf("No tab %d. Type /tabs to list them.", n)
+	}
+	t.current = n - 1
+	return nil
+}
+
+// handleNewTab implements /new "<idea>".
+func handleNewTab(args string, env *CommandEnv) error {
+	if env.Tabs == nil {
+		return fmt.Errorf("Tabs are not available here")
+	}
+	idea := strings.Trim(args, `"'`)
+	if idea == "" {
+		return fmt.Errorf(`Usage: /new "<idea>"`)
+	}
+	env.Tabs.Open(idea)
+	fmt.Fprintf(env.Out, "Opened tab %d\n", len(env.Tabs.All()))
+	return nil
+}
+
+// handleListTabs implements /tabs.
+func handleListTabs(env *CommandEnv) error {
+	if env.Tabs == nil {
+		return fmt.Errorf("Tabs are not available here")
+	}
+	for i, tab := range env.Tabs.All() {
+		marker := " "
+		if tab == env.Tabs.Current() {
+			marker = "*"
+		}
+		fmt.Fprintf(env.Out, "%s %d  %s (%d drafts)\n", marker, i+1, firstLine(tab.Session.Idea, 50), draftCount(tab.Conv.Messages))
+	}
+	return nil
+}
+
+// handleSwitchTab implements /switch <n>, showing the tab's last reply.
+func handleSwitchTab(args string, env *CommandEnv) error {
+	if env.Tabs == nil {
+		return fmt.Errorf("Tabs are not available here")
+	}
+	n, err := strconv.Atoi(args)
+	if err != nil {
+		return fmt.Errorf("Usage: /switch <n>")
+	}
+	if err := env.Tabs.Switch(n); err != nil {
+		return err
+	}
+	tab := env.Tabs.Current()
+	fmt.Fprintf(env.Out, "Switched to tab %d: %s\n", n, firstLine(tab.Session.Idea, 50))
+	if tab.Response != "" {
+		fmt.Fprintln(env.Out, tab.Response)
+	}
+	return nil
+}
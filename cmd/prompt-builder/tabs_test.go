@@ -0,0 +1,84 @@
+// tabs_test.go
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTabs_OpenAndSwitch(t *testing.T) {
+	first := &Tab{Conv: NewConversation("system"), Session: &Session{Idea: "first", Model: "llama3.2"}}
+	tabs := NewTabs("system", first)
+	temp := 0.2
+	tabs.Defaults = GenerationParams{Temperature: &temp}
+
+	second := tabs.Open("second idea")
+	if tabs.Current() != second {
+		t.Error("Open should make the new tab current")
+	}
+	if !second.AwaitingReply {
+		t.Error("new tab should await a reply")
+	}
+	if second.Session.Model != "llama3.2" {
+		t.Errorf("new tab model = %q, want inherited %q", second.Session.Model, "llama3.2")
+	}
+	if deref(second.Params.Temperature) != temp {
+		t.Errorf("new tab params = %+v, want the defaults", second.Params)
+	}
+	if msgs := second.Conv.Messages; len(msgs) != 2 || msgs[0].Content != "system" || msgs[1].Content != "second idea" {
+		t.Errorf("new tab messages = %+v", msgs)
+	}
+
+	if err := tabs.Switch(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tabs.Current() != first {
+		t.Error("Switch(1) should select the first tab")
+	}
+	if err := tabs.Switch(3); err == nil {
+		t.Error("expected error switching to missing tab")
+	}
+}
+
+func TestTabs_SetSystemPrompt(t *testing.T) {
+	first := &Tab{Conv: NewConversation("old"), Session: &Session{Idea: "first"}}
+	tabs := NewTabs("old", first)
+	tabs.Open("second idea")
+
+	tabs.SetSystemPrompt("new")
+	third := tabs.Open("third idea")
+	for i, tab := range []*Tab{first, tabs.All()[1], third} {
+		if msgs := tab.Conv.Messages; msgs[0].Content != "new" {
+			t.Errorf("tab %d messages = %+v, want the new system prompt", i+1, msgs)
+		}
+	}
+}
+
+func TestHandleCommand_Tabs(t *testing.T) {
+	first := &Tab{Conv: NewConversation("system"), Session: &Session{Idea: "first idea"}, Response: "first reply"}
+	tabs := NewTabs("system", first)
+	var out bytes.Buffer
+	env := &CommandEnv{Tabs: tabs, Out: &out}
+
+	if _, err := HandleCommand(`/new "second idea"`, "", env); err != nil {
+		t.Fatalf("/new error: %v", err)
+	}
+	if got := tabs.Current().Session.Idea; got != "second idea" {
+		t.Errorf("new tab idea = %q, want quotes stripped", got)
+	}
+
+	out.Reset()
+	HandleCommand("/tabs", "", env)
+	if !strings.Contains(out.String(), "  1  first idea") || !strings.Contains(out.String(), "* 2  second idea") {
+		t.Errorf("unexpected /tabs output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if _, err := HandleCommand("/switch 1", "", env); err != nil {
+		t.Fatalf("/switch error: %v", err)
+	}
+	if !strings.Contains(out.String(), "first reply") {
+		t.Errorf("/switch should show the tab's last reply, got: %s", out.String())
+	}
+}
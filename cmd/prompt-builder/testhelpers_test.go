@@ -3,8 +3,10 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"strings"
+	"testing"
 )
 
 // mockLLM implements LLMClient for testing.
@@ -12,9 +14,17 @@ type mockLLM struct {
 	responses []string
 	calls     int
 	err       error
+	last      []Message // messages from the most recent call
+	params    GenerationParams
+	spinner   bool   // whether the last call asked for a spinner
+	usage     *Usage // reported for every call when set
 }
 
-func (m *mockLLM) ChatStream(messages []Message, onToken StreamCallback) (string, error) {
+func (m *mockLLM) ChatStream(ctx context.Context, messages []Message, onToken StreamCallback) (string, error) {
+	m.last = messages
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	if m.err != nil {
 		return "", m.err
 	}
@@ -24,17 +34,32 @@ func (m *mockLLM) ChatStream(messages []Message, onToken StreamCallback) (string
 	resp := m.responses[m.calls]
 	m.calls++
 
-	// Simulate streaming by calling callback with chunks
-	for _, chunk := range strings.Split(resp, " ") {
-		if err := onToken(chunk + " "); err != nil {
+	// Simulate streaming by calling callback with word-sized chunks
+	for _, chunk := range strings.SplitAfter(resp, " ") {
+		if chunk == "" {
+			continue
+		}
+		if err := onToken(chunk); err != nil {
 			return "", err
 		}
 	}
 	return resp, nil
 }
 
-func (m *mockLLM) ChatStreamWithSpinner(messages []Message, tty bool, onToken StreamCallback) (string, error) {
-	return m.ChatStream(messages, onToken)
+func (m *mockLLM) SetParams(params GenerationParams) {
+	m.params = params
+}
+
+func (m *mockLLM) LastUsage() (Usage, bool) {
+	if m.usage == nil {
+		return Usage{}, false
+	}
+	return *m.usage, true
+}
+
+func (m *mockLLM) ChatStreamWithSpinner(ctx context.Context, messages []Message, tty bool, onToken StreamCallback) (string, error) {
+	m.spinner = tty
+	return m.ChatStream(ctx, messages, onToken)
 }
 
 // mockClipboard implements ClipboardWriter for testing.
@@ -56,6 +81,7 @@ type testOption func(*Deps)
 
 // newTestDeps creates Deps with mocks for testing.
 func newTestDeps(opts ...testOption) *Deps {
+	cfg := defaultConfig()
 	d := &Deps{
 		Client:       &mockLLM{},
 		Stdin:        strings.NewReader(""),
@@ -63,7 +89,9 @@ func newTestDeps(opts ...testOption) *Deps {
 		Stderr:       &bytes.Buffer{},
 		Clipboard:    &mockClipboard{},
 		IsTTY:        func() bool { return true },
+		StatusTTY:    func() bool { return false },
 		SystemPrompt: "You are a test assistant.",
+		Config:       &cfg,
 	}
 	for _, opt := range opts {
 		opt(d)
@@ -112,3 +140,12 @@ func clipboardWritten(d *Deps) string {
 	}
 	return ""
 }
+
+// requireBuild skips t in a binary built with -tags tag, which leaves out
+// what t tests.
+func requireBuild(t *testing.T, built bool, tag string) {
+	t.Helper()
+	if !built {
+		t.Skipf("built with -tags %s", tag)
+	}
+}
@@ -0,0 +1,61 @@
+// title.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// titleWriter shows the state of a run in the terminal title, and so in
+// the tab bar (terminal_title): OSC 0 sets the title and OSC 9;4 shows a
+// busy indicator in terminals that have one. The title in place before
+// is saved and restored.
+type titleWriter struct {
+	out       io.Writer
+	start     time.Time // when the current request was sent
+	streaming bool
+}
+
+func newTitleWriter(out io.Writer) *titleWriter {
+	fmt.Fprint(out, "\x1b[22;0t") // push the current title
+	return &titleWriter{out: out}
+}
+
+// thinking marks a request as sent at now.
+func (t *titleWriter) thinking(now time.Time) {
+	t.start, t.streaming = now, false
+	t.set("thinking…", true)
+}
+
+// token marks the reply as streaming, on its first token.
+func (t *titleWriter) token() {
+	if !t.streaming {
+		t.streaming = true
+		t.set("writing…", true)
+	}
+}
+
+// done shows what the reply left the user with and how long it took.
+// drafts is the number of replies so far.
+func (t *titleWriter) done(now time.Time, drafts int, complete bool) {
+	took := now.Sub(t.start).Round(time.Second)
+	if complete {
+		t.set(fmt.Sprintf("draft %d ready (%s)", drafts, took), false)
+	} else {
+		t.set(fmt.Sprintf("your turn (%s)", took), false)
+	}
+}
+
+func (t *titleWriter) set(state string, busy bool) {
+	progress := 0 // none
+	if busy {
+		progress = 3 // indeterminate
+	}
+	fmt.Fprintf(t.out, "\x1b]0;prompt-builder: %s\x07\x1b]9;4;%d\x07", state, progress)
+}
+
+// restore clears the busy indicator and pops the title saved earlier.
+func (t *titleWriter) restore() {
+	fmt.Fprint(t.out, "\x1b]9;4;0\x07\x1b[23;0t")
+}
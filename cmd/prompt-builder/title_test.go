@@ -0,0 +1,36 @@
+// title_test.go
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTitleWriter(t *testing.T) {
+	var out bytes.Buffer
+	title := newTitleWriter(&out)
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	title.thinking(start)
+	title.token()
+	title.token()
+	title.done(start.Add(12400*time.Millisecond), 3, true)
+	title.thinking(start.Add(time.Minute))
+	title.done(start.Add(time.Minute+2*time.Second), 4, false)
+	title.restore()
+
+	want := []string{
+		"\x1b[22;0t",
+		"\x1b]0;prompt-builder: thinking…\x07\x1b]9;4;3\x07",
+		"\x1b]0;prompt-builder: writing…\x07\x1b]9;4;3\x07",
+		"\x1b]0;prompt-builder: draft 3 ready (12s)\x07\x1b]9;4;0\x07",
+		"\x1b]0;prompt-builder: thinking…\x07\x1b]9;4;3\x07",
+		"\x1b]0;prompt-builder: your turn (2s)\x07\x1b]9;4;0\x07",
+		"\x1b]9;4;0\x07\x1b[23;0t",
+	}
+	if got := out.String(); got != strings.Join(want, "") {
+		t.Errorf("output = %q\nwant     %q", got, strings.Join(want, ""))
+	}
+}
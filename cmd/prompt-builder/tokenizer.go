@@ -0,0 +1,95 @@
+// tokenizer.go
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// heuristicSpec names the tokenizer used when nothing better is known.
+const heuristicSpec = "heuristic"
+
+// Tokenizer counts the tokens text takes for a model.
+type Tokenizer interface {
+	// Spec names the tokenizer in the form newTokenizer reads, such as
+	// o200k_base or sentencepiece:~/models/llama/tokenizer.model.
+	Spec() string
+	Count(text string) int
+}
+
+// tokenizers caches loaded tokenizers by spec, since a SentencePiece
+// model is read from disk and counted with on every turn.
+var tokenizers sync.Map
+
+// newTokenizer loads the tokenizer a spec names: heuristic, a tiktoken
+// encoding such as o200k_base (optionally written tiktoken:o200k_base), or
+// sentencepiece:PATH for a SentencePiece .model file.
+func newTokenizer(spec string) (Tokenizer, error) {
+	if t, ok := tokenizers.Load(spec); ok {
+		return t.(Tokenizer), nil
+	}
+	var t Tokenizer
+	kind, arg, found := strings.Cut(spec, ":")
+	switch {
+	case spec == heuristicSpec:
+		t = heuristicTokenizer{}
+	case kind == "sentencepiece" && found:
+		m, err := loadSentencePiece(ExpandPath(arg))
+		if err != nil {
+			return nil, err
+		}
+		m.spec = spec
+		t = m
+	case kind == "tiktoken" && found, !found:
+		name := spec
+		if found {
+			name = arg
+		}
+		var err error
+		if t, err = loadTiktoken(spec, name); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q; use a tiktoken encoding, sentencepiece:PATH or heuristic", spec)
+	}
+	tokenizers.Store(spec, t)
+	return t, nil
+}
+
+// tokenizerSpec returns the spec of the tokenizer for model: the
+// tokenizers config entry with the longest matching prefix, else the
+// built-in tiktoken encoding of its family, else heuristic. A binary built
+// with -tags notiktoken has no built-in encodings.
+func tokenizerSpec(model string, configured map[string]string) string {
+	model = strings.ToLower(model)
+	prefixes := make([]string, 0, len(configured))
+	for p := range configured {
+		prefixes = append(prefixes, p)
+	}
+	// Longest first, so llama3 wins over llama
+	slices.SortFunc(prefixes, func(a, b string) int { return len(b) - len(a) })
+	for _, p := range prefixes {
+		if strings.HasPrefix(model, strings.ToLower(p)) {
+			return configured[p]
+		}
+	}
+	for _, e := range fitEncodings {
+		if tiktokenBuilt && strings.HasPrefix(model, e.prefix) {
+			return e.encoding
+		}
+	}
+	return heuristicSpec
+}
+
+// tokenizerFor loads the tokenizer for model; see tokenizerSpec.
+func tokenizerFor(model string, configured map[string]string) (Tokenizer, error) {
+	return newTokenizer(tokenizerSpec(model, configured))
+}
+
+// heuristicTokenizer estimates at four bytes per token; see EstimateTokens.
+type heuristicTokenizer struct{}
+
+func (heuristicTokenizer) Spec() string          { return heuristicSpec }
+func (heuristicTokenizer) Count(text string) int { return EstimateTokens(text) }
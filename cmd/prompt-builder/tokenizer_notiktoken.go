@@ -0,0 +1,14 @@
+//go:build notiktoken
+
+// tokenizer_notiktoken.go
+package main
+
+import "fmt"
+
+// tiktokenBuilt reports whether the tiktoken encodings are in this binary.
+const tiktokenBuilt = false
+
+// loadTiktoken fails: this binary was built without the encodings.
+func loadTiktoken(spec, name string) (Tokenizer, error) {
+	return nil, fmt.Errorf("cannot load the %s tokenizer: this binary was built with -tags notiktoken", name)
+}
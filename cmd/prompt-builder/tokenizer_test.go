@@ -0,0 +1,62 @@
+// tokenizer_test.go
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenizerSpec(t *testing.T) {
+	requireBuild(t, tiktokenBuilt, "notiktoken")
+	configured := map[string]string{
+		"llama":    "sentencepiece:~/models/llama2/tokenizer.model",
+		"llama3":   "tiktoken:cl100k_base",
+		"gpt-4o":   "heuristic",
+		"MyProxy-": "o200k_base",
+	}
+	tests := map[string]string{
+		"gpt-4o-mini":   "heuristic", // the config overrides the built-in families
+		"gpt-4-turbo":   "cl100k_base",
+		"llama2:13b":    "sentencepiece:~/models/llama2/tokenizer.model",
+		"llama3.2":      "tiktoken:cl100k_base", // the longest prefix wins
+		"myproxy-fast":  "o200k_base",
+		"qwen2.5-coder": heuristicSpec,
+	}
+	for model, want := range tests {
+		if got := tokenizerSpec(model, configured); got != want {
+			t.Errorf("tokenizerSpec(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestNewTokenizer(t *testing.T) {
+	requireBuild(t, tiktokenBuilt, "notiktoken")
+	for spec, want := range map[string]int{"o200k_base": 2, "tiktoken:cl100k_base": 2, "heuristic": 3} {
+		tok, err := newTokenizer(spec)
+		if err != nil {
+			t.Fatalf("newTokenizer(%q): %v", spec, err)
+		}
+		if got := tok.Count("hello world"); got != want || tok.Spec() != spec {
+			t.Errorf("%s: Count() = %d, Spec() = %q, want %d", spec, got, tok.Spec(), want)
+		}
+	}
+
+	path := writeSentencePiece(t, false, false, []spPiece{{"▁hello", -1, 0}, {"▁world", -1, 0}})
+	tok, err := newTokenizer("sentencepiece:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tok.Count("hello world"); got != 2 {
+		t.Errorf("sentencepiece: Count() = %d, want 2", got)
+	}
+
+	for spec, want := range map[string]string{
+		"p50k_nope":              "cannot load the p50k_nope tokenizer",
+		"sentencepiece:/nowhere": "cannot load the SentencePiece tokenizer",
+		"wordpiece:vocab.txt":    "unknown tokenizer",
+	} {
+		if _, err := newTokenizer(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("newTokenizer(%q) error = %v, want %q", spec, err, want)
+		}
+	}
+}
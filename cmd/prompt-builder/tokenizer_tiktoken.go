@@ -0,0 +1,45 @@
+//go:build !notiktoken
+
+// tokenizer_tiktoken.go
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// tiktokenBuilt reports whether the tiktoken encodings are in this binary;
+// build with -tags notiktoken to leave them out, which makes it several
+// megabytes smaller.
+const tiktokenBuilt = true
+
+var setBpeLoader sync.Once
+
+// loadTiktoken loads the tiktoken encoding name for spec.
+func loadTiktoken(spec, name string) (Tokenizer, error) {
+	// The encodings ship with the binary; tiktoken would otherwise
+	// download them on first use
+	setBpeLoader.Do(func() { tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader()) })
+	enc, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the %s tokenizer: %v", name, err)
+	}
+	return tiktokenTokenizer{spec: spec, enc: enc}, nil
+}
+
+// tiktokenTokenizer counts with a tiktoken encoding, as OpenAI models do.
+type tiktokenTokenizer struct {
+	spec string
+	enc  *tiktoken.Tiktoken
+}
+
+func (t tiktokenTokenizer) Spec() string { return t.spec }
+
+// Count counts special tokens such as <|endoftext|> as plain text, as
+// they would be when sent in a message.
+func (t tiktokenTokenizer) Count(text string) int {
+	return len(t.enc.EncodeOrdinary(text))
+}
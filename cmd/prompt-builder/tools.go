@@ -0,0 +1,135 @@
+// tools.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Tool is a tool of the agent a prompt is written for, as read from
+// --tools. Params holds every property name in its input schema, so they
+// are not taken for tool names.
+type Tool struct {
+	Name   string   `json:"name"`
+	Params []string `json:"params,omitempty"`
+}
+
+// parseTools reads tool schemas in OpenAI form ({"type": "function",
+// "function": {...}}), Anthropic form ({"name", "input_schema"}) or plain
+// {"name", "parameters"}, given as a list or as {"tools": [...]}.
+func parseTools(data []byte) ([]Tool, error) {
+	var raw []map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var wrapped struct {
+			Tools []map[string]any `json:"tools"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil {
+			return nil, err
+		}
+		raw = wrapped.Tools
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("no tools defined")
+	}
+
+	var tools []Tool
+	for i, t := range raw {
+		if fn, ok := t["function"].(map[string]any); ok {
+			t = fn
+		}
+		name, _ := t["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("tool %d has no name", i+1)
+		}
+		if slices.ContainsFunc(tools, func(x Tool) bool { return x.Name == name }) {
+			return nil, fmt.Errorf("tool %q is defined twice", name)
+		}
+		tool := Tool{Name: name}
+		for _, key := range []string{"parameters", "input_schema"} {
+			schemaParams(t[key], &tool.Params)
+		}
+		slices.Sort(tool.Params)
+		tool.Params = slices.Compact(tool.Params)
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// schemaParams appends the property names anywhere in schema to params.
+func schemaParams(schema any, params *[]string) {
+	switch s := schema.(type) {
+	case map[string]any:
+		if props, ok := s["properties"].(map[string]any); ok {
+			for name := range props {
+				*params = append(*params, name)
+			}
+		}
+		for _, v := range s {
+			schemaParams(v, params)
+		}
+	case []any:
+		for _, v := range s {
+			schemaParams(v, params)
+		}
+	}
+}
+
+// toolsInstruction tells the architect about the agent's tools. schemas
+// is the --tools file as given.
+func toolsInstruction(tools []Tool, schemas string) string {
+	schemas = strings.TrimSpace(schemas)
+	fence := fenceFor(schemas)
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = "`" + t.Name + "`"
+	}
+	return fmt.Sprintf(`
+
+The prompt is for an agent that can call these tools: %s. Their schemas:
+
+%sjson
+%s
+%s
+
+Include explicit tool-usage guidance in the prompt: when to use each tool and when not to, what arguments to pass, how to handle errors and empty results, and in what order to combine tools. Write tool names exactly as defined, in backticks, such as `+"`%s`"+`. Do not mention any tool that is not in the list.`,
+		strings.Join(names, ", "), fence, schemas, fence, tools[0].Name)
+}
+
+// toolLike matches a backticked name that reads as a tool: snake_case or
+// written as a call, such as `search_docs` or `search()`. File names such
+// as `notes.md` do not match.
+var toolLike = regexp.MustCompile("`([A-Za-z][A-Za-z0-9]*(?:_[A-Za-z0-9]+)+|[A-Za-z][A-Za-z0-9_]*\\(\\))`")
+
+// unknownTools returns the tool-like names in prompt that are neither a
+// tool nor a parameter of one, in order of first mention.
+func unknownTools(prompt string, tools []Tool) []string {
+	known := map[string]bool{}
+	for _, t := range tools {
+		known[t.Name] = true
+		for _, p := range t.Params {
+			known[p] = true
+		}
+	}
+	var unknown []string
+	for _, m := range toolLike.FindAllStringSubmatch(prompt, -1) {
+		name := strings.TrimSuffix(m[1], "()")
+		if !known[name] && !slices.Contains(unknown, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// unusedTools returns the tools prompt never names.
+func unusedTools(prompt string, tools []Tool) []string {
+	var unused []string
+	for _, t := range tools {
+		if !strings.Contains(prompt, t.Name) {
+			unused = append(unused, t.Name)
+		}
+	}
+	return unused
+}
@@ -0,0 +1,84 @@
+// tools_test.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const openAITools = `[
+  {"type": "function", "function": {"name": "search_docs", "description": "Search the docs",
+    "parameters": {"type": "object", "properties": {"query": {"type": "string"}, "max_results": {"type": "integer"}}}}},
+  {"type": "function", "function": {"name": "open_ticket", "parameters": {"type": "object",
+    "properties": {"customer": {"type": "object", "properties": {"account_id": {"type": "string"}}}}}}}
+]`
+
+func TestParseTools(t *testing.T) {
+	tests := map[string]string{
+		"openai":    openAITools,
+		"anthropic": `{"tools": [{"name": "search_docs", "input_schema": {"properties": {"query": {}, "max_results": {}}}}, {"name": "open_ticket", "input_schema": {"properties": {"customer": {"properties": {"account_id": {}}}}}}]}`,
+	}
+	for name, data := range tests {
+		tools, err := parseTools([]byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got := fmt.Sprint(tools)
+		want := "[{search_docs [max_results query]} {open_ticket [account_id customer]}]"
+		if got != want {
+			t.Errorf("%s: parseTools() = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestParseTools_Errors(t *testing.T) {
+	for name, data := range map[string]string{
+		"not json":  "tools:",
+		"empty":     "[]",
+		"no name":   `[{"description": "x"}]`,
+		"duplicate": `[{"name": "a"}, {"name": "a"}]`,
+	} {
+		if _, err := parseTools([]byte(data)); err == nil {
+			t.Errorf("%s: parseTools() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestToolsInstruction(t *testing.T) {
+	tools, _ := parseTools([]byte(openAITools))
+	got := toolsInstruction(tools, openAITools)
+	for _, want := range []string{"`search_docs`, `open_ticket`", "```json\n[", `"max_results"`, "Do not mention any tool"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("instruction missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestUnknownAndUnusedTools(t *testing.T) {
+	tools, _ := parseTools([]byte(openAITools))
+	prompt := "Use `search_docs` with a `query` and `max_results` of 5. If nothing helps, call `escalate_case()` " +
+		"or `send_email`. Read `notes.md` and reply in `JSON`. Again: `send_email`."
+	if got := unknownTools(prompt, tools); fmt.Sprint(got) != "[escalate_case send_email]" {
+		t.Errorf("unknownTools() = %v, want [escalate_case send_email]", got)
+	}
+	if got := unusedTools(prompt, tools); fmt.Sprint(got) != "[open_ticket]" {
+		t.Errorf("unusedTools() = %v, want [open_ticket]", got)
+	}
+}
+
+func TestRun_ToolsWarnings(t *testing.T) {
+	tools, _ := parseTools([]byte(openAITools))
+	deps := newTestDeps(withResponses("```\nUse `search_docs` first, then `delete_all`.\n```"), withTTY(false))
+	deps.Session = &Session{Idea: "support agent", Tools: tools}
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support agent", Quiet: QuietPrompt}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{`mentions tool "delete_all"`, `no guidance for tool "open_ticket"`} {
+		if !strings.Contains(stderr(deps), want) {
+			t.Errorf("stderr missing %q:\n%s", want, stderr(deps))
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// tour.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// tourIdea is the idea the tour refines.
+const tourIdea = "a prompt that reviews pull requests"
+
+// tourReplies are the scripted model's replies, one per turn. Replies past
+// the end repeat the last one. Lines starting with "Tour:" guide the user.
+var tourReplies = []string{
+	`Before I draft this, a few questions:
+
+1. Which language or stack are the pull requests in?
+2. What should the review focus on: bugs, style, security, or tests?
+
+Tour: The model asks clarifying questions before writing. Answer in plain
+text, for example "Go, mostly error handling", and press Enter.`,
+
+	"Thanks. Here is a first draft:\n\n```\n" + `# Role
+You are a senior engineer reviewing a pull request.
+
+# Goal
+Find defects before they are merged, especially in error handling.
+
+# Context
+The code under review is provided below the instructions.
+
+# Output
+A list of findings, most severe first. For each: file and line, the
+problem, and a suggested fix. Say "No findings" if there are none.
+
+# Ask
+Review the pull request.` + "\n```\n\n" + `Tour: A finished prompt arrives in a code block. You can keep refining by
+typing feedback such as "make it shorter". Type /help to see every
+command, or /copy to copy the prompt and finish.`,
+
+	"Revised:\n\n```\n" + `# Role
+You are a senior engineer reviewing a pull request.
+
+# Goal
+Find defects before they are merged. Be brief.
+
+# Output
+Findings, most severe first: file:line, problem, fix.
+
+# Ask
+Review the pull request.` + "\n```\n\n" + `Tour: Each reply replaces the draft. Type /copy to copy this prompt to the
+clipboard and end the tour.`,
+}
+
+// tourClient is an LLMClient that plays scripted replies, streaming a
+// word at a time like a real model.
+type tourClient struct {
+	replies []string
+	turn    int
+	delay   time.Duration // between words
+}
+
+func (c *tourClient) ChatStream(ctx context.Context, messages []Message, onToken StreamCallback) (string, error) {
+	reply := c.replies[min(c.turn, len(c.replies)-1)]
+	c.turn++
+	for _, word := range strings.SplitAfter(reply, " ") {
+		if word == "" {
+			continue
+		}
+		time.Sleep(c.delay)
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onToken(word); err != nil {
+			return "", err
+		}
+	}
+	return reply, nil
+}
+
+func (c *tourClient) ChatStreamWithSpinner(ctx context.Context, messages []Message, tty bool, onToken StreamCallback) (string, error) {
+	return c.ChatStream(ctx, messages, onToken)
+}
+
+func (c *tourClient) SetParams(GenerationParams) {}
+
+const tourIntro = `Welcome to prompt-builder.
+
+This tour runs a practice session against a scripted model, so nothing
+is sent to a server and no config is needed. You describe an idea, the
+model asks questions, and together you refine it into a prompt.
+
+The idea for this tour: %s
+
+`
+
+const tourOutro = `
+That's the tour. To use a real model, start a local server such as Ollama and run:
+
+  prompt-builder --model llama3.2 "your idea"
+
+or set model and host in ~/.config/prompt-builder/config.yaml (see the README).
+`
+
+// runTour plays the tour on deps, whose Client should play tourReplies.
+func runTour(ctx context.Context, deps *Deps) error {
+	fmt.Fprintf(deps.Stdout, tourIntro, tourIdea)
+	if err := runWithDeps(ctx, &CLI{Idea: tourIdea}, deps); err != nil {
+		return err
+	}
+	fmt.Fprint(deps.Stdout, tourOutro)
+	return nil
+}
+
+// runTourCommand implements the tour subcommand.
+func runTourCommand(ctx context.Context, out, errOut io.Writer) int {
+	if !isTTY() {
+		fmt.Fprintln(errOut, "The tour is interactive; run it in a terminal.")
+		return ExitConfigError
+	}
+
+	cfg := defaultConfig()
+	deps := &Deps{
+		Client:       &tourClient{replies: tourReplies, delay: 15 * time.Millisecond},
+		Stdin:        os.Stdin,
+		Stdout:       out,
+		Stderr:       errOut,
+		Clipboard:    NewClipboardWriter(DetectClipboardCmds("")...),
+		IsTTY:        isTTY,
+		StatusTTY:    isStderrTTY,
+		SystemPrompt: "You are a prompt engineer.",
+		Config:       &cfg,
+	}
+	if err := runTour(ctx, deps); err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return 1
+	}
+	return ExitSuccess
+}
@@ -0,0 +1,42 @@
+// tour_test.go
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunTour(t *testing.T) {
+	deps := newTestDeps(withStdin("Go, mostly error handling\nmake it shorter\n/copy\n"))
+	deps.Client = &tourClient{replies: tourReplies}
+
+	if err := runTour(context.Background(), deps); err != nil {
+		t.Fatalf("runTour() error = %v", err)
+	}
+
+	out := stdout(deps)
+	for _, want := range []string{"Welcome to prompt-builder", tourIdea, "clarifying questions", "/help", "end the tour", "That's the tour"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("tour output missing %q", want)
+		}
+	}
+	if got := clipboardWritten(deps); !strings.Contains(got, "Be brief.") || strings.Contains(got, "Tour:") {
+		t.Errorf("clipboard = %q, want the revised prompt without tour notes", got)
+	}
+}
+
+func TestTourClient_RepeatsLastReply(t *testing.T) {
+	c := &tourClient{replies: tourReplies}
+	var last string
+	for range len(tourReplies) + 2 {
+		reply, err := c.ChatStream(context.Background(), nil, func(string) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = reply
+	}
+	if last != tourReplies[len(tourReplies)-1] {
+		t.Errorf("reply after the script = %q, want the last scripted reply", last)
+	}
+}
@@ -0,0 +1,184 @@
+// transcript_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateTranscripts = flag.Bool("update", false, "rewrite testdata/transcripts and testdata/schema.json with the current output")
+
+// A transcript file scripts one session. Free text before the first
+// section describes it. Input sections:
+//
+//	-- options --   key: value lines: idea, tty (true/false), quiet
+//	                (none, prompt, clipboard, silent), llm_error
+//	-- response --  one reply from the mock LLM; repeat for each turn
+//	-- stdin --     what the user types
+//
+// Output sections, written by -update and compared otherwise, are
+// stdout, stderr, clipboard and error. Empty outputs are left out.
+type transcript struct {
+	comment  string
+	sections []transcriptSection
+}
+
+type transcriptSection struct {
+	name, body string
+}
+
+var transcriptOutputs = []string{"stdout", "stderr", "clipboard", "error"}
+
+func parseTranscript(data string) transcript {
+	var tr transcript
+	current := &tr.comment
+	for _, line := range strings.SplitAfter(data, "\n") {
+		trimmed := strings.TrimSuffix(line, "\n")
+		if name, ok := strings.CutPrefix(trimmed, "-- "); ok && strings.HasSuffix(name, " --") {
+			tr.sections = append(tr.sections, transcriptSection{name: strings.TrimSuffix(name, " --")})
+			current = &tr.sections[len(tr.sections)-1].body
+			continue
+		}
+		*current += line
+	}
+	return tr
+}
+
+func (tr transcript) String() string {
+	var b strings.Builder
+	b.WriteString(tr.comment)
+	for _, s := range tr.sections {
+		fmt.Fprintf(&b, "-- %s --\n%s", s.name, s.body)
+		if s.body != "" && !strings.HasSuffix(s.body, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// all returns the bodies of every section called name, in order.
+func (tr transcript) all(name string) []string {
+	var bodies []string
+	for _, s := range tr.sections {
+		if s.name == name {
+			bodies = append(bodies, s.body)
+		}
+	}
+	return bodies
+}
+
+func (tr transcript) get(name string) string {
+	if bodies := tr.all(name); len(bodies) > 0 {
+		return bodies[0]
+	}
+	return ""
+}
+
+// withOutputs returns tr with its output sections replaced by outputs.
+func (tr transcript) withOutputs(outputs map[string]string) transcript {
+	result := transcript{comment: tr.comment}
+	for _, s := range tr.sections {
+		if !isTranscriptOutput(s.name) {
+			result.sections = append(result.sections, s)
+		}
+	}
+	for _, name := range transcriptOutputs {
+		if outputs[name] != "" {
+			result.sections = append(result.sections, transcriptSection{name, outputs[name]})
+		}
+	}
+	return result
+}
+
+func isTranscriptOutput(name string) bool {
+	for _, o := range transcriptOutputs {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// playTranscript runs the scripted session and returns its outputs.
+func playTranscript(t *testing.T, tr transcript) map[string]string {
+	t.Helper()
+	cli := &CLI{Idea: "test idea"}
+	opts := []testOption{withStdin(tr.get("stdin"))}
+	// A reply body ends with the newline before the next section
+	var responses []string
+	for _, r := range tr.all("response") {
+		responses = append(responses, strings.TrimSuffix(r, "\n"))
+	}
+	opts = append(opts, withResponses(responses...))
+
+	for _, line := range strings.Split(strings.TrimSpace(tr.get("options")), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "idea":
+			cli.Idea = value
+		case "tty":
+			opts = append(opts, withTTY(value == "true"))
+		case "quiet":
+			levels := map[string]QuietLevel{"none": QuietNone, "prompt": QuietPrompt, "clipboard": QuietClipboard, "silent": QuietSilent}
+			level, ok := levels[value]
+			if !ok {
+				t.Fatalf("unknown quiet level %q", value)
+			}
+			cli.Quiet = level
+		case "llm_error":
+			opts = append(opts, withLLMError(errors.New(value)))
+		default:
+			t.Fatalf("unknown option %q", key)
+		}
+	}
+
+	deps := newTestDeps(opts...)
+	err := runWithDeps(context.Background(), cli, deps)
+	outputs := map[string]string{
+		"stdout":    stdout(deps),
+		"stderr":    stderr(deps),
+		"clipboard": clipboardWritten(deps),
+	}
+	if err != nil {
+		outputs["error"] = err.Error()
+	}
+	return outputs
+}
+
+func TestTranscripts(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "transcripts", "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no transcripts found: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".txt"), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := parseTranscript(string(data))
+			got := tr.withOutputs(playTranscript(t, tr)).String()
+
+			if *updateTranscripts {
+				if err := os.WriteFile(file, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if want := tr.String(); got != want {
+				t.Errorf("transcript differs; run go test -run TestTranscripts -update to accept\n--- want\n%s\n--- got\n%s", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// tunnel.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTunnelRemotePort = 11434
+
+// TunnelSpec describes an SSH port forward to a remote LLM server.
+type TunnelSpec struct {
+	Destination string // user@host, passed to ssh as-is
+	RemotePort  int
+}
+
+// ParseTunnelSpec parses "user@host[:port]". The port defaults to Ollama's.
+func ParseTunnelSpec(spec string) (TunnelSpec, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return TunnelSpec{}, fmt.Errorf("empty ssh_tunnel")
+	}
+
+	dest, port := spec, defaultTunnelRemotePort
+	if i := strings.LastIndex(spec, ":"); i != -1 {
+		p, err := strconv.Atoi(spec[i+1:])
+		if err != nil || p <= 0 || p > 65535 {
+			return TunnelSpec{}, fmt.Errorf("invalid port in ssh_tunnel: %q", spec)
+		}
+		dest, port = spec[:i], p
+	}
+	if dest == "" {
+		return TunnelSpec{}, fmt.Errorf("missing host in ssh_tunnel: %q", spec)
+	}
+	return TunnelSpec{Destination: dest, RemotePort: port}, nil
+}
+
+// sshArgs returns the ssh arguments that forward localPort to the remote server.
+func (s TunnelSpec) sshArgs(localPort int) []string {
+	return []string{
+		"-N",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "BatchMode=yes",
+		"-L", fmt.Sprintf("127.0.0.1:%d:localhost:%d", localPort, s.RemotePort),
+		s.Destination,
+	}
+}
+
+// SSHTunnel is a running ssh port forward.
+type SSHTunnel struct {
+	Host string // local URL that reaches the remote server
+	cmd  *exec.Cmd
+}
+
+// StartSSHTunnel launches the system ssh client and waits for the forwarded
+// port to accept connections.
+func StartSSHTunnel(ctx context.Context, spec TunnelSpec, timeout time.Duration) (*SSHTunnel, error) {
+	localPort, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: %w", err)
+	}
+
+	// ssh stops with ctx, and its complaints explain a failed start
+	cmd := exec.CommandContext(ctx, "ssh", spec.sshArgs(localPort)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to connect ssh tunnel: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", localPort)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			if msg := lastLines(stderr.String(), 3); msg != "" {
+				return nil, fmt.Errorf("failed to connect ssh tunnel to %s: ssh exited: %v: %s", spec.Destination, err, msg)
+			}
+			return nil, fmt.Errorf("failed to connect ssh tunnel to %s: ssh exited: %v", spec.Destination, err)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+		if conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond); err == nil {
+			conn.Close()
+			return &SSHTunnel{Host: "http://" + addr, cmd: cmd}, nil
+		}
+	}
+
+	cmd.Process.Kill()
+	return nil, fmt.Errorf("failed to connect ssh tunnel to %s: timed out after %s", spec.Destination, timeout)
+}
+
+// Close stops the ssh process.
+func (t *SSHTunnel) Close() {
+	if t != nil && t.cmd != nil && t.cmd.Process != nil {
+		t.cmd.Process.Kill()
+	}
+}
+
+// lastLines returns the last n non-blank lines of s, joined with "; ".
+func lastLines(s string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines[max(len(lines)-n, 0):], "; ")
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
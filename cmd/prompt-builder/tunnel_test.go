@@ -0,0 +1,72 @@
+// tunnel_test.go
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTunnelSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    TunnelSpec
+		wantErr bool
+	}{
+		{"user host port", "me@gpu-box:8080", TunnelSpec{"me@gpu-box", 8080}, false},
+		{"default port", "me@gpu-box", TunnelSpec{"me@gpu-box", 11434}, false},
+		{"host only", "gpu-box:11434", TunnelSpec{"gpu-box", 11434}, false},
+		{"bad port", "me@gpu-box:http", TunnelSpec{}, true},
+		{"port out of range", "me@gpu-box:70000", TunnelSpec{}, true},
+		{"missing host", ":11434", TunnelSpec{}, true},
+		{"empty", "", TunnelSpec{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTunnelSpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTunnelSpec(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTunnelSpec(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTunnelSpec_SSHArgs(t *testing.T) {
+	spec := TunnelSpec{Destination: "me@gpu-box", RemotePort: 11434}
+	got := spec.sshArgs(40000)
+	want := []string{
+		"-N",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "BatchMode=yes",
+		"-L", "127.0.0.1:40000:localhost:11434",
+		"me@gpu-box",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sshArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestStartSSHTunnel_ReportsStderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script standing in for ssh")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'debug1: connecting' >&2\necho 'Host key verification failed.' >&2\nexit 255\n"
+	os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0o755)
+	t.Setenv("PATH", dir)
+
+	_, err := StartSSHTunnel(context.Background(), TunnelSpec{Destination: "me@gpu-box", RemotePort: 11434}, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "Host key verification failed.") {
+		t.Errorf("error = %v, want what ssh printed", err)
+	}
+}
@@ -0,0 +1,70 @@
+// usage.go
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Usage is the token count the server reported for one or more requests.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// UsageReporter is implemented by clients that can tell how many tokens
+// their last request used. ok is false when the server did not say.
+type UsageReporter interface {
+	LastUsage() (usage Usage, ok bool)
+}
+
+// StreamOptions asks an OpenAI-style server for a final chunk with usage.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+func (u Usage) String() string {
+	return fmt.Sprintf("%d prompt + %d completion = %d tokens", u.PromptTokens, u.CompletionTokens, u.PromptTokens+u.CompletionTokens)
+}
+
+// usageMeter reports token usage per turn and for the session
+// (--show-usage).
+type usageMeter struct {
+	out        io.Writer
+	total      Usage
+	requests   int
+	unreported int
+}
+
+// record prints the usage of client's last request and adds it to the
+// total. A server that does not report usage is mentioned once.
+func (m *usageMeter) record(client LLMClient) {
+	r, ok := client.(UsageReporter)
+	var u Usage
+	if ok {
+		u, ok = r.LastUsage()
+	}
+	if !ok {
+		if m.unreported == 0 {
+			fmt.Fprintln(m.out, "Tokens: the server did not report usage")
+		}
+		m.unreported++
+		return
+	}
+	m.total.PromptTokens += u.PromptTokens
+	m.total.CompletionTokens += u.CompletionTokens
+	m.requests++
+	fmt.Fprintf(m.out, "Tokens: %s\n", u)
+}
+
+// summary prints the session total, if there was more than one request.
+func (m *usageMeter) summary() {
+	if m.requests < 2 {
+		return
+	}
+	line := fmt.Sprintf("Session total: %s over %d requests", m.total, m.requests)
+	if m.unreported > 0 {
+		line += fmt.Sprintf(" (%d more without usage)", m.unreported)
+	}
+	fmt.Fprintln(m.out, line)
+}
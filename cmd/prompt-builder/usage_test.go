@@ -0,0 +1,99 @@
+// usage_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChatClient_IncludeUsage(t *testing.T) {
+	var options []*StreamOptions
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		options = append(options, req.StreamOptions)
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		if req.StreamOptions != nil {
+			// OpenAI sends usage in a last chunk without choices
+			fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3}}\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewChatClient(server.URL, "m")
+	client.ChatStream(context.Background(), nil, func(string) error { return nil })
+	if _, ok := client.LastUsage(); ok || options[0] != nil {
+		t.Errorf("without IncludeUsage: stream_options = %+v, usage reported = %v", options[0], ok)
+	}
+
+	client.IncludeUsage = true
+	reply, err := client.ChatStream(context.Background(), nil, func(string) error { return nil })
+	if err != nil || reply != "Hi" {
+		t.Fatalf("ChatStream() = %q, %v", reply, err)
+	}
+	if options[1] == nil || !options[1].IncludeUsage {
+		t.Error("stream_options.include_usage was not sent")
+	}
+	if u, ok := client.LastUsage(); !ok || u != (Usage{PromptTokens: 12, CompletionTokens: 3}) {
+		t.Errorf("LastUsage() = %+v, %v, want 12 + 3", u, ok)
+	}
+}
+
+func TestParseAnthropicStreamUsage(t *testing.T) {
+	stream := `data: {"type":"message_start","message":{"usage":{"input_tokens":25,"output_tokens":1}}}
+
+data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hi"}}
+
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}
+
+data: {"type":"message_stop"}
+`
+	reply, usage, err := parseAnthropicStreamUsage(strings.NewReader(stream), func(string) error { return nil })
+	if err != nil || reply != "Hi" {
+		t.Fatalf("reply = %q, %v", reply, err)
+	}
+	if usage == nil || *usage != (Usage{PromptTokens: 25, CompletionTokens: 15}) {
+		t.Errorf("usage = %+v, want 25 + 15", usage)
+	}
+}
+
+func TestUsageMeter(t *testing.T) {
+	var out bytes.Buffer
+	m := &usageMeter{out: &out}
+	m.record(&mockLLM{usage: &Usage{PromptTokens: 100, CompletionTokens: 20}})
+	m.record(&mockLLM{usage: &Usage{PromptTokens: 150, CompletionTokens: 30}})
+	m.record(&mockLLM{})
+	m.record(&mockLLM{})
+	m.summary()
+
+	want := "Tokens: 100 prompt + 20 completion = 120 tokens\n" +
+		"Tokens: 150 prompt + 30 completion = 180 tokens\n" +
+		"Tokens: the server did not report usage\n" +
+		"Session total: 250 prompt + 50 completion = 300 tokens over 2 requests (2 more without usage)\n"
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRun_ShowUsage(t *testing.T) {
+	deps := newTestDeps(withResponses("What audience?", "```\nprompt\n```"), withStdin("developers\n/quit\n"))
+	deps.Client.(*mockLLM).usage = &Usage{PromptTokens: 40, CompletionTokens: 10}
+	deps.Config.ShowUsage = true
+
+	if err := runWithDeps(context.Background(), &CLI{Idea: "code reviewer"}, deps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := strings.Count(stderr(deps), "Tokens: 40 prompt + 10 completion = 50 tokens"); n != 2 {
+		t.Errorf("stderr has %d usage lines, want one per turn:\n%s", n, stderr(deps))
+	}
+	if !strings.Contains(stderr(deps), "Session total: 80 prompt + 20 completion = 100 tokens over 2 requests") {
+		t.Errorf("stderr = %q, want the session total", stderr(deps))
+	}
+}
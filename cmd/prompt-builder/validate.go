@@ -0,0 +1,247 @@
+// validate.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unknownKeys reports the keys in node that no field of t reads, such
+// as a misspelt sytem_prompt_file, which decoding silently ignores. It
+// follows nested settings, lists and maps; path prefixes the keys it
+// reports.
+func unknownKeys(node *yaml.Node, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+
+	var problems []string
+	switch {
+	case t == reflect.TypeFor[yaml.Node]():
+		// Decoded later, such as a profile; checked by its user
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			ft, ok := fields[key.Value]
+			if !ok {
+				problem := fmt.Sprintf("line %d: unknown key %s%s", key.Line, path, key.Value)
+				if s := closestKey(key.Value, fields); s != "" {
+					problem += fmt.Sprintf(" (did you mean %s?)", s)
+				}
+				problems = append(problems, problem)
+				continue
+			}
+			problems = append(problems, unknownKeys(node.Content[i+1], ft, path+key.Value+".")...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			problems = append(problems, unknownKeys(item, t.Elem(), fmt.Sprintf("%s%d.", path, i+1))...)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			problems = append(problems, unknownKeys(node.Content[i+1], t.Elem(), path+node.Content[i].Value+".")...)
+		}
+	}
+	return problems
+}
+
+// yamlFields maps the YAML keys of struct t, inline structs included, to
+// their types.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch {
+		case name == "-" || !f.IsExported():
+		case opts == "inline":
+			maps.Copy(fields, yamlFields(f.Type))
+		case name == "":
+			fields[strings.ToLower(f.Name)] = f.Type
+		default:
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
+
+// closestKey returns the key of fields nearest to key, if it is close
+// enough to be a likely typo.
+func closestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", len(key)/3+1
+	for _, k := range slices.Sorted(maps.Keys(fields)) {
+		if d := editDistance(key, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the number of single-character insertions,
+// deletions, substitutions and swaps of neighbours that turn a into b.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// configKeyProblems reports the unknown keys of a config document,
+// including those inside each profile. Legacy keys that are still read
+// are not reported; loading warns about them.
+func configKeyProblems(doc *yaml.Node) []string {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := *doc.Content[0]
+	root.Content = nil
+	var profiles *yaml.Node
+	for i := 0; i+1 < len(doc.Content[0].Content); i += 2 {
+		key, value := doc.Content[0].Content[i], doc.Content[0].Content[i+1]
+		if slices.ContainsFunc(renamedKeys, func(r struct{ Old, New string }) bool { return r.Old == key.Value }) {
+			continue
+		}
+		if key.Value == "profiles" && value.Kind == yaml.MappingNode {
+			profiles = value
+		}
+		root.Content = append(root.Content, key, value)
+	}
+
+	configType := reflect.TypeFor[Config]()
+	problems := unknownKeys(&root, configType, "")
+	if profiles != nil {
+		for i := 0; i+1 < len(profiles.Content); i += 2 {
+			problems = append(problems, unknownKeys(profiles.Content[i+1], configType, "profiles."+profiles.Content[i].Value+".")...)
+		}
+	}
+	return problems
+}
+
+// checkHost reports whether cfg's host is a URL the tool can reach.
+func checkHost(cfg *Config) error {
+	if cfg.Host == hostAuto || cfg.SSHTunnel != "" {
+		return nil
+	}
+	u, err := url.Parse(cfg.Host)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("host must be an http or https url such as http://localhost:11434, got %q", cfg.Host)
+	}
+	return nil
+}
+
+// runConfigValidate implements config validate: it checks the config
+// file strictly, without talking to the server unless --ping is given.
+func runConfigValidate(ctx context.Context, args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	configPath := fs.String("config", "", "Path to config file (default: your config and the project's)")
+	profile := fs.String("profile", "", "Profile from the config file to check")
+	ping := fs.Bool("ping", false, "Also check that the server answers")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	files := configFiles()
+	if *configPath != "" {
+		files = []string{ExpandPath(*configPath)}
+	}
+
+	code := ExitSuccess
+	clean := make(map[string]bool)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(out, "✗ config %s: %v\n", path, err)
+			return ExitConfigError
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			fmt.Fprintf(out, "✗ config %s: %v\n", path, err)
+			return ExitConfigError
+		}
+		problems := configKeyProblems(&doc)
+		for _, p := range problems {
+			fmt.Fprintf(out, "✗ config %s: %s\n", path, p)
+			code = ExitConfigError
+		}
+		clean[path] = len(problems) == 0
+	}
+	cfg, err := LoadFiles(files, *profile)
+	if err != nil {
+		fmt.Fprintf(out, "✗ config %s: %v\n", strings.Join(files, " + "), err)
+		return ExitConfigError
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(out, "- no config file; the defaults apply (%s)\n", defaultConfigPath())
+	}
+	for _, path := range files {
+		if clean[path] {
+			fmt.Fprintf(out, "✓ config %s\n", path)
+		}
+	}
+	if cfg.Profile != "" {
+		fmt.Fprintf(out, "✓ profile %s\n", cfg.Profile)
+	}
+	for _, d := range cfg.Deprecations {
+		fmt.Fprintf(out, "  ! %s\n", d)
+	}
+
+	if _, err := cfg.systemPrompt(); err != nil {
+		fmt.Fprintf(out, "✗ %v\n", err)
+		code = ExitConfigError
+	} else {
+		fmt.Fprintf(out, "✓ system prompt %s\n", cfg.systemPromptName())
+	}
+	for _, v := range cfg.Experiment.Variants {
+		variant := *cfg
+		variant.SystemPromptFile = v.SystemPromptFile
+		if _, err := variant.systemPrompt(); err != nil {
+			fmt.Fprintf(out, "✗ experiment variant %s: %v\n", v.Name, err)
+			code = ExitConfigError
+		}
+	}
+	if err := checkHost(cfg); err != nil {
+		fmt.Fprintf(out, "✗ %v\n", err)
+		code = ExitConfigError
+	} else if cfg.SSHTunnel == "" {
+		fmt.Fprintf(out, "✓ host %s\n", cfg.Host)
+	}
+
+	if *ping && code == ExitSuccess {
+		code = doctorServer(ctx, cfg, out)
+	}
+	return code
+}
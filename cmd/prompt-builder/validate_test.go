@@ -0,0 +1,130 @@
+// validate_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigKeyProblems(t *testing.T) {
+	data := `sytem_prompt_file: ~/prompt.md
+ollama_host: http://localhost:11434
+temperature: 0.2
+save:
+  dir: ~/prompts
+  titel: model
+hosts:
+  - url: http://a:11434
+    wieght: 2
+personas:
+  critic:
+    model: fast
+    mdoel_name: x
+profiles:
+  work:
+    modle: llama3.2
+    host: http://gpu:11434
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"line 1: unknown key sytem_prompt_file (did you mean system_prompt_file?)",
+		"line 6: unknown key save.titel (did you mean title?)",
+		"line 9: unknown key hosts.1.wieght (did you mean weight?)",
+		"line 13: unknown key personas.critic.mdoel_name",
+		"line 16: unknown key profiles.work.modle (did you mean model?)",
+	}
+	got := configKeyProblems(&doc)
+	if !slices.Equal(got, want) {
+		t.Errorf("configKeyProblems() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "abc", 3},
+		{"model", "model", 0},
+		{"modle", "model", 1},
+		{"kitten", "sitting", 3},
+		{"sytem", "system", 1},
+	} {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestRunConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	promptPath := filepath.Join(dir, "prompt.md")
+	os.WriteFile(promptPath, []byte("You are helpful."), 0644)
+
+	tests := []struct {
+		name     string
+		config   string
+		wantCode int
+		want     []string
+	}{
+		{"valid", "system_prompt_file: " + promptPath + "\nhost: http://localhost:11434\n", ExitSuccess,
+			[]string{"✓ config", "✓ system prompt " + promptPath, "✓ host http://localhost:11434"}},
+		{"typo", "sytem_prompt_file: " + promptPath + "\n", ExitConfigError,
+			[]string{"line 1: unknown key sytem_prompt_file (did you mean system_prompt_file?)", "✓ system prompt prompt-architect.md (built-in)"}},
+		{"missing system prompt", "system_prompt_file: " + filepath.Join(dir, "gone.md") + "\n", ExitConfigError,
+			[]string{"✗ system prompt not found"}},
+		{"bad host", "host: localhost:11434\n", ExitConfigError,
+			[]string{`✗ host must be an http or https url such as http://localhost:11434, got "localhost:11434"`}},
+		{"bad value", "temperature: 5\n", ExitConfigError, []string{"✗ config", "temperature"}},
+		{"syntax", "model: [\n", ExitConfigError, []string{"✗ config", "yaml:"}},
+		{"deprecated", "ollama_host: http://localhost:11434\n", ExitSuccess, []string{"✓ config", "ollama_host is deprecated"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(dir, "config.yaml")
+			os.WriteFile(configPath, []byte(tt.config), 0644)
+			var out, errOut bytes.Buffer
+			code := runConfig(context.Background(), []string{"validate", "--config", configPath}, nil, &out, &errOut)
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d\n%s", code, tt.wantCode, out.String())
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(out.String(), w) {
+					t.Errorf("output lacks %q:\n%s", w, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunConfigValidate_Ping(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	srv.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("host: "+srv.URL+"\n"), 0644)
+	var out, errOut bytes.Buffer
+	args := []string{"validate", "--config", configPath}
+	if code := runConfig(context.Background(), args, nil, &out, &errOut); code != ExitSuccess || strings.Contains(out.String(), "server") {
+		t.Errorf("without --ping: code %d, output %q, want no server check", code, out.String())
+	}
+
+	out.Reset()
+	if code := runConfig(context.Background(), append(args, "--ping"), nil, &out, &errOut); code != ExitLLMError || !strings.Contains(out.String(), "✗ server "+srv.URL) {
+		t.Errorf("with --ping: code %d, output %q, want an unreachable server", code, out.String())
+	}
+}
@@ -0,0 +1,61 @@
+// webhook.go
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// webhookEvent is the event webhooks are sent for.
+const webhookEvent = "session.completed"
+
+// Webhook is a URL notified when a session completes (webhooks in the
+// config). With SecretEnv, each delivery is signed with HMAC-SHA256 of
+// the body, keyed with that variable's value, in the
+// X-Prompt-Builder-Signature-256 header as sha256=<hex>.
+type Webhook struct {
+	URL       string `yaml:"url"`
+	SecretEnv string `yaml:"secret_env"`
+}
+
+// webhookPayload is the JSON body of a delivery.
+type webhookPayload struct {
+	Event       string    `json:"event"`
+	Idea        string    `json:"idea"`
+	Prompt      string    `json:"prompt"`
+	Model       string    `json:"model,omitempty"`
+	SessionID   string    `json:"session_id,omitempty"`
+	Turns       int       `json:"turns"`
+	Tokens      int       `json:"tokens"` // estimated size of the prompt
+	Version     string    `json:"version"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// validateWebhooks checks the webhooks config value.
+func validateWebhooks(hooks []Webhook) error {
+	for i, h := range hooks {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhooks: entry %d needs an http or https url, got %q", i+1, h.URL)
+		}
+	}
+	return nil
+}
+
+// completionPayload describes the completed session of tab, whose final
+// prompt as delivered is prompt.
+func completionPayload(tab *Tab, prompt string, now time.Time) webhookPayload {
+	p := webhookPayload{
+		Event:       webhookEvent,
+		Prompt:      prompt,
+		Turns:       draftCount(tab.Conv.Messages),
+		Tokens:      EstimateTokens(prompt),
+		Version:     version,
+		CompletedAt: now.UTC(),
+	}
+	if s := tab.Session; s != nil {
+		p.Idea, p.Model, p.SessionID = s.Idea, s.Model, s.ID
+	}
+	return p
+}
@@ -0,0 +1,19 @@
+//go:build nopublish
+
+// webhook_nopublish.go
+package main
+
+import (
+	"context"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// notifyCompletion warns that configured webhooks are not sent: this
+// binary was built without them. One config can serve both builds.
+func notifyCompletion(ctx context.Context, cfg *Config, tab *Tab, warn func(format string, args ...any)) {
+	if len(cfg.Webhooks) == 0 || promptbuilder.ExtractLastCodeBlock(tab.Response) == "" {
+		return
+	}
+	warn("Warning: webhooks not sent: this binary was built with -tags nopublish\n")
+}
@@ -0,0 +1,96 @@
+//go:build !nopublish
+
+// webhook_publish.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jwp23/prompt-builder/pkg/promptbuilder"
+)
+
+// webhookTimeout bounds one delivery so a slow receiver cannot hold up
+// the end of a session.
+const webhookTimeout = 10 * time.Second
+
+// signWebhook returns the signature header value for body.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook delivers body to hook.
+func sendWebhook(ctx context.Context, hook Webhook, body []byte) error {
+	// Sent as the session ends, possibly after --deadline passed
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	delivery := make([]byte, 8)
+	rand.Read(delivery)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "prompt-builder/"+version)
+	req.Header.Set("X-Prompt-Builder-Event", webhookEvent)
+	req.Header.Set("X-Prompt-Builder-Delivery", hex.EncodeToString(delivery))
+	if hook.SecretEnv != "" {
+		secret := os.Getenv(hook.SecretEnv)
+		if secret == "" {
+			return fmt.Errorf("%s is not set; not sending an unsigned delivery", hook.SecretEnv)
+		}
+		req.Header.Set("X-Prompt-Builder-Signature-256", signWebhook(secret, body))
+	}
+
+	client := &http.Client{
+		// The payload holds the prompt; it goes only where it was configured to
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("returned %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyCompletion sends the completion of tab's session to every
+// configured webhook. A failed delivery is reported on warn and does not
+// fail the run.
+func notifyCompletion(ctx context.Context, cfg *Config, tab *Tab, warn func(format string, args ...any)) {
+	if len(cfg.Webhooks) == 0 || promptbuilder.ExtractLastCodeBlock(tab.Response) == "" {
+		return
+	}
+	now := time.Now()
+	prompt, err := finalPrompt(tab.Session, tab.Response, func(p string) (string, error) {
+		return stampPrompt(cfg, tab.Session, now, p)
+	})
+	if err != nil {
+		warn("Warning: webhooks not sent: %v\n", err)
+		return
+	}
+	body, err := json.Marshal(completionPayload(tab, prompt, now))
+	if err != nil {
+		warn("Warning: webhooks not sent: %v\n", err)
+		return
+	}
+	for _, hook := range cfg.Webhooks {
+		if err := sendWebhook(ctx, hook, body); err != nil {
+			warn("Warning: webhook %s: %v\n", hook.URL, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+//go:build !nopublish
+
+// webhook_test.go
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// webhookReceiver records the deliveries it gets.
+type webhookReceiver struct {
+	*httptest.Server
+	bodies  [][]byte
+	headers []http.Header
+}
+
+func newWebhookReceiver(t *testing.T, status int) *webhookReceiver {
+	t.Helper()
+	r := &webhookReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.bodies = append(r.bodies, body)
+		r.headers = append(r.headers, req.Header)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestNotifyCompletion_Signed(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+	recv := newWebhookReceiver(t, http.StatusNoContent)
+	cfg := &Config{Webhooks: []Webhook{{URL: recv.URL, SecretEnv: "TEST_WEBHOOK_SECRET"}}}
+	tab := &Tab{Conv: NewConversation("system"), Response: "```\nBe kind.\n```", Session: &Session{ID: "2026-10-16-1", Idea: "support bot", Model: "llama3.2"}}
+	tab.Conv.AddUserMessage("support bot")
+	tab.Conv.AddAssistantMessage(tab.Response)
+
+	var warnings []string
+	notifyCompletion(context.Background(), cfg, tab, func(format string, args ...any) { warnings = append(warnings, format) })
+	if len(warnings) > 0 || len(recv.bodies) != 1 {
+		t.Fatalf("warnings = %q, deliveries = %d, want one clean delivery", warnings, len(recv.bodies))
+	}
+
+	body, header := recv.bodies[0], recv.headers[0]
+	if got, want := header.Get("X-Prompt-Builder-Signature-256"), signWebhook("s3cret", body); !hmac.Equal([]byte(got), []byte(want)) {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if header.Get("X-Prompt-Builder-Event") != webhookEvent || header.Get("X-Prompt-Builder-Delivery") == "" {
+		t.Errorf("headers = %v", header)
+	}
+	var p webhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Idea != "support bot" || p.Prompt != "Be kind.\n" || p.Model != "llama3.2" || p.SessionID != "2026-10-16-1" || p.Turns != 1 || p.CompletedAt.IsZero() {
+		t.Errorf("payload = %+v", p)
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac key
+	if got := signWebhook("key", []byte(`{"a":1}`)); got != "sha256=88a67f24bbcdaed0e6c997404bb79a743baf44c6bab2f4c27328e3009d22e342" {
+		t.Errorf("signWebhook() = %q", got)
+	}
+	if signWebhook("key", []byte("a")) == signWebhook("other", []byte("a")) {
+		t.Error("signatures do not depend on the secret")
+	}
+}
+
+func TestNotifyCompletion_Failures(t *testing.T) {
+	failing := newWebhookReceiver(t, http.StatusInternalServerError)
+	redirect := httptest.NewServer(http.RedirectHandler("http://example.com/elsewhere", http.StatusTemporaryRedirect))
+	defer redirect.Close()
+	ok := newWebhookReceiver(t, http.StatusOK)
+	cfg := &Config{Webhooks: []Webhook{
+		{URL: failing.URL},
+		{URL: redirect.URL},
+		{URL: ok.URL, SecretEnv: "TEST_WEBHOOK_UNSET"},
+		{URL: ok.URL},
+	}}
+	tab := &Tab{Conv: NewConversation("system"), Response: "```\np\n```"}
+
+	var warnings []string
+	notifyCompletion(context.Background(), cfg, tab, func(format string, args ...any) {
+		warnings = append(warnings, args[len(args)-1].(error).Error())
+	})
+	want := []string{"returned 500 Internal Server Error", "returned 307 Temporary Redirect", "TEST_WEBHOOK_UNSET is not set"}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings = %q, want %d", warnings, len(want))
+	}
+	for i := range want {
+		if !strings.Contains(warnings[i], want[i]) {
+			t.Errorf("warning %d = %q, want %q", i, warnings[i], want[i])
+		}
+	}
+	if len(ok.bodies) != 1 {
+		t.Errorf("the working webhook got %d deliveries, want 1 (none unsigned)", len(ok.bodies))
+	}
+}
+
+func TestRun_WebhooksOnCompletion(t *testing.T) {
+	recv := newWebhookReceiver(t, http.StatusOK)
+
+	deps := newTestDeps(withResponses("```\nBe kind.\n```"), withTTY(false))
+	deps.Config.Webhooks = []Webhook{{URL: recv.URL}}
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support bot", Quiet: QuietPrompt}, deps); err != nil {
+		t.Fatalf("pipe mode: %v", err)
+	}
+
+	deps = newTestDeps(withResponses("Who is it for?"), withStdin("/bye\n"), withTTY(true))
+	deps.Config.Webhooks = []Webhook{{URL: recv.URL}}
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support bot", NoCopy: true}, deps); err != nil {
+		t.Fatalf("interactive: %v", err)
+	}
+
+	deps = newTestDeps(withResponses("```\nBe brief.\n```"), withStdin("/bye\n"), withTTY(true))
+	deps.Config.Webhooks = []Webhook{{URL: recv.URL}}
+	if err := runWithDeps(context.Background(), &CLI{Idea: "support bot", NoCopy: true}, deps); err != nil {
+		t.Fatalf("interactive: %v", err)
+	}
+
+	if len(recv.bodies) != 2 {
+		t.Fatalf("got %d deliveries, want one per session that ended with a prompt", len(recv.bodies))
+	}
+	for i, want := range []string{"Be kind.", "Be brief."} {
+		if !strings.Contains(string(recv.bodies[i]), want) {
+			t.Errorf("delivery %d = %s, want %q", i, recv.bodies[i], want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// build.go
+package promptbuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+// Kinds of failure BuildPrompt and RefinePrompt report, for errors.Is.
+var (
+	ErrLLM                 = errors.New("LLM server error")
+	ErrClarificationNeeded = errors.New("the model asked for clarification")
+)
+
+// kindError is an error marked with its kind of failure.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// PipeModePrefix asks the model to skip clarifying questions.
+const PipeModePrefix = "Generate your best prompt without asking clarifying questions. User's idea: "
+
+// BuildPrompt generates a prompt for idea in a single non-interactive turn
+// and returns the extracted final prompt.
+func BuildPrompt(ctx context.Context, client LLMClient, systemPrompt, idea string) (string, error) {
+	return completeOnce(ctx, client, systemPrompt, PipeModePrefix+idea)
+}
+
+// RefinePrompt revises an existing prompt according to instructions in a
+// single non-interactive turn and returns the extracted result.
+func RefinePrompt(ctx context.Context, client LLMClient, systemPrompt, prompt, instructions string) (string, error) {
+	return completeOnce(ctx, client, systemPrompt, fmt.Sprintf("Revise this prompt without asking clarifying questions. Instructions: %s\n\n```\n%s\n```", instructions, prompt))
+}
+
+func completeOnce(ctx context.Context, client LLMClient, systemPrompt, user string) (string, error) {
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: user},
+	}
+	response, err := client.ChatStream(ctx, messages, func(string) error { return nil })
+	if err != nil {
+		return "", &kindError{ErrLLM, fmt.Errorf("LLM request failed: %w", err)}
+	}
+	if !IsComplete(response) {
+		return "", &kindError{ErrClarificationNeeded, errors.New("LLM requested clarification instead of returning a prompt")}
+	}
+	return ExtractLastCodeBlock(response), nil
+}
+
+// TemplateFuncs exposes BuildPrompt and RefinePrompt to text/template as
+// buildPrompt and refinePrompt. Requests are cancelled with ctx:
+//
+//	{{ buildPrompt "a code reviewer for Go" }}
+//	{{ refinePrompt .Existing "make it stricter about citations" }}
+func TemplateFuncs(ctx context.Context, client LLMClient, systemPrompt string) template.FuncMap {
+	return template.FuncMap{
+		"buildPrompt": func(idea string) (string, error) {
+			return BuildPrompt(ctx, client, systemPrompt, idea)
+		},
+		"refinePrompt": func(prompt, instructions string) (string, error) {
+			return RefinePrompt(ctx, client, systemPrompt, prompt, instructions)
+		},
+	}
+}
@@ -0,0 +1,98 @@
+// build_test.go
+package promptbuilder
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// fakeClient replies with reply, or fails with err, and records the
+// messages it was sent.
+type fakeClient struct {
+	reply string
+	err   error
+	last  []Message
+}
+
+func (c *fakeClient) ChatStream(ctx context.Context, messages []Message, onToken StreamCallback) (string, error) {
+	c.last = messages
+	if c.err != nil {
+		return "", c.err
+	}
+	return c.reply, onToken(c.reply)
+}
+
+func (c *fakeClient) ChatStreamWithSpinner(ctx context.Context, messages []Message, tty bool, onToken StreamCallback) (string, error) {
+	return c.ChatStream(ctx, messages, onToken)
+}
+
+func (c *fakeClient) SetParams(GenerationParams) {}
+
+func TestBuildPrompt(t *testing.T) {
+	client := &fakeClient{reply: "Done:\n```\nfinal prompt\n```"}
+
+	got, err := BuildPrompt(context.Background(), client, "system", "an idea")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "final prompt\n" {
+		t.Errorf("BuildPrompt(%q) = %q, want %q", "an idea", got, "final prompt\n")
+	}
+	if system := client.last[0]; system.Role != "system" || system.Content != "system" {
+		t.Errorf("first message = %+v, want the system prompt", system)
+	}
+	if user := client.last[1].Content; !strings.HasPrefix(user, PipeModePrefix) {
+		t.Errorf("user message missing pipe mode prefix: %q", user)
+	}
+}
+
+func TestBuildPrompt_Clarification(t *testing.T) {
+	client := &fakeClient{reply: "Who is the audience?"}
+
+	_, err := BuildPrompt(context.Background(), client, "system", "an idea")
+	if !errors.Is(err, ErrClarificationNeeded) || !strings.Contains(err.Error(), "clarification") {
+		t.Errorf("expected clarification error, got: %v", err)
+	}
+}
+
+func TestBuildPrompt_LLMError(t *testing.T) {
+	cause := errors.New("connection refused")
+	client := &fakeClient{err: cause}
+
+	_, err := BuildPrompt(context.Background(), client, "system", "an idea")
+	if !errors.Is(err, ErrLLM) || !errors.Is(err, cause) {
+		t.Errorf("expected an LLM error wrapping %v, got: %v", cause, err)
+	}
+}
+
+func TestRefinePrompt(t *testing.T) {
+	client := &fakeClient{reply: "```\nstricter\n```"}
+
+	got, err := RefinePrompt(context.Background(), client, "system", "original", "be stricter")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "stricter\n" {
+		t.Errorf("RefinePrompt(%q, %q) = %q, want %q", "original", "be stricter", got, "stricter\n")
+	}
+	user := client.last[1].Content
+	if !strings.Contains(user, "original") || !strings.Contains(user, "be stricter") {
+		t.Errorf("user message missing prompt or instructions: %q", user)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	client := &fakeClient{reply: "```\nfrom template\n```"}
+
+	tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(context.Background(), client, "system")).Parse(`{{ buildPrompt "idea" }}`))
+	var out strings.Builder
+	if err := tmpl.Execute(&out, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "from template\n" {
+		t.Errorf("template output = %q, want %q", out.String(), "from template\n")
+	}
+}
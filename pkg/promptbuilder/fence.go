@@ -0,0 +1,95 @@
+// fence.go
+package promptbuilder
+
+import "strings"
+
+// ExtractLastCodeBlock extracts the content of the last code block from text.
+func ExtractLastCodeBlock(text string) string {
+	block, _ := lastCodeBlock(text)
+	return block
+}
+
+// ParseFence reports whether line is a code fence: optional indentation,
+// a run of at least three backticks, then an info string such as a
+// language name. It returns the run length and the info string.
+func ParseFence(line string) (n int, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	for n < len(trimmed) && trimmed[n] == '`' {
+		n++
+	}
+	if n < 3 {
+		return 0, "", false
+	}
+	info = strings.TrimSpace(trimmed[n:])
+	if strings.Contains(info, "`") {
+		return 0, "", false // inline code such as ```x```, not a fence
+	}
+	return n, info, true
+}
+
+// CodeBlock is a fenced code block and the info string of its fence.
+type CodeBlock struct {
+	Info string
+	Body string
+}
+
+// CodeBlocks finds the closed fenced code blocks in text, in order. A
+// fence closes only with at least as many backticks as opened it. Models
+// often nest examples inside a prompt with the same fence length, so
+// inside a block a fence with an info string opens a nested block that the
+// next bare fence closes; nested blocks are part of their parent. A last
+// block left open is accepted if its final line ends in the fence, as in
+// "text```"; otherwise it is ignored.
+func CodeBlocks(text string) []CodeBlock {
+	var (
+		blocks []CodeBlock
+		open   int    // backticks in the open fence, 0 outside a block
+		info   string // info string of the open fence
+		depth  int    // nested blocks inside the open one
+		start  int    // offset of the open block's content
+		offset int
+	)
+	for _, line := range strings.SplitAfter(text, "\n") {
+		n, lineInfo, ok := ParseFence(strings.TrimRight(line, "\r\n"))
+		switch {
+		case !ok:
+		case open == 0:
+			open, info, start = n, lineInfo, offset+len(line)
+		case n < open:
+		case lineInfo != "":
+			depth++
+		case depth > 0:
+			depth--
+		default:
+			blocks = append(blocks, CodeBlock{Info: info, Body: text[start:offset]})
+			open = 0
+		}
+		offset += len(line)
+	}
+
+	if open > 0 {
+		body := strings.TrimRight(text[start:], " \t\r\n")
+		if content := strings.TrimRight(body, "`"); len(body)-len(content) >= open {
+			blocks = append(blocks, CodeBlock{Info: info, Body: content})
+		}
+	}
+	return blocks
+}
+
+// lastCodeBlock finds the last fenced code block in text, as CodeBlocks
+// does.
+func lastCodeBlock(text string) (string, bool) {
+	blocks := CodeBlocks(text)
+	if len(blocks) == 0 {
+		return "", false
+	}
+	return blocks[len(blocks)-1].Body, true
+}
+
+// IsComplete returns true if the response contains a code block and doesn't end with a question.
+func IsComplete(response string) bool {
+	_, hasCodeBlock := lastCodeBlock(response)
+	trimmed := strings.TrimSpace(response)
+	endsWithQuestion := strings.HasSuffix(trimmed, "?")
+	return hasCodeBlock && !endsWithQuestion
+}
@@ -0,0 +1,171 @@
+// fence_test.go
+package promptbuilder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractLastCodeBlock(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "single code block",
+			input: "Here is your prompt:\n```\n# Role\nYou are an expert.\n```\n",
+			want:  "# Role\nYou are an expert.\n",
+		},
+		{
+			name:  "multiple code blocks - returns last",
+			input: "Example:\n```\nfirst block\n```\n\nHere is the final:\n```\nsecond block\n```\n",
+			want:  "second block\n",
+		},
+		{
+			name:  "no code block",
+			input: "Just plain text",
+			want:  "",
+		},
+		{
+			name:  "language tag",
+			input: "```markdown\n# Role\n```\n",
+			want:  "# Role\n",
+		},
+		{
+			name:  "unterminated last fence - returns last closed block",
+			input: "```\nfirst\n```\nRevised:\n```\nsecond, cut off",
+			want:  "first\n",
+		},
+		{
+			name:  "only an unterminated fence",
+			input: "Here it is:\n```\nprompt",
+			want:  "",
+		},
+		{
+			name:  "closing fence at end of last line",
+			input: "```\nprompt```",
+			want:  "prompt",
+		},
+		{
+			name:  "inline triple backticks are not fences",
+			input: "```\nprompt\n```\nWrap code in ```like this``` when you reply.",
+			want:  "prompt\n",
+		},
+		{
+			name:  "longer outer fence keeps inner block",
+			input: "````markdown\nExample:\n```\ncode\n```\n````\n",
+			want:  "Example:\n```\ncode\n```\n",
+		},
+		{
+			name:  "nested block with language tag",
+			input: "```markdown\n# Output\n```json\n{}\n```\nDone.\n```\n",
+			want:  "# Output\n```json\n{}\n```\nDone.\n",
+		},
+		{
+			name:  "indented fence in a list",
+			input: "1. Prompt:\n   ```\n   text\n   ```\n",
+			want:  "   text\n",
+		},
+		{
+			name:  "CRLF line endings",
+			input: "```\r\nprompt\r\n```\r\n",
+			want:  "prompt\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractLastCodeBlock(tt.input)
+			if got != tt.want {
+				t.Errorf("ExtractLastCodeBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{
+			name:  "code block without question - complete",
+			input: "Here is your prompt:\n```\ncontent\n```\n",
+			want:  true,
+		},
+		{
+			name:  "code block with trailing question - not complete",
+			input: "Here is a draft:\n```\ncontent\n```\nDoes this look right?",
+			want:  false,
+		},
+		{
+			name:  "question only - not complete",
+			input: "What is your target audience?",
+			want:  false,
+		},
+		{
+			name:  "no code block no question - not complete",
+			input: "Let me think about that.",
+			want:  false,
+		},
+		{
+			name:  "unterminated code block - not complete",
+			input: "Here is your prompt:\n```\ncontent",
+			want:  false,
+		},
+		{
+			name:  "inline backticks only - not complete",
+			input: "Use ```code``` for examples.",
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IsComplete(tt.input)
+			if got != tt.want {
+				t.Errorf("IsComplete() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzExtractLastCodeBlock(f *testing.F) {
+	f.Add("```\nprompt\n```\n")
+	f.Add("````\n```\n````")
+	f.Add("```\n```go\n```")
+	f.Add("``````")
+	f.Add("```x```\n```")
+	f.Add("   ```\r\n\r\n```")
+
+	f.Fuzz(func(t *testing.T, text string) {
+		got, ok := lastCodeBlock(text)
+		if !strings.Contains(text, got) {
+			t.Errorf("lastCodeBlock(%q) = %q, not part of the input", text, got)
+		}
+		if !ok && got != "" {
+			t.Errorf("lastCodeBlock(%q) = %q with no block found", text, got)
+		}
+		if IsComplete(text) && !ok {
+			t.Errorf("IsComplete(%q) without a code block", text)
+		}
+	})
+}
+
+func FuzzExtractLastCodeBlock_RoundTrip(f *testing.F) {
+	f.Add("Here is your prompt:", "# Role\nYou are an expert.")
+	f.Add("", "")
+	f.Add("a\n\n", "  indented\n\ttabs")
+
+	f.Fuzz(func(t *testing.T, prose, prompt string) {
+		if strings.Contains(prose+prompt, "`") {
+			t.Skip("backticks may form fences")
+		}
+		text := prose + "\n```\n" + prompt + "\n```\n" + prose
+		if got, want := ExtractLastCodeBlock(text), prompt+"\n"; got != want {
+			t.Errorf("ExtractLastCodeBlock(%q) = %q, want %q", text, got, want)
+		}
+	})
+}
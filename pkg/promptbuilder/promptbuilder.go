@@ -0,0 +1,70 @@
+// promptbuilder.go
+
+// Package promptbuilder turns an idea into a structured prompt with one
+// request to a chat model, for Go tools that want what the prompt-builder
+// CLI does without running it.
+//
+// The client is anything that implements LLMClient; the CLI's clients for
+// Ollama, OpenAI-compatible servers and other providers all do. Backends
+// register with RegisterProvider, which makes them selectable with the
+// CLI's provider config key.
+package promptbuilder
+
+import (
+	"context"
+	"fmt"
+)
+
+// LLMClient abstracts the LLM backend for testing.
+type LLMClient interface {
+	ChatStream(ctx context.Context, messages []Message, onToken StreamCallback) (string, error)
+	ChatStreamWithSpinner(ctx context.Context, messages []Message, tty bool, onToken StreamCallback) (string, error)
+	SetParams(params GenerationParams)
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type StreamCallback func(token string) error
+
+// GenerationParams are optional sampling settings. Nil fields are omitted
+// so the server default applies.
+type GenerationParams struct {
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature"`
+	TopP        *float64 `json:"top_p,omitempty" yaml:"top_p"`
+	MaxTokens   *int     `json:"max_tokens,omitempty" yaml:"max_tokens"`
+	Seed        *int     `json:"seed,omitempty" yaml:"seed"`
+}
+
+// WithOverrides returns p with every setting that over has replaced.
+func (p GenerationParams) WithOverrides(over GenerationParams) GenerationParams {
+	if over.Temperature != nil {
+		p.Temperature = over.Temperature
+	}
+	if over.TopP != nil {
+		p.TopP = over.TopP
+	}
+	if over.MaxTokens != nil {
+		p.MaxTokens = over.MaxTokens
+	}
+	if over.Seed != nil {
+		p.Seed = over.Seed
+	}
+	return p
+}
+
+// Validate checks the settings against the ranges servers accept.
+func (p GenerationParams) Validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2, got %v", *p.Temperature)
+	}
+	if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p must be greater than 0 and at most 1, got %v", *p.TopP)
+	}
+	if p.MaxTokens != nil && *p.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens must be positive, got %d", *p.MaxTokens)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// promptbuilder_test.go
+package promptbuilder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerationParams_WithOverrides(t *testing.T) {
+	low, high, seed := 0.2, 0.9, 7
+	base := GenerationParams{Temperature: &low, Seed: &seed}
+	got := base.WithOverrides(GenerationParams{Temperature: &high})
+	if got.Temperature == nil || *got.Temperature != high || got.Seed == nil || *got.Seed != seed || got.TopP != nil {
+		t.Errorf("WithOverrides() = %+v, want temperature replaced and seed kept", got)
+	}
+	if *base.Temperature != low {
+		t.Error("WithOverrides() changed the receiver")
+	}
+}
+
+func TestGenerationParams_Validate(t *testing.T) {
+	hot, zero, none := 2.5, 0.0, 0
+	tests := []struct {
+		name   string
+		params GenerationParams
+		want   string
+	}{
+		{"unset", GenerationParams{}, ""},
+		{"temperature", GenerationParams{Temperature: &hot}, "temperature"},
+		{"top_p", GenerationParams{TopP: &zero}, "top_p"},
+		{"max_tokens", GenerationParams{MaxTokens: &none}, "max_tokens"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.params.Validate()
+			if tc.want == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Validate() = %v, want an error about %s", err, tc.want)
+			}
+		})
+	}
+}
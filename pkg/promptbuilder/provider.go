@@ -0,0 +1,62 @@
+// provider.go
+package promptbuilder
+
+import (
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// ProviderFactory creates the client for one provider. apiKey is empty
+// when none is configured; hc carries the configured timeouts and should
+// make every request.
+type ProviderFactory func(host, model, apiKey string, hc *http.Client) LLMClient
+
+// Provider describes a backend selectable with the provider config key.
+type Provider struct {
+	New       ProviderFactory
+	Host      string // default when host is not configured
+	APIKeyEnv string // environment variable read when no key is configured
+	NeedsKey  bool
+	Prefill   bool // the model continues a trailing assistant message
+
+	// Parse reads the provider's reply stream; replay-stream uses it on
+	// recorded responses. Nil means an OpenAI-style event stream.
+	Parse func(r io.Reader, onToken StreamCallback) (string, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+)
+
+// RegisterProvider makes a backend available as provider: name. It is
+// meant to be called from init and panics if name is already taken.
+func RegisterProvider(name string, p Provider) {
+	if p.New == nil {
+		panic("RegisterProvider: nil factory for " + name)
+	}
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if _, dup := providers[name]; dup {
+		panic("RegisterProvider: provider registered twice: " + name)
+	}
+	providers[name] = p
+}
+
+// LookupProvider returns the backend registered as name.
+func LookupProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// ProviderNames returns the registered providers, sorted.
+func ProviderNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return slices.Sorted(maps.Keys(providers))
+}
@@ -0,0 +1,45 @@
+// provider_test.go
+package promptbuilder
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestRegisterProvider(t *testing.T) {
+	newClient := func(string, string, string, *http.Client) LLMClient { return &fakeClient{} }
+	RegisterProvider("test-b", Provider{New: newClient, Host: "http://b.test"})
+	RegisterProvider("test-a", Provider{New: newClient})
+	t.Cleanup(func() {
+		providersMu.Lock()
+		delete(providers, "test-a")
+		delete(providers, "test-b")
+		providersMu.Unlock()
+	})
+
+	p, ok := LookupProvider("test-b")
+	if !ok || p.Host != "http://b.test" {
+		t.Errorf("LookupProvider(%q) = %+v, %v, want the registered provider", "test-b", p, ok)
+	}
+	if _, ok := LookupProvider("test-c"); ok {
+		t.Errorf("LookupProvider(%q) found an unregistered provider", "test-c")
+	}
+	if names := ProviderNames(); !slices.Equal(names, []string{"test-a", "test-b"}) {
+		t.Errorf("ProviderNames() = %q, want them sorted", names)
+	}
+
+	for name, p := range map[string]Provider{
+		"test-a":   {New: newClient},
+		"test-nil": {},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterProvider(%q) did not panic", name)
+				}
+			}()
+			RegisterProvider(name, p)
+		}()
+	}
+}